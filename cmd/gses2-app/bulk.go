@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gses2-app/internal/core/service/subscription"
+	"gses2-app/internal/handler/subscriberfile"
+	"gses2-app/internal/repository/config"
+	"gses2-app/internal/repository/signature"
+)
+
+const (
+	_importCommand = "import"
+	_exportCommand = "export"
+
+	// The file name which stands for stdin or stdout
+	_stdioFileName = "-"
+)
+
+var (
+	errUnknownCommand = errors.New("unknown command")
+	errImportFileArg  = errors.New("import takes exactly one file")
+)
+
+// runCommand runs the import or export subcommand instead of the server,
+// it returns the exit code
+func runCommand(config *config.Config, name string, args []string) int {
+	var err error
+	switch name {
+	case _importCommand:
+		err = runImport(config, args)
+	case _exportCommand:
+		err = runExport(config, args)
+	default:
+		err = fmt.Errorf(
+			"%w: %q, use %q or %q",
+			errUnknownCommand, name, _importCommand, _exportCommand,
+		)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error, %s: %s\n", name, err)
+		return 1
+	}
+
+	return 0
+}
+
+// runImport adds the subscribers of the file, the format is told
+// by the -format flag or the file extension
+func runImport(config *config.Config, args []string) error {
+	flags := flag.NewFlagSet(_importCommand, flag.ContinueOnError)
+	formatName := flags.String("format", "", "csv, json or ndjson, by the file extension if empty")
+	dryRun := flags.Bool("dry-run", false, "validate the file without storing anything")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: gses2-app import [flags] <file|->\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errImportFileArg
+	}
+
+	path := flags.Arg(0)
+	format, err := subscriberFileFormat(*formatName, path)
+	if err != nil {
+		return err
+	}
+
+	input := io.Reader(os.Stdin)
+	if path != _stdioFileName {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		input = file
+	}
+
+	reader, err := subscriberfile.NewReader(format, input)
+	if err != nil {
+		return err
+	}
+
+	return withSubscriptionService(config, func(service *subscription.Service) error {
+		report, err := service.Import(reader, *dryRun)
+		if report != nil {
+			printImportReport(os.Stdout, report)
+		}
+
+		return err
+	})
+}
+
+// runExport writes every subscriber to the -o file or to stdout
+func runExport(config *config.Config, args []string) error {
+	flags := flag.NewFlagSet(_exportCommand, flag.ContinueOnError)
+	formatName := flags.String("format", "", "csv, json or ndjson, by the file extension if empty, csv for stdout")
+	path := flags.String("o", _stdioFileName, "the file to write, stdout if -")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: gses2-app export [flags]\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	format := subscriberfile.FormatCSV
+	if *formatName != "" || *path != _stdioFileName {
+		var err error
+		if format, err = subscriberFileFormat(*formatName, *path); err != nil {
+			return err
+		}
+	}
+
+	return withSubscriptionService(config, func(service *subscription.Service) error {
+		output := io.Writer(os.Stdout)
+		if *path != _stdioFileName {
+			file, err := os.Create(*path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			output = file
+		}
+
+		writer, err := subscriberfile.NewWriter(format, output)
+		if err != nil {
+			return err
+		}
+
+		if err = service.Export(writer); err != nil {
+			return err
+		}
+
+		return writer.Close()
+	})
+}
+
+func subscriberFileFormat(name, path string) (subscriberfile.Format, error) {
+	if name != "" {
+		return subscriberfile.ParseFormat(name)
+	}
+
+	return subscriberfile.FormatByFileName(path)
+}
+
+// withSubscriptionService opens the user storage for the command, the bulk
+// commands never send a confirmation so no email sender is created
+func withSubscriptionService(
+	config *config.Config,
+	run func(service *subscription.Service) error,
+) error {
+	userStorage, err := createUserStorage(config)
+	if err != nil {
+		return err
+	}
+
+	if closer, ok := userStorage.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	service, err := createSubscriptionService(
+		config,
+		userStorage,
+		signature.NewHMACSigner(config.Signature),
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	return run(service)
+}
+
+func printImportReport(w io.Writer, report *subscription.ImportReport) {
+	if report.DryRun {
+		fmt.Fprintln(w, "Dry run, nothing is stored")
+	}
+
+	fmt.Fprintf(
+		w,
+		"Rows: %d, imported: %d, duplicates: %d, invalid: %d\n",
+		report.Total, report.Imported, report.Duplicates, report.Invalid,
+	)
+
+	for _, importErr := range report.Errors {
+		fmt.Fprintf(w, "Row %d %q: %s\n", importErr.Row, importErr.Email, importErr.Reason)
+	}
+}
@@ -2,28 +2,54 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
 
 	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/alert"
+	"gses2-app/internal/core/service/job"
+	"gses2-app/internal/core/service/outbox"
 	"gses2-app/internal/core/service/rate"
+	"gses2-app/internal/core/service/ratehistory"
+	"gses2-app/internal/core/service/scheduler"
 	"gses2-app/internal/core/service/sender"
 	"gses2-app/internal/core/service/subscription"
+	"gses2-app/internal/core/service/webhook"
 	"gses2-app/internal/handler/httpcontroller"
 	"gses2-app/internal/handler/router"
+	"gses2-app/internal/handler/telegrambot"
+	"gses2-app/internal/repository/blocklist"
 	"gses2-app/internal/repository/config"
 	"gses2-app/internal/repository/logger/rabbit"
 	"gses2-app/internal/repository/rate/rest/binance"
 	"gses2-app/internal/repository/rate/rest/coingecko"
 	"gses2-app/internal/repository/rate/rest/kuna"
 	"gses2-app/internal/repository/sender/email"
+	"gses2-app/internal/repository/sender/email/send"
 	"gses2-app/internal/repository/sender/smtp"
+	"gses2-app/internal/repository/sender/telegram"
+	webhooksender "gses2-app/internal/repository/sender/webhook"
+	"gses2-app/internal/repository/signature"
 	"gses2-app/internal/repository/storage"
 )
 
-const _configPrefix = "GSES2_APP"
+const (
+	_configPrefix = "GSES2_APP"
+	// The purpose of the token the subscriber manages the subscription with,
+	// so it isn't the unsubscribe token mailed with the rate
+	_managementTokenPurpose = "manage"
+)
+
+var errUnknownStorageDriver = errors.New("unknown storage driver")
 
 func main() {
 	config, err := config.Load(_configPrefix)
@@ -32,6 +58,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	// gses2-app import|export works with the subscribers and exits
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(&config, os.Args[1], os.Args[2:]))
+	}
+
 	ctx := context.Background()
 	conn, ch, q, err := rabbit.ConnectToRabbitMQ(config.RabbitMQ.URL)
 	if err != nil {
@@ -47,38 +78,246 @@ func main() {
 		os.Exit(1)
 	}
 
-	loging := make(chan bool)
-
 	go consumer()
 
-	senderService, err := createSenderService(&config)
+	// The workers and the server are stopped on a shutdown signal,
+	// so the deferred closes run
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	signer := signature.NewHMACSigner(config.Signature)
+	managementSigner := signer.WithPurpose(_managementTokenPurpose)
+
+	rateHistoryRepository, err := createRateHistoryRepository(&config)
+	if err != nil {
+		logger.Errorf("Error, cannot open the rate history: %s", err)
+		os.Exit(1)
+	}
+
+	if closer, ok := rateHistoryRepository.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	rateHistoryService := ratehistory.NewService(rateHistoryRepository)
+
+	emailTemplates, err := send.NewTemplates(
+		config.Email,
+		logger,
+		os.DirFS(config.Email.TemplatesDir),
+	)
+	if err != nil {
+		logger.Errorf("Error, invalid email templates: %s", err)
+		os.Exit(1)
+	}
+
+	emailSenderProvider, err := createEmailSenderProvider(
+		&config,
+		signer,
+		emailTemplates,
+		rateHistoryService,
+	)
 	if err != nil {
-		logger.Errorf("Connection error: %s", err)
+		logger.Errorf("Error, cannot create the email sender: %s", err)
 		os.Exit(1)
 	}
 
+	defer emailSenderProvider.Close()
+
 	defer conn.Close()
 	defer ch.Close()
 
-	rateService := createRateService(logger, &config)
-	subscriptionService := createSubscriptionService(&config)
+	userStorage, err := createUserStorage(&config)
+	if err != nil {
+		logger.Errorf("Error, cannot open the storage: %s", err)
+		os.Exit(1)
+	}
+
+	if closer, ok := userStorage.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	webhookStorage, webhookDeliveryStorage, err := createWebhookStorages(&config)
+	if err != nil {
+		logger.Errorf("Error, cannot open the webhook storage: %s", err)
+		os.Exit(1)
+	}
+
+	for _, s := range []port.Storage{webhookStorage, webhookDeliveryStorage} {
+		if closer, ok := s.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+
+	webhookService := webhook.NewService(
+		config.Webhook,
+		port.NewWebhookRepository(webhookStorage),
+		port.NewWebhookDeliveryRepository(webhookDeliveryStorage),
+		port.NewUserRepository(userStorage),
+		managementSigner,
+	)
+
+	outboxStorage, err := createOutboxStorage(&config)
+	if err != nil {
+		logger.Errorf("Error, cannot open the outbox storage: %s", err)
+		os.Exit(1)
+	}
+
+	if closer, ok := outboxStorage.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	jobStorage, err := createJobStorage(&config)
+	if err != nil {
+		logger.Errorf("Error, cannot open the job storage: %s", err)
+		os.Exit(1)
+	}
+
+	if closer, ok := jobStorage.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	jobTracker := job.NewTracker(port.NewJobRepository(jobStorage))
+
+	outboxService := outbox.NewService(
+		config.Outbox,
+		logger,
+		port.NewOutboxRepository(outboxStorage),
+		port.NewUserRepository(userStorage),
+		emailSenderProvider,
+		jobTracker,
+	)
+
+	// The rate emails are queued in the outbox unless it's disabled
+	var emailSender sender.SenderPort = emailSenderProvider
+	var emailQueue job.EmailQueue = job.NewDirectEmails(emailSenderProvider, jobTracker)
+	if config.Outbox.Enabled {
+		emailSender = outboxService
+		emailQueue = outboxService
+	}
+
+	webhookSenderProvider := createWebhookSenderProvider(logger, &config, webhookService)
+	channelProviders := []sender.SenderPort{webhookSenderProvider}
+
+	var telegramProvider *telegram.Provider
+	if config.Telegram.Enabled {
+		telegramProvider, err = createTelegramProvider(&config)
+		if err != nil {
+			logger.Errorf("Error, cannot create the telegram provider: %s", err)
+			os.Exit(1)
+		}
+
+		channelProviders = append(channelProviders, telegramProvider)
+	}
+
+	senderService := sender.NewService(
+		append([]sender.SenderPort{emailSender}, channelProviders...)...,
+	)
+
+	rateService := createRateService(logger, &config, rateHistoryService)
+
+	alertStorage, err := createAlertStorage(&config)
+	if err != nil {
+		logger.Errorf("Error, cannot open the alert storage: %s", err)
+		os.Exit(1)
+	}
+
+	if closer, ok := alertStorage.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	alertService := alert.NewService(
+		config.Alert,
+		logger,
+		port.NewAlertRepository(alertStorage),
+		port.NewUserRepository(userStorage),
+		managementSigner,
+		rateService,
+		rateHistoryService,
+		emailSenderProvider,
+	)
+
+	// The alerts and webhooks of a subscriber are removed along with it
+	subscriptionService, err := createSubscriptionService(
+		&config,
+		userStorage,
+		signer,
+		emailSenderProvider,
+		alertService,
+		webhookService,
+	)
+	if err != nil {
+		logger.Errorf("Error, cannot load the domain blocklist: %s", err)
+		os.Exit(1)
+	}
+
+	// The emails of a send job are tracked one by one, the other
+	// channels are sent to alongside them
+	jobService := job.NewService(
+		config.Job,
+		logger,
+		jobTracker,
+		rateService,
+		subscriptionService,
+		emailQueue,
+		sender.NewService(channelProviders...),
+	)
 
 	appController := httpcontroller.NewAppController(
 		rateService,
 		subscriptionService,
-		senderService,
+		jobService,
+		rateHistoryService,
+		alertService,
+		webhookService,
+		outboxService,
 	)
 
-	mux := registerRoutes(appController)
-	startServer(logger, config.HTTP.Port, mux)
+	go startJobWorker(ctx, logger, jobService)
+
+	if config.Email.TemplatesReloadInterval > 0 {
+		go startTemplateReloader(ctx, logger, emailTemplates)
+	}
+
+	if config.Outbox.Enabled {
+		go startOutboxWorker(ctx, logger, outboxService)
+	}
+
+	go startWebhookRetrier(ctx, logger, webhookSenderProvider)
+
+	if config.Alert.Enabled {
+		go startAlertWorker(ctx, logger, alertService)
+	}
+
+	if config.Telegram.Enabled {
+		bot := telegrambot.NewBot(logger, telegramProvider, subscriptionService)
+		go startTelegramBot(ctx, logger, bot)
+	}
+
+	if config.Scheduler.Enabled {
+		schedulerService, err := createSchedulerService(
+			logger,
+			&config,
+			rateService,
+			subscriptionService,
+			senderService,
+		)
+		if err != nil {
+			logger.Errorf("Error, invalid scheduler config: %s", err)
+			os.Exit(1)
+		}
+
+		go startScheduler(ctx, logger, schedulerService)
+	}
 
-	<-loging
+	mux := registerRoutes(appController, config.HTTP.AdminToken)
+	startServer(ctx, logger, &config, mux)
 }
 
 func createRateService(
 	logger port.Logger,
 	config *config.Config,
-) *rate.Service {
+	rateHistoryService *ratehistory.Service,
+) rate.RateService {
 
 	httpClient := &http.Client{Timeout: config.HTTP.Timeout}
 
@@ -94,42 +333,316 @@ func createRateService(
 		logger, config.CoingeckoAPI, httpClient,
 	)
 
-	return rate.NewService(
+	rateService := rate.NewService(
+		config.Rate,
 		logger,
-		BinanceRateProvider,
-		CoingeckoRateProvider,
-		KunaRateProvider,
+		rate.NewRecordingProvider(logger, BinanceRateProvider, rateHistoryService),
+		rate.NewRecordingProvider(logger, CoingeckoRateProvider, rateHistoryService),
+		rate.NewRecordingProvider(logger, KunaRateProvider, rateHistoryService),
 	)
+
+	if config.RateCache.TTL <= 0 {
+		return rateService
+	}
+
+	return rate.NewCachedService(config.RateCache, logger, rateService)
 }
 
-func createSenderService(
+func createEmailSenderProvider(
 	config *config.Config,
-) (*sender.Service, error) {
-	emailSenderProvider, err := email.NewProvider(
+	signer *signature.HMACSigner,
+	templates *send.Templates,
+	rateHistoryService *ratehistory.Service,
+) (*email.Provider, error) {
+	return email.NewProvider(
 		&email.EmailSenderConfig{
 			SMTP:  config.SMTP,
 			Email: config.Email,
 		},
-		&smtp.TLSConnectionDialerImpl{},
+		&smtp.ConnectionDialerImpl{},
 		&smtp.SMTPClientFactoryImpl{},
+		signer,
+		templates,
+		rateHistoryService,
+	)
+}
+
+func createTelegramProvider(config *config.Config) (*telegram.Provider, error) {
+	// A poll is held by the Bot API for up to the poll timeout
+	httpClient := &http.Client{
+		Timeout: config.Telegram.PollTimeout + config.HTTP.Timeout,
+	}
+
+	return telegram.NewProvider(
+		config.Telegram,
+		httpClient,
+		os.DirFS(config.Telegram.TemplatesDir),
+	)
+}
+
+func createWebhookSenderProvider(
+	logger port.Logger,
+	config *config.Config,
+	webhookService *webhook.Service,
+) *webhooksender.Provider {
+	return webhooksender.NewProvider(
+		config.WebhookSender,
+		logger,
+		webhooksender.NewHTTPClient(config.WebhookSender.Timeout),
+		webhookService,
 	)
+}
+
+func createUserStorage(config *config.Config) (port.Storage, error) {
+	switch config.Storage.Driver {
+	case storage.DriverCSV:
+		return storage.NewCSVStorage(config.Storage.Path), nil
+	case storage.DriverSQLite:
+		return storage.NewSQLiteStorage(config.Storage.SQLitePath)
+	default:
+		return nil, fmt.Errorf(
+			"%w: %q", errUnknownStorageDriver, config.Storage.Driver,
+		)
+	}
+}
+
+func createAlertStorage(config *config.Config) (port.Storage, error) {
+	switch config.Storage.Driver {
+	case storage.DriverCSV:
+		return storage.NewCSVAlertStorage(config.Storage.AlertsPath), nil
+	case storage.DriverSQLite:
+		return storage.NewSQLiteAlertStorage(config.Storage.SQLitePath)
+	default:
+		return nil, fmt.Errorf(
+			"%w: %q", errUnknownStorageDriver, config.Storage.Driver,
+		)
+	}
+}
+
+func createOutboxStorage(config *config.Config) (port.Storage, error) {
+	switch config.Storage.Driver {
+	case storage.DriverCSV:
+		return storage.NewCSVOutboxStorage(config.Storage.OutboxPath), nil
+	case storage.DriverSQLite:
+		return storage.NewSQLiteOutboxStorage(config.Storage.SQLitePath)
+	default:
+		return nil, fmt.Errorf(
+			"%w: %q", errUnknownStorageDriver, config.Storage.Driver,
+		)
+	}
+}
+
+func createJobStorage(config *config.Config) (port.Storage, error) {
+	switch config.Storage.Driver {
+	case storage.DriverCSV:
+		return storage.NewCSVJobStorage(config.Storage.JobsPath), nil
+	case storage.DriverSQLite:
+		return storage.NewSQLiteJobStorage(config.Storage.SQLitePath)
+	default:
+		return nil, fmt.Errorf(
+			"%w: %q", errUnknownStorageDriver, config.Storage.Driver,
+		)
+	}
+}
+
+func createWebhookStorages(
+	config *config.Config,
+) (webhooks port.Storage, deliveries port.Storage, err error) {
+	switch config.Storage.Driver {
+	case storage.DriverCSV:
+		return storage.NewCSVWebhookStorage(config.Storage.WebhooksPath),
+			storage.NewCSVWebhookDeliveryStorage(config.Storage.WebhookDeliveriesPath),
+			nil
+	case storage.DriverSQLite:
+		sqliteWebhooks, err := storage.NewSQLiteWebhookStorage(config.Storage.SQLitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sqliteDeliveries, err := storage.NewSQLiteWebhookDeliveryStorage(
+			config.Storage.SQLitePath,
+		)
+		if err != nil {
+			sqliteWebhooks.Close()
+			return nil, nil, err
+		}
+
+		return sqliteWebhooks, sqliteDeliveries, nil
+	default:
+		return nil, nil, fmt.Errorf(
+			"%w: %q", errUnknownStorageDriver, config.Storage.Driver,
+		)
+	}
+}
+
+func createRateHistoryRepository(
+	config *config.Config,
+) (ratehistory.Repository, error) {
+	switch config.Storage.Driver {
+	case storage.DriverCSV:
+		return storage.NewCSVRateHistory(config.Storage.RateHistoryPath), nil
+	case storage.DriverSQLite:
+		return storage.NewSQLiteRateHistory(config.Storage.SQLitePath)
+	default:
+		return nil, fmt.Errorf(
+			"%w: %q", errUnknownStorageDriver, config.Storage.Driver,
+		)
+	}
+}
+
+func createSubscriptionService(
+	config *config.Config,
+	userStorage port.Storage,
+	signer *signature.HMACSigner,
+	confirmationSender subscription.ConfirmationSender,
+	ownedRemovers ...subscription.OwnedRemover,
+) (*subscription.Service, error) {
+	userRepository := port.NewUserRepository(userStorage)
 
+	domainBlocklist, err := blocklist.LoadDomainBlocklist(
+		config.Subscription.DomainBlocklistPath,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	return sender.NewService(emailSenderProvider), nil
+	return subscription.NewService(
+		config.Subscription,
+		userRepository,
+		signer,
+		signer.WithPurpose(_managementTokenPurpose),
+		confirmationSender,
+		domainBlocklist,
+		ownedRemovers...,
+	), nil
 }
 
-func createSubscriptionService(config *config.Config) *subscription.Service {
-	storageCSV := storage.NewCSVStorage(config.Storage.Path)
-	userRepository := port.NewUserRepository(storageCSV)
+func createSchedulerService(
+	logger port.Logger,
+	config *config.Config,
+	rateService rate.RateService,
+	subscriptionService *subscription.Service,
+	senderService *sender.Service,
+) (*scheduler.Service, error) {
+	schedule, err := cron.ParseStandard(config.Scheduler.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cron used to be the slot of every subscriber, now the daily
+	// ones get the rate at the daily hour only
+	err = scheduler.CheckSchedule(config.Scheduler, schedule, time.Now())
+	if err != nil {
+		return nil, err
+	}
 
-	return subscription.NewService(userRepository)
+	return scheduler.NewService(
+		config.Scheduler,
+		logger,
+		schedule,
+		storage.NewTimestampFile(config.Scheduler.LastRunPath),
+		rateService,
+		subscriptionService,
+		senderService,
+	), nil
+}
+
+func startScheduler(
+	ctx context.Context,
+	logger port.Logger,
+	schedulerService *scheduler.Service,
+) {
+	logger.Infof("Starting scheduler")
+
+	err := schedulerService.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Errorf("Error, scheduler stopped: %s", err)
+	}
+}
+
+func startAlertWorker(
+	ctx context.Context,
+	logger port.Logger,
+	alertService *alert.Service,
+) {
+	logger.Infof("Starting alert worker")
+
+	err := alertService.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Errorf("Error, alert worker stopped: %s", err)
+	}
+}
+
+func startOutboxWorker(
+	ctx context.Context,
+	logger port.Logger,
+	outboxService *outbox.Service,
+) {
+	logger.Infof("Starting outbox worker")
+
+	err := outboxService.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Errorf("Error, outbox worker stopped: %s", err)
+	}
 }
 
-func registerRoutes(appController *httpcontroller.AppController) *http.ServeMux {
-	router := router.NewHTTPRouter(appController)
+func startWebhookRetrier(
+	ctx context.Context,
+	logger port.Logger,
+	webhookSender *webhooksender.Provider,
+) {
+	logger.Infof("Starting webhook retrier")
+
+	err := webhookSender.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Errorf("Error, webhook retrier stopped: %s", err)
+	}
+}
+
+func startJobWorker(
+	ctx context.Context,
+	logger port.Logger,
+	jobService *job.Service,
+) {
+	logger.Infof("Starting job worker")
+
+	err := jobService.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Errorf("Error, job worker stopped: %s", err)
+	}
+}
+
+func startTemplateReloader(
+	ctx context.Context,
+	logger port.Logger,
+	templates *send.Templates,
+) {
+	logger.Infof("Starting email template reloader")
+
+	err := templates.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Errorf("Error, email template reloader stopped: %s", err)
+	}
+}
+
+func startTelegramBot(
+	ctx context.Context,
+	logger port.Logger,
+	bot *telegrambot.Bot,
+) {
+	logger.Infof("Starting telegram bot")
+
+	err := bot.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Errorf("Error, telegram bot stopped: %s", err)
+	}
+}
+
+func registerRoutes(
+	appController *httpcontroller.AppController,
+	adminToken string,
+) *http.ServeMux {
+	router := router.NewHTTPRouter(appController, adminToken)
 
 	mux := http.NewServeMux()
 	router.RegisterRoutes(mux)
@@ -137,12 +650,40 @@ func registerRoutes(appController *httpcontroller.AppController) *http.ServeMux
 	return mux
 }
 
-func startServer(logger port.Logger, port string, handler http.Handler) {
-	logger.Infof("Starting server on port %s\n", port)
+// startServer serves the requests until the context is done and
+// the requests in flight are finished
+func startServer(
+	ctx context.Context,
+	logger port.Logger,
+	config *config.Config,
+	handler http.Handler,
+) {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%s", config.HTTP.Port),
+		Handler: handler,
+	}
 
-	err := http.ListenAndServe(fmt.Sprintf(":%s", port), handler)
-	if err != nil {
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(
+			context.Background(),
+			config.HTTP.Timeout,
+		)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Errorf("Error, server shutdown: %s", err)
+		}
+	}()
+
+	logger.Infof("Starting server on port %s\n", config.HTTP.Port)
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Error(err)
 		os.Exit(1)
 	}
+
+	logger.Infof("Server stopped")
 }
@@ -0,0 +1,219 @@
+package port
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	_alertIDKey              = "id"
+	_alertBaseKey            = "base"
+	_alertQuoteKey           = "quote"
+	_alertKindKey            = "kind"
+	_alertValueKey           = "value"
+	_alertCooldownKey        = "cooldown"
+	_alertLastRateKey        = "last_rate"
+	_alertLastTriggeredAtKey = "last_triggered_at"
+)
+
+var (
+	ErrAlertAlreadyAdded = errors.New("alert is already added")
+	ErrCannotFindAlert   = errors.New("cannot find alert")
+	ErrCannotLoadAlerts  = errors.New("cannot load alerts")
+)
+
+type AlertKind string
+
+const (
+	// Fires when the rate crosses the value in either direction
+	AlertKindThreshold AlertKind = "threshold"
+	// Fires when the rate has moved by more than the value percent
+	// within a day
+	AlertKindChange AlertKind = "change"
+)
+
+// Represents a rate alert rule of a subscriber
+type Alert struct {
+	ID    string
+	Email string
+	Pair  CurrencyPair
+	Kind  AlertKind
+	// The threshold rate or the percent of the change
+	Value float64
+	// The minimum time between two notifications, zero for the default
+	Cooldown time.Duration
+	// The rate seen by the last check, zero if it wasn't checked yet
+	LastRate        float32
+	LastTriggeredAt time.Time
+}
+
+// AlertNotice tells the subscriber that the alert has fired
+type AlertNotice struct {
+	Alert Alert
+	Rate  Rate
+	// What has happened, e.g. "BTC/UAH rose above 1500000"
+	Message string
+	// The details of the message for a translation: the threshold
+	// alert's rate rose above the value, otherwise it fell below,
+	// and the percent the change alert's rate changed by
+	Rose   bool
+	Change float64
+	// The language of the subscriber, the default one if empty
+	Language string
+}
+
+type AlertRepository struct {
+	storage Storage
+}
+
+func NewAlertRepository(storage Storage) *AlertRepository {
+	return &AlertRepository{
+		storage: storage,
+	}
+}
+
+func (ar *AlertRepository) Add(alert *Alert) error {
+	err := ar.storage.AppendUnique(_alertIDKey, alertToRecord(alert))
+	if errors.Is(err, ErrDuplicateRecord) {
+		return ErrAlertAlreadyAdded
+	}
+
+	return err
+}
+
+func (ar *AlertRepository) Update(alert *Alert) error {
+	_, err := ar.FindByID(alert.ID)
+	if err != nil {
+		return err
+	}
+
+	return ar.storage.Update(_alertIDKey, alert.ID, alertToRecord(alert))
+}
+
+// UpdateAll stores the alerts at once if the storage supports it,
+// the alerts no longer stored are skipped
+func (ar *AlertRepository) UpdateAll(alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	records := make([]map[string]string, 0, len(alerts))
+	for i := range alerts {
+		records = append(records, alertToRecord(&alerts[i]))
+	}
+
+	if writer, ok := ar.storage.(BatchWriter); ok {
+		return writer.UpdateAll(_alertIDKey, records)
+	}
+
+	for _, record := range records {
+		if err := ar.storage.Update(_alertIDKey, record[_alertIDKey], record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ar *AlertRepository) Remove(alert *Alert) error {
+	_, err := ar.FindByID(alert.ID)
+	if err != nil {
+		return err
+	}
+
+	return ar.storage.Delete(_alertIDKey, alert.ID)
+}
+
+func (ar *AlertRepository) FindByID(id string) (*Alert, error) {
+	alerts, err := ar.findBy(_alertIDKey, id)
+	if err != nil {
+		return &Alert{}, err
+	}
+
+	if len(alerts) == 0 {
+		return &Alert{}, ErrCannotFindAlert
+	}
+
+	return &alerts[0], nil
+}
+
+// FindByEmail returns the alerts of the subscriber, possibly none
+func (ar *AlertRepository) FindByEmail(email string) ([]Alert, error) {
+	return ar.findBy(_emailKey, email)
+}
+
+func (ar *AlertRepository) All() ([]Alert, error) {
+	return ar.findBy("", "")
+}
+
+// findBy returns the alerts whose value of the key matches the value,
+// all of them if the key is empty
+func (ar *AlertRepository) findBy(key, value string) ([]Alert, error) {
+	records, err := ar.storage.AllRecords()
+	if err != nil {
+		return nil, errors.Join(err, ErrCannotLoadAlerts)
+	}
+
+	alerts := []Alert{}
+	for _, record := range records {
+		if key == "" || record[key] == value {
+			alerts = append(alerts, recordToAlert(record))
+		}
+	}
+
+	return alerts, nil
+}
+
+func alertToRecord(alert *Alert) map[string]string {
+	record := map[string]string{
+		_alertIDKey:              alert.ID,
+		_emailKey:                alert.Email,
+		_alertBaseKey:            alert.Pair.Base,
+		_alertQuoteKey:           alert.Pair.Quote,
+		_alertKindKey:            string(alert.Kind),
+		_alertValueKey:           strconv.FormatFloat(alert.Value, 'f', -1, 64),
+		_alertCooldownKey:        "",
+		_alertLastRateKey:        "",
+		_alertLastTriggeredAtKey: "",
+	}
+
+	if alert.Cooldown != 0 {
+		record[_alertCooldownKey] = alert.Cooldown.String()
+	}
+
+	if alert.LastRate != 0 {
+		record[_alertLastRateKey] = strconv.FormatFloat(
+			float64(alert.LastRate), 'f', -1, 32,
+		)
+	}
+
+	if !alert.LastTriggeredAt.IsZero() {
+		record[_alertLastTriggeredAtKey] = alert.LastTriggeredAt.
+			UTC().Format(time.RFC3339)
+	}
+
+	return record
+}
+
+func recordToAlert(record map[string]string) Alert {
+	// The malformed optional values are left zero, i.e. unset
+	value, _ := strconv.ParseFloat(record[_alertValueKey], 64)
+	cooldown, _ := time.ParseDuration(record[_alertCooldownKey])
+	lastRate, _ := strconv.ParseFloat(record[_alertLastRateKey], 32)
+	lastTriggeredAt, _ := time.Parse(time.RFC3339, record[_alertLastTriggeredAtKey])
+
+	return Alert{
+		ID:    record[_alertIDKey],
+		Email: record[_emailKey],
+		Pair: CurrencyPair{
+			Base:  record[_alertBaseKey],
+			Quote: record[_alertQuoteKey],
+		},
+		Kind:            AlertKind(record[_alertKindKey]),
+		Value:           value,
+		Cooldown:        cooldown,
+		LastRate:        float32(lastRate),
+		LastTriggeredAt: lastTriggeredAt,
+	}
+}
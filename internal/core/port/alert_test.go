@@ -0,0 +1,78 @@
+package port
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertRepository(t *testing.T) {
+	t.Parallel()
+
+	triggeredAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	alert := Alert{
+		ID:              "alert1",
+		Email:           "user1",
+		Pair:            DefaultCurrencyPair,
+		Kind:            AlertKindThreshold,
+		Value:           1000000.5,
+		Cooldown:        30 * time.Minute,
+		LastRate:        999999.5,
+		LastTriggeredAt: triggeredAt,
+	}
+	other := Alert{
+		ID:    "alert2",
+		Email: "user2",
+		Pair:  DefaultCurrencyPair,
+		Kind:  AlertKindChange,
+		Value: 5,
+	}
+
+	stubStorage := &StubStorage{}
+	alertRepository := NewAlertRepository(stubStorage)
+
+	require.NoError(t, alertRepository.Add(&alert))
+	require.NoError(t, alertRepository.Add(&other))
+	require.Equal(t, ErrAlertAlreadyAdded, alertRepository.Add(&alert))
+
+	found, err := alertRepository.FindByID("alert1")
+	require.NoError(t, err)
+	require.Equal(t, &alert, found)
+
+	alerts, err := alertRepository.FindByEmail("user2")
+	require.NoError(t, err)
+	require.Equal(t, []Alert{other}, alerts)
+
+	other.LastRate = 1000
+	require.NoError(t, alertRepository.Update(&other))
+
+	alerts, err = alertRepository.All()
+	require.NoError(t, err)
+	require.Equal(t, []Alert{alert, other}, alerts)
+
+	require.NoError(t, alertRepository.Remove(&alert))
+	require.Equal(t, ErrCannotFindAlert, alertRepository.Remove(&alert))
+	require.Equal(t, ErrCannotFindAlert, alertRepository.Update(&alert))
+
+	// The batch skips the alerts no longer stored
+	other.LastRate = 2000
+	require.NoError(t, alertRepository.UpdateAll([]Alert{alert, other}))
+
+	alerts, err = alertRepository.All()
+	require.NoError(t, err)
+	require.Equal(t, []Alert{other}, alerts)
+
+	_, err = alertRepository.FindByID("alert1")
+	require.Equal(t, ErrCannotFindAlert, err)
+}
+
+func TestAlertRepositoryStorageError(t *testing.T) {
+	t.Parallel()
+
+	alertRepository := NewAlertRepository(&StubStorage{err: ErrCannotLoadUsers})
+
+	_, err := alertRepository.All()
+
+	require.ErrorIs(t, err, ErrCannotLoadAlerts)
+}
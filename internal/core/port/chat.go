@@ -0,0 +1,9 @@
+package port
+
+// ChatMessage is a message a user has sent to the bot
+type ChatMessage struct {
+	// Increases with every message, so the bot can skip the handled ones
+	UpdateID int64
+	ChatID   int64
+	Text     string
+}
@@ -0,0 +1,80 @@
+package port
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The channels the rate is delivered through
+const (
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+	ChannelWebhook  = "webhook"
+)
+
+var (
+	ErrDeliveryFailed = errors.New("delivery failed")
+	// The delivery to the recipient would fail again, e.g. the mailbox
+	// doesn't exist, so it's not retried
+	ErrPermanentFailure = errors.New("permanent delivery failure")
+)
+
+// DeliveryFailure is a recipient the message wasn't delivered to
+type DeliveryFailure struct {
+	Channel string
+	// The email, the chat or the webhook the message was sent to
+	Recipient string
+	Err       error
+}
+
+// DeliveryError reports the recipients a send has failed for,
+// the others have received the message
+type DeliveryError struct {
+	Sent     int
+	Failures []DeliveryFailure
+}
+
+// NewDeliveryError returns the report as an error if there are
+// failures, nil otherwise
+func NewDeliveryError(sent int, failures []DeliveryFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &DeliveryError{Sent: sent, Failures: failures}
+}
+
+func (e *DeliveryError) Error() string {
+	reasons := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		reasons = append(reasons, fmt.Sprintf("%s %s: %v", f.Channel, f.Recipient, f.Err))
+	}
+
+	return fmt.Sprintf(
+		"%v for %d of %d recipients: %s",
+		ErrDeliveryFailed,
+		len(e.Failures),
+		e.Sent+len(e.Failures),
+		strings.Join(reasons, "; "),
+	)
+}
+
+func (e *DeliveryError) Is(target error) bool {
+	return target == ErrDeliveryFailed
+}
+
+func (e *DeliveryError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		errs = append(errs, f.Err)
+	}
+
+	return errs
+}
+
+// Merge adds the report of another send to the report
+func (e *DeliveryError) Merge(other *DeliveryError) {
+	e.Sent += other.Sent
+	e.Failures = append(e.Failures, other.Failures...)
+}
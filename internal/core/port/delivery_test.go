@@ -0,0 +1,38 @@
+package port
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryError(t *testing.T) {
+	t.Parallel()
+
+	errRecipient := errors.New("550 mailbox unavailable")
+
+	require.NoError(t, NewDeliveryError(3, nil))
+
+	err := NewDeliveryError(2, []DeliveryFailure{{
+		Channel:   ChannelEmail,
+		Recipient: "missing@example.com",
+		Err:       errRecipient,
+	}})
+
+	require.ErrorIs(t, err, ErrDeliveryFailed)
+	require.ErrorIs(t, err, errRecipient)
+	require.EqualError(
+		t,
+		err,
+		"delivery failed for 1 of 3 recipients: "+
+			"email missing@example.com: 550 mailbox unavailable",
+	)
+
+	var report *DeliveryError
+	require.ErrorAs(t, err, &report)
+
+	report.Merge(&DeliveryError{Sent: 1})
+	require.Equal(t, 3, report.Sent)
+	require.Len(t, report.Failures, 1)
+}
@@ -0,0 +1,171 @@
+package port
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	_jobIDKey         = "id"
+	_jobStatusKey     = "status"
+	_jobTotalKey      = "total"
+	_jobSentKey       = "sent"
+	_jobFailedKey     = "failed"
+	_jobSkippedKey    = "skipped"
+	_jobErrorKey      = "error"
+	_jobCreatedAtKey  = "created_at"
+	_jobStartedAtKey  = "started_at"
+	_jobFinishedAtKey = "finished_at"
+)
+
+var (
+	ErrJobAlreadyAdded = errors.New("job is already added")
+	ErrCannotFindJob   = errors.New("cannot find job")
+	ErrCannotLoadJobs  = errors.New("cannot load jobs")
+)
+
+type JobStatus string
+
+const (
+	JobStatusQueued JobStatus = "queued"
+	// The emails are being sent
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	// The job couldn't send the emails, e.g. the rate is unavailable
+	JobStatusFailed JobStatus = "failed"
+)
+
+// Job sends the rate emails to all the subscribers in the background
+type Job struct {
+	ID     string
+	Status JobStatus
+	// The number of the subscribers the emails are sent to
+	Total  int
+	Sent   int
+	Failed int
+	// The emails dropped as the subscriber has unsubscribed meanwhile
+	Skipped int
+	// Why the job has failed
+	Error      string
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Pending is the number of the emails which are not sent yet
+func (j *Job) Pending() int {
+	pending := j.Total - j.Sent - j.Failed - j.Skipped
+	if pending < 0 {
+		return 0
+	}
+
+	return pending
+}
+
+// Finished tells whether the job has completed or failed
+func (j *Job) Finished() bool {
+	return j.Status == JobStatusCompleted || j.Status == JobStatusFailed
+}
+
+type JobRepository struct {
+	storage Storage
+}
+
+func NewJobRepository(storage Storage) *JobRepository {
+	return &JobRepository{
+		storage: storage,
+	}
+}
+
+func (jr *JobRepository) Add(job *Job) error {
+	err := jr.storage.AppendUnique(_jobIDKey, jobToRecord(job))
+	if errors.Is(err, ErrDuplicateRecord) {
+		return ErrJobAlreadyAdded
+	}
+
+	return err
+}
+
+func (jr *JobRepository) Update(job *Job) error {
+	_, err := jr.FindByID(job.ID)
+	if err != nil {
+		return err
+	}
+
+	return jr.storage.Update(_jobIDKey, job.ID, jobToRecord(job))
+}
+
+func (jr *JobRepository) Remove(job *Job) error {
+	_, err := jr.FindByID(job.ID)
+	if err != nil {
+		return err
+	}
+
+	return jr.storage.Delete(_jobIDKey, job.ID)
+}
+
+func (jr *JobRepository) FindByID(id string) (*Job, error) {
+	jobs, err := jr.All()
+	if err != nil {
+		return &Job{}, err
+	}
+
+	for i := range jobs {
+		if jobs[i].ID == id {
+			return &jobs[i], nil
+		}
+	}
+
+	return &Job{}, ErrCannotFindJob
+}
+
+// All returns the jobs in the order they were added
+func (jr *JobRepository) All() ([]Job, error) {
+	records, err := jr.storage.AllRecords()
+	if err != nil {
+		return nil, errors.Join(err, ErrCannotLoadJobs)
+	}
+
+	jobs := make([]Job, 0, len(records))
+	for _, record := range records {
+		jobs = append(jobs, recordToJob(record))
+	}
+
+	return jobs, nil
+}
+
+func jobToRecord(job *Job) map[string]string {
+	return map[string]string{
+		_jobIDKey:         job.ID,
+		_jobStatusKey:     string(job.Status),
+		_jobTotalKey:      strconv.Itoa(job.Total),
+		_jobSentKey:       strconv.Itoa(job.Sent),
+		_jobFailedKey:     strconv.Itoa(job.Failed),
+		_jobSkippedKey:    strconv.Itoa(job.Skipped),
+		_jobErrorKey:      job.Error,
+		_jobCreatedAtKey:  formatTime(job.CreatedAt),
+		_jobStartedAtKey:  formatTime(job.StartedAt),
+		_jobFinishedAtKey: formatTime(job.FinishedAt),
+	}
+}
+
+func recordToJob(record map[string]string) Job {
+	total, _ := strconv.Atoi(record[_jobTotalKey])
+	sent, _ := strconv.Atoi(record[_jobSentKey])
+	failed, _ := strconv.Atoi(record[_jobFailedKey])
+	skipped, _ := strconv.Atoi(record[_jobSkippedKey])
+
+	return Job{
+		ID:         record[_jobIDKey],
+		Status:     JobStatus(record[_jobStatusKey]),
+		Total:      total,
+		Sent:       sent,
+		Failed:     failed,
+		Skipped:    skipped,
+		Error:      record[_jobErrorKey],
+		CreatedAt:  parseTime(record[_jobCreatedAtKey]),
+		StartedAt:  parseTime(record[_jobStartedAtKey]),
+		FinishedAt: parseTime(record[_jobFinishedAtKey]),
+	}
+}
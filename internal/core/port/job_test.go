@@ -0,0 +1,45 @@
+package port
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobRepository(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	job := Job{
+		ID:        "job1",
+		Status:    JobStatusQueued,
+		CreatedAt: createdAt,
+	}
+
+	jobRepository := NewJobRepository(&StubStorage{})
+
+	require.NoError(t, jobRepository.Add(&job))
+	require.Equal(t, ErrJobAlreadyAdded, jobRepository.Add(&job))
+
+	running := job
+	running.Status = JobStatusRunning
+	running.Total = 3
+	running.Sent = 1
+	running.Failed = 1
+	running.StartedAt = createdAt.Add(time.Second)
+	require.NoError(t, jobRepository.Update(&running))
+
+	found, err := jobRepository.FindByID("job1")
+	require.NoError(t, err)
+	require.Equal(t, &running, found)
+	require.Equal(t, 1, found.Pending())
+	require.False(t, found.Finished())
+
+	jobs, err := jobRepository.All()
+	require.NoError(t, err)
+	require.Equal(t, []Job{running}, jobs)
+
+	require.NoError(t, jobRepository.Remove(&running))
+	require.Equal(t, ErrCannotFindJob, jobRepository.Remove(&running))
+}
@@ -0,0 +1,257 @@
+package port
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	_outboxIDKey            = "id"
+	_outboxBaseKey          = "base"
+	_outboxQuoteKey         = "quote"
+	_outboxRateKey          = "rate"
+	_outboxProvidersKey     = "providers"
+	_outboxFetchedAtKey     = "fetched_at"
+	_outboxStatusKey        = "status"
+	_outboxAttemptsKey      = "attempts"
+	_outboxNextAttemptAtKey = "next_attempt_at"
+	_outboxLastErrorKey     = "last_error"
+	_outboxCreatedAtKey     = "created_at"
+	_outboxJobIDKey         = "job_id"
+)
+
+var (
+	ErrOutboxMessageAlreadyAdded = errors.New("outbox message is already added")
+	ErrCannotFindOutboxMessage   = errors.New("cannot find outbox message")
+	ErrCannotLoadOutbox          = errors.New("cannot load outbox")
+)
+
+type OutboxStatus string
+
+const (
+	// Waits for the next attempt
+	OutboxStatusPending OutboxStatus = "pending"
+	// Failed permanently or ran out of attempts, kept for inspection
+	OutboxStatusDead OutboxStatus = "dead"
+)
+
+// OutboxMessage is a rate email waiting to be sent to the subscriber
+type OutboxMessage struct {
+	ID     string
+	Email  string
+	Rate   Rate
+	Status OutboxStatus
+	// The number of the failed attempts
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	// The job the message was queued by, empty if it wasn't
+	JobID string
+}
+
+// BatchAppender is implemented by the storages which append many
+// records at once faster than one by one
+type BatchAppender interface {
+	AppendAll(records []map[string]string) error
+}
+
+// BatchWriter is implemented by the storages which update and delete
+// many records at once faster than one by one
+type BatchWriter interface {
+	UpdateAll(key string, records []map[string]string) error
+	DeleteAll(key string, values []string) error
+}
+
+type OutboxRepository struct {
+	storage Storage
+}
+
+func NewOutboxRepository(storage Storage) *OutboxRepository {
+	return &OutboxRepository{
+		storage: storage,
+	}
+}
+
+// AddAll queues the messages, at once if the storage supports it
+func (or *OutboxRepository) AddAll(messages []OutboxMessage) error {
+	records := make([]map[string]string, 0, len(messages))
+	for i := range messages {
+		records = append(records, outboxMessageToRecord(&messages[i]))
+	}
+
+	if appender, ok := or.storage.(BatchAppender); ok {
+		return appender.AppendAll(records)
+	}
+
+	for _, record := range records {
+		if err := or.storage.Append(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (or *OutboxRepository) Update(message *OutboxMessage) error {
+	_, err := or.FindByID(message.ID)
+	if err != nil {
+		return err
+	}
+
+	return or.storage.Update(_outboxIDKey, message.ID, outboxMessageToRecord(message))
+}
+
+func (or *OutboxRepository) Remove(message *OutboxMessage) error {
+	_, err := or.FindByID(message.ID)
+	if err != nil {
+		return err
+	}
+
+	return or.storage.Delete(_outboxIDKey, message.ID)
+}
+
+// UpdateAll stores the messages at once if the storage supports it,
+// the messages no longer in the outbox are skipped
+func (or *OutboxRepository) UpdateAll(messages []OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	records := make([]map[string]string, 0, len(messages))
+	for i := range messages {
+		records = append(records, outboxMessageToRecord(&messages[i]))
+	}
+
+	if writer, ok := or.storage.(BatchWriter); ok {
+		return writer.UpdateAll(_outboxIDKey, records)
+	}
+
+	for _, record := range records {
+		if err := or.storage.Update(_outboxIDKey, record[_outboxIDKey], record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveAll removes the messages at once if the storage supports it,
+// the messages no longer in the outbox are skipped
+func (or *OutboxRepository) RemoveAll(messages []OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+
+	if writer, ok := or.storage.(BatchWriter); ok {
+		return writer.DeleteAll(_outboxIDKey, ids)
+	}
+
+	for _, id := range ids {
+		if err := or.storage.Delete(_outboxIDKey, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (or *OutboxRepository) FindByID(id string) (*OutboxMessage, error) {
+	messages, err := or.All()
+	if err != nil {
+		return &OutboxMessage{}, err
+	}
+
+	for i := range messages {
+		if messages[i].ID == id {
+			return &messages[i], nil
+		}
+	}
+
+	return &OutboxMessage{}, ErrCannotFindOutboxMessage
+}
+
+// All returns the queued messages in the order they were added
+func (or *OutboxRepository) All() ([]OutboxMessage, error) {
+	records, err := or.storage.AllRecords()
+	if err != nil {
+		return nil, errors.Join(err, ErrCannotLoadOutbox)
+	}
+
+	messages := make([]OutboxMessage, 0, len(records))
+	for _, record := range records {
+		messages = append(messages, recordToOutboxMessage(record))
+	}
+
+	return messages, nil
+}
+
+func outboxMessageToRecord(message *OutboxMessage) map[string]string {
+	return map[string]string{
+		_outboxIDKey:            message.ID,
+		_emailKey:               message.Email,
+		_outboxBaseKey:          message.Rate.Pair.Base,
+		_outboxQuoteKey:         message.Rate.Pair.Quote,
+		_outboxRateKey:          strconv.FormatFloat(float64(message.Rate.Value), 'f', -1, 32),
+		_outboxProvidersKey:     strings.Join(message.Rate.Providers, ","),
+		_outboxFetchedAtKey:     formatTime(message.Rate.FetchedAt),
+		_outboxStatusKey:        string(message.Status),
+		_outboxAttemptsKey:      strconv.Itoa(message.Attempts),
+		_outboxNextAttemptAtKey: formatTime(message.NextAttemptAt),
+		_outboxLastErrorKey:     message.LastError,
+		_outboxCreatedAtKey:     formatTime(message.CreatedAt),
+		_outboxJobIDKey:         message.JobID,
+	}
+}
+
+func recordToOutboxMessage(record map[string]string) OutboxMessage {
+	// The malformed values are left zero, e.g. a message without
+	// the next attempt time is due at once
+	rate, _ := strconv.ParseFloat(record[_outboxRateKey], 32)
+	attempts, _ := strconv.Atoi(record[_outboxAttemptsKey])
+
+	var providers []string
+	if record[_outboxProvidersKey] != "" {
+		providers = strings.Split(record[_outboxProvidersKey], ",")
+	}
+
+	return OutboxMessage{
+		ID:    record[_outboxIDKey],
+		Email: record[_emailKey],
+		Rate: Rate{
+			Pair: CurrencyPair{
+				Base:  record[_outboxBaseKey],
+				Quote: record[_outboxQuoteKey],
+			},
+			Value:     float32(rate),
+			Providers: providers,
+			FetchedAt: parseTime(record[_outboxFetchedAtKey]),
+		},
+		Status:        OutboxStatus(record[_outboxStatusKey]),
+		Attempts:      attempts,
+		NextAttemptAt: parseTime(record[_outboxNextAttemptAtKey]),
+		LastError:     record[_outboxLastErrorKey],
+		CreatedAt:     parseTime(record[_outboxCreatedAtKey]),
+		JobID:         record[_outboxJobIDKey],
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
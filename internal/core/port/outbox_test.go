@@ -0,0 +1,135 @@
+package port
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type StubBatchStorage struct {
+	StubStorage
+	batches int
+}
+
+func (s *StubBatchStorage) AppendAll(records []map[string]string) error {
+	s.batches++
+	s.data = append(s.data, records...)
+	return nil
+}
+
+func (s *StubBatchStorage) UpdateAll(key string, records []map[string]string) error {
+	s.batches++
+	for _, record := range records {
+		if err := s.Update(key, record[key], record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StubBatchStorage) DeleteAll(key string, values []string) error {
+	s.batches++
+	for _, value := range values {
+		if err := s.Delete(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestOutboxRepository(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	pending := OutboxMessage{
+		ID:    "message1",
+		Email: "first@example.com",
+		Rate: Rate{
+			Pair:      CurrencyPair{Base: "BTC", Quote: "UAH"},
+			Value:     1000000.5,
+			Providers: []string{"coingecko", "binance"},
+			FetchedAt: createdAt.Add(-time.Second),
+		},
+		Status:    OutboxStatusPending,
+		CreatedAt: createdAt,
+		JobID:     "job1",
+	}
+	dead := OutboxMessage{
+		ID:            "message2",
+		Email:         "second@example.com",
+		Rate:          Rate{Pair: CurrencyPair{Base: "BTC", Quote: "UAH"}, Value: 1},
+		Status:        OutboxStatusDead,
+		Attempts:      5,
+		NextAttemptAt: createdAt.Add(time.Minute),
+		LastError:     "550 mailbox unavailable",
+		CreatedAt:     createdAt,
+	}
+
+	tests := []struct {
+		name    string
+		storage Storage
+	}{
+		{
+			name:    "Storage appending one by one",
+			storage: &StubStorage{},
+		},
+		{
+			name:    "Storage appending in batches",
+			storage: &StubBatchStorage{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			outboxRepository := NewOutboxRepository(tt.storage)
+
+			require.NoError(t, outboxRepository.AddAll([]OutboxMessage{pending, dead}))
+
+			messages, err := outboxRepository.All()
+			require.NoError(t, err)
+			require.Equal(t, []OutboxMessage{pending, dead}, messages)
+
+			if batchStorage, ok := tt.storage.(*StubBatchStorage); ok {
+				require.Equal(t, 1, batchStorage.batches)
+			}
+
+			retried := pending
+			retried.Attempts = 1
+			retried.LastError = "421 try again later"
+			retried.NextAttemptAt = createdAt.Add(time.Minute)
+			require.NoError(t, outboxRepository.Update(&retried))
+
+			found, err := outboxRepository.FindByID("message1")
+			require.NoError(t, err)
+			require.Equal(t, &retried, found)
+
+			require.NoError(t, outboxRepository.Remove(&retried))
+			require.Equal(t, ErrCannotFindOutboxMessage, outboxRepository.Remove(&retried))
+			require.Equal(t, ErrCannotFindOutboxMessage, outboxRepository.Update(&retried))
+
+			// The batches skip the messages no longer in the outbox
+			retriedDead := dead
+			retriedDead.Status = OutboxStatusPending
+			retriedDead.Attempts = 0
+			require.NoError(t, outboxRepository.UpdateAll([]OutboxMessage{retried, retriedDead}))
+
+			messages, err = outboxRepository.All()
+			require.NoError(t, err)
+			require.Equal(t, []OutboxMessage{retriedDead}, messages)
+
+			require.NoError(t, outboxRepository.RemoveAll([]OutboxMessage{retried, retriedDead}))
+
+			messages, err = outboxRepository.All()
+			require.NoError(t, err)
+			require.Empty(t, messages)
+
+			if batchStorage, ok := tt.storage.(*StubBatchStorage); ok {
+				require.Equal(t, 3, batchStorage.batches)
+			}
+		})
+	}
+}
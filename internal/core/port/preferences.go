@@ -0,0 +1,172 @@
+package port
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const _pairsSeparator = ","
+
+var (
+	ErrInvalidCadence = errors.New("invalid cadence")
+	ErrInvalidChannel = errors.New("invalid channel")
+)
+
+// Cadence tells how often the subscriber gets the scheduled rate
+type Cadence string
+
+const (
+	CadenceHourly Cadence = "hourly"
+	CadenceDaily  Cadence = "daily"
+	CadenceWeekly Cadence = "weekly"
+)
+
+// DefaultCadence is the cadence of the subscribers who haven't chosen one
+const DefaultCadence = CadenceDaily
+
+// ParseCadence validates the cadence, an empty one is the default
+func ParseCadence(value string) (Cadence, error) {
+	cadence := Cadence(strings.ToLower(strings.TrimSpace(value)))
+
+	switch cadence {
+	case "":
+		return DefaultCadence, nil
+	case CadenceHourly, CadenceDaily, CadenceWeekly:
+		return cadence, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidCadence, value)
+	}
+}
+
+// Preferences tell what the subscriber gets and how. The zero value
+// is the default: the default pair daily through every linked channel
+// in the default language.
+type Preferences struct {
+	// The language of the emails, e.g. uk, the default one if empty
+	Language string
+	// The pairs the rate is sent for, the default pair if empty
+	Pairs   []CurrencyPair
+	Cadence Cadence
+	// The only channel the rate is sent through, every linked one
+	// if empty
+	Channel string
+}
+
+// SubscribedPairs returns the pairs the rate is sent for
+func (p Preferences) SubscribedPairs() []CurrencyPair {
+	if len(p.Pairs) == 0 {
+		return []CurrencyPair{DefaultCurrencyPair}
+	}
+
+	return p.Pairs
+}
+
+// WantsChannel tells whether the rate is sent through the channel
+func (p Preferences) WantsChannel(channel string) bool {
+	return p.Channel == "" || p.Channel == channel
+}
+
+// WantsCadence tells whether the subscriber gets the rate of the cadence
+func (p Preferences) WantsCadence(cadence Cadence) bool {
+	if p.Cadence == "" {
+		return cadence == DefaultCadence
+	}
+
+	return p.Cadence == cadence
+}
+
+// ParseChannel validates the preferred channel, an empty one means
+// every linked channel. The webhooks aren't a subscriber's channel.
+func ParseChannel(value string) (string, error) {
+	channel := strings.ToLower(strings.TrimSpace(value))
+
+	switch channel {
+	case "", ChannelEmail, ChannelTelegram:
+		return channel, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidChannel, value)
+	}
+}
+
+// ParseCurrencyPair parses a pair written as BASE/QUOTE
+func ParseCurrencyPair(value string) (CurrencyPair, error) {
+	base, quote, found := strings.Cut(value, "/")
+	if !found {
+		return CurrencyPair{}, fmt.Errorf("%w: %q", ErrInvalidCurrencyPair, value)
+	}
+
+	pair, err := NewCurrencyPair(base, quote)
+	if err != nil {
+		return CurrencyPair{}, fmt.Errorf("%w: %q", err, value)
+	}
+
+	return pair, nil
+}
+
+// PairSubscribers are the subscribers who get the rate of the pair
+type PairSubscribers struct {
+	Pair        CurrencyPair
+	Subscribers []User
+}
+
+// SubscribersByPair groups the subscribers by the pairs they get.
+// The default pair always comes first, even without subscribers,
+// the others follow in the order they were first asked for.
+func SubscribersByPair(users []User) []PairSubscribers {
+	groups := []PairSubscribers{{Pair: DefaultCurrencyPair}}
+	index := map[CurrencyPair]int{DefaultCurrencyPair: 0}
+
+	for _, user := range users {
+		for _, pair := range user.Preferences.SubscribedPairs() {
+			i, ok := index[pair]
+			if !ok {
+				i = len(groups)
+				index[pair] = i
+				groups = append(groups, PairSubscribers{Pair: pair})
+			}
+
+			groups[i].Subscribers = append(groups[i].Subscribers, user)
+		}
+	}
+
+	return groups
+}
+
+// FilterByChannel returns the users who get the rate through the channel
+func FilterByChannel(users []User, channel string) []User {
+	filtered := make([]User, 0, len(users))
+	for _, user := range users {
+		if user.Preferences.WantsChannel(channel) {
+			filtered = append(filtered, user)
+		}
+	}
+
+	return filtered
+}
+
+func formatPairs(pairs []CurrencyPair) string {
+	formatted := make([]string, len(pairs))
+	for i, pair := range pairs {
+		formatted[i] = pair.String()
+	}
+
+	return strings.Join(formatted, _pairsSeparator)
+}
+
+// parsePairs skips the malformed pairs of a stored record
+func parsePairs(value string) []CurrencyPair {
+	if value == "" {
+		return nil
+	}
+
+	var pairs []CurrencyPair
+	for _, field := range strings.Split(value, _pairsSeparator) {
+		pair, err := ParseCurrencyPair(field)
+		if err == nil {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return pairs
+}
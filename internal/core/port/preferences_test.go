@@ -0,0 +1,106 @@
+package port
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCadence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value       string
+		expected    Cadence
+		expectedErr error
+	}{
+		{value: "", expected: DefaultCadence},
+		{value: "hourly", expected: CadenceHourly},
+		{value: " Weekly ", expected: CadenceWeekly},
+		{value: "monthly", expectedErr: ErrInvalidCadence},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.value, func(t *testing.T) {
+			t.Parallel()
+
+			cadence, err := ParseCadence(tt.value)
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expected, cadence)
+		})
+	}
+}
+
+func TestParseCurrencyPair(t *testing.T) {
+	t.Parallel()
+
+	pair, err := ParseCurrencyPair("eth/usd")
+	require.NoError(t, err)
+	require.Equal(t, CurrencyPair{Base: "ETH", Quote: "USD"}, pair)
+
+	_, err = ParseCurrencyPair("ETHUSD")
+	require.ErrorIs(t, err, ErrInvalidCurrencyPair)
+}
+
+func TestPreferences(t *testing.T) {
+	t.Parallel()
+
+	var defaults Preferences
+	require.Equal(t, []CurrencyPair{DefaultCurrencyPair}, defaults.SubscribedPairs())
+	require.True(t, defaults.WantsChannel(ChannelEmail))
+	require.True(t, defaults.WantsChannel(ChannelTelegram))
+	require.True(t, defaults.WantsCadence(CadenceDaily))
+	require.False(t, defaults.WantsCadence(CadenceHourly))
+
+	chosen := Preferences{Cadence: CadenceWeekly, Channel: ChannelTelegram}
+	require.False(t, chosen.WantsChannel(ChannelEmail))
+	require.True(t, chosen.WantsCadence(CadenceWeekly))
+	require.False(t, chosen.WantsCadence(CadenceDaily))
+}
+
+func TestSubscribersByPair(t *testing.T) {
+	t.Parallel()
+
+	btcUSD := CurrencyPair{Base: "BTC", Quote: "USD"}
+	ethUAH := CurrencyPair{Base: "ETH", Quote: "UAH"}
+
+	first := User{Email: "first@example.com", Preferences: Preferences{
+		Pairs: []CurrencyPair{ethUAH, btcUSD},
+	}}
+	second := User{Email: "second@example.com", Preferences: Preferences{
+		Pairs: []CurrencyPair{btcUSD},
+	}}
+
+	require.Equal(t, []PairSubscribers{
+		{Pair: DefaultCurrencyPair},
+		{Pair: ethUAH, Subscribers: []User{first}},
+		{Pair: btcUSD, Subscribers: []User{first, second}},
+	}, SubscribersByPair([]User{first, second}))
+
+	third := User{Email: "third@example.com"}
+	require.Equal(t, []PairSubscribers{
+		{Pair: DefaultCurrencyPair, Subscribers: []User{third}},
+	}, SubscribersByPair([]User{third}))
+}
+
+func TestPairsRecord(t *testing.T) {
+	t.Parallel()
+
+	user := User{Email: "test@example.com", Preferences: Preferences{
+		Language: "uk",
+		Pairs:    []CurrencyPair{{Base: "BTC", Quote: "USD"}, DefaultCurrencyPair},
+		Cadence:  CadenceHourly,
+		Channel:  ChannelEmail,
+	}}
+
+	record := userToRecord(&user)
+	require.Equal(t, "BTC/USD,BTC/UAH", record[_pairsKey])
+	require.Equal(t, user, recordToUser(record))
+
+	record[_pairsKey] = "BTC/USD,broken"
+	require.Equal(t,
+		[]CurrencyPair{{Base: "BTC", Quote: "USD"}},
+		recordToUser(record).Preferences.Pairs,
+	)
+}
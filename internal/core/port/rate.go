@@ -1,5 +1,57 @@
 package port
 
-// Rate represents the exchange rate between two currencies.
-// It is expressed as a float32 value.
-type Rate float32
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCurrencyPair = errors.New("invalid currency pair")
+)
+
+var _currencyCodePattern = regexp.MustCompile(`^[A-Z0-9]{2,10}$`)
+
+// DefaultCurrencyPair is the pair used when the caller does not request
+// a specific one.
+var DefaultCurrencyPair = CurrencyPair{Base: "BTC", Quote: "UAH"}
+
+// CurrencyPair represents a market, where Base is the currency being priced
+// and Quote is the currency the price is expressed in.
+type CurrencyPair struct {
+	Base  string
+	Quote string
+}
+
+// NewCurrencyPair normalizes the currency codes to upper case and validates
+// that they form a pair of two distinct currencies.
+func NewCurrencyPair(base, quote string) (CurrencyPair, error) {
+	pair := CurrencyPair{
+		Base:  strings.ToUpper(strings.TrimSpace(base)),
+		Quote: strings.ToUpper(strings.TrimSpace(quote)),
+	}
+
+	if !_currencyCodePattern.MatchString(pair.Base) ||
+		!_currencyCodePattern.MatchString(pair.Quote) ||
+		pair.Base == pair.Quote {
+		return CurrencyPair{}, ErrInvalidCurrencyPair
+	}
+
+	return pair, nil
+}
+
+func (p CurrencyPair) String() string {
+	return p.Base + "/" + p.Quote
+}
+
+// Rate represents the exchange rate of a currency pair:
+// the amount of the Quote currency per one unit of the Base currency.
+type Rate struct {
+	Pair  CurrencyPair
+	Value float32
+	// The names of the providers the value is based on
+	Providers []string
+	// When the value was received from the providers, zero if unknown
+	FetchedAt time.Time
+}
@@ -0,0 +1,62 @@
+package port
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCurrencyPair(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		base         string
+		quote        string
+		expectedPair CurrencyPair
+		expectedErr  error
+	}{
+		{
+			name:         "Upper case pair",
+			base:         "BTC",
+			quote:        "UAH",
+			expectedPair: CurrencyPair{Base: "BTC", Quote: "UAH"},
+		},
+		{
+			name:         "Lower case pair is normalized",
+			base:         " eth",
+			quote:        "usd ",
+			expectedPair: CurrencyPair{Base: "ETH", Quote: "USD"},
+		},
+		{
+			name:        "Empty base",
+			base:        "",
+			quote:       "UAH",
+			expectedErr: ErrInvalidCurrencyPair,
+		},
+		{
+			name:        "Same currencies",
+			base:        "BTC",
+			quote:       "btc",
+			expectedErr: ErrInvalidCurrencyPair,
+		},
+		{
+			name:        "Unexpected characters",
+			base:        "BTC&x=1",
+			quote:       "UAH",
+			expectedErr: ErrInvalidCurrencyPair,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			pair, err := NewCurrencyPair(tt.base, tt.quote)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedPair, pair)
+		})
+	}
+}
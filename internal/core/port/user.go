@@ -2,24 +2,63 @@ package port
 
 import (
 	"errors"
+	"strconv"
+	"time"
 )
 
-const _emailKey = "email"
+const (
+	_emailKey                 = "email"
+	_statusKey                = "status"
+	_confirmationTokenKey     = "confirmation_token"
+	_confirmationExpiresAtKey = "confirmation_expires_at"
+	_telegramChatIDKey        = "telegram_chat_id"
+	_languageKey              = "language"
+	_pairsKey                 = "pairs"
+	_cadenceKey               = "cadence"
+	_channelKey               = "channel"
+)
 
 var (
 	ErrAlreadyAdded      = errors.New("user is already added")
 	ErrCannotFindByEmail = errors.New("cannot find user by email")
+	ErrCannotFindByToken = errors.New("cannot find user by confirmation token")
 	ErrCannotLoadUsers   = errors.New("cannot load users")
+
+	// ErrDuplicateRecord is returned by a storage when a record
+	// with the same unique value is already stored
+	ErrDuplicateRecord = errors.New("record already exists")
+)
+
+type UserStatus string
+
+const (
+	// Users stored before the confirmation flow have no status
+	// and are treated as active
+	UserStatusActive  UserStatus = "active"
+	UserStatusPending UserStatus = "pending"
 )
 
 // Represents a User entity
 type User struct {
-	Email string
+	Email                 string
+	Status                UserStatus
+	ConfirmationToken     string
+	ConfirmationExpiresAt time.Time
+	// The chat the rate is also sent to, zero if Telegram isn't linked
+	TelegramChatID int64
+	Preferences    Preferences
+}
+
+func (u *User) IsActive() bool {
+	return u.Status == UserStatusActive || u.Status == ""
 }
 
 type Storage interface {
 	Append(record map[string]string) error
+	AppendUnique(key string, record map[string]string) error
 	AllRecords() (records []map[string]string, err error)
+	Update(key, value string, record map[string]string) error
+	Delete(key, value string) error
 }
 
 type UserRepository struct {
@@ -32,34 +71,77 @@ func NewUserRepository(storage Storage) *UserRepository {
 	}
 }
 
+// Add stores the user unless the email is already stored. The storage
+// checks and appends in one step, so concurrent calls can't add
+// the same email twice.
 func (ur *UserRepository) Add(user *User) error {
-	_, err := ur.FindByEmail(user.Email)
+	err := ur.storage.AppendUnique(_emailKey, userToRecord(user))
+	if errors.Is(err, ErrDuplicateRecord) {
+		return ErrAlreadyAdded
+	}
+
+	return err
+}
+
+// AddAll stores the users at once if the storage supports it. A storage
+// which refuses a stored email refuses the whole batch with ErrAlreadyAdded.
+func (ur *UserRepository) AddAll(users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
 
-	isUserFound := !errors.Is(err, ErrCannotFindByEmail)
-	if isUserFound {
+	records := make([]map[string]string, 0, len(users))
+	for i := range users {
+		records = append(records, userToRecord(&users[i]))
+	}
+
+	appender, ok := ur.storage.(BatchAppender)
+	if !ok {
+		for i := range users {
+			if err := ur.Add(&users[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	err := appender.AppendAll(records)
+	if errors.Is(err, ErrDuplicateRecord) {
 		return ErrAlreadyAdded
 	}
 
-	if err != nil && isUserFound {
+	return err
+}
+
+func (ur *UserRepository) Update(user *User) error {
+	_, err := ur.FindByEmail(user.Email)
+	if err != nil {
 		return err
 	}
 
-	return ur.storage.Append(map[string]string{_emailKey: user.Email})
+	return ur.storage.Update(_emailKey, user.Email, userToRecord(user))
 }
 
-func (ur *UserRepository) FindByEmail(email string) (*User, error) {
-	records, err := ur.storage.AllRecords()
+func (ur *UserRepository) Remove(user *User) error {
+	_, err := ur.FindByEmail(user.Email)
 	if err != nil {
-		return &User{}, err
+		return err
 	}
 
-	for _, e := range records {
-		if e[_emailKey] == email {
-			return &User{Email: email}, nil
-		}
+	return ur.storage.Delete(_emailKey, user.Email)
+}
+
+func (ur *UserRepository) FindByEmail(email string) (*User, error) {
+	return ur.findBy(_emailKey, email, ErrCannotFindByEmail)
+}
+
+func (ur *UserRepository) FindByConfirmationToken(token string) (*User, error) {
+	if token == "" {
+		return &User{}, ErrCannotFindByToken
 	}
 
-	return &User{}, ErrCannotFindByEmail
+	return ur.findBy(_confirmationTokenKey, token, ErrCannotFindByToken)
 }
 
 func (ur *UserRepository) All() ([]User, error) {
@@ -70,8 +152,69 @@ func (ur *UserRepository) All() ([]User, error) {
 
 	users := make([]User, len(records))
 	for i, record := range records {
-		users[i].Email = record[_emailKey]
+		users[i] = recordToUser(record)
 	}
 
 	return users, nil
 }
+
+func (ur *UserRepository) findBy(key, value string, errNotFound error) (*User, error) {
+	records, err := ur.storage.AllRecords()
+	if err != nil {
+		return &User{}, err
+	}
+
+	for _, record := range records {
+		if record[key] == value {
+			user := recordToUser(record)
+			return &user, nil
+		}
+	}
+
+	return &User{}, errNotFound
+}
+
+func userToRecord(user *User) map[string]string {
+	record := map[string]string{
+		_emailKey:                 user.Email,
+		_statusKey:                string(user.Status),
+		_confirmationTokenKey:     user.ConfirmationToken,
+		_confirmationExpiresAtKey: "",
+		_telegramChatIDKey:        "",
+		_languageKey:              user.Preferences.Language,
+		_pairsKey:                 formatPairs(user.Preferences.Pairs),
+		_cadenceKey:               string(user.Preferences.Cadence),
+		_channelKey:               user.Preferences.Channel,
+	}
+
+	if !user.ConfirmationExpiresAt.IsZero() {
+		record[_confirmationExpiresAtKey] = user.ConfirmationExpiresAt.
+			UTC().Format(time.RFC3339)
+	}
+
+	if user.TelegramChatID != 0 {
+		record[_telegramChatIDKey] = strconv.FormatInt(user.TelegramChatID, 10)
+	}
+
+	return record
+}
+
+func recordToUser(record map[string]string) User {
+	// A malformed expiration time is left zero, which means expired
+	expiresAt, _ := time.Parse(time.RFC3339, record[_confirmationExpiresAtKey])
+	telegramChatID, _ := strconv.ParseInt(record[_telegramChatIDKey], 10, 64)
+
+	return User{
+		Email:                 record[_emailKey],
+		Status:                UserStatus(record[_statusKey]),
+		ConfirmationToken:     record[_confirmationTokenKey],
+		ConfirmationExpiresAt: expiresAt,
+		TelegramChatID:        telegramChatID,
+		Preferences: Preferences{
+			Language: record[_languageKey],
+			Pairs:    parsePairs(record[_pairsKey]),
+			Cadence:  Cadence(record[_cadenceKey]),
+			Channel:  record[_channelKey],
+		},
+	}
+}
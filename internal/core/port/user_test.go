@@ -2,6 +2,7 @@ package port
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -19,6 +20,21 @@ func (s *StubStorage) Append(record map[string]string) error {
 	return nil
 }
 
+func (s *StubStorage) AppendUnique(key string, record map[string]string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	for _, r := range s.data {
+		if r[key] == record[key] {
+			return ErrDuplicateRecord
+		}
+	}
+
+	s.data = append(s.data, record)
+	return nil
+}
+
 func (s *StubStorage) AllRecords() ([]map[string]string, error) {
 	if s.err != nil {
 		return nil, s.err
@@ -26,6 +42,36 @@ func (s *StubStorage) AllRecords() ([]map[string]string, error) {
 	return s.data, nil
 }
 
+func (s *StubStorage) Update(key, value string, record map[string]string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	for i := range s.data {
+		if s.data[i][key] == value {
+			s.data[i] = record
+		}
+	}
+
+	return nil
+}
+
+func (s *StubStorage) Delete(key, value string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	kept := s.data[:0]
+	for _, record := range s.data {
+		if record[key] != value {
+			kept = append(kept, record)
+		}
+	}
+	s.data = kept
+
+	return nil
+}
+
 func TestAdd(t *testing.T) {
 	t.Parallel()
 
@@ -64,6 +110,186 @@ func TestAdd(t *testing.T) {
 	}
 }
 
+func TestAddAll(t *testing.T) {
+	t.Parallel()
+
+	users := []User{{Email: "first"}, {Email: "second"}}
+
+	t.Run("Batch storage", func(t *testing.T) {
+		t.Parallel()
+
+		batchStorage := &StubBatchStorage{}
+		userRepository := NewUserRepository(batchStorage)
+
+		require.NoError(t, userRepository.AddAll(users))
+		require.NoError(t, userRepository.AddAll(nil))
+		require.Equal(t, 1, batchStorage.batches)
+
+		stored, err := userRepository.All()
+		require.NoError(t, err)
+		require.Equal(t, []string{"first", "second"}, []string{stored[0].Email, stored[1].Email})
+	})
+
+	t.Run("Storage without batches", func(t *testing.T) {
+		t.Parallel()
+
+		stubStorage := &StubStorage{data: []map[string]string{{"email": "second"}}}
+		userRepository := NewUserRepository(stubStorage)
+
+		require.Equal(t, ErrAlreadyAdded, userRepository.AddAll(users))
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		existingData   []map[string]string
+		user           *User
+		expectedErr    error
+		expectedStatus UserStatus
+	}{
+		{
+			name: "Update user successfully",
+			existingData: []map[string]string{
+				{"email": "user1", "status": "pending"},
+			},
+			user:           &User{Email: "user1", Status: UserStatusActive},
+			expectedErr:    nil,
+			expectedStatus: UserStatusActive,
+		},
+		{
+			name: "Update a missing user",
+			existingData: []map[string]string{
+				{"email": "user1", "status": "pending"},
+			},
+			user:           &User{Email: "user2", Status: UserStatusActive},
+			expectedErr:    ErrCannotFindByEmail,
+			expectedStatus: UserStatusPending,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stubStorage := &StubStorage{data: tt.existingData}
+			userRepository := NewUserRepository(stubStorage)
+
+			err := userRepository.Update(tt.user)
+
+			require.Equal(t, tt.expectedErr, err)
+
+			user, err := userRepository.FindByEmail("user1")
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedStatus, user.Status)
+		})
+	}
+}
+
+func TestFindByConfirmationToken(t *testing.T) {
+	t.Parallel()
+
+	expiresAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	existingData := []map[string]string{
+		{"email": "user1", "status": "active"},
+		{
+			"email":                   "user2",
+			"status":                  "pending",
+			"confirmation_token":      "token",
+			"confirmation_expires_at": expiresAt.Format(time.RFC3339),
+		},
+	}
+
+	tests := []struct {
+		name         string
+		token        string
+		expectedUser *User
+		expectedErr  error
+	}{
+		{
+			name:  "Find user successfully",
+			token: "token",
+			expectedUser: &User{
+				Email:                 "user2",
+				Status:                UserStatusPending,
+				ConfirmationToken:     "token",
+				ConfirmationExpiresAt: expiresAt,
+			},
+		},
+		{
+			name:         "Unknown token",
+			token:        "unknown",
+			expectedUser: &User{},
+			expectedErr:  ErrCannotFindByToken,
+		},
+		{
+			name:         "Empty token",
+			token:        "",
+			expectedUser: &User{},
+			expectedErr:  ErrCannotFindByToken,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			userRepository := NewUserRepository(&StubStorage{data: existingData})
+
+			user, err := userRepository.FindByConfirmationToken(tt.token)
+
+			require.Equal(t, tt.expectedErr, err)
+			require.Equal(t, tt.expectedUser, user)
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		existingData  []map[string]string
+		emailToRemove string
+		expectedErr   error
+		expectedCount int
+	}{
+		{
+			name:          "Remove user successfully",
+			existingData:  []map[string]string{{"email": "user1"}, {"email": "user2"}},
+			emailToRemove: "user1",
+			expectedErr:   nil,
+			expectedCount: 1,
+		},
+		{
+			name:          "Remove a missing user",
+			existingData:  []map[string]string{{"email": "user1"}},
+			emailToRemove: "user2",
+			expectedErr:   ErrCannotFindByEmail,
+			expectedCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stubStorage := &StubStorage{data: tt.existingData}
+			userRepository := NewUserRepository(stubStorage)
+
+			err := userRepository.Remove(&User{Email: tt.emailToRemove})
+
+			require.Equal(t, tt.expectedErr, err)
+			require.Equal(t, tt.expectedCount, len(stubStorage.data))
+		})
+	}
+}
+
 func TestFindByEmail(t *testing.T) {
 	t.Parallel()
 
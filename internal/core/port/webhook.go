@@ -0,0 +1,294 @@
+package port
+
+import (
+	"errors"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+const (
+	_webhookIDKey        = "id"
+	_webhookURLKey       = "url"
+	_webhookSecretKey    = "secret"
+	_webhookCreatedAtKey = "created_at"
+	_webhookEmailKey     = "email"
+
+	_deliveryIDKey          = "id"
+	_deliveryWebhookIDKey   = "webhook_id"
+	_deliveryAttemptKey     = "attempt"
+	_deliveryStatusCodeKey  = "status_code"
+	_deliveryErrorKey       = "error"
+	_deliveryDurationKey    = "duration"
+	_deliveryDeliveredAtKey = "delivered_at"
+)
+
+var (
+	ErrWebhookAlreadyAdded = errors.New("webhook is already added")
+	ErrCannotFindWebhook   = errors.New("cannot find webhook")
+	ErrCannotLoadWebhooks  = errors.New("cannot load webhooks")
+	ErrCannotLoadDelivery  = errors.New("cannot load webhook deliveries")
+)
+
+// Webhook is a URL the rate is posted to
+type Webhook struct {
+	ID  string
+	URL string
+	// The key of the HMAC signature of the posted payloads
+	Secret    string
+	CreatedAt time.Time
+	// The subscriber who registered the webhook, empty for the ones
+	// registered by the admin without an owner
+	Email string
+}
+
+// WebhookDelivery is an attempt to post the rate to a webhook
+type WebhookDelivery struct {
+	ID        string
+	WebhookID string
+	// Starts with 1, the retries of a delivery share its ID
+	Attempt int
+	// The status of the response, zero if there was none
+	StatusCode  int
+	Error       string
+	Duration    time.Duration
+	DeliveredAt time.Time
+}
+
+// Succeeded tells whether the webhook accepted the payload
+func (d *WebhookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// _nonPublicPrefixes are the ranges a webhook can't be posted to, so it
+// can't reach the network of the application or the one of its provider
+var _nonPublicPrefixes = []netip.Prefix{
+	// "This network", the unspecified address among them
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	// The shared address space of the carrier-grade NAT
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	// The benchmarking networks
+	netip.MustParsePrefix("198.18.0.0/15"),
+	// The multicast, the reserved and the broadcast addresses
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	// NAT64 and 6to4, both embed an IPv4 address that may be a private one
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// IsPublicAddr tells whether the rate may be posted to the address.
+// The IPv4-mapped IPv6 addresses are checked as the IPv4 ones.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() {
+		return false
+	}
+
+	for _, prefix := range _nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type WebhookRepository struct {
+	storage Storage
+}
+
+func NewWebhookRepository(storage Storage) *WebhookRepository {
+	return &WebhookRepository{
+		storage: storage,
+	}
+}
+
+func (wr *WebhookRepository) Add(webhook *Webhook) error {
+	err := wr.storage.AppendUnique(_webhookIDKey, webhookToRecord(webhook))
+	if errors.Is(err, ErrDuplicateRecord) {
+		return ErrWebhookAlreadyAdded
+	}
+
+	return err
+}
+
+func (wr *WebhookRepository) Remove(webhook *Webhook) error {
+	_, err := wr.FindByID(webhook.ID)
+	if err != nil {
+		return err
+	}
+
+	return wr.storage.Delete(_webhookIDKey, webhook.ID)
+}
+
+func (wr *WebhookRepository) FindByID(id string) (*Webhook, error) {
+	webhooks, err := wr.All()
+	if err != nil {
+		return &Webhook{}, err
+	}
+
+	for i := range webhooks {
+		if webhooks[i].ID == id {
+			return &webhooks[i], nil
+		}
+	}
+
+	return &Webhook{}, ErrCannotFindWebhook
+}
+
+// FindByEmail returns the webhooks the subscriber has registered
+func (wr *WebhookRepository) FindByEmail(email string) ([]Webhook, error) {
+	webhooks, err := wr.All()
+	if err != nil {
+		return nil, err
+	}
+
+	owned := []Webhook{}
+	for _, webhook := range webhooks {
+		if webhook.Email == email {
+			owned = append(owned, webhook)
+		}
+	}
+
+	return owned, nil
+}
+
+func (wr *WebhookRepository) All() ([]Webhook, error) {
+	records, err := wr.storage.AllRecords()
+	if err != nil {
+		return nil, errors.Join(err, ErrCannotLoadWebhooks)
+	}
+
+	webhooks := make([]Webhook, 0, len(records))
+	for _, record := range records {
+		webhooks = append(webhooks, recordToWebhook(record))
+	}
+
+	return webhooks, nil
+}
+
+type WebhookDeliveryRepository struct {
+	storage Storage
+}
+
+func NewWebhookDeliveryRepository(storage Storage) *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{
+		storage: storage,
+	}
+}
+
+func (dr *WebhookDeliveryRepository) Add(delivery *WebhookDelivery) error {
+	return dr.storage.Append(deliveryToRecord(delivery))
+}
+
+// FindByWebhookID returns the attempts to post to the webhook,
+// the latest first
+func (dr *WebhookDeliveryRepository) FindByWebhookID(
+	webhookID string,
+) ([]WebhookDelivery, error) {
+	records, err := dr.storage.AllRecords()
+	if err != nil {
+		return nil, errors.Join(err, ErrCannotLoadDelivery)
+	}
+
+	// The records are stored in the order they were added
+	deliveries := []WebhookDelivery{}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i][_deliveryWebhookIDKey] == webhookID {
+			deliveries = append(deliveries, recordToDelivery(records[i]))
+		}
+	}
+
+	return deliveries, nil
+}
+
+// RemoveDelivery removes all the attempts of the delivery
+func (dr *WebhookDeliveryRepository) RemoveDelivery(id string) error {
+	return dr.storage.Delete(_deliveryIDKey, id)
+}
+
+// RemoveByWebhookID removes all the attempts to post to the webhook
+func (dr *WebhookDeliveryRepository) RemoveByWebhookID(webhookID string) error {
+	return dr.storage.Delete(_deliveryWebhookIDKey, webhookID)
+}
+
+func webhookToRecord(webhook *Webhook) map[string]string {
+	record := map[string]string{
+		_webhookIDKey:        webhook.ID,
+		_webhookURLKey:       webhook.URL,
+		_webhookSecretKey:    webhook.Secret,
+		_webhookCreatedAtKey: "",
+		_webhookEmailKey:     webhook.Email,
+	}
+
+	if !webhook.CreatedAt.IsZero() {
+		record[_webhookCreatedAtKey] = webhook.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	return record
+}
+
+func recordToWebhook(record map[string]string) Webhook {
+	createdAt, _ := time.Parse(time.RFC3339, record[_webhookCreatedAtKey])
+
+	return Webhook{
+		ID:        record[_webhookIDKey],
+		URL:       record[_webhookURLKey],
+		Secret:    record[_webhookSecretKey],
+		CreatedAt: createdAt,
+		Email:     record[_webhookEmailKey],
+	}
+}
+
+func deliveryToRecord(delivery *WebhookDelivery) map[string]string {
+	record := map[string]string{
+		_deliveryIDKey:          delivery.ID,
+		_deliveryWebhookIDKey:   delivery.WebhookID,
+		_deliveryAttemptKey:     strconv.Itoa(delivery.Attempt),
+		_deliveryStatusCodeKey:  "",
+		_deliveryErrorKey:       delivery.Error,
+		_deliveryDurationKey:    delivery.Duration.String(),
+		_deliveryDeliveredAtKey: "",
+	}
+
+	if delivery.StatusCode != 0 {
+		record[_deliveryStatusCodeKey] = strconv.Itoa(delivery.StatusCode)
+	}
+
+	if !delivery.DeliveredAt.IsZero() {
+		record[_deliveryDeliveredAtKey] = delivery.DeliveredAt.
+			UTC().Format(time.RFC3339Nano)
+	}
+
+	return record
+}
+
+func recordToDelivery(record map[string]string) WebhookDelivery {
+	// The malformed values are left zero, i.e. unknown
+	attempt, _ := strconv.Atoi(record[_deliveryAttemptKey])
+	statusCode, _ := strconv.Atoi(record[_deliveryStatusCodeKey])
+	duration, _ := time.ParseDuration(record[_deliveryDurationKey])
+	deliveredAt, _ := time.Parse(time.RFC3339Nano, record[_deliveryDeliveredAtKey])
+
+	return WebhookDelivery{
+		ID:          record[_deliveryIDKey],
+		WebhookID:   record[_deliveryWebhookIDKey],
+		Attempt:     attempt,
+		StatusCode:  statusCode,
+		Error:       record[_deliveryErrorKey],
+		Duration:    duration,
+		DeliveredAt: deliveredAt,
+	}
+}
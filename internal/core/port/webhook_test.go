@@ -0,0 +1,152 @@
+package port
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookRepository(t *testing.T) {
+	t.Parallel()
+
+	webhook := Webhook{
+		ID:        "webhook1",
+		URL:       "https://example.com/rate",
+		Secret:    "secret",
+		CreatedAt: time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC),
+		Email:     "test@example.com",
+	}
+
+	webhookRepository := NewWebhookRepository(&StubStorage{})
+
+	require.NoError(t, webhookRepository.Add(&webhook))
+	require.Equal(t, ErrWebhookAlreadyAdded, webhookRepository.Add(&webhook))
+
+	found, err := webhookRepository.FindByID("webhook1")
+	require.NoError(t, err)
+	require.Equal(t, &webhook, found)
+
+	webhooks, err := webhookRepository.All()
+	require.NoError(t, err)
+	require.Equal(t, []Webhook{webhook}, webhooks)
+
+	owned, err := webhookRepository.FindByEmail("test@example.com")
+	require.NoError(t, err)
+	require.Equal(t, []Webhook{webhook}, owned)
+
+	owned, err = webhookRepository.FindByEmail("other@example.com")
+	require.NoError(t, err)
+	require.Empty(t, owned)
+
+	require.NoError(t, webhookRepository.Remove(&webhook))
+	require.Equal(t, ErrCannotFindWebhook, webhookRepository.Remove(&webhook))
+}
+
+func TestWebhookDeliveryRepository(t *testing.T) {
+	t.Parallel()
+
+	deliveredAt := time.Date(2023, 7, 1, 9, 0, 0, 500, time.UTC)
+	failed := WebhookDelivery{
+		ID:          "delivery1",
+		WebhookID:   "webhook1",
+		Attempt:     1,
+		Error:       "connection refused",
+		Duration:    time.Millisecond,
+		DeliveredAt: deliveredAt,
+	}
+	retried := WebhookDelivery{
+		ID:          "delivery1",
+		WebhookID:   "webhook1",
+		Attempt:     2,
+		StatusCode:  200,
+		Duration:    time.Second,
+		DeliveredAt: deliveredAt.Add(time.Second),
+	}
+	other := WebhookDelivery{
+		ID:         "delivery2",
+		WebhookID:  "webhook2",
+		Attempt:    1,
+		StatusCode: 500,
+	}
+
+	deliveryRepository := NewWebhookDeliveryRepository(&StubStorage{})
+	for _, delivery := range []WebhookDelivery{failed, other, retried} {
+		delivery := delivery
+		require.NoError(t, deliveryRepository.Add(&delivery))
+	}
+
+	deliveries, err := deliveryRepository.FindByWebhookID("webhook1")
+	require.NoError(t, err)
+	require.Equal(t, []WebhookDelivery{retried, failed}, deliveries)
+	require.False(t, deliveries[1].Succeeded())
+	require.True(t, deliveries[0].Succeeded())
+
+	require.NoError(t, deliveryRepository.RemoveByWebhookID("webhook1"))
+
+	deliveries, err = deliveryRepository.FindByWebhookID("webhook1")
+	require.NoError(t, err)
+	require.Empty(t, deliveries)
+
+	require.NoError(t, deliveryRepository.RemoveDelivery("delivery2"))
+
+	deliveries, err = deliveryRepository.FindByWebhookID("webhook2")
+	require.NoError(t, err)
+	require.Empty(t, deliveries)
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{addr: "93.184.216.34", expected: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", expected: true},
+		{addr: "::ffff:93.184.216.34", expected: true},
+		{addr: "100.63.255.255", expected: true},
+		{addr: "100.128.0.1", expected: true},
+		{addr: "198.20.0.1", expected: true},
+		{addr: "0.0.0.0"},
+		{addr: "0.1.2.3"},
+		{addr: "10.0.0.1"},
+		{addr: "100.64.0.1"},
+		{addr: "100.127.255.255"},
+		{addr: "127.0.0.1"},
+		{addr: "127.1.2.3"},
+		{addr: "169.254.169.254"},
+		{addr: "172.16.0.1"},
+		{addr: "172.31.255.255"},
+		{addr: "192.0.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "198.18.0.1"},
+		{addr: "198.19.255.255"},
+		{addr: "224.0.0.1"},
+		{addr: "240.0.0.1"},
+		{addr: "255.255.255.255"},
+		{addr: "::"},
+		{addr: "::1"},
+		{addr: "64:ff9b::7f00:1"},
+		{addr: "64:ff9b::a00:1"},
+		{addr: "2002:7f00:1::1"},
+		{addr: "2002:a00:1::1"},
+		{addr: "fd00::1"},
+		{addr: "fe80::1"},
+		{addr: "ff02::1"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "::ffff:10.0.0.1"},
+		{addr: "::ffff:100.64.0.1"},
+		{addr: "::ffff:0.0.0.0"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.addr, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, IsPublicAddr(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package alert
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/ratehistory"
+)
+
+const (
+	_alertIDSize = 8
+	// The window of the change alerts
+	_changeWindow = 24 * time.Hour
+)
+
+var (
+	ErrInvalidKind     = errors.New("unknown alert kind")
+	ErrInvalidValue    = errors.New("alert value must be positive")
+	ErrInvalidCooldown = errors.New("alert cooldown must not be negative")
+	ErrUnavailablePair = errors.New("cannot get the rate of the alert pair")
+	ErrInvalidToken    = errors.New("invalid subscriber token")
+	ErrNotSubscribed   = errors.New("email is not subscribed")
+	ErrTooManyAlerts   = errors.New("too many alerts for the subscriber")
+	ErrAlertNotFound   = errors.New("alert not found")
+	ErrAlertRepository = errors.New("alert repository error")
+	ErrSendAlert       = errors.New("cannot send alert")
+)
+
+type AlertConfig struct {
+	// Run the worker checking the alerts
+	Enabled       bool          `default:"true"`
+	CheckInterval time.Duration `default:"1m"`
+	// The cooldown of the alerts which don't set their own
+	Cooldown         time.Duration `default:"1h"`
+	MaxPerSubscriber int           `default:"10"`
+}
+
+type Repository interface {
+	Add(alert *port.Alert) error
+	Update(alert *port.Alert) error
+	// The batch skips the alerts no longer stored
+	UpdateAll(alerts []port.Alert) error
+	Remove(alert *port.Alert) error
+	FindByID(id string) (*port.Alert, error)
+	FindByEmail(email string) ([]port.Alert, error)
+	All() ([]port.Alert, error)
+}
+
+type SubscriberRepository interface {
+	FindByEmail(email string) (*port.User, error)
+}
+
+// TokenVerifier checks that the token was issued by the application
+// for the given email, i.e. the management token of the subscriber
+type TokenVerifier interface {
+	Verify(email, token string) bool
+}
+
+type RateService interface {
+	ExchangeRate(pair port.CurrencyPair) (rate port.Rate, err error)
+}
+
+type RateHistory interface {
+	RateAt(pair port.CurrencyPair, at time.Time) (port.Rate, error)
+}
+
+type Notifier interface {
+	SendAlert(notice port.AlertNotice) error
+}
+
+type Service struct {
+	config        AlertConfig
+	logger        port.Logger
+	repository    Repository
+	subscribers   SubscriberRepository
+	tokenVerifier TokenVerifier
+	rateService   RateService
+	rateHistory   RateHistory
+	notifier      Notifier
+	now           func() time.Time
+}
+
+func NewService(
+	config AlertConfig,
+	logger port.Logger,
+	repository Repository,
+	subscribers SubscriberRepository,
+	tokenVerifier TokenVerifier,
+	rateService RateService,
+	rateHistory RateHistory,
+	notifier Notifier,
+) *Service {
+	return &Service{
+		config:        config,
+		logger:        logger,
+		repository:    repository,
+		subscribers:   subscribers,
+		tokenVerifier: tokenVerifier,
+		rateService:   rateService,
+		rateHistory:   rateHistory,
+		notifier:      notifier,
+		now:           time.Now,
+	}
+}
+
+// Create validates the alert of the confirmed subscriber the token
+// was issued for and stores it with a new ID. The rate of the pair
+// must be available, otherwise the alert would never be checked.
+func (s *Service) Create(alert *port.Alert, token string) error {
+	if !s.tokenVerifier.Verify(alert.Email, token) {
+		return ErrInvalidToken
+	}
+
+	if err := validate(alert); err != nil {
+		return err
+	}
+
+	if _, err := s.rateService.ExchangeRate(alert.Pair); err != nil {
+		return fmt.Errorf("%w %v: %w", ErrUnavailablePair, alert.Pair, err)
+	}
+
+	subscriber, err := s.subscribers.FindByEmail(alert.Email)
+	if errors.Is(err, port.ErrCannotFindByEmail) || err == nil && !subscriber.IsActive() {
+		return ErrNotSubscribed
+	}
+
+	if err != nil {
+		return err
+	}
+
+	alerts, err := s.repository.FindByEmail(alert.Email)
+	if err != nil {
+		return errors.Join(err, ErrAlertRepository)
+	}
+
+	if len(alerts) >= s.config.MaxPerSubscriber {
+		return ErrTooManyAlerts
+	}
+
+	id := make([]byte, _alertIDSize)
+	if _, err = rand.Read(id); err != nil {
+		return err
+	}
+
+	alert.ID = hex.EncodeToString(id)
+	alert.LastRate = 0
+	alert.LastTriggeredAt = time.Time{}
+
+	if err = s.repository.Add(alert); err != nil {
+		return errors.Join(err, ErrAlertRepository)
+	}
+
+	return nil
+}
+
+// Alerts returns the alerts of the subscriber the token was issued for
+func (s *Service) Alerts(email, token string) ([]port.Alert, error) {
+	if !s.tokenVerifier.Verify(email, token) {
+		return nil, ErrInvalidToken
+	}
+
+	alerts, err := s.repository.FindByEmail(email)
+	if err != nil {
+		return nil, errors.Join(err, ErrAlertRepository)
+	}
+
+	return alerts, nil
+}
+
+// Delete removes the alert if it belongs to the subscriber
+// the token was issued for
+func (s *Service) Delete(email, token, id string) error {
+	if !s.tokenVerifier.Verify(email, token) {
+		return ErrInvalidToken
+	}
+
+	alert, err := s.repository.FindByID(id)
+	if errors.Is(err, port.ErrCannotFindAlert) || err == nil && alert.Email != email {
+		return ErrAlertNotFound
+	}
+
+	if err != nil {
+		return errors.Join(err, ErrAlertRepository)
+	}
+
+	if err = s.repository.Remove(alert); err != nil {
+		return errors.Join(err, ErrAlertRepository)
+	}
+
+	return nil
+}
+
+// RemoveByEmail removes the alerts of the subscriber, e.g. once
+// the subscriber has unsubscribed
+func (s *Service) RemoveByEmail(email string) error {
+	alerts, err := s.repository.FindByEmail(email)
+	if err != nil {
+		return errors.Join(err, ErrAlertRepository)
+	}
+
+	for i := range alerts {
+		if err = s.repository.Remove(&alerts[i]); err != nil {
+			return errors.Join(err, ErrAlertRepository)
+		}
+	}
+
+	return nil
+}
+
+// Run checks the alerts every check interval until the context
+// is canceled
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.Check(); err != nil {
+				s.logger.Errorf("Error, alerts check: %v", err)
+			}
+		}
+	}
+}
+
+// Check evaluates all the alerts against the current rates and notifies
+// the subscribers of the fired ones. An alert fired within its cooldown
+// is not notified again. The rate of a pair is fetched once, even if it
+// fails, and the changed alerts are stored at once.
+func (s *Service) Check() error {
+	alerts, err := s.repository.All()
+	if err != nil {
+		return errors.Join(err, ErrAlertRepository)
+	}
+
+	now := s.now()
+	rates := make(map[port.CurrencyPair]port.Rate)
+	failed := make(map[port.CurrencyPair]bool)
+
+	var (
+		changed []port.Alert
+		errs    []error
+	)
+	for i := range alerts {
+		alert := &alerts[i]
+		if failed[alert.Pair] {
+			continue
+		}
+
+		rate, ok := rates[alert.Pair]
+		if !ok {
+			rate, err = s.rateService.ExchangeRate(alert.Pair)
+			if err != nil {
+				failed[alert.Pair] = true
+				errs = append(errs, fmt.Errorf("%v: %w", alert.Pair, err))
+				continue
+			}
+			rates[alert.Pair] = rate
+		}
+
+		updated, err := s.check(alert, rate, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert %s: %w", alert.ID, err))
+		}
+
+		if updated {
+			changed = append(changed, *alert)
+		}
+	}
+
+	// The alerts deleted while they were checked are skipped
+	if err = s.repository.UpdateAll(changed); err != nil {
+		errs = append(errs, errors.Join(err, ErrAlertRepository))
+	}
+
+	return errors.Join(errs...)
+}
+
+// check evaluates the alert and tells whether it has changed
+// and has to be stored
+func (s *Service) check(alert *port.Alert, rate port.Rate, now time.Time) (bool, error) {
+	lastRate := alert.LastRate
+	alert.LastRate = rate.Value
+
+	notice, fired, err := s.evaluate(alert, lastRate, rate, now)
+	if err != nil {
+		return false, err
+	}
+
+	triggered := false
+	if fired && s.cooledDown(alert, now) {
+		triggered, err = s.notify(alert, rate, notice)
+		if err != nil {
+			return false, err
+		}
+
+		if triggered {
+			alert.LastTriggeredAt = now
+		}
+	}
+
+	return alert.LastRate != lastRate || triggered, nil
+}
+
+// evaluate tells whether the alert has fired and describes what happened
+// in the notice
+func (s *Service) evaluate(
+	alert *port.Alert,
+	lastRate float32,
+	rate port.Rate,
+	now time.Time,
+) (notice port.AlertNotice, fired bool, err error) {
+	switch alert.Kind {
+	case port.AlertKindThreshold:
+		// The first check has nothing to compare with
+		if lastRate == 0 {
+			return notice, false, nil
+		}
+
+		wasAbove := float64(lastRate) >= alert.Value
+		isAbove := float64(rate.Value) >= alert.Value
+		if wasAbove == isAbove {
+			return notice, false, nil
+		}
+
+		direction := "fell below"
+		if isAbove {
+			direction = "rose above"
+		}
+
+		notice.Message = fmt.Sprintf("%v %s %v", alert.Pair, direction, alert.Value)
+		notice.Rose = isAbove
+
+		return notice, true, nil
+	case port.AlertKindChange:
+		past, err := s.rateHistory.RateAt(alert.Pair, now.Add(-_changeWindow))
+		// Not enough history yet
+		if errors.Is(err, ratehistory.ErrNoRate) {
+			return notice, false, nil
+		}
+
+		if err != nil {
+			return notice, false, err
+		}
+
+		if past.Value == 0 {
+			return notice, false, nil
+		}
+
+		change := float64((rate.Value - past.Value) / past.Value * 100)
+		if math.Abs(change) < alert.Value {
+			return notice, false, nil
+		}
+
+		notice.Message = fmt.Sprintf("%v changed by %+.2f%% within 24h", alert.Pair, change)
+		notice.Change = change
+
+		return notice, true, nil
+	default:
+		return notice, false, fmt.Errorf("%w: %q", ErrInvalidKind, alert.Kind)
+	}
+}
+
+func (s *Service) cooledDown(alert *port.Alert, now time.Time) bool {
+	cooldown := alert.Cooldown
+	if cooldown == 0 {
+		cooldown = s.config.Cooldown
+	}
+
+	return alert.LastTriggeredAt.IsZero() ||
+		now.Sub(alert.LastTriggeredAt) >= cooldown
+}
+
+// notify sends the notice to the subscriber in their language. The alerts
+// of a subscriber who has unsubscribed are removed and not sent.
+func (s *Service) notify(
+	alert *port.Alert,
+	rate port.Rate,
+	notice port.AlertNotice,
+) (sent bool, err error) {
+	subscriber, err := s.subscribers.FindByEmail(alert.Email)
+	if errors.Is(err, port.ErrCannotFindByEmail) {
+		err = s.repository.Remove(alert)
+		if err != nil && !errors.Is(err, port.ErrCannotFindAlert) {
+			return false, errors.Join(err, ErrAlertRepository)
+		}
+
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if !subscriber.IsActive() {
+		return false, nil
+	}
+
+	notice.Alert = *alert
+	notice.Rate = rate
+	notice.Language = subscriber.Preferences.Language
+
+	err = s.notifier.SendAlert(notice)
+	if err != nil {
+		return false, errors.Join(err, ErrSendAlert)
+	}
+
+	return true, nil
+}
+
+func validate(alert *port.Alert) error {
+	if alert.Kind != port.AlertKindThreshold && alert.Kind != port.AlertKindChange {
+		return fmt.Errorf("%w: %q", ErrInvalidKind, alert.Kind)
+	}
+
+	if alert.Value <= 0 || math.IsInf(alert.Value, 0) || math.IsNaN(alert.Value) {
+		return ErrInvalidValue
+	}
+
+	if alert.Cooldown < 0 {
+		return ErrInvalidCooldown
+	}
+
+	return nil
+}
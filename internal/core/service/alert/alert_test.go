@@ -0,0 +1,659 @@
+package alert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/ratehistory"
+)
+
+var (
+	errSendAlert  = errors.New("send alert error")
+	errRate       = errors.New("rate error")
+	errRepository = errors.New("repository error")
+)
+
+type StubLogger struct{}
+
+func (s *StubLogger) Info(...interface{})           {}
+func (s *StubLogger) Infof(string, ...interface{})  {}
+func (s *StubLogger) Debug(...interface{})          {}
+func (s *StubLogger) Debugf(string, ...interface{}) {}
+func (s *StubLogger) Error(...interface{})          {}
+func (s *StubLogger) Errorf(string, ...interface{}) {}
+
+type StubRepository struct {
+	Alerts []port.Alert
+	Err    error
+	// The calls of UpdateAll
+	batches int
+}
+
+func (s *StubRepository) Add(alert *port.Alert) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Alerts = append(s.Alerts, *alert)
+	return nil
+}
+
+func (s *StubRepository) Update(alert *port.Alert) error {
+	for i, a := range s.Alerts {
+		if a.ID == alert.ID {
+			s.Alerts[i] = *alert
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindAlert
+}
+
+func (s *StubRepository) UpdateAll(alerts []port.Alert) error {
+	if len(alerts) > 0 {
+		s.batches++
+	}
+
+	for _, alert := range alerts {
+		for i, a := range s.Alerts {
+			if a.ID == alert.ID {
+				s.Alerts[i] = alert
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *StubRepository) Remove(alert *port.Alert) error {
+	for i, a := range s.Alerts {
+		if a.ID == alert.ID {
+			s.Alerts = append(s.Alerts[:i], s.Alerts[i+1:]...)
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindAlert
+}
+
+func (s *StubRepository) FindByID(id string) (*port.Alert, error) {
+	for _, a := range s.Alerts {
+		if a.ID == id {
+			alert := a
+			return &alert, nil
+		}
+	}
+
+	return &port.Alert{}, port.ErrCannotFindAlert
+}
+
+func (s *StubRepository) FindByEmail(email string) ([]port.Alert, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	alerts := []port.Alert{}
+	for _, a := range s.Alerts {
+		if a.Email == email {
+			alerts = append(alerts, a)
+		}
+	}
+
+	return alerts, nil
+}
+
+func (s *StubRepository) All() ([]port.Alert, error) {
+	return append([]port.Alert(nil), s.Alerts...), s.Err
+}
+
+type StubSubscribers struct {
+	Users []port.User
+}
+
+func (s *StubSubscribers) FindByEmail(email string) (*port.User, error) {
+	for _, u := range s.Users {
+		if u.Email == email {
+			user := u
+			return &user, nil
+		}
+	}
+
+	return &port.User{}, port.ErrCannotFindByEmail
+}
+
+type StubTokenVerifier struct {
+	Valid bool
+}
+
+func (s *StubTokenVerifier) Verify(email, token string) bool {
+	return s.Valid
+}
+
+type StubRateService struct {
+	Rate port.Rate
+	Err  error
+	// The pairs whose rate fails with the error
+	PairErrs map[port.CurrencyPair]error
+	calls    int
+}
+
+func (s *StubRateService) ExchangeRate(pair port.CurrencyPair) (port.Rate, error) {
+	s.calls++
+	if err := s.PairErrs[pair]; err != nil {
+		return port.Rate{}, err
+	}
+
+	return s.Rate, s.Err
+}
+
+type StubRateHistory struct {
+	Rate port.Rate
+	Err  error
+}
+
+func (s *StubRateHistory) RateAt(pair port.CurrencyPair, at time.Time) (port.Rate, error) {
+	return s.Rate, s.Err
+}
+
+type StubNotifier struct {
+	Sent []port.AlertNotice
+	Err  error
+}
+
+func (s *StubNotifier) SendAlert(notice port.AlertNotice) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Sent = append(s.Sent, notice)
+	return nil
+}
+
+var (
+	_now    = time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	_config = AlertConfig{Cooldown: time.Hour, MaxPerSubscriber: 2}
+
+	_activeUser = port.User{
+		Email:       "active@example.com",
+		Status:      port.UserStatusActive,
+		Preferences: port.Preferences{Language: "uk"},
+	}
+	_pendingUser = port.User{Email: "pending@example.com", Status: port.UserStatusPending}
+)
+
+func newTestService(
+	repository *StubRepository,
+	tokenVerifier *StubTokenVerifier,
+	rateService *StubRateService,
+	rateHistory *StubRateHistory,
+	notifier *StubNotifier,
+) *Service {
+	service := NewService(
+		_config,
+		&StubLogger{},
+		repository,
+		&StubSubscribers{Users: []port.User{_activeUser, _pendingUser}},
+		tokenVerifier,
+		rateService,
+		rateHistory,
+		notifier,
+	)
+	service.now = func() time.Time { return _now }
+
+	return service
+}
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+
+	threshold := port.Alert{
+		Email: _activeUser.Email,
+		Pair:  port.DefaultCurrencyPair,
+		Kind:  port.AlertKindThreshold,
+		Value: 1000000,
+	}
+
+	withChanges := func(change func(alert *port.Alert)) port.Alert {
+		alert := threshold
+		change(&alert)
+		return alert
+	}
+
+	tests := []struct {
+		name           string
+		alert          port.Alert
+		validToken     bool
+		existingAlerts []port.Alert
+		rateErr        error
+		expectedErr    error
+	}{
+		{
+			name:       "Threshold alert",
+			alert:      threshold,
+			validToken: true,
+		},
+		{
+			name: "Change alert with a cooldown",
+			alert: withChanges(func(alert *port.Alert) {
+				alert.Kind = port.AlertKindChange
+				alert.Value = 5
+				alert.Cooldown = 6 * time.Hour
+			}),
+			validToken: true,
+		},
+		{
+			name:        "Invalid token",
+			alert:       threshold,
+			validToken:  false,
+			expectedErr: ErrInvalidToken,
+		},
+		{
+			name: "Unknown kind",
+			alert: withChanges(func(alert *port.Alert) {
+				alert.Kind = "above"
+			}),
+			validToken:  true,
+			expectedErr: ErrInvalidKind,
+		},
+		{
+			name: "Zero value",
+			alert: withChanges(func(alert *port.Alert) {
+				alert.Value = 0
+			}),
+			validToken:  true,
+			expectedErr: ErrInvalidValue,
+		},
+		{
+			name: "Negative cooldown",
+			alert: withChanges(func(alert *port.Alert) {
+				alert.Cooldown = -time.Minute
+			}),
+			validToken:  true,
+			expectedErr: ErrInvalidCooldown,
+		},
+		{
+			name:        "Unavailable pair",
+			alert:       threshold,
+			validToken:  true,
+			rateErr:     errRate,
+			expectedErr: ErrUnavailablePair,
+		},
+		{
+			name: "Unknown subscriber",
+			alert: withChanges(func(alert *port.Alert) {
+				alert.Email = "unknown@example.com"
+			}),
+			validToken:  true,
+			expectedErr: ErrNotSubscribed,
+		},
+		{
+			name: "Pending subscriber",
+			alert: withChanges(func(alert *port.Alert) {
+				alert.Email = _pendingUser.Email
+			}),
+			validToken:  true,
+			expectedErr: ErrNotSubscribed,
+		},
+		{
+			name:       "Too many alerts",
+			alert:      threshold,
+			validToken: true,
+			existingAlerts: []port.Alert{
+				{ID: "1", Email: _activeUser.Email},
+				{ID: "2", Email: _activeUser.Email},
+			},
+			expectedErr: ErrTooManyAlerts,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repository := &StubRepository{Alerts: tt.existingAlerts}
+			service := newTestService(
+				repository,
+				&StubTokenVerifier{Valid: tt.validToken},
+				&StubRateService{Err: tt.rateErr},
+				&StubRateHistory{},
+				&StubNotifier{},
+			)
+
+			alert := tt.alert
+			err := service.Create(&alert, "token")
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr != nil {
+				require.Equal(t, tt.existingAlerts, repository.Alerts)
+				return
+			}
+
+			require.NotEmpty(t, alert.ID)
+			require.Equal(t, []port.Alert{alert}, repository.Alerts)
+		})
+	}
+}
+
+func TestAlertsAndDelete(t *testing.T) {
+	t.Parallel()
+
+	own := port.Alert{ID: "own", Email: _activeUser.Email}
+	foreign := port.Alert{ID: "foreign", Email: "other@example.com"}
+
+	tests := []struct {
+		name          string
+		id            string
+		validToken    bool
+		expectedErr   error
+		expectedCount int
+	}{
+		{name: "Delete own alert", id: "own", validToken: true, expectedCount: 1},
+		{
+			name:          "Delete foreign alert",
+			id:            "foreign",
+			validToken:    true,
+			expectedErr:   ErrAlertNotFound,
+			expectedCount: 2,
+		},
+		{
+			name:          "Delete unknown alert",
+			id:            "unknown",
+			validToken:    true,
+			expectedErr:   ErrAlertNotFound,
+			expectedCount: 2,
+		},
+		{
+			name:          "Invalid token",
+			id:            "own",
+			validToken:    false,
+			expectedErr:   ErrInvalidToken,
+			expectedCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repository := &StubRepository{Alerts: []port.Alert{own, foreign}}
+			service := newTestService(
+				repository,
+				&StubTokenVerifier{Valid: tt.validToken},
+				&StubRateService{},
+				&StubRateHistory{},
+				&StubNotifier{},
+			)
+
+			alerts, err := service.Alerts(_activeUser.Email, "token")
+			if tt.validToken {
+				require.NoError(t, err)
+				require.Equal(t, []port.Alert{own}, alerts)
+			} else {
+				require.ErrorIs(t, err, ErrInvalidToken)
+			}
+
+			err = service.Delete(_activeUser.Email, "token", tt.id)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Len(t, repository.Alerts, tt.expectedCount)
+		})
+	}
+}
+
+func TestRemoveByEmail(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{Alerts: []port.Alert{
+		{ID: "alert1", Email: _activeUser.Email},
+		{ID: "alert2", Email: "other@example.com"},
+		{ID: "alert3", Email: _activeUser.Email},
+	}}
+	service := newTestService(
+		repository,
+		&StubTokenVerifier{},
+		&StubRateService{},
+		&StubRateHistory{},
+		&StubNotifier{},
+	)
+
+	require.NoError(t, service.RemoveByEmail(_activeUser.Email))
+	require.Equal(t, []port.Alert{{ID: "alert2", Email: "other@example.com"}}, repository.Alerts)
+
+	repository.Err = errRepository
+	require.ErrorIs(t, service.RemoveByEmail(_activeUser.Email), ErrAlertRepository)
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	rate := func(value float32) port.Rate {
+		return port.Rate{Pair: port.DefaultCurrencyPair, Value: value}
+	}
+
+	threshold := func(lastRate float32, lastTriggeredAt time.Time) port.Alert {
+		return port.Alert{
+			ID:              "threshold",
+			Email:           _activeUser.Email,
+			Pair:            port.DefaultCurrencyPair,
+			Kind:            port.AlertKindThreshold,
+			Value:           100,
+			LastRate:        lastRate,
+			LastTriggeredAt: lastTriggeredAt,
+		}
+	}
+
+	change := port.Alert{
+		ID:    "change",
+		Email: _activeUser.Email,
+		Pair:  port.DefaultCurrencyPair,
+		Kind:  port.AlertKindChange,
+		Value: 5,
+	}
+
+	tests := []struct {
+		name             string
+		alert            port.Alert
+		rate             port.Rate
+		rateErr          error
+		pastRate         port.Rate
+		pastRateErr      error
+		notifierErr      error
+		expectedMessages []string
+		expectedAlerts   []port.Alert
+		expectedErr      error
+	}{
+		{
+			name:           "First check of a threshold",
+			alert:          threshold(0, time.Time{}),
+			rate:           rate(101),
+			expectedAlerts: []port.Alert{threshold(101, time.Time{})},
+		},
+		{
+			name:             "Rate rose above the threshold",
+			alert:            threshold(99, time.Time{}),
+			rate:             rate(100),
+			expectedMessages: []string{"BTC/UAH rose above 100"},
+			expectedAlerts:   []port.Alert{threshold(100, _now)},
+		},
+		{
+			name:             "Rate fell below the threshold",
+			alert:            threshold(101, time.Time{}),
+			rate:             rate(99),
+			expectedMessages: []string{"BTC/UAH fell below 100"},
+			expectedAlerts:   []port.Alert{threshold(99, _now)},
+		},
+		{
+			name:           "Rate stayed above the threshold",
+			alert:          threshold(101, time.Time{}),
+			rate:           rate(102),
+			expectedAlerts: []port.Alert{threshold(102, time.Time{})},
+		},
+		{
+			name:  "Threshold crossed within the cooldown",
+			alert: threshold(101, _now.Add(-30*time.Minute)),
+			rate:  rate(99),
+			expectedAlerts: []port.Alert{
+				threshold(99, _now.Add(-30*time.Minute)),
+			},
+		},
+		{
+			name: "Threshold crossed after the own cooldown",
+			alert: func() port.Alert {
+				alert := threshold(101, _now.Add(-30*time.Minute))
+				alert.Cooldown = 15 * time.Minute
+				return alert
+			}(),
+			rate:             rate(99),
+			expectedMessages: []string{"BTC/UAH fell below 100"},
+			expectedAlerts: []port.Alert{func() port.Alert {
+				alert := threshold(99, _now)
+				alert.Cooldown = 15 * time.Minute
+				return alert
+			}()},
+		},
+		{
+			name:             "Rate changed more than the percent",
+			alert:            change,
+			rate:             rate(110),
+			pastRate:         rate(100),
+			expectedMessages: []string{"BTC/UAH changed by +10.00% within 24h"},
+			expectedAlerts: []port.Alert{func() port.Alert {
+				alert := change
+				alert.LastRate = 110
+				alert.LastTriggeredAt = _now
+				return alert
+			}()},
+		},
+		{
+			name:     "Rate changed less than the percent",
+			alert:    change,
+			rate:     rate(96),
+			pastRate: rate(100),
+			expectedAlerts: []port.Alert{func() port.Alert {
+				alert := change
+				alert.LastRate = 96
+				return alert
+			}()},
+		},
+		{
+			name:        "No rate a day ago",
+			alert:       change,
+			rate:        rate(200),
+			pastRateErr: ratehistory.ErrNoRate,
+			expectedAlerts: []port.Alert{func() port.Alert {
+				alert := change
+				alert.LastRate = 200
+				return alert
+			}()},
+		},
+		{
+			name: "Alert of an unsubscribed email",
+			alert: func() port.Alert {
+				alert := threshold(99, time.Time{})
+				alert.Email = "unsubscribed@example.com"
+				return alert
+			}(),
+			rate:           rate(101),
+			expectedAlerts: []port.Alert{},
+		},
+		{
+			name:           "Notifier error",
+			alert:          threshold(99, time.Time{}),
+			rate:           rate(101),
+			notifierErr:    errSendAlert,
+			expectedAlerts: []port.Alert{threshold(99, time.Time{})},
+			expectedErr:    ErrSendAlert,
+		},
+		{
+			name:           "Rate error",
+			alert:          threshold(99, time.Time{}),
+			rateErr:        errRate,
+			expectedAlerts: []port.Alert{threshold(99, time.Time{})},
+			expectedErr:    errRate,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repository := &StubRepository{Alerts: []port.Alert{tt.alert}}
+			notifier := &StubNotifier{Err: tt.notifierErr}
+			service := newTestService(
+				repository,
+				&StubTokenVerifier{},
+				&StubRateService{Rate: tt.rate, Err: tt.rateErr},
+				&StubRateHistory{Rate: tt.pastRate, Err: tt.pastRateErr},
+				notifier,
+			)
+
+			err := service.Check()
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedAlerts, repository.Alerts)
+
+			var messages []string
+			for _, notice := range notifier.Sent {
+				require.Equal(t, tt.rate, notice.Rate)
+				require.Equal(t, "uk", notice.Language)
+				messages = append(messages, notice.Message)
+			}
+			require.Equal(t, tt.expectedMessages, messages)
+		})
+	}
+}
+
+func TestCheckManyAlerts(t *testing.T) {
+	t.Parallel()
+
+	usd := port.CurrencyPair{Base: "BTC", Quote: "USD"}
+	alert := func(id string, pair port.CurrencyPair, lastRate float32) port.Alert {
+		return port.Alert{
+			ID:       id,
+			Email:    _activeUser.Email,
+			Pair:     pair,
+			Kind:     port.AlertKindThreshold,
+			Value:    100,
+			LastRate: lastRate,
+		}
+	}
+
+	repository := &StubRepository{Alerts: []port.Alert{
+		alert("uah1", port.DefaultCurrencyPair, 98),
+		alert("usd1", usd, 98),
+		alert("uah2", port.DefaultCurrencyPair, 98),
+		alert("usd2", usd, 98),
+	}}
+	rateService := &StubRateService{
+		Rate:     port.Rate{Pair: port.DefaultCurrencyPair, Value: 99},
+		PairErrs: map[port.CurrencyPair]error{usd: errRate},
+	}
+	service := newTestService(
+		repository,
+		&StubTokenVerifier{},
+		rateService,
+		&StubRateHistory{},
+		&StubNotifier{},
+	)
+
+	err := service.Check()
+
+	require.ErrorIs(t, err, errRate)
+	// The failed pair isn't fetched again for its next alert
+	require.Equal(t, 2, rateService.calls)
+	// The changed alerts are stored at once
+	require.Equal(t, 1, repository.batches)
+	require.Equal(t, []port.Alert{
+		alert("uah1", port.DefaultCurrencyPair, 99),
+		alert("usd1", usd, 98),
+		alert("uah2", port.DefaultCurrencyPair, 99),
+		alert("usd2", usd, 98),
+	}, repository.Alerts)
+}
@@ -0,0 +1,262 @@
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+const _jobIDSize = 8
+
+var (
+	ErrJobNotFound   = errors.New("job not found")
+	ErrJobRepository = errors.New("job repository error")
+	// The process stopped while the job was running, so some of its
+	// emails may never be sent
+	ErrJobInterrupted = errors.New("job was interrupted by a restart")
+)
+
+type JobConfig struct {
+	// How often the queued jobs are checked for
+	PollInterval time.Duration `default:"1s"`
+	// How long the finished jobs are kept for polling
+	Retention time.Duration `default:"24h"`
+}
+
+type Repository interface {
+	Add(job *port.Job) error
+	Update(job *port.Job) error
+	Remove(job *port.Job) error
+	FindByID(id string) (*port.Job, error)
+	All() ([]port.Job, error)
+}
+
+type RateService interface {
+	ExchangeRate(pair port.CurrencyPair) (rate port.Rate, err error)
+}
+
+type SubscriptionService interface {
+	Subscriptions() (subscribers []port.User, err error)
+}
+
+// EmailQueue queues the emails of the job, the outcome of every email
+// is recorded by the tracker once it's known. The emails which couldn't
+// be queued are reported by a port.DeliveryError.
+type EmailQueue interface {
+	EnqueueJob(jobID string, rate port.Rate, subscribers []port.User) error
+}
+
+// Sender sends the rate through the other channels, e.g. to the webhooks
+type Sender interface {
+	SendExchangeRate(rate port.Rate, subscribers ...port.User) error
+}
+
+type Service struct {
+	config        JobConfig
+	logger        port.Logger
+	tracker       *Tracker
+	rateService   RateService
+	subscriptions SubscriptionService
+	emails        EmailQueue
+	channels      Sender
+	now           func() time.Time
+}
+
+func NewService(
+	config JobConfig,
+	logger port.Logger,
+	tracker *Tracker,
+	rateService RateService,
+	subscriptions SubscriptionService,
+	emails EmailQueue,
+	channels Sender,
+) *Service {
+	return &Service{
+		config:        config,
+		logger:        logger,
+		tracker:       tracker,
+		rateService:   rateService,
+		subscriptions: subscriptions,
+		emails:        emails,
+		channels:      channels,
+		now:           time.Now,
+	}
+}
+
+// Enqueue adds the job sending the rate to all the subscribers,
+// it's run by the worker
+func (s *Service) Enqueue() (*port.Job, error) {
+	id := make([]byte, _jobIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	job := &port.Job{
+		ID:        hex.EncodeToString(id),
+		Status:    port.JobStatusQueued,
+		CreatedAt: s.now(),
+	}
+
+	if err := s.tracker.repository.Add(job); err != nil {
+		return nil, errors.Join(err, ErrJobRepository)
+	}
+
+	return job, nil
+}
+
+func (s *Service) Job(id string) (*port.Job, error) {
+	job, err := s.tracker.repository.FindByID(id)
+	if errors.Is(err, port.ErrCannotFindJob) {
+		return nil, ErrJobNotFound
+	}
+
+	if err != nil {
+		return nil, errors.Join(err, ErrJobRepository)
+	}
+
+	return job, nil
+}
+
+// Run runs the queued jobs every poll interval until the context
+// is canceled. The jobs left running by the previous process are
+// failed first.
+func (s *Service) Run(ctx context.Context) error {
+	if err := s.Recover(); err != nil {
+		s.logger.Errorf("Error, jobs: %v", err)
+	}
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.Process(); err != nil {
+				s.logger.Errorf("Error, jobs: %v", err)
+			}
+		}
+	}
+}
+
+// Recover fails the jobs which were running when the process stopped,
+// as nothing would finish them. They aren't run again, since that would
+// send the rate twice to the subscribers who have got it. The emails
+// already in the outbox are still sent and counted.
+func (s *Service) Recover() error {
+	jobs, err := s.tracker.repository.All()
+	if err != nil {
+		return errors.Join(err, ErrJobRepository)
+	}
+
+	var errs []error
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Status != port.JobStatusRunning {
+			continue
+		}
+
+		if err = s.tracker.fail(job, ErrJobInterrupted); err != nil {
+			errs = append(errs, fmt.Errorf("job %s: %w", job.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Process runs the queued jobs in the order they were added
+// and removes the finished ones older than the retention
+func (s *Service) Process() error {
+	jobs, err := s.tracker.repository.All()
+	if err != nil {
+		return errors.Join(err, ErrJobRepository)
+	}
+
+	now := s.now()
+
+	var errs []error
+	for i := range jobs {
+		job := &jobs[i]
+
+		switch {
+		case job.Status == port.JobStatusQueued:
+			err = s.run(job)
+		case job.Finished() && now.Sub(job.FinishedAt) > s.config.Retention:
+			err = s.tracker.repository.Remove(job)
+		default:
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("job %s: %w", job.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// run queues the emails of the job, the job keeps running until
+// all of them are sent. The other channels are sent at once. Every
+// subscriber gets the rate of each pair they asked for.
+func (s *Service) run(job *port.Job) error {
+	subscribers, err := s.subscriptions.Subscriptions()
+	if err != nil {
+		return s.tracker.fail(job, err)
+	}
+
+	groups := port.SubscribersByPair(subscribers)
+
+	// The job sends nothing unless the rates of all the pairs are known
+	rates := make([]port.Rate, len(groups))
+	total := 0
+	for i, group := range groups {
+		rates[i], err = s.rateService.ExchangeRate(group.Pair)
+		if err != nil {
+			return s.tracker.fail(job, err)
+		}
+
+		total += len(port.FilterByChannel(group.Subscribers, port.ChannelEmail))
+	}
+
+	if err = s.tracker.start(job, total); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, group := range groups {
+		errs = append(errs, s.send(job, rates[i], group.Subscribers))
+	}
+
+	return errors.Join(errs...)
+}
+
+// send queues the emails of the pair's rate and sends it through
+// the other channels
+func (s *Service) send(job *port.Job, rate port.Rate, subscribers []port.User) error {
+	var errs []error
+
+	emailSubscribers := port.FilterByChannel(subscribers, port.ChannelEmail)
+	err := s.emails.EnqueueJob(job.ID, rate, emailSubscribers)
+
+	var report *port.DeliveryError
+	switch {
+	case errors.As(err, &report):
+		errs = append(errs, s.tracker.Record(job.ID, 0, len(report.Failures), 0))
+	case err != nil:
+		errs = append(errs, err, s.tracker.Record(job.ID, 0, len(emailSubscribers), 0))
+	}
+
+	// The failures of the other channels aren't counted by the job,
+	// e.g. the webhooks keep their own delivery log
+	if err = s.channels.SendExchangeRate(rate, subscribers...); err != nil {
+		s.logger.Errorf("Error, job %s: %v", job.ID, err)
+	}
+
+	return errors.Join(errs...)
+}
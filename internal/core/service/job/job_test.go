@@ -0,0 +1,518 @@
+package job
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+var (
+	errRate       = errors.New("rate error")
+	errRepository = errors.New("repository error")
+	errSend       = errors.New("send error")
+)
+
+var _now = time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+
+type StubLogger struct{}
+
+func (s *StubLogger) Info(...interface{})           {}
+func (s *StubLogger) Infof(string, ...interface{})  {}
+func (s *StubLogger) Debug(...interface{})          {}
+func (s *StubLogger) Debugf(string, ...interface{}) {}
+func (s *StubLogger) Error(...interface{})          {}
+func (s *StubLogger) Errorf(string, ...interface{}) {}
+
+type StubRepository struct {
+	Jobs []port.Job
+	Err  error
+}
+
+func (s *StubRepository) Add(job *port.Job) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Jobs = append(s.Jobs, *job)
+	return nil
+}
+
+func (s *StubRepository) Update(job *port.Job) error {
+	for i, j := range s.Jobs {
+		if j.ID == job.ID {
+			s.Jobs[i] = *job
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindJob
+}
+
+func (s *StubRepository) Remove(job *port.Job) error {
+	for i, j := range s.Jobs {
+		if j.ID == job.ID {
+			s.Jobs = append(s.Jobs[:i], s.Jobs[i+1:]...)
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindJob
+}
+
+func (s *StubRepository) FindByID(id string) (*port.Job, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	for _, j := range s.Jobs {
+		if j.ID == id {
+			job := j
+			return &job, nil
+		}
+	}
+
+	return &port.Job{}, port.ErrCannotFindJob
+}
+
+func (s *StubRepository) All() ([]port.Job, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	return append([]port.Job{}, s.Jobs...), nil
+}
+
+type StubRateService struct {
+	Err error
+}
+
+func (s *StubRateService) ExchangeRate(pair port.CurrencyPair) (port.Rate, error) {
+	return port.Rate{Pair: pair, Value: 10.5}, s.Err
+}
+
+type StubSubscriptionService struct {
+	Subscribers []port.User
+}
+
+func (s *StubSubscriptionService) Subscriptions() ([]port.User, error) {
+	return s.Subscribers, nil
+}
+
+// StubEmailQueue queues every email but the failed ones
+type StubEmailQueue struct {
+	failed []string
+	err    error
+	queued []string
+	// The queued emails with the pair of their rate, e.g. BTC/UAH a@example.com
+	queuedRates []string
+}
+
+func (s *StubEmailQueue) EnqueueJob(
+	jobID string,
+	rate port.Rate,
+	subscribers []port.User,
+) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	var failures []port.DeliveryFailure
+	for _, subscriber := range subscribers {
+		if contains(s.failed, subscriber.Email) {
+			failures = append(failures, port.DeliveryFailure{
+				Channel:   port.ChannelEmail,
+				Recipient: subscriber.Email,
+				Err:       errSend,
+			})
+			continue
+		}
+		s.queued = append(s.queued, subscriber.Email)
+		s.queuedRates = append(s.queuedRates, rate.Pair.String()+" "+subscriber.Email)
+	}
+
+	return port.NewDeliveryError(0, failures)
+}
+
+type StubSender struct {
+	Err   error
+	calls int
+	pairs []port.CurrencyPair
+}
+
+func (s *StubSender) SendExchangeRate(rate port.Rate, subscribers ...port.User) error {
+	s.calls++
+	s.pairs = append(s.pairs, rate.Pair)
+	return s.Err
+}
+
+type StubEmailSender struct {
+	failed []string
+}
+
+func (s *StubEmailSender) SendExchangeRate(rate port.Rate, subscribers []port.User) error {
+	sent := 0
+	var failures []port.DeliveryFailure
+	for _, subscriber := range subscribers {
+		if contains(s.failed, subscriber.Email) {
+			failures = append(failures, port.DeliveryFailure{
+				Channel:   port.ChannelEmail,
+				Recipient: subscriber.Email,
+				Err:       errSend,
+			})
+			continue
+		}
+		sent++
+	}
+
+	return port.NewDeliveryError(sent, failures)
+}
+
+func contains(emails []string, email string) bool {
+	for _, e := range emails {
+		if e == email {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newTestTracker(repository Repository) *Tracker {
+	tracker := NewTracker(repository)
+	tracker.now = func() time.Time { return _now }
+
+	return tracker
+}
+
+func newTestService(
+	repository Repository,
+	rateService RateService,
+	subscribers []port.User,
+	emails EmailQueue,
+	channels Sender,
+) *Service {
+	service := NewService(
+		JobConfig{PollInterval: time.Second, Retention: time.Hour},
+		&StubLogger{},
+		newTestTracker(repository),
+		rateService,
+		&StubSubscriptionService{Subscribers: subscribers},
+		emails,
+		channels,
+	)
+	service.now = func() time.Time { return _now }
+
+	return service
+}
+
+func TestEnqueue(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{}
+	service := newTestService(repository, &StubRateService{}, nil, &StubEmailQueue{}, &StubSender{})
+
+	job, err := service.Enqueue()
+	require.NoError(t, err)
+	require.NotEmpty(t, job.ID)
+	require.Equal(t, port.JobStatusQueued, job.Status)
+	require.Equal(t, _now, job.CreatedAt)
+
+	found, err := service.Job(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, job, found)
+
+	_, err = service.Job("missing")
+	require.ErrorIs(t, err, ErrJobNotFound)
+
+	repository.Err = errRepository
+	_, err = service.Enqueue()
+	require.ErrorIs(t, err, ErrJobRepository)
+}
+
+func TestProcess(t *testing.T) {
+	t.Parallel()
+
+	subscribers := []port.User{{Email: "a@example.com"}, {Email: "b@example.com"}}
+
+	tests := []struct {
+		name          string
+		rateErr       error
+		subscribers   []port.User
+		emails        *StubEmailQueue
+		channelsErr   error
+		expectedJob   port.Job
+		expectedQueue []string
+	}{
+		{
+			name:        "Emails are queued",
+			subscribers: subscribers,
+			emails:      &StubEmailQueue{},
+			expectedJob: port.Job{
+				ID:        "job1",
+				Status:    port.JobStatusRunning,
+				Total:     2,
+				StartedAt: _now,
+			},
+			expectedQueue: []string{"a@example.com", "b@example.com"},
+		},
+		{
+			name:        "Emails failed to queue are counted",
+			subscribers: subscribers,
+			emails:      &StubEmailQueue{failed: []string{"b@example.com"}},
+			expectedJob: port.Job{
+				ID:        "job1",
+				Status:    port.JobStatusRunning,
+				Total:     2,
+				Failed:    1,
+				StartedAt: _now,
+			},
+			expectedQueue: []string{"a@example.com"},
+		},
+		{
+			name:        "Outbox fails",
+			subscribers: subscribers,
+			emails:      &StubEmailQueue{err: errRepository},
+			expectedJob: port.Job{
+				ID:         "job1",
+				Status:     port.JobStatusCompleted,
+				Total:      2,
+				Failed:     2,
+				StartedAt:  _now,
+				FinishedAt: _now,
+			},
+		},
+		{
+			name:        "Other channels fail",
+			subscribers: subscribers,
+			emails:      &StubEmailQueue{},
+			channelsErr: errSend,
+			expectedJob: port.Job{
+				ID:        "job1",
+				Status:    port.JobStatusRunning,
+				Total:     2,
+				StartedAt: _now,
+			},
+			expectedQueue: []string{"a@example.com", "b@example.com"},
+		},
+		{
+			name:   "No subscribers",
+			emails: &StubEmailQueue{},
+			expectedJob: port.Job{
+				ID:         "job1",
+				Status:     port.JobStatusCompleted,
+				StartedAt:  _now,
+				FinishedAt: _now,
+			},
+		},
+		{
+			name:        "Rate is unavailable",
+			rateErr:     errRate,
+			subscribers: subscribers,
+			emails:      &StubEmailQueue{},
+			expectedJob: port.Job{
+				ID:         "job1",
+				Status:     port.JobStatusFailed,
+				Error:      errRate.Error(),
+				FinishedAt: _now,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repository := &StubRepository{Jobs: []port.Job{
+				{ID: "job1", Status: port.JobStatusQueued},
+			}}
+			channels := &StubSender{Err: tt.channelsErr}
+			service := newTestService(
+				repository,
+				&StubRateService{Err: tt.rateErr},
+				tt.subscribers,
+				tt.emails,
+				channels,
+			)
+
+			err := service.Process()
+			if errors.Is(tt.emails.err, errRepository) {
+				require.ErrorIs(t, err, errRepository)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, []port.Job{tt.expectedJob}, repository.Jobs)
+			require.Equal(t, tt.expectedQueue, tt.emails.queued)
+
+			if tt.rateErr == nil {
+				require.Equal(t, 1, channels.calls)
+			}
+		})
+	}
+}
+
+func TestProcessSendsSubscribedPairs(t *testing.T) {
+	t.Parallel()
+
+	btcUSD, _ := port.NewCurrencyPair("BTC", "USD")
+	ethUAH, _ := port.NewCurrencyPair("ETH", "UAH")
+
+	repository := &StubRepository{Jobs: []port.Job{
+		{ID: "job1", Status: port.JobStatusQueued},
+	}}
+	emails := &StubEmailQueue{}
+	channels := &StubSender{}
+	service := newTestService(
+		repository,
+		&StubRateService{},
+		[]port.User{
+			{Email: "a@example.com"},
+			{
+				Email:       "b@example.com",
+				Preferences: port.Preferences{Pairs: []port.CurrencyPair{btcUSD, ethUAH}},
+			},
+			{
+				Email: "c@example.com",
+				Preferences: port.Preferences{
+					Pairs:   []port.CurrencyPair{btcUSD},
+					Channel: port.ChannelTelegram,
+				},
+			},
+		},
+		emails,
+		channels,
+	)
+
+	require.NoError(t, service.Process())
+
+	require.Equal(t, 3, repository.Jobs[0].Total)
+	require.Equal(t, []string{
+		"BTC/UAH a@example.com",
+		"BTC/USD b@example.com",
+		"ETH/UAH b@example.com",
+	}, emails.queuedRates)
+	require.Equal(t, []port.CurrencyPair{
+		port.DefaultCurrencyPair,
+		btcUSD,
+		ethUAH,
+	}, channels.pairs)
+}
+
+func TestProcessRemovesOldJobs(t *testing.T) {
+	t.Parallel()
+
+	running := port.Job{ID: "running", Status: port.JobStatusRunning, StartedAt: _now.Add(-2 * time.Hour)}
+	recent := port.Job{ID: "recent", Status: port.JobStatusCompleted, FinishedAt: _now.Add(-time.Minute)}
+	repository := &StubRepository{Jobs: []port.Job{
+		{ID: "old", Status: port.JobStatusCompleted, FinishedAt: _now.Add(-2 * time.Hour)},
+		{ID: "failed", Status: port.JobStatusFailed, FinishedAt: _now.Add(-2 * time.Hour)},
+		running,
+		recent,
+	}}
+	service := newTestService(repository, &StubRateService{}, nil, &StubEmailQueue{}, &StubSender{})
+
+	require.NoError(t, service.Process())
+	require.Equal(t, []port.Job{running, recent}, repository.Jobs)
+}
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	queued := port.Job{ID: "queued", Status: port.JobStatusQueued, CreatedAt: _now}
+	completed := port.Job{ID: "completed", Status: port.JobStatusCompleted, FinishedAt: _now}
+	repository := &StubRepository{Jobs: []port.Job{
+		{ID: "running", Status: port.JobStatusRunning, Total: 3, Sent: 1, StartedAt: _now.Add(-time.Hour)},
+		queued,
+		completed,
+	}}
+	emails := &StubEmailQueue{}
+	service := newTestService(repository, &StubRateService{}, nil, emails, &StubSender{})
+
+	require.NoError(t, service.Recover())
+	require.Equal(t, []port.Job{
+		{
+			ID:         "running",
+			Status:     port.JobStatusFailed,
+			Total:      3,
+			Sent:       1,
+			StartedAt:  _now.Add(-time.Hour),
+			FinishedAt: _now,
+			Error:      ErrJobInterrupted.Error(),
+		},
+		queued,
+		completed,
+	}, repository.Jobs)
+
+	job, err := service.Job("running")
+	require.NoError(t, err)
+	require.True(t, job.Finished())
+
+	repository.Err = errRepository
+	require.ErrorIs(t, service.Recover(), ErrJobRepository)
+}
+
+func TestTrackerRecord(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{Jobs: []port.Job{
+		{ID: "job1", Status: port.JobStatusRunning, Total: 3},
+	}}
+	tracker := newTestTracker(repository)
+
+	require.NoError(t, tracker.Record("job1", 1, 0, 0))
+	require.Equal(t, port.JobStatusRunning, repository.Jobs[0].Status)
+	require.Equal(t, 2, repository.Jobs[0].Pending())
+
+	require.NoError(t, tracker.Record("job1", 0, 1, 1))
+	require.Equal(t, port.Job{
+		ID:         "job1",
+		Status:     port.JobStatusCompleted,
+		Total:      3,
+		Sent:       1,
+		Failed:     1,
+		Skipped:    1,
+		FinishedAt: _now,
+	}, repository.Jobs[0])
+
+	// The removed jobs are not tracked
+	require.NoError(t, tracker.Record("missing", 1, 0, 0))
+}
+
+func TestDirectEmails(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{Jobs: []port.Job{
+		{ID: "job1", Status: port.JobStatusQueued},
+	}}
+	tracker := newTestTracker(repository)
+	service := NewService(
+		JobConfig{},
+		&StubLogger{},
+		tracker,
+		&StubRateService{},
+		&StubSubscriptionService{Subscribers: []port.User{
+			{Email: "a@example.com"},
+			{Email: "b@example.com"},
+			{Email: "c@example.com"},
+		}},
+		NewDirectEmails(&StubEmailSender{failed: []string{"b@example.com"}}, tracker),
+		&StubSender{},
+	)
+	service.now = func() time.Time { return _now }
+
+	require.NoError(t, service.Process())
+	require.Equal(t, []port.Job{{
+		ID:         "job1",
+		Status:     port.JobStatusCompleted,
+		Total:      3,
+		Sent:       2,
+		Failed:     1,
+		StartedAt:  _now,
+		FinishedAt: _now,
+	}}, repository.Jobs)
+}
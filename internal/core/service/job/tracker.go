@@ -0,0 +1,124 @@
+package job
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+// Tracker keeps the progress of the jobs. It's shared by the service
+// running the jobs and the workers sending their emails.
+type Tracker struct {
+	mu         sync.Mutex
+	repository Repository
+	now        func() time.Time
+}
+
+func NewTracker(repository Repository) *Tracker {
+	return &Tracker{
+		repository: repository,
+		now:        time.Now,
+	}
+}
+
+// Record adds the outcomes of the job's emails and completes the job
+// once the outcomes of all of them are known
+func (t *Tracker) Record(jobID string, sent, failed, skipped int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, err := t.repository.FindByID(jobID)
+	// The job was finished long ago and removed
+	if errors.Is(err, port.ErrCannotFindJob) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Join(err, ErrJobRepository)
+	}
+
+	job.Sent += sent
+	job.Failed += failed
+	job.Skipped += skipped
+	t.complete(job)
+
+	return t.update(job)
+}
+
+// start marks the job running with the number of the emails to send
+func (t *Tracker) start(job *port.Job, total int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job.Status = port.JobStatusRunning
+	job.Total = total
+	job.StartedAt = t.now()
+	t.complete(job)
+
+	return t.update(job)
+}
+
+// fail marks the job failed, e.g. before any email was sent
+func (t *Tracker) fail(job *port.Job, reason error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job.Status = port.JobStatusFailed
+	job.Error = reason.Error()
+	job.FinishedAt = t.now()
+
+	return t.update(job)
+}
+
+func (t *Tracker) complete(job *port.Job) {
+	if job.Status == port.JobStatusRunning && job.Pending() == 0 {
+		job.Status = port.JobStatusCompleted
+		job.FinishedAt = t.now()
+	}
+}
+
+func (t *Tracker) update(job *port.Job) error {
+	if err := t.repository.Update(job); err != nil {
+		return errors.Join(err, ErrJobRepository)
+	}
+
+	return nil
+}
+
+// EmailSender sends the emails at once, e.g. over SMTP
+type EmailSender interface {
+	SendExchangeRate(rate port.Rate, subscribers []port.User) error
+}
+
+// DirectEmails sends the emails of the job at once instead of queueing
+// them, for when the outbox is disabled
+type DirectEmails struct {
+	sender  EmailSender
+	tracker *Tracker
+}
+
+func NewDirectEmails(sender EmailSender, tracker *Tracker) *DirectEmails {
+	return &DirectEmails{sender: sender, tracker: tracker}
+}
+
+// EnqueueJob sends the emails and records the sent ones, the failed
+// ones are reported by a port.DeliveryError
+func (d *DirectEmails) EnqueueJob(
+	jobID string,
+	rate port.Rate,
+	subscribers []port.User,
+) error {
+	err := d.sender.SendExchangeRate(rate, subscribers)
+
+	var report *port.DeliveryError
+	switch {
+	case err == nil:
+		return d.tracker.Record(jobID, len(subscribers), 0, 0)
+	case errors.As(err, &report):
+		return errors.Join(err, d.tracker.Record(jobID, report.Sent, 0, 0))
+	default:
+		return err
+	}
+}
@@ -0,0 +1,466 @@
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+const _messageIDSize = 8
+
+var (
+	ErrMessageNotFound  = errors.New("dead letter not found")
+	ErrOutboxRepository = errors.New("outbox repository error")
+)
+
+type OutboxConfig struct {
+	// Queue the rate emails and send them by the workers,
+	// otherwise they're sent at once
+	Enabled      bool          `default:"true"`
+	Workers      int           `default:"4"`
+	PollInterval time.Duration `default:"1s"`
+	// How many due emails are sent before their outcomes are stored,
+	// so a crash resends at most a batch
+	BatchSize int `default:"100"`
+	// The failed attempts after which the email is dead-lettered
+	MaxAttempts    int           `default:"5"`
+	InitialBackoff time.Duration `default:"30s"`
+	MaxBackoff     time.Duration `default:"30m"`
+}
+
+type Repository interface {
+	AddAll(messages []port.OutboxMessage) error
+	Update(message *port.OutboxMessage) error
+	Remove(message *port.OutboxMessage) error
+	// The batches skip the messages no longer in the outbox
+	UpdateAll(messages []port.OutboxMessage) error
+	RemoveAll(messages []port.OutboxMessage) error
+	FindByID(id string) (*port.OutboxMessage, error)
+	All() ([]port.OutboxMessage, error)
+}
+
+type SubscriberRepository interface {
+	FindByEmail(email string) (*port.User, error)
+}
+
+// Sender sends the queued emails, e.g. over SMTP
+type Sender interface {
+	SendExchangeRate(rate port.Rate, subscribers []port.User) error
+}
+
+// Progress records the outcomes of the emails queued by the job
+type Progress interface {
+	Record(jobID string, sent, failed, skipped int) error
+}
+
+// Stats describe the state of the outbox
+type Stats struct {
+	Pending int
+	// The pending messages whose next attempt is due
+	Due  int
+	Dead int
+	// The zero time if nothing is pending
+	OldestPendingAt time.Time
+	DeadLetters     []port.OutboxMessage
+}
+
+// Service queues the rate emails in a durable outbox, so they survive
+// a restart and a failing SMTP server, and sends them in the background.
+// The outbox is processed by a single instance of the application.
+type Service struct {
+	config      OutboxConfig
+	logger      port.Logger
+	repository  Repository
+	subscribers SubscriberRepository
+	sender      Sender
+	progress    Progress
+	now         func() time.Time
+}
+
+func NewService(
+	config OutboxConfig,
+	logger port.Logger,
+	repository Repository,
+	subscribers SubscriberRepository,
+	sender Sender,
+	progress Progress,
+) *Service {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+
+	if config.BatchSize < 1 {
+		config.BatchSize = 1
+	}
+
+	return &Service{
+		config:      config,
+		logger:      logger,
+		repository:  repository,
+		subscribers: subscribers,
+		sender:      sender,
+		progress:    progress,
+		now:         time.Now,
+	}
+}
+
+// SendExchangeRate queues an email to every subscriber who wants the rate
+// by email. The subscribers whose emails couldn't be queued are reported
+// by a port.DeliveryError.
+func (s *Service) SendExchangeRate(rate port.Rate, subscribers []port.User) error {
+	return s.EnqueueJob("", rate, subscribers)
+}
+
+// EnqueueJob queues the emails of the job like SendExchangeRate,
+// the outcome of every email is recorded by the progress
+func (s *Service) EnqueueJob(
+	jobID string,
+	rate port.Rate,
+	subscribers []port.User,
+) error {
+	now := s.now()
+
+	subscribers = port.FilterByChannel(subscribers, port.ChannelEmail)
+
+	messages := make([]port.OutboxMessage, 0, len(subscribers))
+	for _, subscriber := range subscribers {
+		id := make([]byte, _messageIDSize)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+
+		messages = append(messages, port.OutboxMessage{
+			ID:            hex.EncodeToString(id),
+			Email:         subscriber.Email,
+			Rate:          rate,
+			Status:        port.OutboxStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			JobID:         jobID,
+		})
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	err := s.repository.AddAll(messages)
+	if err == nil {
+		return nil
+	}
+
+	failures := make([]port.DeliveryFailure, 0, len(subscribers))
+	for _, subscriber := range subscribers {
+		failures = append(failures, port.DeliveryFailure{
+			Channel:   port.ChannelEmail,
+			Recipient: subscriber.Email,
+			Err:       errors.Join(err, ErrOutboxRepository),
+		})
+	}
+
+	return port.NewDeliveryError(0, failures)
+}
+
+// Run sends the due emails every poll interval until the context
+// is canceled
+func (s *Service) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.Dispatch(ctx); err != nil {
+				s.logger.Errorf("Error, outbox dispatch: %v", err)
+			}
+		}
+	}
+}
+
+// Dispatch sends the due emails in batches by the pool of workers and
+// waits for them. A failed email is retried with an exponential backoff,
+// the one which fails permanently or runs out of attempts is dead-lettered.
+// The outcomes of a batch are stored at once before the next one is sent,
+// so draining the outbox neither rewrites it for every email nor loses
+// the outcomes of all the emails sent before a crash.
+func (s *Service) Dispatch(ctx context.Context) error {
+	messages, err := s.repository.All()
+	if err != nil {
+		return errors.Join(err, ErrOutboxRepository)
+	}
+
+	now := s.now()
+	due := make([]port.OutboxMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.Status == port.OutboxStatusPending && !message.NextAttemptAt.After(now) {
+			due = append(due, message)
+		}
+	}
+
+	var errs []error
+	for start := 0; start < len(due) && ctx.Err() == nil; start += s.config.BatchSize {
+		end := start + s.config.BatchSize
+		if end > len(due) {
+			end = len(due)
+		}
+
+		errs = append(errs, s.dispatchBatch(ctx, due[start:end]))
+	}
+
+	return errors.Join(errs...)
+}
+
+// dispatchBatch sends the messages by the pool of workers and stores
+// their outcomes once the workers are done
+func (s *Service) dispatchBatch(ctx context.Context, batch []port.OutboxMessage) error {
+	due := make(chan port.OutboxMessage)
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		deliveries []delivery
+		errs       []error
+	)
+	for i := 0; i < s.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for message := range due {
+				message := message
+				d, err := s.deliver(&message)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("message %s: %w", message.ID, err))
+				} else {
+					deliveries = append(deliveries, d)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, message := range batch {
+		select {
+		case <-ctx.Done():
+			break feed
+		case due <- message:
+		}
+	}
+	close(due)
+	wg.Wait()
+
+	return errors.Join(append(errs, s.save(deliveries))...)
+}
+
+// delivery is the outcome of an attempt to send the message
+type delivery struct {
+	message port.OutboxMessage
+	// The message is removed from the outbox, otherwise it's updated
+	done bool
+	counts
+}
+
+// counts are the outcomes reported to the job
+type counts struct {
+	sent, failed, skipped int
+}
+
+// deliver sends the email unless the subscriber has unsubscribed or
+// turned the emails off since it was queued. A sent or dropped email is
+// done, a failed one is kept for the next attempt or dead-lettered.
+func (s *Service) deliver(message *port.OutboxMessage) (delivery, error) {
+	subscriber, err := s.subscribers.FindByEmail(message.Email)
+	if errors.Is(err, port.ErrCannotFindByEmail) || err == nil && !wantsEmail(subscriber) {
+		return delivery{message: *message, done: true, counts: counts{skipped: 1}}, nil
+	}
+
+	// The subscribers are looked up again on the next attempt
+	if err != nil {
+		return delivery{}, err
+	}
+
+	err = s.sender.SendExchangeRate(message.Rate, []port.User{*subscriber})
+	if err == nil {
+		return delivery{message: *message, done: true, counts: counts{sent: 1}}, nil
+	}
+
+	message.Attempts++
+	message.LastError = err.Error()
+
+	if errors.Is(err, port.ErrPermanentFailure) || message.Attempts >= s.config.MaxAttempts {
+		message.Status = port.OutboxStatusDead
+		message.NextAttemptAt = time.Time{}
+		s.logger.Errorf(
+			"Error, email to %s is dead-lettered after %d attempts: %v",
+			message.Email,
+			message.Attempts,
+			err,
+		)
+
+		return delivery{message: *message, counts: counts{failed: 1}}, nil
+	}
+
+	message.NextAttemptAt = s.now().Add(s.backoff(message.Attempts))
+
+	return delivery{message: *message}, nil
+}
+
+// save removes the done messages and updates the others in two batches,
+// the outcomes are reported to the jobs once they're stored. A message
+// discarded while it was sent isn't stored again.
+func (s *Service) save(deliveries []delivery) error {
+	var done, kept []port.OutboxMessage
+	for _, d := range deliveries {
+		if d.done {
+			done = append(done, d.message)
+		} else {
+			kept = append(kept, d.message)
+		}
+	}
+
+	removeErr := s.repository.RemoveAll(done)
+	updateErr := s.repository.UpdateAll(kept)
+
+	// The counts of the job are summed, so it's updated once
+	jobs := make(map[string]counts)
+	for _, d := range deliveries {
+		stored := removeErr == nil
+		if !d.done {
+			stored = updateErr == nil
+		}
+
+		if !stored || d.message.JobID == "" {
+			continue
+		}
+
+		c := jobs[d.message.JobID]
+		c.sent += d.sent
+		c.failed += d.failed
+		c.skipped += d.skipped
+		jobs[d.message.JobID] = c
+	}
+
+	var errs []error
+	for _, err := range []error{removeErr, updateErr} {
+		if err != nil {
+			errs = append(errs, errors.Join(err, ErrOutboxRepository))
+		}
+	}
+
+	for jobID, c := range jobs {
+		if c == (counts{}) {
+			continue
+		}
+
+		errs = append(errs, s.progress.Record(jobID, c.sent, c.failed, c.skipped))
+	}
+
+	return errors.Join(errs...)
+}
+
+func wantsEmail(subscriber *port.User) bool {
+	return subscriber.IsActive() &&
+		subscriber.Preferences.WantsChannel(port.ChannelEmail)
+}
+
+// backoff doubles the initial backoff on every failed attempt
+// up to the max backoff
+func (s *Service) backoff(attempts int) time.Duration {
+	backoff := s.config.InitialBackoff
+	for i := 1; i < attempts && backoff < s.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > s.config.MaxBackoff {
+		return s.config.MaxBackoff
+	}
+
+	return backoff
+}
+
+// Stats returns the depth of the queue and the dead letters
+func (s *Service) Stats() (*Stats, error) {
+	messages, err := s.repository.All()
+	if err != nil {
+		return nil, errors.Join(err, ErrOutboxRepository)
+	}
+
+	now := s.now()
+	stats := &Stats{DeadLetters: []port.OutboxMessage{}}
+	for _, message := range messages {
+		switch message.Status {
+		case port.OutboxStatusDead:
+			stats.Dead++
+			stats.DeadLetters = append(stats.DeadLetters, message)
+		case port.OutboxStatusPending:
+			stats.Pending++
+			if !message.NextAttemptAt.After(now) {
+				stats.Due++
+			}
+
+			if stats.OldestPendingAt.IsZero() || message.CreatedAt.Before(stats.OldestPendingAt) {
+				stats.OldestPendingAt = message.CreatedAt
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// Retry queues the dead letter again with all the attempts. The job
+// has already counted it as failed, so it's no longer a part of it.
+func (s *Service) Retry(id string) error {
+	message, err := s.deadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	message.JobID = ""
+	message.Status = port.OutboxStatusPending
+	message.Attempts = 0
+	message.NextAttemptAt = s.now()
+
+	if err = s.repository.Update(message); err != nil {
+		return errors.Join(err, ErrOutboxRepository)
+	}
+
+	return nil
+}
+
+// Discard removes the dead letter from the outbox
+func (s *Service) Discard(id string) error {
+	message, err := s.deadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	if err = s.repository.Remove(message); err != nil {
+		return errors.Join(err, ErrOutboxRepository)
+	}
+
+	return nil
+}
+
+func (s *Service) deadLetter(id string) (*port.OutboxMessage, error) {
+	message, err := s.repository.FindByID(id)
+	if errors.Is(err, port.ErrCannotFindOutboxMessage) ||
+		err == nil && message.Status != port.OutboxStatusDead {
+		return nil, ErrMessageNotFound
+	}
+
+	if err != nil {
+		return nil, errors.Join(err, ErrOutboxRepository)
+	}
+
+	return message, nil
+}
@@ -0,0 +1,641 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+var (
+	errRepository = errors.New("repository error")
+	errBusy       = errors.New("421 try again later")
+)
+
+var _now = time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+
+type StubLogger struct{}
+
+func (s *StubLogger) Info(...interface{})           {}
+func (s *StubLogger) Infof(string, ...interface{})  {}
+func (s *StubLogger) Debug(...interface{})          {}
+func (s *StubLogger) Debugf(string, ...interface{}) {}
+func (s *StubLogger) Error(...interface{})          {}
+func (s *StubLogger) Errorf(string, ...interface{}) {}
+
+// StubRepository is used by the workers concurrently
+type StubRepository struct {
+	mu       sync.Mutex
+	Messages []port.OutboxMessage
+	Err      error
+	// The calls of UpdateAll and RemoveAll
+	batches int
+}
+
+func (s *StubRepository) AddAll(messages []port.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Messages = append(s.Messages, messages...)
+	return nil
+}
+
+func (s *StubRepository) Update(message *port.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, m := range s.Messages {
+		if m.ID == message.ID {
+			s.Messages[i] = *message
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindOutboxMessage
+}
+
+func (s *StubRepository) Remove(message *port.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, m := range s.Messages {
+		if m.ID == message.ID {
+			s.Messages = append(s.Messages[:i], s.Messages[i+1:]...)
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindOutboxMessage
+}
+
+func (s *StubRepository) UpdateAll(messages []port.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(messages) > 0 {
+		s.batches++
+	}
+
+	for _, message := range messages {
+		for i, m := range s.Messages {
+			if m.ID == message.ID {
+				s.Messages[i] = message
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *StubRepository) RemoveAll(messages []port.OutboxMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(messages) > 0 {
+		s.batches++
+	}
+
+	for _, message := range messages {
+		for i, m := range s.Messages {
+			if m.ID == message.ID {
+				s.Messages = append(s.Messages[:i], s.Messages[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *StubRepository) FindByID(id string) (*port.OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.Messages {
+		if m.ID == id {
+			message := m
+			return &message, nil
+		}
+	}
+
+	return &port.OutboxMessage{}, port.ErrCannotFindOutboxMessage
+}
+
+func (s *StubRepository) All() ([]port.OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	return append([]port.OutboxMessage{}, s.Messages...), nil
+}
+
+type StubSubscriberRepository struct {
+	Users []port.User
+}
+
+func (s *StubSubscriberRepository) FindByEmail(email string) (*port.User, error) {
+	for _, u := range s.Users {
+		if u.Email == email {
+			user := u
+			return &user, nil
+		}
+	}
+
+	return &port.User{}, port.ErrCannotFindByEmail
+}
+
+// StubSender fails the emails to the addresses of the errs
+type StubSender struct {
+	mu   sync.Mutex
+	errs map[string]error
+	sent []string
+}
+
+func (s *StubSender) SendExchangeRate(rate port.Rate, subscribers []port.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, subscriber := range subscribers {
+		if err := s.errs[subscriber.Email]; err != nil {
+			return err
+		}
+		s.sent = append(s.sent, subscriber.Email)
+	}
+
+	return nil
+}
+
+// StubProgress keeps the sent, failed and skipped counts of the jobs
+type StubProgress struct {
+	mu   sync.Mutex
+	jobs map[string][3]int
+}
+
+func (s *StubProgress) Record(jobID string, sent, failed, skipped int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jobs == nil {
+		s.jobs = make(map[string][3]int)
+	}
+
+	counts := s.jobs[jobID]
+	s.jobs[jobID] = [3]int{counts[0] + sent, counts[1] + failed, counts[2] + skipped}
+	return nil
+}
+
+func newTestService(
+	repository Repository,
+	subscribers SubscriberRepository,
+	sender Sender,
+) *Service {
+	return newTestServiceWithProgress(repository, subscribers, sender, &StubProgress{})
+}
+
+func newTestServiceWithProgress(
+	repository Repository,
+	subscribers SubscriberRepository,
+	sender Sender,
+	progress Progress,
+) *Service {
+	service := NewService(
+		OutboxConfig{
+			Workers:        2,
+			BatchSize:      10,
+			MaxAttempts:    3,
+			InitialBackoff: time.Minute,
+			MaxBackoff:     3 * time.Minute,
+		},
+		&StubLogger{},
+		repository,
+		subscribers,
+		sender,
+		progress,
+	)
+	service.now = func() time.Time { return _now }
+
+	return service
+}
+
+func TestSendExchangeRate(t *testing.T) {
+	t.Parallel()
+
+	rate := port.Rate{Pair: port.DefaultCurrencyPair, Value: 10.5}
+	subscribers := []port.User{
+		{Email: "first@example.com"},
+		{Email: "second@example.com"},
+		{
+			Email:       "telegram@example.com",
+			Preferences: port.Preferences{Channel: port.ChannelTelegram},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		repoErr      error
+		wantQueued   []string
+		wantFailures int
+	}{
+		{
+			name:       "Emails are queued",
+			wantQueued: []string{"first@example.com", "second@example.com"},
+		},
+		{
+			name:         "Outbox fails",
+			repoErr:      errRepository,
+			wantFailures: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repository := &StubRepository{Err: tt.repoErr}
+			service := newTestService(repository, &StubSubscriberRepository{}, &StubSender{})
+
+			err := service.SendExchangeRate(rate, subscribers)
+			if tt.wantFailures > 0 {
+				var report *port.DeliveryError
+				require.ErrorAs(t, err, &report)
+				require.Len(t, report.Failures, tt.wantFailures)
+				require.ErrorIs(t, err, ErrOutboxRepository)
+				return
+			}
+			require.NoError(t, err)
+
+			var queued []string
+			for _, message := range repository.Messages {
+				require.NotEmpty(t, message.ID)
+				require.Equal(t, rate, message.Rate)
+				require.Equal(t, port.OutboxStatusPending, message.Status)
+				require.Equal(t, _now, message.NextAttemptAt)
+				queued = append(queued, message.Email)
+			}
+			require.Equal(t, tt.wantQueued, queued)
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	t.Parallel()
+
+	permanentErr := errors.Join(errors.New("550 mailbox unavailable"), port.ErrPermanentFailure)
+
+	tests := []struct {
+		name       string
+		message    port.OutboxMessage
+		subscriber *port.User
+		sendErr    error
+		wantSent   bool
+		// The message left in the outbox, nil if it's removed
+		want *port.OutboxMessage
+	}{
+		{
+			name:       "Sent email is removed",
+			message:    port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusPending},
+			subscriber: &port.User{Email: "a@example.com"},
+			wantSent:   true,
+		},
+		{
+			name:       "Transient failure is retried later",
+			message:    port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusPending, Attempts: 1},
+			subscriber: &port.User{Email: "a@example.com"},
+			sendErr:    errBusy,
+			want: &port.OutboxMessage{
+				ID:            "1",
+				Email:         "a@example.com",
+				Status:        port.OutboxStatusPending,
+				Attempts:      2,
+				NextAttemptAt: _now.Add(2 * time.Minute),
+				LastError:     errBusy.Error(),
+			},
+		},
+		{
+			name:       "Permanent failure is dead-lettered",
+			message:    port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusPending},
+			subscriber: &port.User{Email: "a@example.com"},
+			sendErr:    permanentErr,
+			want: &port.OutboxMessage{
+				ID:        "1",
+				Email:     "a@example.com",
+				Status:    port.OutboxStatusDead,
+				Attempts:  1,
+				LastError: permanentErr.Error(),
+			},
+		},
+		{
+			name:       "Last attempt is dead-lettered",
+			message:    port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusPending, Attempts: 2},
+			subscriber: &port.User{Email: "a@example.com"},
+			sendErr:    errBusy,
+			want: &port.OutboxMessage{
+				ID:        "1",
+				Email:     "a@example.com",
+				Status:    port.OutboxStatusDead,
+				Attempts:  3,
+				LastError: errBusy.Error(),
+			},
+		},
+		{
+			name:    "Email to unsubscribed is dropped",
+			message: port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusPending},
+		},
+		{
+			name:       "Email to pending subscriber is dropped",
+			message:    port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusPending},
+			subscriber: &port.User{Email: "a@example.com", Status: port.UserStatusPending},
+		},
+		{
+			name:    "Email to subscriber who turned emails off is dropped",
+			message: port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusPending},
+			subscriber: &port.User{
+				Email:       "a@example.com",
+				Preferences: port.Preferences{Channel: port.ChannelTelegram},
+			},
+		},
+		{
+			name: "Email not due is kept",
+			message: port.OutboxMessage{
+				ID:            "1",
+				Email:         "a@example.com",
+				Status:        port.OutboxStatusPending,
+				NextAttemptAt: _now.Add(time.Second),
+			},
+			subscriber: &port.User{Email: "a@example.com"},
+			want: &port.OutboxMessage{
+				ID:            "1",
+				Email:         "a@example.com",
+				Status:        port.OutboxStatusPending,
+				NextAttemptAt: _now.Add(time.Second),
+			},
+		},
+		{
+			name:       "Dead letter is kept",
+			message:    port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusDead},
+			subscriber: &port.User{Email: "a@example.com"},
+			want:       &port.OutboxMessage{ID: "1", Email: "a@example.com", Status: port.OutboxStatusDead},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			subscribers := &StubSubscriberRepository{}
+			if tt.subscriber != nil {
+				subscribers.Users = []port.User{*tt.subscriber}
+			}
+			sender := &StubSender{errs: map[string]error{"a@example.com": tt.sendErr}}
+			repository := &StubRepository{Messages: []port.OutboxMessage{tt.message}}
+
+			service := newTestService(repository, subscribers, sender)
+
+			require.NoError(t, service.Dispatch(context.Background()))
+
+			if tt.wantSent {
+				require.Equal(t, []string{"a@example.com"}, sender.sent)
+			} else {
+				require.Empty(t, sender.sent)
+			}
+
+			if tt.want == nil {
+				require.Empty(t, repository.Messages)
+			} else {
+				require.Equal(t, []port.OutboxMessage{*tt.want}, repository.Messages)
+			}
+		})
+	}
+}
+
+func TestDispatchManyMessages(t *testing.T) {
+	t.Parallel()
+
+	var (
+		users    []port.User
+		messages []port.OutboxMessage
+	)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		users = append(users, port.User{Email: email})
+		messages = append(messages, port.OutboxMessage{
+			ID:     email,
+			Email:  email,
+			Status: port.OutboxStatusPending,
+		})
+	}
+
+	sender := &StubSender{errs: map[string]error{"c@example.com": errBusy}}
+	repository := &StubRepository{Messages: messages}
+	service := newTestService(repository, &StubSubscriberRepository{Users: users}, sender)
+
+	require.NoError(t, service.Dispatch(context.Background()))
+	require.ElementsMatch(t, []string{"a@example.com", "b@example.com", "d@example.com"}, sender.sent)
+	require.Len(t, repository.Messages, 1)
+	require.Equal(t, "c@example.com", repository.Messages[0].Email)
+	// One batch removes the sent emails and one updates the failed one
+	require.Equal(t, 2, repository.batches)
+}
+
+// StubStoredSender checks that the emails of the earlier batches
+// are stored as sent before it sends the next one
+type StubStoredSender struct {
+	repository *StubRepository
+	// The messages left in the outbox when each email was sent
+	left []int
+}
+
+func (s *StubStoredSender) SendExchangeRate(rate port.Rate, subscribers []port.User) error {
+	messages, err := s.repository.All()
+	if err != nil {
+		return err
+	}
+
+	s.left = append(s.left, len(messages))
+	return nil
+}
+
+func TestDispatchBatches(t *testing.T) {
+	t.Parallel()
+
+	var (
+		users    []port.User
+		messages []port.OutboxMessage
+	)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		users = append(users, port.User{Email: email})
+		messages = append(messages, port.OutboxMessage{
+			ID:     email,
+			Email:  email,
+			Status: port.OutboxStatusPending,
+		})
+	}
+
+	repository := &StubRepository{Messages: messages}
+	sender := &StubStoredSender{repository: repository}
+	// A single worker sends the emails in order, none of them is
+	// dropped or deadlocks without the workers configured
+	service := NewService(
+		OutboxConfig{BatchSize: 2},
+		&StubLogger{},
+		repository,
+		&StubSubscriberRepository{Users: users},
+		sender,
+		&StubProgress{},
+	)
+	service.now = func() time.Time { return _now }
+
+	require.NoError(t, service.Dispatch(context.Background()))
+	require.Equal(t, []int{3, 3, 1}, sender.left)
+	require.Empty(t, repository.Messages)
+	require.Equal(t, 2, repository.batches)
+}
+
+func TestDispatchRepositoryError(t *testing.T) {
+	t.Parallel()
+
+	service := newTestService(
+		&StubRepository{Err: errRepository},
+		&StubSubscriberRepository{},
+		&StubSender{},
+	)
+
+	require.ErrorIs(t, service.Dispatch(context.Background()), ErrOutboxRepository)
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	service := newTestService(&StubRepository{}, &StubSubscriberRepository{}, &StubSender{})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 3, want: 3 * time.Minute},
+		{attempts: 10, want: 3 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, service.backoff(tt.attempts))
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	dead := port.OutboxMessage{ID: "3", Status: port.OutboxStatusDead, CreatedAt: _now.Add(-time.Hour)}
+	repository := &StubRepository{Messages: []port.OutboxMessage{
+		{ID: "1", Status: port.OutboxStatusPending, NextAttemptAt: _now, CreatedAt: _now.Add(-time.Minute)},
+		{ID: "2", Status: port.OutboxStatusPending, NextAttemptAt: _now.Add(time.Minute), CreatedAt: _now.Add(-2 * time.Minute)},
+		dead,
+	}}
+	service := newTestService(repository, &StubSubscriberRepository{}, &StubSender{})
+
+	stats, err := service.Stats()
+	require.NoError(t, err)
+	require.Equal(t, &Stats{
+		Pending:         2,
+		Due:             1,
+		Dead:            1,
+		OldestPendingAt: _now.Add(-2 * time.Minute),
+		DeadLetters:     []port.OutboxMessage{dead},
+	}, stats)
+}
+
+func TestRetryAndDiscard(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{Messages: []port.OutboxMessage{
+		{ID: "pending", Status: port.OutboxStatusPending},
+		{ID: "dead1", Status: port.OutboxStatusDead, Attempts: 3, LastError: "550", JobID: "job1"},
+		{ID: "dead2", Status: port.OutboxStatusDead, Attempts: 1, LastError: "550"},
+	}}
+	service := newTestService(repository, &StubSubscriberRepository{}, &StubSender{})
+
+	require.ErrorIs(t, service.Retry("pending"), ErrMessageNotFound)
+	require.ErrorIs(t, service.Discard("missing"), ErrMessageNotFound)
+
+	require.NoError(t, service.Retry("dead1"))
+	require.NoError(t, service.Discard("dead2"))
+
+	require.Equal(t, []port.OutboxMessage{
+		{ID: "pending", Status: port.OutboxStatusPending},
+		{ID: "dead1", Status: port.OutboxStatusPending, NextAttemptAt: _now, LastError: "550"},
+	}, repository.Messages)
+}
+
+func TestDispatchRecordsJobProgress(t *testing.T) {
+	t.Parallel()
+
+	users := []port.User{
+		{Email: "sent@example.com"},
+		{Email: "retried@example.com"},
+		{Email: "dead@example.com"},
+	}
+
+	var messages []port.OutboxMessage
+	for _, email := range []string{
+		"sent@example.com",
+		"retried@example.com",
+		"dead@example.com",
+		"unsubscribed@example.com",
+	} {
+		messages = append(messages, port.OutboxMessage{
+			ID:     email,
+			Email:  email,
+			Status: port.OutboxStatusPending,
+			JobID:  "job1",
+		})
+	}
+	// Not queued by a job
+	messages = append(messages, port.OutboxMessage{
+		ID:     "scheduled",
+		Email:  "sent@example.com",
+		Status: port.OutboxStatusPending,
+	})
+
+	progress := &StubProgress{}
+	service := newTestServiceWithProgress(
+		&StubRepository{Messages: messages},
+		&StubSubscriberRepository{Users: users},
+		&StubSender{errs: map[string]error{
+			"retried@example.com": errBusy,
+			"dead@example.com":    errors.Join(errBusy, port.ErrPermanentFailure),
+		}},
+		progress,
+	)
+
+	require.NoError(t, service.Dispatch(context.Background()))
+	require.Equal(t, map[string][3]int{"job1": {1, 1, 1}}, progress.jobs)
+}
+
+func TestEnqueueJob(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{}
+	service := newTestService(repository, &StubSubscriberRepository{}, &StubSender{})
+
+	require.NoError(t, service.EnqueueJob(
+		"job1",
+		port.Rate{Pair: port.DefaultCurrencyPair},
+		[]port.User{{Email: "a@example.com"}},
+	))
+	require.Len(t, repository.Messages, 1)
+	require.Equal(t, "job1", repository.Messages[0].JobID)
+}
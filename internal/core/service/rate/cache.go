@@ -0,0 +1,124 @@
+package rate
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"gses2-app/internal/core/port"
+)
+
+type CacheConfig struct {
+	// How long a rate is served without asking the providers,
+	// zero disables the cache
+	TTL time.Duration `default:"10s"`
+	// How long after the TTL an expired rate is still served while it's
+	// refreshed in the background
+	StaleWhileRevalidate time.Duration `default:"0s"`
+	// How long after the TTL an expired rate is served
+	// when the providers fail
+	MaxStale time.Duration `default:"5m"`
+}
+
+type RateService interface {
+	ExchangeRate(pair port.CurrencyPair) (port.Rate, error)
+}
+
+// CachedService caches the rates of the wrapped service per currency pair.
+// Concurrent requests of an expired rate share a single request
+// to the wrapped service.
+type CachedService struct {
+	config  CacheConfig
+	logger  port.Logger
+	service RateService
+	now     func() time.Time
+
+	mu    sync.RWMutex
+	rates map[port.CurrencyPair]port.Rate
+	group singleflight.Group
+}
+
+func NewCachedService(
+	config CacheConfig,
+	logger port.Logger,
+	service RateService,
+) *CachedService {
+	return &CachedService{
+		config:  config,
+		logger:  logger,
+		service: service,
+		now:     time.Now,
+		rates:   make(map[port.CurrencyPair]port.Rate),
+	}
+}
+
+// ExchangeRate returns the cached rate while it's fresh. The FetchedAt
+// of the returned rate tells its age.
+func (c *CachedService) ExchangeRate(pair port.CurrencyPair) (port.Rate, error) {
+	cached, ok := c.cached(pair)
+	if ok {
+		age := c.now().Sub(cached.FetchedAt)
+
+		if age < c.config.TTL {
+			return cached, nil
+		}
+
+		if age < c.config.TTL+c.config.StaleWhileRevalidate {
+			c.revalidate(pair)
+			return cached, nil
+		}
+	}
+
+	rate, err, _ := c.group.Do(pair.String(), func() (any, error) {
+		return c.refresh(pair)
+	})
+	if err == nil {
+		return rate.(port.Rate), nil
+	}
+
+	if ok && c.now().Sub(cached.FetchedAt) <= c.config.TTL+c.config.MaxStale {
+		c.logger.Errorf("Error, %v: %v, serving the cached rate", pair, err)
+		return cached, nil
+	}
+
+	return port.Rate{}, err
+}
+
+func (c *CachedService) refresh(pair port.CurrencyPair) (port.Rate, error) {
+	rate, err := c.service.ExchangeRate(pair)
+	if err != nil {
+		return port.Rate{}, err
+	}
+
+	if rate.FetchedAt.IsZero() {
+		rate.FetchedAt = c.now()
+	}
+
+	c.mu.Lock()
+	c.rates[pair] = rate
+	c.mu.Unlock()
+
+	return rate, nil
+}
+
+// revalidate refreshes the rate in the background
+func (c *CachedService) revalidate(pair port.CurrencyPair) {
+	result := c.group.DoChan(pair.String(), func() (any, error) {
+		return c.refresh(pair)
+	})
+
+	go func() {
+		if r := <-result; r.Err != nil {
+			c.logger.Errorf("Error, %v: cannot revalidate the rate: %v", pair, r.Err)
+		}
+	}()
+}
+
+func (c *CachedService) cached(pair port.CurrencyPair) (port.Rate, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	rate, ok := c.rates[pair]
+	return rate, ok
+}
@@ -0,0 +1,212 @@
+package rate
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+var errRateService = errors.New("rate service error")
+
+type StubRateService struct {
+	mu    sync.Mutex
+	value float32
+	err   error
+	// Closed to let the pending calls return
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (s *StubRateService) ExchangeRate(pair port.CurrencyPair) (port.Rate, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return port.Rate{Pair: pair, Value: s.value}, s.err
+}
+
+func (s *StubRateService) set(value float32, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.value, s.err = value, err
+}
+
+type StubClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *StubClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *StubClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newTestCachedService(
+	config CacheConfig,
+	service *StubRateService,
+) (*CachedService, *StubClock) {
+	clock := &StubClock{now: time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)}
+	cache := NewCachedService(config, &StubLogger{}, service)
+	cache.now = clock.Now
+
+	return cache, clock
+}
+
+func TestCachedServiceTTL(t *testing.T) {
+	t.Parallel()
+
+	service := &StubRateService{value: 1}
+	cache, clock := newTestCachedService(CacheConfig{TTL: time.Minute}, service)
+
+	rate, err := cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.NoError(t, err)
+	require.Equal(t, float32(1), rate.Value)
+	require.Equal(t, clock.Now(), rate.FetchedAt)
+
+	service.set(2, nil)
+	clock.Advance(30 * time.Second)
+
+	rate, err = cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.NoError(t, err)
+	require.Equal(t, float32(1), rate.Value, "expected the cached rate")
+
+	clock.Advance(time.Minute)
+
+	rate, err = cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.NoError(t, err)
+	require.Equal(t, float32(2), rate.Value, "expected the refreshed rate")
+	require.EqualValues(t, 2, service.calls.Load())
+}
+
+func TestCachedServicePairs(t *testing.T) {
+	t.Parallel()
+
+	service := &StubRateService{value: 1}
+	cache, _ := newTestCachedService(CacheConfig{TTL: time.Minute}, service)
+
+	ethUSD := port.CurrencyPair{Base: "ETH", Quote: "USD"}
+	for _, pair := range []port.CurrencyPair{port.DefaultCurrencyPair, ethUSD} {
+		rate, err := cache.ExchangeRate(pair)
+		require.NoError(t, err)
+		require.Equal(t, pair, rate.Pair)
+	}
+
+	require.EqualValues(t, 2, service.calls.Load())
+}
+
+func TestCachedServiceSingleflight(t *testing.T) {
+	t.Parallel()
+
+	service := &StubRateService{value: 1, release: make(chan struct{})}
+	cache, _ := newTestCachedService(CacheConfig{TTL: time.Minute}, service)
+
+	const requests = 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rate, err := cache.ExchangeRate(port.DefaultCurrencyPair)
+			require.NoError(t, err)
+			require.Equal(t, float32(1), rate.Value)
+		}()
+	}
+
+	require.Eventually(
+		t,
+		func() bool { return service.calls.Load() == 1 },
+		time.Second,
+		time.Millisecond,
+	)
+	// Let the other requests join the pending call
+	time.Sleep(10 * time.Millisecond)
+	close(service.release)
+	wg.Wait()
+
+	require.EqualValues(t, 1, service.calls.Load())
+}
+
+func TestCachedServiceStaleOnError(t *testing.T) {
+	t.Parallel()
+
+	service := &StubRateService{value: 1}
+	cache, clock := newTestCachedService(
+		CacheConfig{TTL: time.Minute, MaxStale: 5 * time.Minute},
+		service,
+	)
+
+	fetched, err := cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.NoError(t, err)
+
+	service.set(0, errRateService)
+	clock.Advance(3 * time.Minute)
+
+	rate, err := cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.NoError(t, err)
+	require.Equal(t, fetched, rate, "expected the stale rate")
+
+	clock.Advance(4 * time.Minute)
+
+	_, err = cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.ErrorIs(t, err, errRateService, "expected the rate to be too stale")
+}
+
+func TestCachedServiceNoCachedRateOnError(t *testing.T) {
+	t.Parallel()
+
+	service := &StubRateService{err: errRateService}
+	cache, _ := newTestCachedService(
+		CacheConfig{TTL: time.Minute, MaxStale: time.Hour},
+		service,
+	)
+
+	_, err := cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.ErrorIs(t, err, errRateService)
+}
+
+func TestCachedServiceStaleWhileRevalidate(t *testing.T) {
+	t.Parallel()
+
+	service := &StubRateService{value: 1}
+	cache, clock := newTestCachedService(
+		CacheConfig{TTL: time.Minute, StaleWhileRevalidate: time.Minute},
+		service,
+	)
+
+	_, err := cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.NoError(t, err)
+
+	service.set(2, nil)
+	clock.Advance(90 * time.Second)
+
+	rate, err := cache.ExchangeRate(port.DefaultCurrencyPair)
+	require.NoError(t, err)
+	require.Equal(t, float32(1), rate.Value, "expected the stale rate")
+
+	require.Eventually(t, func() bool {
+		rate, err := cache.ExchangeRate(port.DefaultCurrencyPair)
+		return err == nil && rate.Value == 2
+	}, time.Second, time.Millisecond, "expected the rate to be revalidated")
+}
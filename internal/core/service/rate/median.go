@@ -0,0 +1,128 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"gses2-app/internal/core/port"
+)
+
+var ErrNoConsensus = errors.New("no consensus between rate providers")
+
+// median asks all the providers at once and drops the answers deviating
+// from their median more than the configured percentage. The consensus
+// rate is the median of the rest and it lists the providers it's based on,
+// there must be at least the configured number of them.
+func (s *Service) median(pair port.CurrencyPair) (port.Rate, error) {
+	if len(s.providers) == 0 {
+		return port.Rate{}, ErrNoProviders
+	}
+
+	minProviders := s.config.MinProviders
+	if minProviders < 1 {
+		minProviders = 1
+	}
+
+	results := make([]providerResult, len(s.providers))
+
+	var wg sync.WaitGroup
+	for i, provider := range s.providers {
+		wg.Add(1)
+		go func(i int, provider RatePort) {
+			defer wg.Done()
+
+			rate, err := provider.ExchangeRate(context.Background(), pair)
+			results[i] = providerResult{provider: provider, rate: rate, err: err}
+		}(i, provider)
+	}
+	wg.Wait()
+
+	var (
+		answers []providerResult
+		errs    []error
+	)
+	for _, result := range results {
+		if result.err != nil {
+			s.logger.Errorf("Error, %v %v: %v", result.provider.Name(), pair, result.err)
+			errs = append(errs, result.err)
+			continue
+		}
+
+		answers = append(answers, result)
+	}
+
+	if len(answers) < minProviders {
+		return port.Rate{}, errors.Join(append(errs, noQuorum(len(answers), minProviders))...)
+	}
+
+	values := make([]float64, len(answers))
+	for i, answer := range answers {
+		values[i] = float64(answer.rate.Value)
+	}
+	median := medianOf(values)
+
+	var (
+		consensus []float64
+		providers []string
+	)
+	for i, answer := range answers {
+		if !withinDeviation(values[i], median, s.config.MaxDeviation) {
+			s.logger.Errorf(
+				"Error, %v %v: rate %v deviates from the median %v, dropped",
+				answer.provider.Name(), pair, values[i], median,
+			)
+			continue
+		}
+
+		consensus = append(consensus, values[i])
+		providers = append(providers, answer.provider.Name())
+	}
+
+	if len(consensus) < minProviders {
+		return port.Rate{}, noQuorum(len(consensus), minProviders)
+	}
+
+	rate := port.Rate{
+		Pair:      pair,
+		Value:     float32(medianOf(consensus)),
+		Providers: providers,
+	}
+	s.logger.Infof(
+		"Consensus rate %v %v from %v",
+		pair, rate.Value, strings.Join(providers, ", "),
+	)
+
+	return rate, nil
+}
+
+func noQuorum(agreeing, minProviders int) error {
+	return fmt.Errorf(
+		"%w: %d of at least %d providers agree",
+		ErrNoConsensus, agreeing, minProviders,
+	)
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
+
+func withinDeviation(value, median, maxDeviation float64) bool {
+	if median == 0 {
+		return value == 0
+	}
+
+	return math.Abs(value-median)/math.Abs(median)*100 <= maxDeviation
+}
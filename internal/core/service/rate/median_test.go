@@ -0,0 +1,164 @@
+package rate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+func TestMedianStrategy(t *testing.T) {
+	errProvider := errors.New("provider error")
+	rate := func(value float32) port.Rate {
+		return port.Rate{Pair: port.DefaultCurrencyPair, Value: value}
+	}
+
+	tests := []struct {
+		name         string
+		providers    []*StubProvider
+		minProviders int
+		expectedRate port.Rate
+		expectedErr  error
+	}{
+		{
+			name: "Median of agreeing providers",
+			providers: []*StubProvider{
+				{ProviderName: "A", Rate: rate(100)},
+				{ProviderName: "B", Rate: rate(102)},
+				{ProviderName: "C", Rate: rate(101)},
+			},
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     101,
+				Providers: []string{"A", "B", "C"},
+			},
+		},
+		{
+			name: "Outlier dropped",
+			providers: []*StubProvider{
+				{ProviderName: "A", Rate: rate(100)},
+				{ProviderName: "B", Rate: rate(1000)},
+				{ProviderName: "C", Rate: rate(104)},
+			},
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     102,
+				Providers: []string{"A", "C"},
+			},
+		},
+		{
+			name: "Failed provider skipped",
+			providers: []*StubProvider{
+				{ProviderName: "A", Error: errProvider},
+				{ProviderName: "B", Rate: rate(100)},
+				{ProviderName: "C", Rate: rate(102)},
+			},
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     101,
+				Providers: []string{"B", "C"},
+			},
+		},
+		{
+			name: "Two disagreeing providers",
+			providers: []*StubProvider{
+				{ProviderName: "A", Rate: rate(100)},
+				{ProviderName: "B", Rate: rate(200)},
+			},
+			expectedErr: ErrNoConsensus,
+		},
+		{
+			name: "Single answer below the quorum",
+			providers: []*StubProvider{
+				{ProviderName: "A", Rate: rate(100)},
+				{ProviderName: "B", Error: errProvider},
+				{ProviderName: "C", Error: errProvider},
+			},
+			expectedErr: ErrNoConsensus,
+		},
+		{
+			name: "Single answer within the quorum",
+			providers: []*StubProvider{
+				{ProviderName: "A", Rate: rate(100)},
+				{ProviderName: "B", Error: errProvider},
+			},
+			minProviders: 1,
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     100,
+				Providers: []string{"A"},
+			},
+		},
+		{
+			name: "Outliers leave too few providers",
+			providers: []*StubProvider{
+				{ProviderName: "A", Rate: rate(100)},
+				{ProviderName: "B", Rate: rate(150)},
+				{ProviderName: "C", Rate: rate(200)},
+			},
+			expectedErr: ErrNoConsensus,
+		},
+		{
+			name: "Three agreeing providers required",
+			providers: []*StubProvider{
+				{ProviderName: "A", Rate: rate(100)},
+				{ProviderName: "B", Rate: rate(101)},
+				{ProviderName: "C", Rate: rate(1000)},
+			},
+			minProviders: 3,
+			expectedErr:  ErrNoConsensus,
+		},
+		{
+			name: "All providers failed",
+			providers: []*StubProvider{
+				{ProviderName: "A", Error: errProvider},
+				{ProviderName: "B", Error: errProvider},
+			},
+			expectedErr: errProvider,
+		},
+		{
+			name:        "No providers",
+			expectedErr: ErrNoProviders,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ports := make([]RatePort, len(tt.providers))
+			for i, provider := range tt.providers {
+				ports[i] = provider
+			}
+
+			minProviders := tt.minProviders
+			if minProviders == 0 {
+				minProviders = 2
+			}
+
+			service := NewService(
+				RateConfig{
+					Strategy:     StrategyMedian,
+					MaxDeviation: 5,
+					MinProviders: minProviders,
+				},
+				&StubLogger{},
+				ports...,
+			)
+			rate, err := service.ExchangeRate(port.DefaultCurrencyPair)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedRate, rate)
+		})
+	}
+}
+
+func TestMedianOf(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 2.0, medianOf([]float64{3, 1, 2}))
+	require.Equal(t, 2.5, medianOf([]float64{4, 1, 3, 2}))
+}
@@ -1,35 +1,166 @@
 package rate
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"gses2-app/internal/core/port"
 )
 
+var (
+	ErrUnknownStrategy = errors.New("unknown rate strategy")
+	ErrNoProviders     = errors.New("no rate providers")
+)
+
+// Strategy defines how the providers are asked for the rate
+type Strategy string
+
+const (
+	// Ask the providers one by one until one of them answers
+	StrategyFallback Strategy = "fallback"
+	// Ask all the providers at once and take the first answer
+	StrategyRace Strategy = "race"
+	// Ask the providers in order, starting the next one when the previous
+	// one fails or doesn't answer within the hedge delay
+	StrategyHedge Strategy = "hedge"
+	// Ask all the providers at once and take the median of the answers
+	// which don't deviate from it more than allowed
+	StrategyMedian Strategy = "median"
+)
+
+// Decode lets envconfig reject an unknown strategy when the config is loaded
+func (s *Strategy) Decode(value string) error {
+	switch strategy := Strategy(value); strategy {
+	case StrategyFallback, StrategyRace, StrategyHedge, StrategyMedian:
+		*s = strategy
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownStrategy, value)
+	}
+}
+
+type RateConfig struct {
+	Strategy   Strategy      `default:"fallback"`
+	HedgeDelay time.Duration `default:"500ms"`
+	// The maximum deviation from the median in percent,
+	// the answers deviating more are dropped as outliers
+	MaxDeviation float64 `default:"5"`
+	// How many providers the median must be based on at least,
+	// so a single provider can't set the rate on its own
+	MinProviders int `default:"2"`
+}
+
 type RatePort interface {
-	ExchangeRate() (port.Rate, error)
+	ExchangeRate(ctx context.Context, pair port.CurrencyPair) (port.Rate, error)
 	Name() string
 }
 
 type Service struct {
+	config    RateConfig
 	providers []RatePort
 	logger    port.Logger
 }
 
-func NewService(logger port.Logger, providers ...RatePort) *Service {
+func NewService(
+	config RateConfig,
+	logger port.Logger,
+	providers ...RatePort,
+) *Service {
 	return &Service{
+		config:    config,
 		logger:    logger,
 		providers: providers,
 	}
 }
 
-func (s *Service) ExchangeRate() (rate port.Rate, err error) {
+func (s *Service) ExchangeRate(pair port.CurrencyPair) (port.Rate, error) {
+	switch s.config.Strategy {
+	case StrategyRace:
+		return s.hedge(pair, 0)
+	case StrategyHedge:
+		return s.hedge(pair, s.config.HedgeDelay)
+	case StrategyMedian:
+		return s.median(pair)
+	default:
+		return s.fallback(pair)
+	}
+}
+
+func (s *Service) fallback(pair port.CurrencyPair) (rate port.Rate, err error) {
 	for _, provider := range s.providers {
-		rate, err = provider.ExchangeRate()
+		rate, err = provider.ExchangeRate(context.Background(), pair)
 		if err == nil {
 			return rate, nil
 		}
 
-		s.logger.Errorf("Error, %v: %v", provider.Name(), err)
+		s.logger.Errorf("Error, %v %v: %v", provider.Name(), pair, err)
 	}
 
 	return rate, err
 }
+
+type providerResult struct {
+	provider RatePort
+	rate     port.Rate
+	err      error
+}
+
+// hedge starts the providers in order, the next one is started when
+// a started one fails or when none has answered within the delay.
+// The first answer wins and the requests still running are canceled.
+// A zero delay starts all the providers at once.
+func (s *Service) hedge(pair port.CurrencyPair, delay time.Duration) (port.Rate, error) {
+	if len(s.providers) == 0 {
+		return port.Rate{}, ErrNoProviders
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Buffered, so the canceled providers don't block after the return
+	results := make(chan providerResult, len(s.providers))
+	started := 0
+	startNext := func() {
+		provider := s.providers[started]
+		started++
+
+		go func() {
+			rate, err := provider.ExchangeRate(ctx, pair)
+			results <- providerResult{provider: provider, rate: rate, err: err}
+		}()
+	}
+
+	startNext()
+	for delay == 0 && started < len(s.providers) {
+		startNext()
+	}
+
+	var errs []error
+	for finished := 0; finished < started; {
+		var hedgeTimer <-chan time.Time
+		if started < len(s.providers) {
+			hedgeTimer = time.After(delay)
+		}
+
+		select {
+		case result := <-results:
+			finished++
+			if result.err == nil {
+				return result.rate, nil
+			}
+
+			s.logger.Errorf("Error, %v %v: %v", result.provider.Name(), pair, result.err)
+			errs = append(errs, result.err)
+
+			if started < len(s.providers) {
+				startNext()
+			}
+		case <-hedgeTimer:
+			startNext()
+		}
+	}
+
+	return port.Rate{}, errors.Join(errs...)
+}
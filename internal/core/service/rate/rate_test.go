@@ -1,8 +1,11 @@
 package rate
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -22,10 +25,46 @@ type StubProvider struct {
 	Rate         port.Rate
 	Error        error
 	ProviderName string
+	// Delay before the answer, canceling the context interrupts it
+	Delay time.Duration
+
+	mu       sync.Mutex
+	calls    int
+	canceled bool
 }
 
-func (m *StubProvider) ExchangeRate() (port.Rate, error) {
-	return m.Rate, m.Error
+func (m *StubProvider) ExchangeRate(
+	ctx context.Context,
+	pair port.CurrencyPair,
+) (port.Rate, error) {
+	m.mu.Lock()
+	m.calls++
+	m.mu.Unlock()
+
+	select {
+	case <-time.After(m.Delay):
+		return m.Rate, m.Error
+	case <-ctx.Done():
+		m.mu.Lock()
+		m.canceled = true
+		m.mu.Unlock()
+
+		return port.Rate{}, ctx.Err()
+	}
+}
+
+func (m *StubProvider) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls
+}
+
+func (m *StubProvider) Canceled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.canceled
 }
 
 func (m *StubProvider) Name() string {
@@ -42,19 +81,18 @@ func TestExchangeRate(t *testing.T) {
 		{
 			name: "Success",
 			stubProvider: &StubProvider{
-				Rate:  1.23,
+				Rate:  port.Rate{Pair: port.DefaultCurrencyPair, Value: 1.23},
 				Error: nil,
 			},
-			expectedRate:   1.23,
+			expectedRate:   port.Rate{Pair: port.DefaultCurrencyPair, Value: 1.23},
 			expectingError: false,
 		},
 		{
 			name: "Failure",
 			stubProvider: &StubProvider{
-				Rate:  0,
 				Error: errors.New("error fetching rate"),
 			},
-			expectedRate:   0,
+			expectedRate:   port.Rate{},
 			expectingError: true,
 		},
 	}
@@ -63,8 +101,8 @@ func TestExchangeRate(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			service := NewService(&StubLogger{}, tt.stubProvider)
-			rate, err := service.ExchangeRate()
+			service := NewService(RateConfig{}, &StubLogger{}, tt.stubProvider)
+			rate, err := service.ExchangeRate(port.DefaultCurrencyPair)
 
 			require.Equal(
 				t, tt.expectedRate, rate,
@@ -76,3 +114,180 @@ func TestExchangeRate(t *testing.T) {
 	}
 
 }
+
+func TestExchangeRateStrategies(t *testing.T) {
+	errProvider := errors.New("provider error")
+	fastRate := port.Rate{Pair: port.DefaultCurrencyPair, Value: 1}
+	slowRate := port.Rate{Pair: port.DefaultCurrencyPair, Value: 2}
+
+	tests := []struct {
+		name          string
+		config        RateConfig
+		providers     func() []*StubProvider
+		expectedRate  port.Rate
+		expectedErr   error
+		expectedCalls []int
+	}{
+		{
+			name:   "Fallback waits for the slow provider",
+			config: RateConfig{Strategy: StrategyFallback},
+			providers: func() []*StubProvider {
+				return []*StubProvider{
+					{Rate: slowRate, Delay: 50 * time.Millisecond},
+					{Rate: fastRate},
+				}
+			},
+			expectedRate:  slowRate,
+			expectedCalls: []int{1, 0},
+		},
+		{
+			name:   "Fallback to the next provider",
+			config: RateConfig{Strategy: StrategyFallback},
+			providers: func() []*StubProvider {
+				return []*StubProvider{
+					{Error: errProvider},
+					{Rate: fastRate},
+				}
+			},
+			expectedRate:  fastRate,
+			expectedCalls: []int{1, 1},
+		},
+		{
+			name:   "Race takes the fastest provider",
+			config: RateConfig{Strategy: StrategyRace},
+			providers: func() []*StubProvider {
+				return []*StubProvider{
+					{Rate: slowRate, Delay: time.Second},
+					{Rate: fastRate},
+				}
+			},
+			expectedRate:  fastRate,
+			expectedCalls: []int{1, 1},
+		},
+		{
+			name:   "Race skips the failed provider",
+			config: RateConfig{Strategy: StrategyRace},
+			providers: func() []*StubProvider {
+				return []*StubProvider{
+					{Error: errProvider},
+					{Rate: slowRate, Delay: 10 * time.Millisecond},
+				}
+			},
+			expectedRate:  slowRate,
+			expectedCalls: []int{1, 1},
+		},
+		{
+			name:   "Race fails when all the providers fail",
+			config: RateConfig{Strategy: StrategyRace},
+			providers: func() []*StubProvider {
+				return []*StubProvider{{Error: errProvider}, {Error: errProvider}}
+			},
+			expectedErr:   errProvider,
+			expectedCalls: []int{1, 1},
+		},
+		{
+			name: "Hedge doesn't start the next provider for a fast answer",
+			config: RateConfig{
+				Strategy:   StrategyHedge,
+				HedgeDelay: time.Second,
+			},
+			providers: func() []*StubProvider {
+				return []*StubProvider{{Rate: fastRate}, {Rate: slowRate}}
+			},
+			expectedRate:  fastRate,
+			expectedCalls: []int{1, 0},
+		},
+		{
+			name: "Hedge starts the next provider after the delay",
+			config: RateConfig{
+				Strategy:   StrategyHedge,
+				HedgeDelay: 10 * time.Millisecond,
+			},
+			providers: func() []*StubProvider {
+				return []*StubProvider{
+					{Rate: slowRate, Delay: time.Second},
+					{Rate: fastRate},
+				}
+			},
+			expectedRate:  fastRate,
+			expectedCalls: []int{1, 1},
+		},
+		{
+			name: "Hedge starts the next provider on a failure",
+			config: RateConfig{
+				Strategy:   StrategyHedge,
+				HedgeDelay: time.Second,
+			},
+			providers: func() []*StubProvider {
+				return []*StubProvider{{Error: errProvider}, {Rate: fastRate}}
+			},
+			expectedRate:  fastRate,
+			expectedCalls: []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			providers := tt.providers()
+			ports := make([]RatePort, len(providers))
+			for i, provider := range providers {
+				ports[i] = provider
+			}
+
+			service := NewService(tt.config, &StubLogger{}, ports...)
+			rate, err := service.ExchangeRate(port.DefaultCurrencyPair)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedRate, rate)
+
+			for i, provider := range providers {
+				if tt.expectedCalls[i] == 0 {
+					require.Zero(t, provider.Calls(), "provider %d was called", i)
+					continue
+				}
+
+				// The losers of a race may be started after the winner returned
+				expected := tt.expectedCalls[i]
+				require.Eventually(
+					t,
+					func() bool { return provider.Calls() == expected },
+					time.Second,
+					time.Millisecond,
+					"unexpected calls of the provider %d", i,
+				)
+			}
+		})
+	}
+}
+
+func TestRaceCancelsTheLosers(t *testing.T) {
+	t.Parallel()
+
+	slow := &StubProvider{Delay: time.Minute}
+	fast := &StubProvider{Rate: port.Rate{Value: 1}}
+
+	service := NewService(
+		RateConfig{Strategy: StrategyRace},
+		&StubLogger{},
+		slow,
+		fast,
+	)
+
+	_, err := service.ExchangeRate(port.DefaultCurrencyPair)
+	require.NoError(t, err)
+
+	require.Eventually(t, slow.Canceled, time.Second, time.Millisecond)
+}
+
+func TestStrategyDecode(t *testing.T) {
+	t.Parallel()
+
+	var strategy Strategy
+	require.NoError(t, strategy.Decode("race"))
+	require.Equal(t, StrategyRace, strategy)
+
+	require.ErrorIs(t, strategy.Decode("fastest"), ErrUnknownStrategy)
+}
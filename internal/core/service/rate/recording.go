@@ -0,0 +1,62 @@
+package rate
+
+import (
+	"context"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+type Recorder interface {
+	Record(rate port.Rate) error
+}
+
+// RecordingProvider records every rate the wrapped provider fetches
+// successfully. A failed record is logged and doesn't fail the request.
+type RecordingProvider struct {
+	provider RatePort
+	recorder Recorder
+	logger   port.Logger
+	now      func() time.Time
+}
+
+func NewRecordingProvider(
+	logger port.Logger,
+	provider RatePort,
+	recorder Recorder,
+) *RecordingProvider {
+	return &RecordingProvider{
+		provider: provider,
+		recorder: recorder,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+func (p *RecordingProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *RecordingProvider) ExchangeRate(
+	ctx context.Context,
+	pair port.CurrencyPair,
+) (port.Rate, error) {
+	rate, err := p.provider.ExchangeRate(ctx, pair)
+	if err != nil {
+		return rate, err
+	}
+
+	if rate.FetchedAt.IsZero() {
+		rate.FetchedAt = p.now()
+	}
+
+	if len(rate.Providers) == 0 {
+		rate.Providers = []string{p.provider.Name()}
+	}
+
+	if err = p.recorder.Record(rate); err != nil {
+		p.logger.Errorf("Error, %v %v: cannot record the rate: %v", p.Name(), pair, err)
+	}
+
+	return rate, nil
+}
@@ -0,0 +1,93 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+type StubRecorder struct {
+	Rates []port.Rate
+	Err   error
+}
+
+func (s *StubRecorder) Record(rate port.Rate) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Rates = append(s.Rates, rate)
+	return nil
+}
+
+func TestRecordingProvider(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	errProvider := errors.New("provider error")
+
+	tests := []struct {
+		name            string
+		provider        *StubProvider
+		recorderErr     error
+		expectedRate    port.Rate
+		expectedErr     error
+		expectedRecords int
+	}{
+		{
+			name: "Rate recorded",
+			provider: &StubProvider{
+				ProviderName: "Binance",
+				Rate:         port.Rate{Pair: port.DefaultCurrencyPair, Value: 1},
+			},
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     1,
+				Providers: []string{"Binance"},
+				FetchedAt: now,
+			},
+			expectedRecords: 1,
+		},
+		{
+			name:        "Failed rate not recorded",
+			provider:    &StubProvider{Error: errProvider},
+			expectedErr: errProvider,
+		},
+		{
+			name: "Record error ignored",
+			provider: &StubProvider{
+				ProviderName: "Binance",
+				Rate:         port.Rate{Pair: port.DefaultCurrencyPair, Value: 1},
+			},
+			recorderErr: errors.New("recorder error"),
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     1,
+				Providers: []string{"Binance"},
+				FetchedAt: now,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := &StubRecorder{Err: tt.recorderErr}
+			provider := NewRecordingProvider(&StubLogger{}, tt.provider, recorder)
+			provider.now = func() time.Time { return now }
+
+			rate, err := provider.ExchangeRate(context.Background(), port.DefaultCurrencyPair)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedRate, rate)
+			require.Len(t, recorder.Rates, tt.expectedRecords)
+		})
+	}
+}
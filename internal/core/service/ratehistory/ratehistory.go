@@ -0,0 +1,145 @@
+package ratehistory
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+const (
+	_maxCandles = 1000
+	// How far back RateAt looks for a rate before the requested time
+	_rateAtLookback = time.Hour
+)
+
+var (
+	ErrInvalidInterval = errors.New("interval must be positive")
+	ErrInvalidRange    = errors.New("the start of the range must be before its end")
+	ErrTooManyCandles  = errors.New("too many intervals in the range")
+	ErrRepository      = errors.New("rate history repository error")
+	ErrNoRate          = errors.New("no rate fetched around the time")
+)
+
+type Repository interface {
+	Append(rate port.Rate) error
+	Range(pair port.CurrencyPair, from, to time.Time) ([]port.Rate, error)
+}
+
+// Candle holds the OHLC prices of the rates fetched within the interval
+// starting at Start
+type Candle struct {
+	Start time.Time `json:"start"`
+	Open  float32   `json:"open"`
+	High  float32   `json:"high"`
+	Low   float32   `json:"low"`
+	Close float32   `json:"close"`
+	Count int       `json:"count"`
+}
+
+type Service struct {
+	repository Repository
+	now        func() time.Time
+}
+
+func NewService(repository Repository) *Service {
+	return &Service{
+		repository: repository,
+		now:        time.Now,
+	}
+}
+
+// Record stores the fetched rate, a rate without the time
+// it was fetched at is stored with the current time
+func (s *Service) Record(rate port.Rate) error {
+	if rate.FetchedAt.IsZero() {
+		rate.FetchedAt = s.now()
+	}
+
+	if err := s.repository.Append(rate); err != nil {
+		return errors.Join(err, ErrRepository)
+	}
+
+	return nil
+}
+
+// RateAt returns the last rate fetched before the time, looking back
+// no further than an hour. ErrNoRate is returned if there is none.
+func (s *Service) RateAt(pair port.CurrencyPair, at time.Time) (port.Rate, error) {
+	rates, err := s.repository.Range(pair, at.Add(-_rateAtLookback), at)
+	if err != nil {
+		return port.Rate{}, errors.Join(err, ErrRepository)
+	}
+
+	var last port.Rate
+	for _, rate := range rates {
+		if !rate.FetchedAt.Before(last.FetchedAt) {
+			last = rate
+		}
+	}
+
+	if last.FetchedAt.IsZero() {
+		return port.Rate{}, ErrNoRate
+	}
+
+	return last, nil
+}
+
+// History returns the candles of the rates fetched within [from, to).
+// The candles are aligned to the multiples of the interval, e.g. to
+// the start of an hour for 1h, the intervals without rates are skipped.
+func (s *Service) History(
+	pair port.CurrencyPair,
+	from, to time.Time,
+	interval time.Duration,
+) ([]Candle, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	if !from.Before(to) {
+		return nil, ErrInvalidRange
+	}
+
+	if to.Sub(from)/interval >= _maxCandles {
+		return nil, ErrTooManyCandles
+	}
+
+	rates, err := s.repository.Range(pair, from, to)
+	if err != nil {
+		return nil, errors.Join(err, ErrRepository)
+	}
+
+	sort.SliceStable(rates, func(i, j int) bool {
+		return rates[i].FetchedAt.Before(rates[j].FetchedAt)
+	})
+
+	candles := []Candle{}
+	for _, rate := range rates {
+		start := rate.FetchedAt.Truncate(interval).UTC()
+
+		last := len(candles) - 1
+		if last < 0 || !candles[last].Start.Equal(start) {
+			candles = append(candles, Candle{
+				Start: start,
+				Open:  rate.Value,
+				High:  rate.Value,
+				Low:   rate.Value,
+			})
+			last++
+		}
+
+		candle := &candles[last]
+		if rate.Value > candle.High {
+			candle.High = rate.Value
+		}
+		if rate.Value < candle.Low {
+			candle.Low = rate.Value
+		}
+		candle.Close = rate.Value
+		candle.Count++
+	}
+
+	return candles, nil
+}
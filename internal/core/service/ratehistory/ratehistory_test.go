@@ -0,0 +1,228 @@
+package ratehistory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+var errRepository = errors.New("repository error")
+
+type StubRepository struct {
+	Rates []port.Rate
+	Err   error
+}
+
+func (s *StubRepository) Append(rate port.Rate) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Rates = append(s.Rates, rate)
+	return nil
+}
+
+func (s *StubRepository) Range(
+	pair port.CurrencyPair,
+	from, to time.Time,
+) ([]port.Rate, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	var rates []port.Rate
+	for _, rate := range s.Rates {
+		if rate.Pair == pair && !rate.FetchedAt.Before(from) && rate.FetchedAt.Before(to) {
+			rates = append(rates, rate)
+		}
+	}
+
+	return rates, nil
+}
+
+var _start = time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+
+func rateAt(offset time.Duration, value float32) port.Rate {
+	return port.Rate{
+		Pair:      port.DefaultCurrencyPair,
+		Value:     value,
+		FetchedAt: _start.Add(offset),
+	}
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{}
+	service := NewService(repository)
+	service.now = func() time.Time { return _start }
+
+	require.NoError(t, service.Record(port.Rate{Pair: port.DefaultCurrencyPair}))
+	require.Equal(t, _start, repository.Rates[0].FetchedAt)
+
+	repository.Err = errRepository
+	require.ErrorIs(t, service.Record(rateAt(0, 1)), ErrRepository)
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	rates := []port.Rate{
+		rateAt(10*time.Minute, 101),
+		rateAt(0, 100),
+		rateAt(20*time.Minute, 99),
+		rateAt(50*time.Minute, 102),
+		rateAt(2*time.Hour+5*time.Minute, 110),
+		{
+			Pair:      port.CurrencyPair{Base: "ETH", Quote: "USD"},
+			Value:     1,
+			FetchedAt: _start,
+		},
+	}
+
+	tests := []struct {
+		name            string
+		from, to        time.Time
+		interval        time.Duration
+		repositoryErr   error
+		expectedCandles []Candle
+		expectedErr     error
+	}{
+		{
+			name:     "Hourly candles",
+			from:     _start,
+			to:       _start.Add(3 * time.Hour),
+			interval: time.Hour,
+			expectedCandles: []Candle{
+				{Start: _start, Open: 100, High: 102, Low: 99, Close: 102, Count: 4},
+				{
+					Start: _start.Add(2 * time.Hour),
+					Open:  110, High: 110, Low: 110, Close: 110,
+					Count: 1,
+				},
+			},
+		},
+		{
+			name:     "Half-hour candles within the range",
+			from:     _start.Add(15 * time.Minute),
+			to:       _start.Add(time.Hour),
+			interval: 30 * time.Minute,
+			expectedCandles: []Candle{
+				{Start: _start, Open: 99, High: 99, Low: 99, Close: 99, Count: 1},
+				{
+					Start: _start.Add(30 * time.Minute),
+					Open:  102, High: 102, Low: 102, Close: 102,
+					Count: 1,
+				},
+			},
+		},
+		{
+			name:            "No rates",
+			from:            _start.Add(-time.Hour),
+			to:              _start,
+			interval:        time.Hour,
+			expectedCandles: []Candle{},
+		},
+		{
+			name:        "Invalid interval",
+			from:        _start,
+			to:          _start.Add(time.Hour),
+			interval:    0,
+			expectedErr: ErrInvalidInterval,
+		},
+		{
+			name:        "Invalid range",
+			from:        _start,
+			to:          _start,
+			interval:    time.Hour,
+			expectedErr: ErrInvalidRange,
+		},
+		{
+			name:        "Too many candles",
+			from:        _start,
+			to:          _start.Add(24 * time.Hour),
+			interval:    time.Second,
+			expectedErr: ErrTooManyCandles,
+		},
+		{
+			name:          "Repository error",
+			from:          _start,
+			to:            _start.Add(time.Hour),
+			interval:      time.Hour,
+			repositoryErr: errRepository,
+			expectedErr:   ErrRepository,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := NewService(&StubRepository{Rates: rates, Err: tt.repositoryErr})
+
+			candles, err := service.History(
+				port.DefaultCurrencyPair,
+				tt.from,
+				tt.to,
+				tt.interval,
+			)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedCandles, candles)
+		})
+	}
+}
+
+func TestRateAt(t *testing.T) {
+	t.Parallel()
+
+	rates := []port.Rate{
+		rateAt(20*time.Minute, 101),
+		rateAt(0, 100),
+		rateAt(2*time.Hour, 110),
+	}
+
+	tests := []struct {
+		name          string
+		at            time.Time
+		repositoryErr error
+		expectedRate  port.Rate
+		expectedErr   error
+	}{
+		{
+			name:         "Last rate before the time",
+			at:           _start.Add(time.Hour),
+			expectedRate: rateAt(20*time.Minute, 101),
+		},
+		{
+			name:        "No rate within the lookback",
+			at:          _start.Add(time.Hour + 30*time.Minute),
+			expectedErr: ErrNoRate,
+		},
+		{
+			name:          "Repository error",
+			at:            _start.Add(time.Hour),
+			repositoryErr: errRepository,
+			expectedErr:   ErrRepository,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := NewService(&StubRepository{Rates: rates, Err: tt.repositoryErr})
+
+			rate, err := service.RateAt(port.DefaultCurrencyPair, tt.at)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedRate, rate)
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+const (
+	_retryDelay = time.Minute
+	// The slots of a week plus a day are checked for the daily hour
+	// and the weekly day, so the week's slots are all seen from any hour
+	_checkedPeriod = 8 * 24 * time.Hour
+)
+
+var (
+	ErrLoadLastRun = errors.New("cannot load the last run time")
+	ErrSaveLastRun = errors.New("cannot save the last run time")
+	ErrNoNextSlot  = errors.New("schedule has no next slot")
+	// The daily or the weekly subscribers would never get the rate
+	ErrDailyHourNotScheduled = errors.New("schedule has no slot at the daily hour")
+	ErrWeeklyDayNotScheduled = errors.New("schedule has no slot at the daily hour of the weekly day")
+)
+
+type SchedulerConfig struct {
+	Enabled bool `default:"false"`
+	// The slots the hourly subscribers get the rate on
+	Cron        string `default:"0 * * * *"`
+	LastRunPath string `default:"./storage/scheduler.lastrun"`
+	// The hour of the slot the daily subscribers get the rate on
+	DailyHour int `default:"9"`
+	// The day the weekly subscribers get the rate on, 0 is Sunday
+	WeeklyDay time.Weekday `default:"1"`
+}
+
+// Schedule describes the slots of a recurring job,
+// e.g. a parsed cron expression
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+// TimestampStorage persists the time of the last executed slot, so
+// restarts neither repeat nor skip a slot
+type TimestampStorage interface {
+	Load() (time.Time, error)
+	Save(time.Time) error
+}
+
+type RateService interface {
+	ExchangeRate(pair port.CurrencyPair) (rate port.Rate, err error)
+}
+
+type SubscriptionService interface {
+	Subscriptions() (subscribers []port.User, err error)
+}
+
+type SenderService interface {
+	SendExchangeRate(rate port.Rate, subscribers ...port.User) error
+}
+
+type Service struct {
+	config              SchedulerConfig
+	logger              port.Logger
+	schedule            Schedule
+	lastRunStorage      TimestampStorage
+	rateService         RateService
+	subscriptionService SubscriptionService
+	senderService       SenderService
+	now                 func() time.Time
+}
+
+func NewService(
+	config SchedulerConfig,
+	logger port.Logger,
+	schedule Schedule,
+	lastRunStorage TimestampStorage,
+	rateService RateService,
+	subscriptionService SubscriptionService,
+	senderService SenderService,
+) *Service {
+	return &Service{
+		config:              config,
+		logger:              logger,
+		schedule:            schedule,
+		lastRunStorage:      lastRunStorage,
+		rateService:         rateService,
+		subscriptionService: subscriptionService,
+		senderService:       senderService,
+		now:                 time.Now,
+	}
+}
+
+// CheckSchedule makes sure the daily and the weekly subscribers get
+// the rate: the schedule must have a slot at the daily hour and one at
+// the daily hour of the weekly day. E.g. a schedule firing at 8 o'clock
+// only with the daily hour 9 would mail the hourly subscribers alone.
+func CheckSchedule(config SchedulerConfig, schedule Schedule, from time.Time) error {
+	daily, weekly := false, false
+
+	end := from.Add(_checkedPeriod)
+	for slot := schedule.Next(from); !slot.IsZero() && slot.Before(end); slot = schedule.Next(slot) {
+		if slot.Hour() != config.DailyHour {
+			continue
+		}
+
+		daily = true
+		if slot.Weekday() == config.WeeklyDay {
+			weekly = true
+			break
+		}
+	}
+
+	if !daily {
+		return fmt.Errorf("%w: %d", ErrDailyHourNotScheduled, config.DailyHour)
+	}
+
+	if !weekly {
+		return fmt.Errorf("%w: %v", ErrWeeklyDayNotScheduled, config.WeeklyDay)
+	}
+
+	return nil
+}
+
+// Run sends the exchange rate to the subscribers on every slot of the
+// schedule until the context is canceled. A failed run is retried
+// after a delay until it succeeds or the next slot comes.
+func (s *Service) Run(ctx context.Context) error {
+	for {
+		next, err := s.tick(s.now())
+		if err == nil && next.IsZero() {
+			return ErrNoNextSlot
+		}
+
+		wait := next.Sub(s.now())
+		if err != nil {
+			s.logger.Errorf("Error, scheduled mailing: %v", err)
+			wait = _retryDelay
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// tick runs the job if a slot is due at the moment now
+// and returns the time of the next slot
+func (s *Service) tick(now time.Time) (time.Time, error) {
+	lastRun, err := s.lastRunStorage.Load()
+	if err != nil {
+		return now, errors.Join(err, ErrLoadLastRun)
+	}
+
+	// The first start only sets the baseline, slots before it are not sent
+	if lastRun.IsZero() {
+		if err := s.lastRunStorage.Save(now); err != nil {
+			return now, errors.Join(err, ErrSaveLastRun)
+		}
+
+		return s.schedule.Next(now), nil
+	}
+
+	slot, cadences := s.latestDueSlot(lastRun, now)
+	if slot.IsZero() {
+		return s.schedule.Next(lastRun), nil
+	}
+
+	if err := s.sendExchangeRate(cadences); err != nil {
+		return now, err
+	}
+
+	if err := s.lastRunStorage.Save(slot); err != nil {
+		return now, errors.Join(err, ErrSaveLastRun)
+	}
+
+	s.logger.Infof("Scheduled mailing for %v is sent", slot)
+
+	return s.schedule.Next(slot), nil
+}
+
+// latestDueSlot returns the latest slot after the last run that is not
+// in the future, or zero time if there is none, and the cadences due
+// on the slots up to it. All missed slots are collapsed into one,
+// so subscribers don't get a burst of emails.
+func (s *Service) latestDueSlot(
+	lastRun, now time.Time,
+) (slot time.Time, cadences map[port.Cadence]bool) {
+	cadences = make(map[port.Cadence]bool)
+
+	next := s.schedule.Next(lastRun)
+	for !next.IsZero() && !next.After(now) {
+		slot = next
+		for _, cadence := range s.dueCadences(slot) {
+			cadences[cadence] = true
+		}
+		next = s.schedule.Next(next)
+	}
+
+	return slot, cadences
+}
+
+// dueCadences returns the cadences of the subscribers who get the rate
+// on the slot
+func (s *Service) dueCadences(slot time.Time) []port.Cadence {
+	cadences := []port.Cadence{port.CadenceHourly}
+	if slot.Hour() != s.config.DailyHour {
+		return cadences
+	}
+
+	cadences = append(cadences, port.CadenceDaily)
+	if slot.Weekday() == s.config.WeeklyDay {
+		cadences = append(cadences, port.CadenceWeekly)
+	}
+
+	return cadences
+}
+
+// sendExchangeRate sends the subscribers of the due cadences the rates
+// of the pairs they asked for. Nothing is sent unless all the rates
+// are known, so a retried run doesn't repeat a part of the mailing.
+// The failed deliveries are only logged, the slot is done once the rate
+// is sent, as a retry would mail everyone who has got it again.
+func (s *Service) sendExchangeRate(cadences map[port.Cadence]bool) error {
+	subscribers, err := s.subscriptionService.Subscriptions()
+	if err != nil {
+		return err
+	}
+
+	var due []port.User
+	for _, subscriber := range subscribers {
+		for cadence := range cadences {
+			if subscriber.Preferences.WantsCadence(cadence) {
+				due = append(due, subscriber)
+				break
+			}
+		}
+	}
+
+	groups := port.SubscribersByPair(due)
+
+	rates := make([]port.Rate, len(groups))
+	for i, group := range groups {
+		rates[i], err = s.rateService.ExchangeRate(group.Pair)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, group := range groups {
+		err := s.senderService.SendExchangeRate(rates[i], group.Subscribers...)
+		if err != nil {
+			s.logger.Errorf("Error, scheduled mailing of %v: %v", group.Pair, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,388 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+var (
+	errExchangeRate = errors.New("exchange rate error")
+	errSave         = errors.New("save error")
+)
+
+type StubLogger struct{}
+
+func (s *StubLogger) Info(...interface{})           {}
+func (s *StubLogger) Infof(string, ...interface{})  {}
+func (s *StubLogger) Debug(...interface{})          {}
+func (s *StubLogger) Debugf(string, ...interface{}) {}
+func (s *StubLogger) Error(...interface{})          {}
+func (s *StubLogger) Errorf(string, ...interface{}) {}
+
+// hourlySchedule fires at the beginning of every hour
+type hourlySchedule struct{}
+
+func (hourlySchedule) Next(t time.Time) time.Time {
+	return t.Truncate(time.Hour).Add(time.Hour)
+}
+
+type StubTimestampStorage struct {
+	timestamp time.Time
+	saveErr   error
+}
+
+func (s *StubTimestampStorage) Load() (time.Time, error) {
+	return s.timestamp, nil
+}
+
+func (s *StubTimestampStorage) Save(t time.Time) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+
+	s.timestamp = t
+	return nil
+}
+
+type StubRateService struct {
+	err error
+}
+
+func (s *StubRateService) ExchangeRate(pair port.CurrencyPair) (port.Rate, error) {
+	return port.Rate{Pair: pair, Value: 1.5}, s.err
+}
+
+type StubSubscriptionService struct {
+	subscribers []port.User
+}
+
+func (s *StubSubscriptionService) Subscriptions() ([]port.User, error) {
+	if s.subscribers == nil {
+		return []port.User{{Email: "test@example.com"}}, nil
+	}
+
+	return s.subscribers, nil
+}
+
+type StubSenderService struct {
+	err   error
+	calls int
+	// The recipients of every pair's rate, e.g. BTC/UAH a@example.com
+	sent []string
+}
+
+func (s *StubSenderService) SendExchangeRate(
+	rate port.Rate,
+	subscribers ...port.User,
+) error {
+	s.calls++
+	for _, subscriber := range subscribers {
+		s.sent = append(s.sent, rate.Pair.String()+" "+subscriber.Email)
+	}
+	return s.err
+}
+
+func TestTick(t *testing.T) {
+	now := time.Date(2023, 7, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		lastRun         time.Time
+		rateErr         error
+		sendErr         error
+		saveErr         error
+		expectedSends   int
+		expectedLastRun time.Time
+		expectedNext    time.Time
+		expectedErr     error
+	}{
+		{
+			name:            "First start sets the baseline",
+			lastRun:         time.Time{},
+			expectedSends:   0,
+			expectedLastRun: now,
+			expectedNext:    now.Truncate(time.Hour).Add(time.Hour),
+		},
+		{
+			name:            "No slot is due",
+			lastRun:         now.Truncate(time.Hour),
+			expectedSends:   0,
+			expectedLastRun: now.Truncate(time.Hour),
+			expectedNext:    now.Truncate(time.Hour).Add(time.Hour),
+		},
+		{
+			name:            "Due slot is sent",
+			lastRun:         now.Add(-time.Hour).Truncate(time.Hour),
+			expectedSends:   1,
+			expectedLastRun: now.Truncate(time.Hour),
+			expectedNext:    now.Truncate(time.Hour).Add(time.Hour),
+		},
+		{
+			name:            "Missed slots are sent once",
+			lastRun:         now.Add(-5 * time.Hour).Truncate(time.Hour),
+			expectedSends:   1,
+			expectedLastRun: now.Truncate(time.Hour),
+			expectedNext:    now.Truncate(time.Hour).Add(time.Hour),
+		},
+		{
+			name:            "Failed run is not saved",
+			lastRun:         now.Add(-time.Hour).Truncate(time.Hour),
+			rateErr:         errExchangeRate,
+			expectedSends:   0,
+			expectedLastRun: now.Add(-time.Hour).Truncate(time.Hour),
+			expectedNext:    now,
+			expectedErr:     errExchangeRate,
+		},
+		{
+			name:    "Failed deliveries finish the slot",
+			lastRun: now.Add(-time.Hour).Truncate(time.Hour),
+			sendErr: port.NewDeliveryError(0, []port.DeliveryFailure{{
+				Channel:   port.ChannelWebhook,
+				Recipient: "https://example.com/hook",
+				Err:       errors.New("500 Internal Server Error"),
+			}}),
+			expectedSends:   1,
+			expectedLastRun: now.Truncate(time.Hour),
+			expectedNext:    now.Truncate(time.Hour).Add(time.Hour),
+		},
+		{
+			name:            "Save error",
+			lastRun:         now.Add(-time.Hour).Truncate(time.Hour),
+			saveErr:         errSave,
+			expectedSends:   1,
+			expectedLastRun: now.Add(-time.Hour).Truncate(time.Hour),
+			expectedNext:    now,
+			expectedErr:     ErrSaveLastRun,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			storage := &StubTimestampStorage{timestamp: tt.lastRun, saveErr: tt.saveErr}
+			sender := &StubSenderService{err: tt.sendErr}
+			service := NewService(
+				SchedulerConfig{DailyHour: 12},
+				&StubLogger{},
+				hourlySchedule{},
+				storage,
+				&StubRateService{err: tt.rateErr},
+				&StubSubscriptionService{},
+				sender,
+			)
+
+			next, err := service.tick(now)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedNext, next)
+			require.Equal(t, tt.expectedSends, sender.calls)
+			require.Equal(t, tt.expectedLastRun, storage.timestamp)
+		})
+	}
+}
+
+func TestTickFailedDeliveryIsNotRepeated(t *testing.T) {
+	now := time.Date(2023, 7, 1, 12, 30, 0, 0, time.UTC)
+
+	storage := &StubTimestampStorage{timestamp: now.Add(-time.Hour).Truncate(time.Hour)}
+	sender := &StubSenderService{
+		err: port.NewDeliveryError(1, []port.DeliveryFailure{{
+			Channel:   port.ChannelEmail,
+			Recipient: "blocked@example.com",
+			Err:       port.ErrPermanentFailure,
+		}}),
+	}
+	service := NewService(
+		SchedulerConfig{DailyHour: 12},
+		&StubLogger{},
+		hourlySchedule{},
+		storage,
+		&StubRateService{},
+		&StubSubscriptionService{},
+		sender,
+	)
+
+	_, err := service.tick(now)
+	require.NoError(t, err)
+
+	// The retry of the Run loop comes a minute later
+	_, err = service.tick(now.Add(_retryDelay))
+	require.NoError(t, err)
+
+	require.Equal(t, 1, sender.calls)
+	require.Equal(t, now.Truncate(time.Hour), storage.timestamp)
+}
+
+func TestTickCadences(t *testing.T) {
+	btcUSD, _ := port.NewCurrencyPair("BTC", "USD")
+
+	subscribers := []port.User{
+		{Email: "daily@example.com"},
+		{
+			Email:       "hourly@example.com",
+			Preferences: port.Preferences{Cadence: port.CadenceHourly},
+		},
+		{
+			Email: "weekly@example.com",
+			Preferences: port.Preferences{
+				Cadence: port.CadenceWeekly,
+				Pairs:   []port.CurrencyPair{btcUSD},
+			},
+		},
+	}
+
+	// Monday
+	monday := time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		lastRun      time.Time
+		now          time.Time
+		expectedSent []string
+	}{
+		{
+			name:         "Hourly slot",
+			lastRun:      monday.Add(7 * time.Hour),
+			now:          monday.Add(8*time.Hour + time.Minute),
+			expectedSent: []string{"BTC/UAH hourly@example.com"},
+		},
+		{
+			name:    "Daily slot on the weekly day",
+			lastRun: monday.Add(8 * time.Hour),
+			now:     monday.Add(9*time.Hour + time.Minute),
+			expectedSent: []string{
+				"BTC/UAH daily@example.com",
+				"BTC/UAH hourly@example.com",
+				"BTC/USD weekly@example.com",
+			},
+		},
+		{
+			name:    "Daily slot on another day",
+			lastRun: monday.Add(32 * time.Hour),
+			now:     monday.Add(33*time.Hour + time.Minute),
+			expectedSent: []string{
+				"BTC/UAH daily@example.com",
+				"BTC/UAH hourly@example.com",
+			},
+		},
+		{
+			name:    "Missed daily slot is sent",
+			lastRun: monday.Add(8 * time.Hour),
+			now:     monday.Add(11*time.Hour + time.Minute),
+			expectedSent: []string{
+				"BTC/UAH daily@example.com",
+				"BTC/UAH hourly@example.com",
+				"BTC/USD weekly@example.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sender := &StubSenderService{}
+			service := NewService(
+				SchedulerConfig{DailyHour: 9, WeeklyDay: time.Monday},
+				&StubLogger{},
+				hourlySchedule{},
+				&StubTimestampStorage{timestamp: tt.lastRun},
+				&StubRateService{},
+				&StubSubscriptionService{subscribers: subscribers},
+				sender,
+			)
+
+			_, err := service.tick(tt.now)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedSent, sender.sent)
+		})
+	}
+}
+
+// dailySchedule fires every day at the hour
+type dailySchedule struct {
+	hour int
+}
+
+func (d dailySchedule) Next(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), d.hour, 0, 0, 0, t.Location())
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+// weekdaysSchedule fires at the hour from Monday to Friday
+type weekdaysSchedule struct {
+	hour int
+}
+
+func (w weekdaysSchedule) Next(t time.Time) time.Time {
+	next := dailySchedule{hour: w.hour}.Next(t)
+	for next.Weekday() == time.Saturday || next.Weekday() == time.Sunday {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+func TestCheckSchedule(t *testing.T) {
+	t.Parallel()
+
+	// Tuesday, after the daily hour
+	from := time.Date(2023, 7, 4, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		config      SchedulerConfig
+		schedule    Schedule
+		expectedErr error
+	}{
+		{
+			name:     "Hourly schedule",
+			config:   SchedulerConfig{DailyHour: 9, WeeklyDay: time.Monday},
+			schedule: hourlySchedule{},
+		},
+		{
+			name:     "Daily schedule at the daily hour",
+			config:   SchedulerConfig{DailyHour: 9, WeeklyDay: time.Monday},
+			schedule: dailySchedule{hour: 9},
+		},
+		{
+			name:        "Daily schedule at another hour",
+			config:      SchedulerConfig{DailyHour: 9, WeeklyDay: time.Monday},
+			schedule:    dailySchedule{hour: 8},
+			expectedErr: ErrDailyHourNotScheduled,
+		},
+		{
+			name:        "Weekly day without a slot",
+			config:      SchedulerConfig{DailyHour: 9, WeeklyDay: time.Sunday},
+			schedule:    weekdaysSchedule{hour: 9},
+			expectedErr: ErrWeeklyDayNotScheduled,
+		},
+		{
+			name:     "Weekly day with a slot",
+			config:   SchedulerConfig{DailyHour: 9, WeeklyDay: time.Monday},
+			schedule: weekdaysSchedule{hour: 9},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckSchedule(tt.config, tt.schedule, from)
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
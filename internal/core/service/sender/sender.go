@@ -1,6 +1,8 @@
 package sender
 
 import (
+	"errors"
+
 	"gses2-app/internal/core/port"
 )
 
@@ -9,16 +11,42 @@ type SenderPort interface {
 }
 
 type Service struct {
-	senderPort SenderPort
+	senderPorts []SenderPort
 }
 
-func NewService(provider SenderPort) *Service {
-	return &Service{senderPort: provider}
+// NewService creates a service which sends the rate through every
+// provider, e.g. by email and in Telegram
+func NewService(providers ...SenderPort) *Service {
+	return &Service{senderPorts: providers}
 }
 
+// SendExchangeRate sends the rate through all the providers. A failed
+// provider doesn't keep the others from sending. The failed recipients
+// of all the providers are reported by a single port.DeliveryError.
 func (s *Service) SendExchangeRate(
 	rate port.Rate,
 	users ...port.User,
 ) error {
-	return s.senderPort.SendExchangeRate(rate, users)
+	report := &port.DeliveryError{}
+
+	var errs []error
+	for _, senderPort := range s.senderPorts {
+		err := senderPort.SendExchangeRate(rate, users)
+
+		var deliveryErr *port.DeliveryError
+		if errors.As(err, &deliveryErr) {
+			report.Merge(deliveryErr)
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(report.Failures) > 0 {
+		errs = append(errs, report)
+	}
+
+	return errors.Join(errs...)
 }
@@ -10,13 +10,15 @@ import (
 )
 
 type StubProvider struct {
-	Err error
+	Err   error
+	Calls int
 }
 
 func (tp *StubProvider) SendExchangeRate(
 	rate port.Rate,
 	subscribers []port.User,
 ) error {
+	tp.Calls++
 	return tp.Err
 }
 
@@ -50,9 +52,59 @@ func TestSendExchangeRate(t *testing.T) {
 			provider := &StubProvider{Err: tt.providerErr}
 			service := NewService(provider)
 
-			err := service.SendExchangeRate(1.23, port.User{Email: "subscriber"})
+			err := service.SendExchangeRate(
+				port.Rate{Pair: port.DefaultCurrencyPair, Value: 1.23},
+				port.User{Email: "subscriber"},
+			)
 
-			require.Equal(t, tt.expectedErr, err)
+			require.ErrorIs(t, err, tt.expectedErr)
 		})
 	}
 }
+
+func TestSendExchangeRateFanOut(t *testing.T) {
+	t.Parallel()
+
+	email := &StubProvider{Err: errProvider}
+	telegram := &StubProvider{}
+	service := NewService(email, telegram)
+
+	err := service.SendExchangeRate(
+		port.Rate{Pair: port.DefaultCurrencyPair, Value: 1.23},
+		port.User{Email: "subscriber", TelegramChatID: 42},
+	)
+
+	require.ErrorIs(t, err, errProvider)
+	require.Equal(t, 1, email.Calls)
+	require.Equal(t, 1, telegram.Calls)
+}
+
+func TestSendExchangeRateDeliveryReport(t *testing.T) {
+	t.Parallel()
+
+	errRecipient := errors.New("recipient error")
+
+	email := &StubProvider{Err: port.NewDeliveryError(2, []port.DeliveryFailure{
+		{Channel: port.ChannelEmail, Recipient: "first", Err: errRecipient},
+	})}
+	telegram := &StubProvider{Err: port.NewDeliveryError(1, []port.DeliveryFailure{
+		{Channel: port.ChannelTelegram, Recipient: "42", Err: errRecipient},
+	})}
+	webhook := &StubProvider{Err: errProvider}
+	service := NewService(email, telegram, webhook)
+
+	err := service.SendExchangeRate(
+		port.Rate{Pair: port.DefaultCurrencyPair, Value: 1.23},
+		port.User{Email: "first"},
+	)
+
+	require.ErrorIs(t, err, errProvider)
+
+	var report *port.DeliveryError
+	require.ErrorAs(t, err, &report)
+	require.Equal(t, 3, report.Sent)
+	require.Equal(t, []port.DeliveryFailure{
+		{Channel: port.ChannelEmail, Recipient: "first", Err: errRecipient},
+		{Channel: port.ChannelTelegram, Recipient: "42", Err: errRecipient},
+	}, report.Failures)
+}
@@ -0,0 +1,141 @@
+package subscription
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gses2-app/internal/core/port"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidLimit  = errors.New("invalid limit")
+)
+
+// SubscriberQuery selects a page of the subscribers ordered by email
+type SubscriberQuery struct {
+	// The cursor of the previous page, the first page if empty
+	Cursor string
+	// The default limit if zero
+	Limit int
+	// The substring of the email to match, case insensitive
+	Search string
+}
+
+// StatusCounts are the numbers of the matched subscribers by status
+type StatusCounts struct {
+	Total   int
+	Active  int
+	Pending int
+}
+
+type SubscriberPage struct {
+	Subscribers []port.User
+	// The cursor of the next page, empty on the last page
+	NextCursor string
+	Counts     StatusCounts
+}
+
+// ListSubscribers returns a page of the subscribers, both the confirmed
+// and the pending ones, matching the search
+func (s *Service) ListSubscribers(query SubscriberQuery) (*SubscriberPage, error) {
+	limit := query.Limit
+	if limit == 0 {
+		limit = DefaultPageLimit
+	}
+
+	if limit < 0 || limit > MaxPageLimit {
+		return nil, fmt.Errorf(
+			"%w: %d, expected 1 to %d",
+			ErrInvalidLimit,
+			query.Limit,
+			MaxPageLimit,
+		)
+	}
+
+	after, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.userRepository.All()
+	if err != nil {
+		return nil, errors.Join(err, ErrUserRepository)
+	}
+
+	search := strings.ToLower(query.Search)
+	page := &SubscriberPage{Subscribers: []port.User{}}
+
+	var matched []port.User
+	for _, user := range users {
+		if !strings.Contains(strings.ToLower(user.Email), search) {
+			continue
+		}
+
+		page.Counts.Total++
+		if user.IsActive() {
+			page.Counts.Active++
+		} else {
+			page.Counts.Pending++
+		}
+
+		if user.Email > after {
+			matched = append(matched, user)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Email < matched[j].Email
+	})
+
+	if len(matched) > limit {
+		matched = matched[:limit]
+		page.NextCursor = encodeCursor(matched[limit-1].Email)
+	}
+	page.Subscribers = append(page.Subscribers, matched...)
+
+	return page, nil
+}
+
+// Subscriber returns the subscriber of the email, confirmed or not
+func (s *Service) Subscriber(email string) (*port.User, error) {
+	user, err := s.userRepository.FindByEmail(email)
+	if errors.Is(err, port.ErrCannotFindByEmail) {
+		return nil, ErrNotSubscribed
+	}
+
+	if err != nil {
+		return nil, errors.Join(err, ErrUserRepository)
+	}
+
+	return user, nil
+}
+
+// RemoveSubscriber removes the subscriber without the unsubscribe token,
+// along with what the subscriber owns like on unsubscribe
+func (s *Service) RemoveSubscriber(email string) error {
+	return s.remove(&port.User{Email: email})
+}
+
+// The cursor is the last email of the page, encoded so it's opaque
+// to the clients and safe in a query string
+func encodeCursor(email string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(email))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	email, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCursor, cursor)
+	}
+
+	return string(email), nil
+}
@@ -0,0 +1,133 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+func newAdminTestService(userRepository *StubUserRepository) *Service {
+	return newTestService(userRepository, &StubTokenSigner{}, &StubConfirmationSender{})
+}
+
+func TestListSubscribers(t *testing.T) {
+	t.Parallel()
+
+	userRepository := &StubUserRepository{Users: []port.User{
+		{Email: "carol@example.com", Status: port.UserStatusActive},
+		{Email: "alice@example.com"},
+		{Email: "dave@test.com", Status: port.UserStatusPending},
+		{Email: "bob@example.com", Status: port.UserStatusPending},
+	}}
+	service := newAdminTestService(userRepository)
+
+	emails := func(page *SubscriberPage) []string {
+		var emails []string
+		for _, user := range page.Subscribers {
+			emails = append(emails, user.Email)
+		}
+		return emails
+	}
+
+	page, err := service.ListSubscribers(SubscriberQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice@example.com", "bob@example.com"}, emails(page))
+	require.Equal(t, StatusCounts{Total: 4, Active: 2, Pending: 2}, page.Counts)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = service.ListSubscribers(SubscriberQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Equal(t, []string{"carol@example.com", "dave@test.com"}, emails(page))
+	require.Empty(t, page.NextCursor)
+
+	page, err = service.ListSubscribers(SubscriberQuery{Search: "EXAMPLE"})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"alice@example.com",
+		"bob@example.com",
+		"carol@example.com",
+	}, emails(page))
+	require.Equal(t, StatusCounts{Total: 3, Active: 2, Pending: 1}, page.Counts)
+
+	page, err = service.ListSubscribers(SubscriberQuery{Search: "nobody"})
+	require.NoError(t, err)
+	require.Empty(t, page.Subscribers)
+	require.Empty(t, page.NextCursor)
+}
+
+func TestListSubscribersInvalidQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		query       SubscriberQuery
+		expectedErr error
+	}{
+		{
+			name:        "Negative limit",
+			query:       SubscriberQuery{Limit: -1},
+			expectedErr: ErrInvalidLimit,
+		},
+		{
+			name:        "Too large limit",
+			query:       SubscriberQuery{Limit: MaxPageLimit + 1},
+			expectedErr: ErrInvalidLimit,
+		},
+		{
+			name:        "Malformed cursor",
+			query:       SubscriberQuery{Cursor: "not a cursor"},
+			expectedErr: ErrInvalidCursor,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := newAdminTestService(&StubUserRepository{})
+
+			_, err := service.ListSubscribers(tt.query)
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestSubscriber(t *testing.T) {
+	t.Parallel()
+
+	userRepository := &StubUserRepository{Users: []port.User{
+		{Email: "test@example.com", Status: port.UserStatusPending},
+	}}
+	service := newAdminTestService(userRepository)
+
+	user, err := service.Subscriber("test@example.com")
+	require.NoError(t, err)
+	require.Equal(t, port.UserStatusPending, user.Status)
+
+	_, err = service.Subscriber("unknown@example.com")
+	require.ErrorIs(t, err, ErrNotSubscribed)
+
+	require.NoError(t, service.RemoveSubscriber("test@example.com"))
+	require.Empty(t, userRepository.Users)
+
+	require.ErrorIs(t, service.RemoveSubscriber("test@example.com"), ErrNotSubscribed)
+}
+
+func TestSubscriberRepositoryError(t *testing.T) {
+	t.Parallel()
+
+	errRepository := errors.New("repository error")
+	service := newAdminTestService(&StubUserRepository{Err: errRepository})
+
+	_, err := service.ListSubscribers(SubscriberQuery{})
+	require.ErrorIs(t, err, ErrUserRepository)
+
+	_, err = service.Subscriber("test@example.com")
+	require.ErrorIs(t, err, ErrUserRepository)
+
+	require.ErrorIs(t, service.RemoveSubscriber("test@example.com"), ErrUserRepository)
+}
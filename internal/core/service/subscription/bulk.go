@@ -0,0 +1,219 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"gses2-app/internal/core/port"
+)
+
+var (
+	// ErrMalformedRecord is returned by a RecordReader for a row it can't
+	// decode, the reading goes on with the next row
+	ErrMalformedRecord = errors.New("malformed record")
+	ErrUnknownStatus   = errors.New("unknown status")
+	// The address must subscribe and confirm the subscription itself
+	ErrPendingImport = errors.New("pending subscriptions are not imported")
+)
+
+// SubscriberRecord is a subscriber in a bulk import or export file.
+// Only the email is required, the empty fields are the defaults.
+type SubscriberRecord struct {
+	Email    string
+	Status   string
+	Language string
+	Pairs    []string
+	Cadence  string
+	Channel  string
+}
+
+// RecordReader reads the records of an import file one by one,
+// it returns io.EOF after the last one
+type RecordReader interface {
+	Read() (SubscriberRecord, error)
+}
+
+// RecordWriter writes the records of an export file one by one
+type RecordWriter interface {
+	Write(record SubscriberRecord) error
+}
+
+// ImportReport tells what the import did with the rows of the file,
+// the rows are numbered from one
+type ImportReport struct {
+	DryRun bool
+	Total  int
+	// The rows added, or those which would be added on a dry run
+	Imported   int
+	Duplicates int
+	Invalid    int
+	Errors     []ImportError
+}
+
+// ImportError is the reason the row wasn't imported
+type ImportError struct {
+	Row    int
+	Email  string
+	Reason string
+}
+
+// Import adds the subscribers of the records as confirmed ones. Every row
+// is validated like a subscription and the addresses already stored or
+// repeated in the file are skipped as duplicates. The new subscribers are
+// stored at once after the file is read. A dry run validates the rows
+// and reports the outcome without storing anything.
+func (s *Service) Import(reader RecordReader, dryRun bool) (*ImportReport, error) {
+	users, err := s.userRepository.All()
+	if err != nil {
+		return nil, errors.Join(err, ErrUserRepository)
+	}
+
+	seen := make(map[string]bool, len(users))
+	for _, user := range users {
+		seen[user.Email] = true
+	}
+
+	report := &ImportReport{DryRun: dryRun, Errors: []ImportError{}}
+	var imported []port.User
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil && !errors.Is(err, ErrMalformedRecord) {
+			return report, err
+		}
+
+		report.Total++
+		if err != nil {
+			report.invalid(row, record.Email, err.Error())
+			continue
+		}
+
+		user, err := s.importedUser(record)
+		if err != nil {
+			report.invalid(row, record.Email, err.Error())
+			continue
+		}
+
+		if seen[user.Email] {
+			report.Duplicates++
+			continue
+		}
+		seen[user.Email] = true
+
+		imported = append(imported, *user)
+	}
+
+	if dryRun {
+		report.Imported = len(imported)
+		return report, nil
+	}
+
+	return report, s.addImported(report, imported)
+}
+
+// addImported stores the imported users in one batch. The batch refused
+// for an address subscribed since the import has started is added one
+// by one, so only the subscribed addresses are skipped as duplicates.
+func (s *Service) addImported(report *ImportReport, users []port.User) error {
+	err := s.userRepository.AddAll(users)
+	if err == nil {
+		report.Imported = len(users)
+		return nil
+	}
+
+	if !errors.Is(err, port.ErrAlreadyAdded) {
+		return errors.Join(err, ErrUserRepository)
+	}
+
+	for i := range users {
+		err = s.userRepository.Add(&users[i])
+		if errors.Is(err, port.ErrAlreadyAdded) {
+			report.Duplicates++
+			continue
+		}
+
+		if err != nil {
+			return errors.Join(err, ErrUserRepository)
+		}
+
+		report.Imported++
+	}
+
+	return nil
+}
+
+// Export writes every subscriber, confirmed or pending, as a record
+func (s *Service) Export(writer RecordWriter) error {
+	users, err := s.userRepository.All()
+	if err != nil {
+		return errors.Join(err, ErrUserRepository)
+	}
+
+	for _, user := range users {
+		if err = writer.Write(newSubscriberRecord(user)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) importedUser(record SubscriberRecord) (*port.User, error) {
+	switch port.UserStatus(record.Status) {
+	case "", port.UserStatusActive:
+	case port.UserStatusPending:
+		return nil, ErrPendingImport
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, record.Status)
+	}
+
+	email, err := s.normalizeEmail(record.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &port.User{Email: email, Status: port.UserStatusActive}
+
+	// The chat isn't imported, so the telegram channel is refused
+	user.Preferences, err = s.applyPreferences(user, PreferencesUpdate{
+		Language: &record.Language,
+		Pairs:    &record.Pairs,
+		Cadence:  &record.Cadence,
+		Channel:  &record.Channel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *ImportReport) invalid(row int, email, reason string) {
+	r.Invalid++
+	r.Errors = append(r.Errors, ImportError{Row: row, Email: email, Reason: reason})
+}
+
+func newSubscriberRecord(user port.User) SubscriberRecord {
+	status := port.UserStatusActive
+	if !user.IsActive() {
+		status = user.Status
+	}
+
+	pairs := make([]string, len(user.Preferences.Pairs))
+	for i, pair := range user.Preferences.Pairs {
+		pairs[i] = pair.String()
+	}
+
+	return SubscriberRecord{
+		Email:    user.Email,
+		Status:   string(status),
+		Language: user.Preferences.Language,
+		Pairs:    pairs,
+		Cadence:  string(user.Preferences.Cadence),
+		Channel:  user.Preferences.Channel,
+	}
+}
@@ -0,0 +1,229 @@
+package subscription
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+// StubRecordReader returns the records and then the error, io.EOF
+// if it's not set
+type StubRecordReader struct {
+	records []SubscriberRecord
+	errs    map[int]error
+	err     error
+	read    int
+	// Called before the first record is read, i.e. during the import
+	onRead func()
+}
+
+func (r *StubRecordReader) Read() (SubscriberRecord, error) {
+	if r.read == 0 && r.onRead != nil {
+		r.onRead()
+	}
+
+	if r.read >= len(r.records) {
+		if r.err != nil {
+			return SubscriberRecord{}, r.err
+		}
+		return SubscriberRecord{}, io.EOF
+	}
+
+	record := r.records[r.read]
+	err := r.errs[r.read]
+	r.read++
+
+	return record, err
+}
+
+type StubRecordWriter struct {
+	records []SubscriberRecord
+}
+
+func (w *StubRecordWriter) Write(record SubscriberRecord) error {
+	w.records = append(w.records, record)
+	return nil
+}
+
+func newBulkTestService(userRepository *StubUserRepository) *Service {
+	return NewService(
+		SubscriptionConfig{Languages: []string{"en", "uk"}, MaxPairs: 2},
+		userRepository,
+		&StubTokenSigner{},
+		&StubTokenSigner{Purpose: "manage:"},
+		&StubConfirmationSender{},
+		&StubDomainBlocklist{Domains: []string{"mailinator.com"}},
+	)
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	btcUSD, _ := port.NewCurrencyPair("BTC", "USD")
+
+	records := []SubscriberRecord{
+		{Email: "New@Example.com", Language: "uk", Pairs: []string{"BTC/USD"}},
+		{Email: "stored@example.com"},
+		{Email: "new@example.com"},
+		{Email: "not an email"},
+		{Email: "throwaway@mailinator.com"},
+		{Email: "pending@example.com", Status: "pending"},
+		{Email: "hourly@example.com", Cadence: "hourly", Channel: "email"},
+		{Email: "french@example.com", Language: "fr"},
+		{Email: "broken@example.com"},
+	}
+	reader := func() *StubRecordReader {
+		return &StubRecordReader{
+			records: records,
+			errs:    map[int]error{8: ErrMalformedRecord},
+		}
+	}
+	stored := []port.User{{Email: "stored@example.com", Status: port.UserStatusActive}}
+
+	t.Run("Dry run", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{Users: append([]port.User(nil), stored...)}
+		service := newBulkTestService(userRepository)
+
+		report, err := service.Import(reader(), true)
+		require.NoError(t, err)
+		require.True(t, report.DryRun)
+		require.Equal(t, 9, report.Total)
+		require.Equal(t, 2, report.Imported)
+		require.Equal(t, 2, report.Duplicates)
+		require.Equal(t, 5, report.Invalid)
+		require.Equal(t, stored, userRepository.Users)
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{Users: append([]port.User(nil), stored...)}
+		service := newBulkTestService(userRepository)
+
+		report, err := service.Import(reader(), false)
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Imported)
+		require.Equal(t, 1, userRepository.batches)
+
+		var rows []int
+		for _, importErr := range report.Errors {
+			rows = append(rows, importErr.Row)
+		}
+		require.Equal(t, []int{4, 5, 6, 8, 9}, rows)
+		require.Equal(t, "throwaway@mailinator.com", report.Errors[1].Email)
+
+		require.Equal(t, []port.User{
+			stored[0],
+			{
+				Email:  "new@example.com",
+				Status: port.UserStatusActive,
+				Preferences: port.Preferences{
+					Language: "uk",
+					Pairs:    []port.CurrencyPair{btcUSD},
+					Cadence:  port.DefaultCadence,
+				},
+			},
+			{
+				Email:  "hourly@example.com",
+				Status: port.UserStatusActive,
+				Preferences: port.Preferences{
+					Cadence: port.CadenceHourly,
+					Channel: port.ChannelEmail,
+				},
+			},
+		}, userRepository.Users)
+	})
+}
+
+func TestImportErrors(t *testing.T) {
+	t.Parallel()
+
+	errRead := errors.New("read error")
+	userRepository := &StubUserRepository{}
+	service := newBulkTestService(userRepository)
+
+	report, err := service.Import(&StubRecordReader{
+		records: []SubscriberRecord{{Email: "first@example.com"}},
+		err:     errRead,
+	}, false)
+	require.ErrorIs(t, err, errRead)
+	// Nothing is stored of a file which can't be read
+	require.Equal(t, 0, report.Imported)
+	require.Empty(t, userRepository.Users)
+
+	errRepository := errors.New("repository error")
+	service = newBulkTestService(&StubUserRepository{Err: errRepository})
+
+	_, err = service.Import(&StubRecordReader{}, false)
+	require.ErrorIs(t, err, ErrUserRepository)
+}
+
+func TestImportSubscribedMeanwhile(t *testing.T) {
+	t.Parallel()
+
+	userRepository := &StubUserRepository{}
+	service := newBulkTestService(userRepository)
+
+	// The address subscribes after the stored ones are loaded
+	reader := &StubRecordReader{records: []SubscriberRecord{
+		{Email: "first@example.com"},
+		{Email: "subscribed@example.com"},
+	}}
+	reader.onRead = func() {
+		userRepository.Users = []port.User{{Email: "subscribed@example.com"}}
+	}
+
+	report, err := service.Import(reader, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Imported)
+	require.Equal(t, 1, report.Duplicates)
+	require.Equal(t, []port.User{
+		{Email: "subscribed@example.com"},
+		{
+			Email:       "first@example.com",
+			Status:      port.UserStatusActive,
+			Preferences: port.Preferences{Cadence: port.DefaultCadence},
+		},
+	}, userRepository.Users)
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	btcUSD, _ := port.NewCurrencyPair("BTC", "USD")
+	service := newBulkTestService(&StubUserRepository{Users: []port.User{
+		{Email: "legacy@example.com"},
+		{Email: "pending@example.com", Status: port.UserStatusPending},
+		{
+			Email:  "preferences@example.com",
+			Status: port.UserStatusActive,
+			Preferences: port.Preferences{
+				Language: "uk",
+				Pairs:    []port.CurrencyPair{btcUSD, port.DefaultCurrencyPair},
+				Cadence:  port.CadenceWeekly,
+				Channel:  port.ChannelEmail,
+			},
+		},
+	}})
+
+	writer := &StubRecordWriter{}
+	require.NoError(t, service.Export(writer))
+	require.Equal(t, []SubscriberRecord{
+		{Email: "legacy@example.com", Status: "active", Pairs: []string{}},
+		{Email: "pending@example.com", Status: "pending", Pairs: []string{}},
+		{
+			Email:    "preferences@example.com",
+			Status:   "active",
+			Language: "uk",
+			Pairs:    []string{"BTC/USD", "BTC/UAH"},
+			Cadence:  "weekly",
+			Channel:  "email",
+		},
+	}, writer.records)
+}
@@ -0,0 +1,157 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"gses2-app/internal/core/port"
+)
+
+var (
+	ErrInvalidPreferences = errors.New("invalid preferences")
+	ErrNotActive          = errors.New("subscription is not confirmed")
+)
+
+// PreferencesUpdate holds the preferences to change, the nil ones
+// are kept. An empty value resets the preference to the default.
+type PreferencesUpdate struct {
+	Language *string
+	Pairs    *[]string
+	Cadence  *string
+	Channel  *string
+}
+
+// Preferences returns the preferences of the subscriber the token
+// of the unsubscribe link was issued for
+func (s *Service) Preferences(email, token string) (*port.Preferences, error) {
+	user, err := s.authorizedSubscriber(email, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user.Preferences, nil
+}
+
+// UpdatePreferences validates and stores the changed preferences
+// of the subscriber the token was issued for
+func (s *Service) UpdatePreferences(
+	email, token string,
+	update PreferencesUpdate,
+) (*port.Preferences, error) {
+	user, err := s.authorizedSubscriber(email, token)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences, err := s.applyPreferences(user, update)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Preferences = preferences
+	if err = s.userRepository.Update(user); err != nil {
+		return nil, errors.Join(err, ErrUserRepository)
+	}
+
+	return &user.Preferences, nil
+}
+
+func (s *Service) authorizedSubscriber(email, token string) (*port.User, error) {
+	if !s.managementSigner.Verify(email, token) {
+		return nil, ErrInvalidManagementToken
+	}
+
+	user, err := s.userRepository.FindByEmail(email)
+	if errors.Is(err, port.ErrCannotFindByEmail) {
+		return nil, ErrNotSubscribed
+	}
+
+	if err != nil {
+		return nil, errors.Join(err, ErrUserRepository)
+	}
+
+	if !user.IsActive() {
+		return nil, ErrNotActive
+	}
+
+	return user, nil
+}
+
+func (s *Service) applyPreferences(
+	user *port.User,
+	update PreferencesUpdate,
+) (port.Preferences, error) {
+	preferences := user.Preferences
+
+	if update.Language != nil {
+		language := strings.ToLower(strings.TrimSpace(*update.Language))
+		if language != "" && !slices.Contains(s.config.Languages, language) {
+			return preferences, fmt.Errorf(
+				"%w: unsupported language %q, expected one of %s",
+				ErrInvalidPreferences,
+				*update.Language,
+				strings.Join(s.config.Languages, ", "),
+			)
+		}
+		preferences.Language = language
+	}
+
+	if update.Pairs != nil {
+		pairs, err := s.parsePairs(*update.Pairs)
+		if err != nil {
+			return preferences, err
+		}
+		preferences.Pairs = pairs
+	}
+
+	if update.Cadence != nil {
+		cadence, err := port.ParseCadence(*update.Cadence)
+		if err != nil {
+			return preferences, errors.Join(ErrInvalidPreferences, err)
+		}
+		preferences.Cadence = cadence
+	}
+
+	if update.Channel != nil {
+		channel, err := port.ParseChannel(*update.Channel)
+		if err != nil {
+			return preferences, errors.Join(ErrInvalidPreferences, err)
+		}
+
+		// The subscriber would get nothing
+		if channel == port.ChannelTelegram && user.TelegramChatID == 0 {
+			return preferences, ErrTelegramNotLinked
+		}
+		preferences.Channel = channel
+	}
+
+	return preferences, nil
+}
+
+// parsePairs validates the pairs and drops the repeated ones
+func (s *Service) parsePairs(values []string) ([]port.CurrencyPair, error) {
+	var pairs []port.CurrencyPair
+	for _, value := range values {
+		pair, err := port.ParseCurrencyPair(value)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidPreferences, err)
+		}
+
+		if !slices.Contains(pairs, pair) {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	if len(pairs) > s.config.MaxPairs {
+		return nil, fmt.Errorf(
+			"%w: at most %d pairs are allowed",
+			ErrInvalidPreferences,
+			s.config.MaxPairs,
+		)
+	}
+
+	return pairs, nil
+}
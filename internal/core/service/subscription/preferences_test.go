@@ -0,0 +1,217 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+func newPreferencesTestService(userRepository *StubUserRepository) *Service {
+	return NewService(
+		SubscriptionConfig{
+			ConfirmationTTL: time.Hour,
+			Languages:       []string{"en", "uk"},
+			MaxPairs:        2,
+		},
+		userRepository,
+		&StubTokenSigner{},
+		&StubTokenSigner{Purpose: "manage:"},
+		&StubConfirmationSender{},
+		&StubDomainBlocklist{},
+	)
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	t.Parallel()
+
+	btcUSD, _ := port.NewCurrencyPair("BTC", "USD")
+	ethUAH, _ := port.NewCurrencyPair("ETH", "UAH")
+
+	users := []port.User{
+		{Email: "active@example.com", Status: port.UserStatusActive},
+		{Email: "pending@example.com", Status: port.UserStatusPending},
+		{
+			Email:          "linked@example.com",
+			TelegramChatID: 42,
+			Preferences:    port.Preferences{Language: "uk", Cadence: port.CadenceWeekly},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		email       string
+		token       string
+		update      PreferencesUpdate
+		expected    port.Preferences
+		expectedErr error
+	}{
+		{
+			name:  "Every preference",
+			email: "active@example.com",
+			token: "signed:manage:active@example.com",
+			update: PreferencesUpdate{
+				Language: stringPointer("UK"),
+				Pairs:    &[]string{"btc/usd", "ETH/UAH", "BTC/USD"},
+				Cadence:  stringPointer("hourly"),
+				Channel:  stringPointer("email"),
+			},
+			expected: port.Preferences{
+				Language: "uk",
+				Pairs:    []port.CurrencyPair{btcUSD, ethUAH},
+				Cadence:  port.CadenceHourly,
+				Channel:  port.ChannelEmail,
+			},
+		},
+		{
+			name:   "Unchanged preferences are kept",
+			email:  "linked@example.com",
+			token:  "signed:manage:linked@example.com",
+			update: PreferencesUpdate{Channel: stringPointer("telegram")},
+			expected: port.Preferences{
+				Language: "uk",
+				Cadence:  port.CadenceWeekly,
+				Channel:  port.ChannelTelegram,
+			},
+		},
+		{
+			name:  "Empty values reset to the defaults",
+			email: "linked@example.com",
+			token: "signed:manage:linked@example.com",
+			update: PreferencesUpdate{
+				Language: stringPointer(""),
+				Cadence:  stringPointer(""),
+			},
+			expected: port.Preferences{Cadence: port.DefaultCadence},
+		},
+		{
+			name:        "Invalid token",
+			email:       "active@example.com",
+			token:       "signed:manage:linked@example.com",
+			expectedErr: ErrInvalidManagementToken,
+		},
+		{
+			name:        "Unknown email",
+			email:       "unknown@example.com",
+			token:       "signed:manage:unknown@example.com",
+			expectedErr: ErrNotSubscribed,
+		},
+		{
+			name:        "Pending subscriber",
+			email:       "pending@example.com",
+			token:       "signed:manage:pending@example.com",
+			expectedErr: ErrNotActive,
+		},
+		{
+			name:        "Unsupported language",
+			email:       "active@example.com",
+			token:       "signed:manage:active@example.com",
+			update:      PreferencesUpdate{Language: stringPointer("fr")},
+			expectedErr: ErrInvalidPreferences,
+		},
+		{
+			name:        "Invalid pair",
+			email:       "active@example.com",
+			token:       "signed:manage:active@example.com",
+			update:      PreferencesUpdate{Pairs: &[]string{"BTCUAH"}},
+			expectedErr: port.ErrInvalidCurrencyPair,
+		},
+		{
+			name:  "Too many pairs",
+			email: "active@example.com",
+			token: "signed:manage:active@example.com",
+			update: PreferencesUpdate{
+				Pairs: &[]string{"BTC/UAH", "BTC/USD", "ETH/UAH"},
+			},
+			expectedErr: ErrInvalidPreferences,
+		},
+		{
+			name:        "Invalid cadence",
+			email:       "active@example.com",
+			token:       "signed:manage:active@example.com",
+			update:      PreferencesUpdate{Cadence: stringPointer("monthly")},
+			expectedErr: port.ErrInvalidCadence,
+		},
+		{
+			name:        "Invalid channel",
+			email:       "active@example.com",
+			token:       "signed:manage:active@example.com",
+			update:      PreferencesUpdate{Channel: stringPointer("webhook")},
+			expectedErr: port.ErrInvalidChannel,
+		},
+		{
+			name:        "Telegram isn't linked",
+			email:       "active@example.com",
+			token:       "signed:manage:active@example.com",
+			update:      PreferencesUpdate{Channel: stringPointer("telegram")},
+			expectedErr: ErrTelegramNotLinked,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			userRepository := &StubUserRepository{
+				Users: append([]port.User(nil), users...),
+			}
+			service := newPreferencesTestService(userRepository)
+
+			preferences, err := service.UpdatePreferences(tt.email, tt.token, tt.update)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				require.Equal(t, users, userRepository.Users)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, *preferences)
+
+			stored, err := userRepository.FindByEmail(tt.email)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, stored.Preferences)
+		})
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	t.Parallel()
+
+	preferences := port.Preferences{Language: "uk", Cadence: port.CadenceHourly}
+	service := newPreferencesTestService(&StubUserRepository{
+		Users: []port.User{{Email: "test@example.com", Preferences: preferences}},
+	})
+
+	got, err := service.Preferences("test@example.com", "signed:manage:test@example.com")
+	require.NoError(t, err)
+	require.Equal(t, preferences, *got)
+
+	_, err = service.Preferences("test@example.com", "token")
+	require.ErrorIs(t, err, ErrInvalidManagementToken)
+
+	_, err = service.Preferences("test@example.com", "signed:test@example.com")
+	require.ErrorIs(t, err, ErrInvalidManagementToken)
+}
+
+func TestUpdatePreferencesRepositoryError(t *testing.T) {
+	t.Parallel()
+
+	errRepository := errors.New("repository error")
+	service := newPreferencesTestService(&StubUserRepository{Err: errRepository})
+
+	_, err := service.UpdatePreferences(
+		"test@example.com",
+		"signed:manage:test@example.com",
+		PreferencesUpdate{},
+	)
+	require.ErrorIs(t, err, errRepository)
+	require.ErrorIs(t, err, ErrUserRepository)
+}
@@ -1,41 +1,274 @@
 package subscription
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
+	"time"
+
 	"gses2-app/internal/core/port"
 )
 
+const _confirmationTokenSize = 32
+
 var (
-	ErrAlreadySubscribed = errors.New("email is already subscribed")
-	ErrUserRepository    = errors.New("user repository error")
+	ErrAlreadySubscribed        = errors.New("email is already subscribed")
+	ErrConfirmationPending      = errors.New("subscription is waiting for confirmation")
+	ErrNotSubscribed            = errors.New("email is not subscribed")
+	ErrInvalidToken             = errors.New("invalid unsubscribe token")
+	ErrInvalidManagementToken   = errors.New("invalid management token")
+	ErrInvalidConfirmationToken = errors.New("invalid confirmation token")
+	ErrConfirmationExpired      = errors.New("confirmation token is expired")
+	ErrSendConfirmation         = errors.New("cannot send confirmation email")
+	ErrUserRepository           = errors.New("user repository error")
+	ErrRemoveOwned              = errors.New("cannot remove what the subscriber owns")
 )
 
+type SubscriptionConfig struct {
+	ConfirmationTTL time.Duration `default:"24h"`
+
+	// The domain of an email is always lower cased, the local part
+	// is lower cased too unless it's configured as case sensitive
+	CaseSensitiveLocalPart bool `default:"false"`
+	// Strip the "+tag" suffix of the local part, so user+news@example.com
+	// is stored as user@example.com
+	StripPlusTag bool `default:"false"`
+	// The file with the blocked domains, one per line
+	DomainBlocklistPath string `default:""`
+
+	// The languages a subscriber may choose, the ones with the email
+	// templates
+	Languages []string `default:"en,uk"`
+	// How many pairs a subscriber may get the rate for
+	MaxPairs int `default:"5"`
+}
+
 type UserRepository interface {
 	Add(user *port.User) error
+	AddAll(users []port.User) error
+	Update(user *port.User) error
+	Remove(user *port.User) error
+	FindByEmail(email string) (*port.User, error)
+	FindByConfirmationToken(token string) (*port.User, error)
 	All() ([]port.User, error)
 }
 
+// TokenSigner issues the tokens the application gives out for a value,
+// e.g. for the email in the unsubscribe link of a mailed message,
+// and checks that a token was issued for the value. The management token
+// the subscriber manages the subscription with is issued by another
+// signer, so it can't be told from the mailed unsubscribe token.
+type TokenSigner interface {
+	Sign(value string) string
+	Verify(value, token string) bool
+}
+
+// ConfirmationSender delivers the confirmation token to the pending user
+type ConfirmationSender interface {
+	SendConfirmation(user port.User) error
+}
+
+// OwnedRemover removes what a subscriber owns, e.g. the webhooks,
+// once the subscriber is removed
+type OwnedRemover interface {
+	RemoveByEmail(email string) error
+}
+
 type Service struct {
-	userRepository UserRepository
+	config             SubscriptionConfig
+	userRepository     UserRepository
+	tokenSigner        TokenSigner
+	managementSigner   TokenSigner
+	confirmationSender ConfirmationSender
+	domainBlocklist    DomainBlocklist
+	ownedRemovers      []OwnedRemover
+	now                func() time.Time
 }
 
-func NewService(userRepository UserRepository) *Service {
-	return &Service{userRepository: userRepository}
+func NewService(
+	config SubscriptionConfig,
+	userRepository UserRepository,
+	tokenSigner TokenSigner,
+	managementSigner TokenSigner,
+	confirmationSender ConfirmationSender,
+	domainBlocklist DomainBlocklist,
+	ownedRemovers ...OwnedRemover,
+) *Service {
+	return &Service{
+		config:             config,
+		userRepository:     userRepository,
+		tokenSigner:        tokenSigner,
+		managementSigner:   managementSigner,
+		confirmationSender: confirmationSender,
+		domainBlocklist:    domainBlocklist,
+		ownedRemovers:      ownedRemovers,
+		now:                time.Now,
+	}
 }
 
+// Subscribe validates and normalizes the email, stores the user as pending
+// and sends the confirmation email. A pending user whose token has expired
+// gets a new one.
 func (s *Service) Subscribe(user *port.User) error {
-	err := s.userRepository.Add(user)
-	if errors.Is(err, port.ErrAlreadyAdded) {
+	email, err := s.normalizeEmail(user.Email)
+	if err != nil {
+		return err
+	}
+	user.Email = email
+
+	existing, err := s.userRepository.FindByEmail(user.Email)
+	if errors.Is(err, port.ErrCannotFindByEmail) {
+		return s.addPending(user)
+	}
+
+	if err != nil {
+		return errors.Join(err, ErrUserRepository)
+	}
+
+	if existing.IsActive() {
 		return ErrAlreadySubscribed
 	}
 
+	if s.now().Before(existing.ConfirmationExpiresAt) {
+		return ErrConfirmationPending
+	}
+
+	if err = s.issueConfirmationToken(existing); err != nil {
+		return err
+	}
+
+	if err = s.userRepository.Update(existing); err != nil {
+		return errors.Join(err, ErrUserRepository)
+	}
+
+	return s.sendConfirmation(existing)
+}
+
+// Confirm activates the pending user the token was issued for and returns
+// the management token, which is never mailed with the rate
+func (s *Service) Confirm(token string) (string, error) {
+	user, err := s.userRepository.FindByConfirmationToken(token)
+	if errors.Is(err, port.ErrCannotFindByToken) {
+		return "", ErrInvalidConfirmationToken
+	}
+
+	if err != nil {
+		return "", errors.Join(err, ErrUserRepository)
+	}
+
+	if !s.now().Before(user.ConfirmationExpiresAt) {
+		return "", ErrConfirmationExpired
+	}
+
+	user.Status = port.UserStatusActive
+	user.ConfirmationToken = ""
+	user.ConfirmationExpiresAt = time.Time{}
+
+	if err = s.userRepository.Update(user); err != nil {
+		return "", errors.Join(err, ErrUserRepository)
+	}
+
+	return s.ManagementToken(user.Email), nil
+}
+
+// ManagementToken returns the token the subscriber manages the alerts,
+// the webhooks and the preferences with
+func (s *Service) ManagementToken(email string) string {
+	return s.managementSigner.Sign(email)
+}
+
+func (s *Service) Unsubscribe(user *port.User, token string) error {
+	if !s.tokenSigner.Verify(user.Email, token) {
+		return ErrInvalidToken
+	}
+
+	return s.remove(user)
+}
+
+// remove removes the user and then what the user owns
+func (s *Service) remove(user *port.User) error {
+	err := s.userRepository.Remove(user)
+	if errors.Is(err, port.ErrCannotFindByEmail) {
+		return ErrNotSubscribed
+	}
+
 	if err != nil {
 		return errors.Join(err, ErrUserRepository)
 	}
 
+	for _, remover := range s.ownedRemovers {
+		if err = remover.RemoveByEmail(user.Email); err != nil {
+			return errors.Join(err, ErrRemoveOwned)
+		}
+	}
+
 	return nil
 }
 
+// Subscriptions returns only the confirmed subscribers
 func (s *Service) Subscriptions() ([]port.User, error) {
-	return s.userRepository.All()
+	users, err := s.userRepository.All()
+	if err != nil {
+		return nil, err
+	}
+
+	subscribers := make([]port.User, 0, len(users))
+	for _, user := range users {
+		if user.IsActive() {
+			subscribers = append(subscribers, user)
+		}
+	}
+
+	return subscribers, nil
+}
+
+func (s *Service) addPending(user *port.User) error {
+	pending := &port.User{
+		Email:  user.Email,
+		Status: port.UserStatusPending,
+	}
+
+	if err := s.issueConfirmationToken(pending); err != nil {
+		return err
+	}
+
+	err := s.userRepository.Add(pending)
+	if errors.Is(err, port.ErrAlreadyAdded) {
+		return ErrAlreadySubscribed
+	}
+
+	if err != nil {
+		return errors.Join(err, ErrUserRepository)
+	}
+
+	if err = s.sendConfirmation(pending); err != nil {
+		// Let the user subscribe again instead of waiting for the expiration
+		if removeErr := s.userRepository.Remove(pending); removeErr != nil {
+			return errors.Join(err, removeErr, ErrUserRepository)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) issueConfirmationToken(user *port.User) error {
+	token := make([]byte, _confirmationTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+
+	user.ConfirmationToken = base64.RawURLEncoding.EncodeToString(token)
+	user.ConfirmationExpiresAt = s.now().Add(s.config.ConfirmationTTL)
+
+	return nil
+}
+
+func (s *Service) sendConfirmation(user *port.User) error {
+	if err := s.confirmationSender.SendConfirmation(*user); err != nil {
+		return errors.Join(err, ErrSendConfirmation)
+	}
+
+	return nil
 }
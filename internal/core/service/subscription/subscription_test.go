@@ -1,64 +1,283 @@
 package subscription
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"gses2-app/internal/core/port"
 )
 
+var errSendConfirmation = errors.New("send confirmation error")
+
 type StubUserRepository struct {
 	Users []port.User
 	Err   error
+	// The calls of AddAll
+	batches int
 }
 
 func (s *StubUserRepository) Add(user *port.User) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	for _, u := range s.Users {
+		if u.Email == user.Email {
+			return port.ErrAlreadyAdded
+		}
+	}
+
 	s.Users = append(s.Users, *user)
-	return s.Err
+	return nil
+}
+
+// AddAll refuses the whole batch if one of the emails is stored
+func (s *StubUserRepository) AddAll(users []port.User) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.batches++
+	for _, user := range users {
+		for _, u := range s.Users {
+			if u.Email == user.Email {
+				return port.ErrAlreadyAdded
+			}
+		}
+	}
+
+	s.Users = append(s.Users, users...)
+	return nil
+}
+
+func (s *StubUserRepository) Update(user *port.User) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	for i, u := range s.Users {
+		if u.Email == user.Email {
+			s.Users[i] = *user
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindByEmail
+}
+
+func (s *StubUserRepository) Remove(user *port.User) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	for i, u := range s.Users {
+		if u.Email == user.Email {
+			s.Users = append(s.Users[:i], s.Users[i+1:]...)
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindByEmail
 }
 
 func (s *StubUserRepository) FindByEmail(email string) (*port.User, error) {
-	return &s.Users[0], s.Err
+	if s.Err != nil {
+		return &port.User{}, s.Err
+	}
+
+	for _, u := range s.Users {
+		if u.Email == email {
+			user := u
+			return &user, nil
+		}
+	}
+
+	return &port.User{}, port.ErrCannotFindByEmail
+}
+
+func (s *StubUserRepository) FindByConfirmationToken(token string) (*port.User, error) {
+	for _, u := range s.Users {
+		if u.ConfirmationToken == token {
+			user := u
+			return &user, nil
+		}
+	}
+
+	return &port.User{}, port.ErrCannotFindByToken
 }
 
 func (s *StubUserRepository) All() ([]port.User, error) {
 	return s.Users, s.Err
 }
 
+type StubTokenSigner struct {
+	Valid   bool
+	Purpose string
+}
+
+func (s *StubTokenSigner) Sign(value string) string {
+	return "signed:" + s.Purpose + value
+}
+
+func (s *StubTokenSigner) Verify(value, token string) bool {
+	return s.Valid || token == s.Sign(value)
+}
+
+type StubConfirmationSender struct {
+	Sent []port.User
+	Err  error
+}
+
+func (s *StubConfirmationSender) SendConfirmation(user port.User) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Sent = append(s.Sent, user)
+	return nil
+}
+
+type StubDomainBlocklist struct {
+	Domains []string
+}
+
+func (s *StubDomainBlocklist) Contains(domain string) bool {
+	for _, d := range s.Domains {
+		if d == domain {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newTestService(
+	userRepository *StubUserRepository,
+	tokenSigner *StubTokenSigner,
+	confirmationSender *StubConfirmationSender,
+) *Service {
+	return NewService(
+		SubscriptionConfig{ConfirmationTTL: time.Hour},
+		userRepository,
+		tokenSigner,
+		&StubTokenSigner{Purpose: "manage:"},
+		confirmationSender,
+		&StubDomainBlocklist{},
+	)
+}
+
 func TestSubscription(t *testing.T) {
 	t.Run("Subscribe", func(t *testing.T) {
 		t.Parallel()
 
 		subscriber := &port.User{Email: "test@example.com"}
 		userRepository := &StubUserRepository{}
-		service := NewService(userRepository)
+		confirmationSender := &StubConfirmationSender{}
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{},
+			confirmationSender,
+		)
 
 		err := service.Subscribe(subscriber)
 		require.NoError(t, err)
 
+		require.Len(t, userRepository.Users, 1)
+		require.Equal(t, port.UserStatusPending, userRepository.Users[0].Status)
+		require.NotEmpty(t, userRepository.Users[0].ConfirmationToken)
+
+		require.Len(
+			t, confirmationSender.Sent, 1,
+			"expected confirmation to be sent",
+		)
+
 		subscribers, err := service.Subscriptions()
 		require.NoError(t, err)
+		require.Empty(
+			t, subscribers,
+			"expected pending subscriber not to be in subscribers list",
+		)
+	})
 
-		require.Equal(
-			t, 1, len(subscribers),
-			"expected subscribers list to contain one subscriber",
+	t.Run("Confirm", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{}
+		confirmationSender := &StubConfirmationSender{}
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{},
+			confirmationSender,
 		)
 
+		err := service.Subscribe(&port.User{Email: "test@example.com"})
+		require.NoError(t, err)
+
+		managementToken, err := service.Confirm(confirmationSender.Sent[0].ConfirmationToken)
+		require.NoError(t, err)
+		require.Equal(t, "signed:manage:test@example.com", managementToken)
+
+		subscribers, err := service.Subscriptions()
+		require.NoError(t, err)
 		require.Equal(
-			t, *subscriber, subscribers[0],
-			"expected subscribers list to contain the subscriber",
+			t,
+			[]port.User{{Email: "test@example.com", Status: port.UserStatusActive}},
+			subscribers,
+			"expected subscribers list to contain the confirmed subscriber",
+		)
+	})
+
+	t.Run("Confirm with unknown token", func(t *testing.T) {
+		t.Parallel()
+
+		service := newTestService(
+			&StubUserRepository{},
+			&StubTokenSigner{},
+			&StubConfirmationSender{},
+		)
+
+		_, err := service.Confirm("unknown")
+		require.ErrorIs(t, err, ErrInvalidConfirmationToken)
+	})
+
+	t.Run("Confirm with expired token", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{
+			Users: []port.User{{
+				Email:                 "test@example.com",
+				Status:                port.UserStatusPending,
+				ConfirmationToken:     "token",
+				ConfirmationExpiresAt: time.Now().Add(-time.Minute),
+			}},
+		}
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{},
+			&StubConfirmationSender{},
 		)
+
+		_, err := service.Confirm("token")
+		require.ErrorIs(t, err, ErrConfirmationExpired)
 	})
 
 	t.Run("Already subscribed", func(t *testing.T) {
 		t.Parallel()
 
 		userRepository := &StubUserRepository{
-			Users: []port.User{},
-			Err:   port.ErrAlreadyAdded,
+			Users: []port.User{{
+				Email:  "test@example.com",
+				Status: port.UserStatusActive,
+			}},
 		}
-		service := NewService(userRepository)
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{},
+			&StubConfirmationSender{},
+		)
 		subscriber := &port.User{Email: "test@example.com"}
 
 		err := service.Subscribe(subscriber)
@@ -67,4 +286,199 @@ func TestSubscription(t *testing.T) {
 			"expected error due to duplicate subscription",
 		)
 	})
+
+	t.Run("Subscription waiting for confirmation", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{}
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{},
+			&StubConfirmationSender{},
+		)
+		subscriber := &port.User{Email: "test@example.com"}
+
+		require.NoError(t, service.Subscribe(subscriber))
+
+		err := service.Subscribe(subscriber)
+		require.ErrorIs(t, err, ErrConfirmationPending)
+	})
+
+	t.Run("Expired pending subscription gets a new token", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{
+			Users: []port.User{{
+				Email:                 "test@example.com",
+				Status:                port.UserStatusPending,
+				ConfirmationToken:     "expired",
+				ConfirmationExpiresAt: time.Now().Add(-time.Minute),
+			}},
+		}
+		confirmationSender := &StubConfirmationSender{}
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{},
+			confirmationSender,
+		)
+
+		err := service.Subscribe(&port.User{Email: "test@example.com"})
+		require.NoError(t, err)
+
+		require.Len(t, confirmationSender.Sent, 1)
+		require.NotEqual(t, "expired", userRepository.Users[0].ConfirmationToken)
+		require.Equal(
+			t,
+			confirmationSender.Sent[0].ConfirmationToken,
+			userRepository.Users[0].ConfirmationToken,
+		)
+	})
+
+	t.Run("Failed confirmation is rolled back", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{}
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{},
+			&StubConfirmationSender{Err: errSendConfirmation},
+		)
+
+		err := service.Subscribe(&port.User{Email: "test@example.com"})
+		require.ErrorIs(t, err, ErrSendConfirmation)
+		require.Empty(t, userRepository.Users)
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{
+			Users: []port.User{{Email: "test@example.com"}},
+		}
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{Valid: true},
+			&StubConfirmationSender{},
+		)
+
+		err := service.Unsubscribe(&port.User{Email: "test@example.com"}, "token")
+		require.NoError(t, err)
+
+		subscribers, err := service.Subscriptions()
+		require.NoError(t, err)
+		require.Empty(t, subscribers, "expected subscribers list to be empty")
+	})
+
+	t.Run("Unsubscribe with invalid token", func(t *testing.T) {
+		t.Parallel()
+
+		userRepository := &StubUserRepository{
+			Users: []port.User{{Email: "test@example.com"}},
+		}
+		service := newTestService(
+			userRepository,
+			&StubTokenSigner{Valid: false},
+			&StubConfirmationSender{},
+		)
+
+		err := service.Unsubscribe(&port.User{Email: "test@example.com"}, "token")
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Len(t, userRepository.Users, 1)
+	})
+
+	t.Run("Unsubscribe not subscribed email", func(t *testing.T) {
+		t.Parallel()
+
+		service := newTestService(
+			&StubUserRepository{},
+			&StubTokenSigner{Valid: true},
+			&StubConfirmationSender{},
+		)
+
+		err := service.Unsubscribe(&port.User{Email: "test@example.com"}, "token")
+		require.ErrorIs(t, err, ErrNotSubscribed)
+	})
+}
+
+type StubOwnedRemover struct {
+	Removed []string
+	Err     error
+}
+
+func (s *StubOwnedRemover) RemoveByEmail(email string) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Removed = append(s.Removed, email)
+	return nil
+}
+
+func TestRemoveOwned(t *testing.T) {
+	t.Parallel()
+
+	errRemove := errors.New("remove error")
+
+	tests := []struct {
+		name            string
+		remove          func(service *Service) error
+		remover         *StubOwnedRemover
+		expectedRemoved []string
+		expectedErr     error
+	}{
+		{
+			name: "Unsubscribe",
+			remove: func(service *Service) error {
+				return service.Unsubscribe(&port.User{Email: "test@example.com"}, "signed:test@example.com")
+			},
+			remover:         &StubOwnedRemover{},
+			expectedRemoved: []string{"test@example.com"},
+		},
+		{
+			name: "Remove by the admin",
+			remove: func(service *Service) error {
+				return service.RemoveSubscriber("test@example.com")
+			},
+			remover:         &StubOwnedRemover{},
+			expectedRemoved: []string{"test@example.com"},
+		},
+		{
+			name: "Not subscribed",
+			remove: func(service *Service) error {
+				return service.RemoveSubscriber("other@example.com")
+			},
+			remover:     &StubOwnedRemover{},
+			expectedErr: ErrNotSubscribed,
+		},
+		{
+			name: "Remover error",
+			remove: func(service *Service) error {
+				return service.RemoveSubscriber("test@example.com")
+			},
+			remover:     &StubOwnedRemover{Err: errRemove},
+			expectedErr: ErrRemoveOwned,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := NewService(
+				SubscriptionConfig{ConfirmationTTL: time.Hour},
+				&StubUserRepository{Users: []port.User{{Email: "test@example.com"}}},
+				&StubTokenSigner{},
+				&StubTokenSigner{Purpose: "manage:"},
+				&StubConfirmationSender{},
+				&StubDomainBlocklist{},
+				tt.remover,
+			)
+
+			err := tt.remove(service)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedRemoved, tt.remover.Removed)
+		})
+	}
 }
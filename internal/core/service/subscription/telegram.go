@@ -0,0 +1,119 @@
+package subscription
+
+import (
+	"errors"
+
+	"gses2-app/internal/core/port"
+)
+
+// Keeps the link codes apart from the unsubscribe tokens of the same email
+const _telegramLinkPrefix = "telegram:"
+
+var (
+	ErrInvalidLinkCode    = errors.New("invalid telegram link code")
+	ErrTelegramNotLinked  = errors.New("telegram chat is not linked")
+	ErrLinkNotActiveEmail = errors.New("only a confirmed subscription can be linked")
+)
+
+// TelegramLinkCode returns the code the subscriber the token was issued
+// for sends to the bot with /start to get the rate in Telegram too
+func (s *Service) TelegramLinkCode(email, token string) (string, error) {
+	if !s.managementSigner.Verify(email, token) {
+		return "", ErrInvalidManagementToken
+	}
+
+	user, err := s.userRepository.FindByEmail(email)
+	if errors.Is(err, port.ErrCannotFindByEmail) {
+		return "", ErrNotSubscribed
+	}
+
+	if err != nil {
+		return "", errors.Join(err, ErrUserRepository)
+	}
+
+	if !user.IsActive() {
+		return "", ErrLinkNotActiveEmail
+	}
+
+	return s.tokenSigner.Sign(_telegramLinkPrefix + user.Email), nil
+}
+
+// LinkTelegram links the chat to the subscriber the code was issued for.
+// A chat receives the rate once, so it's unlinked from other subscribers.
+func (s *Service) LinkTelegram(code string, chatID int64) error {
+	users, err := s.userRepository.All()
+	if err != nil {
+		return errors.Join(err, ErrUserRepository)
+	}
+
+	var linked *port.User
+	for i := range users {
+		user := &users[i]
+		if user.IsActive() &&
+			s.tokenSigner.Verify(_telegramLinkPrefix+user.Email, code) {
+			linked = user
+			break
+		}
+	}
+
+	if linked == nil {
+		return ErrInvalidLinkCode
+	}
+
+	for i := range users {
+		user := &users[i]
+		if user.TelegramChatID == 0 || user.TelegramChatID != chatID ||
+			user.Email == linked.Email {
+			continue
+		}
+
+		unlinkTelegram(user)
+		if err = s.userRepository.Update(user); err != nil {
+			return errors.Join(err, ErrUserRepository)
+		}
+	}
+
+	linked.TelegramChatID = chatID
+	if err = s.userRepository.Update(linked); err != nil {
+		return errors.Join(err, ErrUserRepository)
+	}
+
+	return nil
+}
+
+// UnlinkTelegram stops sending the rate to the chat
+func (s *Service) UnlinkTelegram(chatID int64) error {
+	users, err := s.userRepository.All()
+	if err != nil {
+		return errors.Join(err, ErrUserRepository)
+	}
+
+	unlinked := false
+	for i := range users {
+		user := &users[i]
+		if user.TelegramChatID == 0 || user.TelegramChatID != chatID {
+			continue
+		}
+
+		unlinkTelegram(user)
+		if err = s.userRepository.Update(user); err != nil {
+			return errors.Join(err, ErrUserRepository)
+		}
+		unlinked = true
+	}
+
+	if !unlinked {
+		return ErrTelegramNotLinked
+	}
+
+	return nil
+}
+
+// unlinkTelegram forgets the chat, the subscriber who only wanted
+// the rate in Telegram gets it through every channel again
+func unlinkTelegram(user *port.User) {
+	user.TelegramChatID = 0
+	if user.Preferences.Channel == port.ChannelTelegram {
+		user.Preferences.Channel = ""
+	}
+}
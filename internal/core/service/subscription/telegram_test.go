@@ -0,0 +1,197 @@
+package subscription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+func TestTelegramLinkCode(t *testing.T) {
+	t.Parallel()
+
+	users := []port.User{
+		{Email: "active@example.com", Status: port.UserStatusActive},
+		{Email: "pending@example.com", Status: port.UserStatusPending},
+	}
+
+	tests := []struct {
+		name         string
+		email        string
+		token        string
+		expectedCode string
+		expectedErr  error
+	}{
+		{
+			name:         "Confirmed subscriber",
+			email:        "active@example.com",
+			token:        "signed:manage:active@example.com",
+			expectedCode: "signed:telegram:active@example.com",
+		},
+		{
+			name:        "Invalid token",
+			email:       "active@example.com",
+			token:       "signed:manage:pending@example.com",
+			expectedErr: ErrInvalidManagementToken,
+		},
+		{
+			name:        "Unsubscribe token",
+			email:       "active@example.com",
+			token:       "signed:active@example.com",
+			expectedErr: ErrInvalidManagementToken,
+		},
+		{
+			name:        "Pending subscriber",
+			email:       "pending@example.com",
+			token:       "signed:manage:pending@example.com",
+			expectedErr: ErrLinkNotActiveEmail,
+		},
+		{
+			name:        "Unknown email",
+			email:       "unknown@example.com",
+			token:       "signed:manage:unknown@example.com",
+			expectedErr: ErrNotSubscribed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := newTestService(
+				&StubUserRepository{Users: users},
+				&StubTokenSigner{},
+				&StubConfirmationSender{},
+			)
+
+			code, err := service.TelegramLinkCode(tt.email, tt.token)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedCode, code)
+		})
+	}
+}
+
+func TestLinkTelegram(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		users         []port.User
+		code          string
+		expectedUsers []port.User
+		expectedErr   error
+	}{
+		{
+			name: "Link the chat",
+			users: []port.User{
+				{Email: "first@example.com"},
+				{Email: "second@example.com", Status: port.UserStatusActive},
+			},
+			code: "signed:telegram:second@example.com",
+			expectedUsers: []port.User{
+				{Email: "first@example.com"},
+				{
+					Email:          "second@example.com",
+					Status:         port.UserStatusActive,
+					TelegramChatID: 42,
+				},
+			},
+		},
+		{
+			name: "Move the chat to another subscriber",
+			users: []port.User{
+				{Email: "first@example.com", TelegramChatID: 42},
+				{Email: "second@example.com", TelegramChatID: 7},
+			},
+			code: "signed:telegram:second@example.com",
+			expectedUsers: []port.User{
+				{Email: "first@example.com"},
+				{Email: "second@example.com", TelegramChatID: 42},
+			},
+		},
+		{
+			name: "Code of a pending subscriber",
+			users: []port.User{
+				{Email: "first@example.com", Status: port.UserStatusPending},
+			},
+			code: "signed:telegram:first@example.com",
+			expectedUsers: []port.User{
+				{Email: "first@example.com", Status: port.UserStatusPending},
+			},
+			expectedErr: ErrInvalidLinkCode,
+		},
+		{
+			name:          "Unsubscribe token instead of the code",
+			users:         []port.User{{Email: "first@example.com"}},
+			code:          "signed:first@example.com",
+			expectedUsers: []port.User{{Email: "first@example.com"}},
+			expectedErr:   ErrInvalidLinkCode,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			userRepository := &StubUserRepository{Users: tt.users}
+			service := newTestService(
+				userRepository,
+				&StubTokenSigner{},
+				&StubConfirmationSender{},
+			)
+
+			err := service.LinkTelegram(tt.code, 42)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedUsers, userRepository.Users)
+		})
+	}
+}
+
+func TestUnlinkTelegram(t *testing.T) {
+	t.Parallel()
+
+	userRepository := &StubUserRepository{
+		Users: []port.User{
+			{Email: "first@example.com", TelegramChatID: 42},
+			{Email: "second@example.com"},
+		},
+	}
+	service := newTestService(
+		userRepository,
+		&StubTokenSigner{},
+		&StubConfirmationSender{},
+	)
+
+	require.NoError(t, service.UnlinkTelegram(42))
+	require.Equal(t, []port.User{
+		{Email: "first@example.com"},
+		{Email: "second@example.com"},
+	}, userRepository.Users)
+
+	require.ErrorIs(t, service.UnlinkTelegram(42), ErrTelegramNotLinked)
+}
+
+func TestUnlinkTelegramResetsChannel(t *testing.T) {
+	t.Parallel()
+
+	userRepository := &StubUserRepository{
+		Users: []port.User{{
+			Email:          "test@example.com",
+			TelegramChatID: 42,
+			Preferences:    port.Preferences{Channel: port.ChannelTelegram},
+		}},
+	}
+	service := newTestService(
+		userRepository,
+		&StubTokenSigner{},
+		&StubConfirmationSender{},
+	)
+
+	require.NoError(t, service.UnlinkTelegram(42))
+	require.Equal(t, []port.User{{Email: "test@example.com"}}, userRepository.Users)
+}
@@ -0,0 +1,80 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+const (
+	_reasonRequired   = "email is required"
+	_reasonMalformed  = "email is not a valid address"
+	_reasonNotBare    = "email must be a bare address without a name, comments or quotes"
+	_reasonDomain     = "email domain is not valid"
+	_reasonDisposable = "disposable email addresses are not allowed"
+)
+
+var ErrInvalidEmail = errors.New("invalid email")
+
+// ValidationError describes why the email was rejected
+type ValidationError struct {
+	Email  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v %q: %s", ErrInvalidEmail, e.Email, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidEmail
+}
+
+// DomainBlocklist holds the domains subscribers may not use,
+// e.g. disposable email services
+type DomainBlocklist interface {
+	Contains(domain string) bool
+}
+
+// normalizeEmail parses the address as defined in RFC 5322 and brings it
+// to the canonical form, so variants of the same address are stored once.
+// Only a bare address is accepted: the parsed address of a display name,
+// a comment or a quoted local part isn't what was given.
+func (s *Service) normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", &ValidationError{Email: email, Reason: _reasonRequired}
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return "", &ValidationError{Email: email, Reason: _reasonMalformed}
+	}
+
+	if address.Name != "" || address.Address != email {
+		return "", &ValidationError{Email: email, Reason: _reasonNotBare}
+	}
+
+	at := strings.LastIndex(address.Address, "@")
+	localPart, domain := address.Address[:at], address.Address[at+1:]
+
+	domain = strings.ToLower(domain)
+	if !strings.Contains(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", &ValidationError{Email: email, Reason: _reasonDomain}
+	}
+
+	if s.domainBlocklist.Contains(domain) {
+		return "", &ValidationError{Email: email, Reason: _reasonDisposable}
+	}
+
+	if !s.config.CaseSensitiveLocalPart {
+		localPart = strings.ToLower(localPart)
+	}
+
+	if s.config.StripPlusTag {
+		localPart, _, _ = strings.Cut(localPart, "+")
+	}
+
+	return localPart + "@" + domain, nil
+}
@@ -0,0 +1,154 @@
+package subscription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		config    SubscriptionConfig
+		blocklist []string
+		email     string
+		want      string
+		reason    string
+	}{
+		{
+			name:  "Valid email",
+			email: "user@example.com",
+			want:  "user@example.com",
+		},
+		{
+			name:  "Surrounding spaces",
+			email: "  user@example.com \t",
+			want:  "user@example.com",
+		},
+		{
+			name:  "Lower cased by default",
+			email: "John.Doe@Example.COM",
+			want:  "john.doe@example.com",
+		},
+		{
+			name:   "Case sensitive local part",
+			config: SubscriptionConfig{CaseSensitiveLocalPart: true},
+			email:  "John.Doe@Example.COM",
+			want:   "John.Doe@example.com",
+		},
+		{
+			name:  "Plus tag kept by default",
+			email: "user+news@example.com",
+			want:  "user+news@example.com",
+		},
+		{
+			name:   "Plus tag stripped",
+			config: SubscriptionConfig{StripPlusTag: true},
+			email:  "user+news@example.com",
+			want:   "user@example.com",
+		},
+		{
+			name:   "Display name",
+			email:  "John Doe <user@example.com>",
+			reason: _reasonNotBare,
+		},
+		{
+			name:   "Angle brackets",
+			email:  "<user@example.com>",
+			reason: _reasonNotBare,
+		},
+		{
+			name:   "Comment",
+			email:  "user@example.com (John Doe)",
+			reason: _reasonNotBare,
+		},
+		{
+			name:   "Quoted local part",
+			email:  `"user"@example.com`,
+			reason: _reasonNotBare,
+		},
+		{
+			name:   "Quoted local part with spaces",
+			email:  `"john doe"@example.com`,
+			reason: _reasonNotBare,
+		},
+		{
+			name:   "Empty email",
+			email:  " ",
+			reason: _reasonRequired,
+		},
+		{
+			name:   "Missing at sign",
+			email:  "user.example.com",
+			reason: _reasonMalformed,
+		},
+		{
+			name:   "Missing local part",
+			email:  "@example.com",
+			reason: _reasonMalformed,
+		},
+		{
+			name:   "Domain without dot",
+			email:  "user@localhost",
+			reason: _reasonDomain,
+		},
+		{
+			name:      "Blocked domain",
+			blocklist: []string{"mailinator.com"},
+			email:     "user@Mailinator.com",
+			reason:    _reasonDisposable,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := NewService(
+				tt.config,
+				&StubUserRepository{},
+				&StubTokenSigner{},
+				&StubTokenSigner{Purpose: "manage:"},
+				&StubConfirmationSender{},
+				&StubDomainBlocklist{Domains: tt.blocklist},
+			)
+
+			got, err := service.normalizeEmail(tt.email)
+			if tt.reason != "" {
+				require.ErrorIs(t, err, ErrInvalidEmail)
+
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				require.Equal(t, tt.reason, validationErr.Reason)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSubscribeNormalizesEmail(t *testing.T) {
+	t.Parallel()
+
+	userRepository := &StubUserRepository{
+		Users: []port.User{
+			{Email: "user@example.com", Status: port.UserStatusActive},
+		},
+	}
+	service := newTestService(
+		userRepository,
+		&StubTokenSigner{},
+		&StubConfirmationSender{},
+	)
+
+	err := service.Subscribe(&port.User{Email: " User@Example.com "})
+	require.ErrorIs(t, err, ErrAlreadySubscribed)
+	require.Len(t, userRepository.Users, 1)
+}
@@ -0,0 +1,367 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+const (
+	_webhookIDSize = 8
+	_secretSize    = 32
+)
+
+var (
+	ErrInvalidURL        = errors.New("webhook url must be an absolute http or https url")
+	ErrForbiddenURL      = errors.New("webhook url must not point to a private address")
+	ErrInvalidToken      = errors.New("invalid subscriber token")
+	ErrNotSubscribed     = errors.New("email is not subscribed")
+	ErrTooManyWebhooks   = errors.New("too many webhooks")
+	ErrWebhookNotFound   = errors.New("webhook not found")
+	ErrWebhookRepository = errors.New("webhook repository error")
+)
+
+type WebhookConfig struct {
+	// How many deliveries of a webhook are kept for inspection
+	MaxDeliveries int `default:"100"`
+	// How many webhooks a subscriber may register
+	MaxPerSubscriber int `default:"5"`
+}
+
+type Repository interface {
+	Add(webhook *port.Webhook) error
+	Remove(webhook *port.Webhook) error
+	FindByID(id string) (*port.Webhook, error)
+	FindByEmail(email string) ([]port.Webhook, error)
+	All() ([]port.Webhook, error)
+}
+
+type DeliveryRepository interface {
+	Add(delivery *port.WebhookDelivery) error
+	FindByWebhookID(webhookID string) ([]port.WebhookDelivery, error)
+	RemoveDelivery(id string) error
+	RemoveByWebhookID(webhookID string) error
+}
+
+type SubscriberRepository interface {
+	FindByEmail(email string) (*port.User, error)
+}
+
+// TokenVerifier checks that the token was issued by the application
+// for the given email, i.e. the management token of the subscriber
+type TokenVerifier interface {
+	Verify(email, token string) bool
+}
+
+type Service struct {
+	config        WebhookConfig
+	repository    Repository
+	deliveries    DeliveryRepository
+	subscribers   SubscriberRepository
+	tokenVerifier TokenVerifier
+	lookup        func(ctx context.Context, network, host string) ([]netip.Addr, error)
+	now           func() time.Time
+}
+
+func NewService(
+	config WebhookConfig,
+	repository Repository,
+	deliveries DeliveryRepository,
+	subscribers SubscriberRepository,
+	tokenVerifier TokenVerifier,
+) *Service {
+	return &Service{
+		config:        config,
+		repository:    repository,
+		deliveries:    deliveries,
+		subscribers:   subscribers,
+		tokenVerifier: tokenVerifier,
+		lookup:        net.DefaultResolver.LookupNetIP,
+		now:           time.Now,
+	}
+}
+
+// Register adds the webhook of the confirmed subscriber the token was
+// issued for. The secret of the returned webhook signs the payloads.
+func (s *Service) Register(email, token, rawURL string) (*port.Webhook, error) {
+	if !s.tokenVerifier.Verify(email, token) {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.checkSubscribed(email); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.repository.FindByEmail(email)
+	if err != nil {
+		return nil, errors.Join(err, ErrWebhookRepository)
+	}
+
+	if len(webhooks) >= s.config.MaxPerSubscriber {
+		return nil, ErrTooManyWebhooks
+	}
+
+	return s.add(email, rawURL)
+}
+
+// AdminRegister adds the webhook of the confirmed subscriber without
+// the subscriber's token and limit
+func (s *Service) AdminRegister(email, rawURL string) (*port.Webhook, error) {
+	if err := s.checkSubscribed(email); err != nil {
+		return nil, err
+	}
+
+	return s.add(email, rawURL)
+}
+
+// SubscriberWebhooks returns the webhooks of the subscriber the token
+// was issued for
+func (s *Service) SubscriberWebhooks(email, token string) ([]port.Webhook, error) {
+	if !s.tokenVerifier.Verify(email, token) {
+		return nil, ErrInvalidToken
+	}
+
+	webhooks, err := s.repository.FindByEmail(email)
+	if err != nil {
+		return nil, errors.Join(err, ErrWebhookRepository)
+	}
+
+	return webhooks, nil
+}
+
+// Webhooks returns all the webhooks the rate is posted to
+func (s *Service) Webhooks() ([]port.Webhook, error) {
+	webhooks, err := s.repository.All()
+	if err != nil {
+		return nil, errors.Join(err, ErrWebhookRepository)
+	}
+
+	return webhooks, nil
+}
+
+// Remove stops posting to the webhook if it belongs to the subscriber
+// the token was issued for and drops its deliveries
+func (s *Service) Remove(email, token, id string) error {
+	webhook, err := s.owned(email, token, id)
+	if err != nil {
+		return err
+	}
+
+	return s.remove(webhook)
+}
+
+// AdminRemove stops posting to the webhook of any owner and drops its
+// deliveries
+func (s *Service) AdminRemove(id string) error {
+	webhook, err := s.find(id)
+	if err != nil {
+		return err
+	}
+
+	return s.remove(webhook)
+}
+
+// RemoveByEmail removes the webhooks of the subscriber and their
+// deliveries, e.g. once the subscriber has unsubscribed
+func (s *Service) RemoveByEmail(email string) error {
+	webhooks, err := s.repository.FindByEmail(email)
+	if err != nil {
+		return errors.Join(err, ErrWebhookRepository)
+	}
+
+	for i := range webhooks {
+		if err = s.remove(&webhooks[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliveries returns the attempts to post to the webhook of
+// the subscriber the token was issued for, the latest first
+func (s *Service) Deliveries(email, token, id string) ([]port.WebhookDelivery, error) {
+	if _, err := s.owned(email, token, id); err != nil {
+		return nil, err
+	}
+
+	return s.webhookDeliveries(id)
+}
+
+// AdminDeliveries returns the attempts to post to the webhook of any
+// owner, the latest first
+func (s *Service) AdminDeliveries(id string) ([]port.WebhookDelivery, error) {
+	if _, err := s.find(id); err != nil {
+		return nil, err
+	}
+
+	return s.webhookDeliveries(id)
+}
+
+// Record stores the delivery attempt, dropping the oldest deliveries
+// of the webhook beyond the configured number
+func (s *Service) Record(delivery *port.WebhookDelivery) error {
+	if err := s.deliveries.Add(delivery); err != nil {
+		return errors.Join(err, ErrWebhookRepository)
+	}
+
+	deliveries, err := s.deliveries.FindByWebhookID(delivery.WebhookID)
+	if err != nil {
+		return errors.Join(err, ErrWebhookRepository)
+	}
+
+	// The attempts of a delivery are kept or dropped together
+	seen := make(map[string]bool)
+	for _, d := range deliveries {
+		if seen[d.ID] {
+			continue
+		}
+		seen[d.ID] = true
+
+		if len(seen) <= s.config.MaxDeliveries {
+			continue
+		}
+
+		if err = s.deliveries.RemoveDelivery(d.ID); err != nil {
+			return errors.Join(err, ErrWebhookRepository)
+		}
+	}
+
+	return nil
+}
+
+// checkSubscribed makes sure the owner of a new webhook is
+// a confirmed subscriber, as the rate is posted to the subscribers'
+// webhooks only
+func (s *Service) checkSubscribed(email string) error {
+	subscriber, err := s.subscribers.FindByEmail(email)
+	if errors.Is(err, port.ErrCannotFindByEmail) || err == nil && !subscriber.IsActive() {
+		return ErrNotSubscribed
+	}
+
+	return err
+}
+
+func (s *Service) add(email, rawURL string) (*port.Webhook, error) {
+	if err := s.validateURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	id, err := randomHex(_webhookIDSize)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomHex(_secretSize)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &port.Webhook{
+		ID:        id,
+		URL:       rawURL,
+		Secret:    secret,
+		CreatedAt: s.now().UTC().Truncate(time.Second),
+		Email:     email,
+	}
+
+	if err = s.repository.Add(webhook); err != nil {
+		return nil, errors.Join(err, ErrWebhookRepository)
+	}
+
+	return webhook, nil
+}
+
+// owned returns the webhook if it belongs to the subscriber the token
+// was issued for, the others aren't told apart from the missing ones
+func (s *Service) owned(email, token, id string) (*port.Webhook, error) {
+	if !s.tokenVerifier.Verify(email, token) {
+		return nil, ErrInvalidToken
+	}
+
+	webhook, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if webhook.Email != email {
+		return nil, ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+func (s *Service) find(id string) (*port.Webhook, error) {
+	webhook, err := s.repository.FindByID(id)
+	if errors.Is(err, port.ErrCannotFindWebhook) {
+		return nil, ErrWebhookNotFound
+	}
+
+	if err != nil {
+		return nil, errors.Join(err, ErrWebhookRepository)
+	}
+
+	return webhook, nil
+}
+
+func (s *Service) remove(webhook *port.Webhook) error {
+	if err := s.repository.Remove(webhook); err != nil {
+		return errors.Join(err, ErrWebhookRepository)
+	}
+
+	if err := s.deliveries.RemoveByWebhookID(webhook.ID); err != nil {
+		return errors.Join(err, ErrWebhookRepository)
+	}
+
+	return nil
+}
+
+func (s *Service) webhookDeliveries(id string) ([]port.WebhookDelivery, error) {
+	deliveries, err := s.deliveries.FindByWebhookID(id)
+	if err != nil {
+		return nil, errors.Join(err, ErrWebhookRepository)
+	}
+
+	return deliveries, nil
+}
+
+// validateURL checks that the webhook is an http or https URL whose
+// host resolves to the public addresses only. The sender checks the
+// address again when it connects, as the host may be changed later.
+func (s *Service) validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || u.Scheme != "http" && u.Scheme != "https" {
+		return ErrInvalidURL
+	}
+
+	// The addresses are returned as they are without a lookup
+	addrs, err := s.lookup(context.Background(), "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve %q", ErrInvalidURL, u.Hostname())
+	}
+
+	for _, addr := range addrs {
+		if !port.IsPublicAddr(addr) {
+			return fmt.Errorf("%w: %s", ErrForbiddenURL, addr)
+		}
+	}
+
+	return nil
+}
+
+func randomHex(size int) (string, error) {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(value), nil
+}
@@ -0,0 +1,511 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+var (
+	errRepository = errors.New("repository error")
+	errLookup     = errors.New("no such host")
+)
+
+type StubRepository struct {
+	Webhooks []port.Webhook
+	Err      error
+}
+
+func (s *StubRepository) Add(webhook *port.Webhook) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.Webhooks = append(s.Webhooks, *webhook)
+	return nil
+}
+
+func (s *StubRepository) Remove(webhook *port.Webhook) error {
+	for i, w := range s.Webhooks {
+		if w.ID == webhook.ID {
+			s.Webhooks = append(s.Webhooks[:i], s.Webhooks[i+1:]...)
+			return nil
+		}
+	}
+
+	return port.ErrCannotFindWebhook
+}
+
+func (s *StubRepository) FindByID(id string) (*port.Webhook, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	for _, w := range s.Webhooks {
+		if w.ID == id {
+			webhook := w
+			return &webhook, nil
+		}
+	}
+
+	return &port.Webhook{}, port.ErrCannotFindWebhook
+}
+
+func (s *StubRepository) All() ([]port.Webhook, error) {
+	return append([]port.Webhook(nil), s.Webhooks...), s.Err
+}
+
+// StubDeliveryRepository keeps the deliveries in the order they
+// were added
+type StubDeliveryRepository struct {
+	Deliveries []port.WebhookDelivery
+}
+
+func (s *StubDeliveryRepository) Add(delivery *port.WebhookDelivery) error {
+	s.Deliveries = append(s.Deliveries, *delivery)
+	return nil
+}
+
+func (s *StubDeliveryRepository) FindByWebhookID(
+	webhookID string,
+) ([]port.WebhookDelivery, error) {
+	deliveries := []port.WebhookDelivery{}
+	for i := len(s.Deliveries) - 1; i >= 0; i-- {
+		if s.Deliveries[i].WebhookID == webhookID {
+			deliveries = append(deliveries, s.Deliveries[i])
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (s *StubDeliveryRepository) RemoveDelivery(id string) error {
+	return s.removeBy(func(d port.WebhookDelivery) bool { return d.ID == id })
+}
+
+func (s *StubDeliveryRepository) RemoveByWebhookID(webhookID string) error {
+	return s.removeBy(func(d port.WebhookDelivery) bool {
+		return d.WebhookID == webhookID
+	})
+}
+
+func (s *StubDeliveryRepository) removeBy(match func(port.WebhookDelivery) bool) error {
+	kept := s.Deliveries[:0]
+	for _, d := range s.Deliveries {
+		if !match(d) {
+			kept = append(kept, d)
+		}
+	}
+	s.Deliveries = kept
+
+	return nil
+}
+
+func (s *StubRepository) FindByEmail(email string) ([]port.Webhook, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	webhooks := []port.Webhook{}
+	for _, w := range s.Webhooks {
+		if w.Email == email {
+			webhooks = append(webhooks, w)
+		}
+	}
+
+	return webhooks, nil
+}
+
+type StubSubscribers struct {
+	Users []port.User
+}
+
+func (s *StubSubscribers) FindByEmail(email string) (*port.User, error) {
+	for _, u := range s.Users {
+		if u.Email == email {
+			user := u
+			return &user, nil
+		}
+	}
+
+	return &port.User{}, port.ErrCannotFindByEmail
+}
+
+type StubTokenVerifier struct{}
+
+func (s *StubTokenVerifier) Verify(email, token string) bool {
+	return token == "signed:"+email
+}
+
+// The addresses of the test hosts, the others aren't resolved
+var _hosts = map[string][]netip.Addr{
+	"example.com":          {netip.MustParseAddr("93.184.216.34")},
+	"rates.example.com":    {netip.MustParseAddr("93.184.216.35")},
+	"internal.example.com": {netip.MustParseAddr("93.184.216.36"), netip.MustParseAddr("10.0.0.5")},
+}
+
+func stubLookup(_ context.Context, _, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	addrs, ok := _hosts[host]
+	if !ok {
+		return nil, errLookup
+	}
+
+	return addrs, nil
+}
+
+var _subscribers = &StubSubscribers{Users: []port.User{
+	{Email: "active@example.com", Status: port.UserStatusActive},
+	{Email: "other@example.com", Status: port.UserStatusActive},
+	{Email: "pending@example.com", Status: port.UserStatusPending},
+}}
+
+func newTestService(
+	config WebhookConfig,
+	repository *StubRepository,
+	deliveries *StubDeliveryRepository,
+) *Service {
+	service := NewService(config, repository, deliveries, _subscribers, &StubTokenVerifier{})
+	service.lookup = stubLookup
+
+	return service
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		email       string
+		token       string
+		url         string
+		repository  *StubRepository
+		expectedErr error
+	}{
+		{
+			name:       "HTTPS webhook",
+			email:      "active@example.com",
+			token:      "signed:active@example.com",
+			url:        "https://example.com/rate?source=gses2",
+			repository: &StubRepository{},
+		},
+		{
+			name:       "HTTP webhook",
+			email:      "active@example.com",
+			token:      "signed:active@example.com",
+			url:        "http://rates.example.com:8080/rate",
+			repository: &StubRepository{},
+		},
+		{
+			name:        "Relative URL",
+			email:       "active@example.com",
+			token:       "signed:active@example.com",
+			url:         "/rate",
+			repository:  &StubRepository{},
+			expectedErr: ErrInvalidURL,
+		},
+		{
+			name:        "Unsupported scheme",
+			email:       "active@example.com",
+			token:       "signed:active@example.com",
+			url:         "ftp://example.com/rate",
+			repository:  &StubRepository{},
+			expectedErr: ErrInvalidURL,
+		},
+		{
+			name:        "Unresolved host",
+			email:       "active@example.com",
+			token:       "signed:active@example.com",
+			url:         "https://missing.example.com/rate",
+			repository:  &StubRepository{},
+			expectedErr: ErrInvalidURL,
+		},
+		{
+			name:        "Loopback address",
+			email:       "active@example.com",
+			token:       "signed:active@example.com",
+			url:         "http://127.0.0.1:8080/api/admin/outbox",
+			repository:  &StubRepository{},
+			expectedErr: ErrForbiddenURL,
+		},
+		{
+			name:        "Link-local address",
+			email:       "active@example.com",
+			token:       "signed:active@example.com",
+			url:         "http://[fe80::1]/rate",
+			repository:  &StubRepository{},
+			expectedErr: ErrForbiddenURL,
+		},
+		{
+			name:        "Host with a private address",
+			email:       "active@example.com",
+			token:       "signed:active@example.com",
+			url:         "https://internal.example.com/rate",
+			repository:  &StubRepository{},
+			expectedErr: ErrForbiddenURL,
+		},
+		{
+			name:        "Invalid token",
+			email:       "active@example.com",
+			token:       "signed:other@example.com",
+			url:         "https://example.com/rate",
+			repository:  &StubRepository{},
+			expectedErr: ErrInvalidToken,
+		},
+		{
+			name:        "Pending subscriber",
+			email:       "pending@example.com",
+			token:       "signed:pending@example.com",
+			url:         "https://example.com/rate",
+			repository:  &StubRepository{},
+			expectedErr: ErrNotSubscribed,
+		},
+		{
+			name:  "Too many webhooks",
+			email: "active@example.com",
+			token: "signed:active@example.com",
+			url:   "https://example.com/rate",
+			repository: &StubRepository{Webhooks: []port.Webhook{
+				{ID: "webhook1", Email: "active@example.com"},
+				{ID: "webhook2", Email: "active@example.com"},
+			}},
+			expectedErr: ErrTooManyWebhooks,
+		},
+		{
+			name:        "Repository error",
+			email:       "active@example.com",
+			token:       "signed:active@example.com",
+			url:         "https://example.com/rate",
+			repository:  &StubRepository{Err: errRepository},
+			expectedErr: ErrWebhookRepository,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			existing := len(tt.repository.Webhooks)
+			service := newTestService(
+				WebhookConfig{MaxDeliveries: 10, MaxPerSubscriber: 2},
+				tt.repository,
+				&StubDeliveryRepository{},
+			)
+
+			webhook, err := service.Register(tt.email, tt.token, tt.url)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr != nil {
+				require.Len(t, tt.repository.Webhooks, existing)
+				return
+			}
+
+			require.Equal(t, []port.Webhook{*webhook}, tt.repository.Webhooks)
+			require.Equal(t, tt.url, webhook.URL)
+			require.Equal(t, tt.email, webhook.Email)
+			require.Len(t, webhook.ID, 2*_webhookIDSize)
+			require.Len(t, webhook.Secret, 2*_secretSize)
+		})
+	}
+}
+
+func TestAdminRegister(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{Webhooks: []port.Webhook{
+		{ID: "webhook1", Email: "active@example.com"},
+	}}
+	service := newTestService(
+		WebhookConfig{MaxDeliveries: 10, MaxPerSubscriber: 1},
+		repository,
+		&StubDeliveryRepository{},
+	)
+
+	webhook, err := service.AdminRegister("active@example.com", "https://example.com/rate")
+	require.NoError(t, err)
+	require.Equal(t, "active@example.com", webhook.Email)
+	require.Len(t, repository.Webhooks, 2)
+
+	_, err = service.AdminRegister("active@example.com", "http://169.254.169.254/latest/meta-data")
+	require.ErrorIs(t, err, ErrForbiddenURL)
+
+	_, err = service.AdminRegister("", "https://example.com/rate")
+	require.ErrorIs(t, err, ErrNotSubscribed)
+	require.Len(t, repository.Webhooks, 2)
+}
+
+func TestRemoveByEmail(t *testing.T) {
+	t.Parallel()
+
+	repository := &StubRepository{Webhooks: []port.Webhook{
+		{ID: "webhook1", Email: "active@example.com"},
+		{ID: "webhook2", Email: "other@example.com"},
+		{ID: "webhook3", Email: "active@example.com"},
+	}}
+	deliveries := &StubDeliveryRepository{Deliveries: []port.WebhookDelivery{
+		{ID: "1", WebhookID: "webhook1"},
+		{ID: "2", WebhookID: "webhook2"},
+		{ID: "3", WebhookID: "webhook3"},
+	}}
+	service := newTestService(WebhookConfig{MaxDeliveries: 10}, repository, deliveries)
+
+	require.NoError(t, service.RemoveByEmail("active@example.com"))
+
+	require.Equal(t, []port.Webhook{{ID: "webhook2", Email: "other@example.com"}}, repository.Webhooks)
+	require.Equal(t, []port.WebhookDelivery{{ID: "2", WebhookID: "webhook2"}}, deliveries.Deliveries)
+}
+
+func TestSubscriberWebhooks(t *testing.T) {
+	t.Parallel()
+
+	service := newTestService(
+		WebhookConfig{MaxDeliveries: 10},
+		&StubRepository{Webhooks: []port.Webhook{
+			{ID: "webhook1", Email: "active@example.com"},
+			{ID: "webhook2", Email: "other@example.com"},
+		}},
+		&StubDeliveryRepository{},
+	)
+
+	webhooks, err := service.SubscriberWebhooks("active@example.com", "signed:active@example.com")
+	require.NoError(t, err)
+	require.Equal(t, []port.Webhook{{ID: "webhook1", Email: "active@example.com"}}, webhooks)
+
+	_, err = service.SubscriberWebhooks("active@example.com", "signed:other@example.com")
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	webhooks := []port.Webhook{
+		{ID: "webhook1", Email: "active@example.com"},
+		{ID: "webhook2", Email: "other@example.com"},
+	}
+	deliveries := []port.WebhookDelivery{
+		{ID: "1", WebhookID: "webhook1"},
+		{ID: "2", WebhookID: "webhook2"},
+	}
+
+	tests := []struct {
+		name               string
+		remove             func(service *Service) error
+		expectedWebhooks   []port.Webhook
+		expectedDeliveries []port.WebhookDelivery
+		expectedErr        error
+	}{
+		{
+			name: "Remove the webhook",
+			remove: func(service *Service) error {
+				return service.Remove("active@example.com", "signed:active@example.com", "webhook1")
+			},
+			expectedWebhooks:   webhooks[1:],
+			expectedDeliveries: deliveries[1:],
+		},
+		{
+			name: "Invalid token",
+			remove: func(service *Service) error {
+				return service.Remove("active@example.com", "signed:other@example.com", "webhook1")
+			},
+			expectedWebhooks:   webhooks,
+			expectedDeliveries: deliveries,
+			expectedErr:        ErrInvalidToken,
+		},
+		{
+			name: "Webhook of another subscriber",
+			remove: func(service *Service) error {
+				return service.Remove("active@example.com", "signed:active@example.com", "webhook2")
+			},
+			expectedWebhooks:   webhooks,
+			expectedDeliveries: deliveries,
+			expectedErr:        ErrWebhookNotFound,
+		},
+		{
+			name: "Removed webhook",
+			remove: func(service *Service) error {
+				return service.Remove("active@example.com", "signed:active@example.com", "webhook3")
+			},
+			expectedWebhooks:   webhooks,
+			expectedDeliveries: deliveries,
+			expectedErr:        ErrWebhookNotFound,
+		},
+		{
+			name: "Admin removes any webhook",
+			remove: func(service *Service) error {
+				return service.AdminRemove("webhook2")
+			},
+			expectedWebhooks:   webhooks[:1],
+			expectedDeliveries: deliveries[:1],
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			repository := &StubRepository{
+				Webhooks: append([]port.Webhook(nil), webhooks...),
+			}
+			deliveryRepository := &StubDeliveryRepository{
+				Deliveries: append([]port.WebhookDelivery(nil), deliveries...),
+			}
+			service := newTestService(
+				WebhookConfig{MaxDeliveries: 10},
+				repository,
+				deliveryRepository,
+			)
+
+			err := tt.remove(service)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedWebhooks, repository.Webhooks)
+			require.Equal(t, tt.expectedDeliveries, deliveryRepository.Deliveries)
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	deliveries := &StubDeliveryRepository{}
+	service := newTestService(
+		WebhookConfig{MaxDeliveries: 2},
+		&StubRepository{Webhooks: []port.Webhook{
+			{ID: "webhook1", Email: "active@example.com"},
+		}},
+		deliveries,
+	)
+
+	at := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	attempts := []port.WebhookDelivery{
+		{ID: "1", WebhookID: "webhook1", Attempt: 1, DeliveredAt: at},
+		{ID: "other", WebhookID: "webhook2", Attempt: 1, DeliveredAt: at},
+		{ID: "2", WebhookID: "webhook1", Attempt: 1, DeliveredAt: at},
+		{ID: "2", WebhookID: "webhook1", Attempt: 2, DeliveredAt: at},
+		{ID: "3", WebhookID: "webhook1", Attempt: 1, DeliveredAt: at},
+	}
+	for i := range attempts {
+		require.NoError(t, service.Record(&attempts[i]))
+	}
+
+	recorded, err := service.Deliveries(
+		"active@example.com",
+		"signed:active@example.com",
+		"webhook1",
+	)
+
+	require.NoError(t, err)
+	require.Equal(t, []port.WebhookDelivery{attempts[4], attempts[3], attempts[2]}, recorded)
+	require.Len(t, deliveries.Deliveries, 4)
+}
@@ -0,0 +1,175 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/alert"
+)
+
+const (
+	_idParam       = "id"
+	_kindParam     = "kind"
+	_valueParam    = "value"
+	_cooldownParam = "cooldown"
+)
+
+type AlertService interface {
+	Create(alert *port.Alert, token string) error
+	Alerts(email, token string) ([]port.Alert, error)
+	Delete(email, token, id string) error
+}
+
+type alertResponse struct {
+	ID              string     `json:"id"`
+	Email           string     `json:"email"`
+	Base            string     `json:"base"`
+	Quote           string     `json:"quote"`
+	Kind            string     `json:"kind"`
+	Value           float64    `json:"value"`
+	Cooldown        string     `json:"cooldown,omitempty"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+}
+
+// Alerts lists the subscriber's alerts on GET, creates an alert on POST
+// and deletes one on DELETE. The subscriber is authorized by the token
+// of the unsubscribe link.
+func (ac *AppController) Alerts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ac.listAlerts(w, r)
+	case http.MethodPost:
+		ac.createAlert(w, r)
+	case http.MethodDelete:
+		ac.deleteAlert(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (ac *AppController) listAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := ac.AlertService.Alerts(r.FormValue(_emailParam), r.FormValue(_tokenParam))
+	if err != nil {
+		writeAlertError(w, err)
+		return
+	}
+
+	response := make([]alertResponse, 0, len(alerts))
+	for _, a := range alerts {
+		response = append(response, newAlertResponse(a))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (ac *AppController) createAlert(w http.ResponseWriter, r *http.Request) {
+	newAlert, err := alertFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = ac.AlertService.Create(newAlert, r.FormValue(_tokenParam))
+	if err != nil {
+		writeAlertError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newAlertResponse(*newAlert))
+}
+
+func (ac *AppController) deleteAlert(w http.ResponseWriter, r *http.Request) {
+	err := ac.AlertService.Delete(
+		r.FormValue(_emailParam),
+		r.FormValue(_tokenParam),
+		r.FormValue(_idParam),
+	)
+	if err != nil {
+		writeAlertError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func alertFromRequest(r *http.Request) (*port.Alert, error) {
+	pair, err := currencyPairFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := strconv.ParseFloat(r.FormValue(_valueParam), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", _valueParam, err)
+	}
+
+	var cooldown time.Duration
+	if raw := r.FormValue(_cooldownParam); raw != "" {
+		if cooldown, err = time.ParseDuration(raw); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", _cooldownParam, err)
+		}
+	}
+
+	return &port.Alert{
+		Email:    r.FormValue(_emailParam),
+		Pair:     pair,
+		Kind:     port.AlertKind(r.FormValue(_kindParam)),
+		Value:    value,
+		Cooldown: cooldown,
+	}, nil
+}
+
+func writeAlertError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, alert.ErrInvalidKind),
+		errors.Is(err, alert.ErrInvalidValue),
+		errors.Is(err, alert.ErrInvalidCooldown),
+		errors.Is(err, alert.ErrUnavailablePair):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, alert.ErrInvalidToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, alert.ErrNotSubscribed),
+		errors.Is(err, alert.ErrAlertNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, alert.ErrTooManyAlerts):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func newAlertResponse(a port.Alert) alertResponse {
+	response := alertResponse{
+		ID:    a.ID,
+		Email: a.Email,
+		Base:  a.Pair.Base,
+		Quote: a.Pair.Quote,
+		Kind:  string(a.Kind),
+		Value: a.Value,
+	}
+
+	if a.Cooldown != 0 {
+		response.Cooldown = a.Cooldown.String()
+	}
+
+	if !a.LastTriggeredAt.IsZero() {
+		lastTriggeredAt := a.LastTriggeredAt
+		response.LastTriggeredAt = &lastTriggeredAt
+	}
+
+	return response
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// The status is already sent, an encoding error can only be dropped
+	_ = json.NewEncoder(w).Encode(body)
+}
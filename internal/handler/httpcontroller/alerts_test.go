@@ -0,0 +1,211 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/alert"
+)
+
+var errAlertRepository = errors.New("alert repository error")
+
+type StubAlertService struct {
+	alerts []port.Alert
+	err    error
+
+	created *port.Alert
+	deleted string
+}
+
+func (m *StubAlertService) Create(newAlert *port.Alert, token string) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	newAlert.ID = "id"
+	m.created = newAlert
+	return nil
+}
+
+func (m *StubAlertService) Alerts(email, token string) ([]port.Alert, error) {
+	return m.alerts, m.err
+}
+
+func (m *StubAlertService) Delete(email, token, id string) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.deleted = id
+	return nil
+}
+
+func TestAlerts(t *testing.T) {
+	triggeredAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	existing := port.Alert{
+		ID:              "id",
+		Email:           "test@example.com",
+		Pair:            port.DefaultCurrencyPair,
+		Kind:            port.AlertKindThreshold,
+		Value:           100,
+		LastTriggeredAt: triggeredAt,
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		service        *StubAlertService
+		expectedStatus int
+		expectedAlert  *port.Alert
+		expectedDelete string
+	}{
+		{
+			name:           "List alerts",
+			method:         http.MethodGet,
+			url:            "/api/alerts?email=test@example.com&token=token",
+			service:        &StubAlertService{alerts: []port.Alert{existing}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Create alert",
+			method: http.MethodPost,
+			url:    "/api/alerts",
+			body: "email=test@example.com&token=token&base=eth&quote=usd" +
+				"&kind=change&value=5&cooldown=6h",
+			service:        &StubAlertService{},
+			expectedStatus: http.StatusCreated,
+			expectedAlert: &port.Alert{
+				ID:       "id",
+				Email:    "test@example.com",
+				Pair:     port.CurrencyPair{Base: "ETH", Quote: "USD"},
+				Kind:     port.AlertKindChange,
+				Value:    5,
+				Cooldown: 6 * time.Hour,
+			},
+		},
+		{
+			name:           "Create alert with an invalid value",
+			method:         http.MethodPost,
+			url:            "/api/alerts",
+			body:           "email=test@example.com&token=token&kind=threshold&value=high",
+			service:        &StubAlertService{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Create alert with an invalid kind",
+			method:         http.MethodPost,
+			url:            "/api/alerts",
+			body:           "email=test@example.com&token=token&kind=above&value=1",
+			service:        &StubAlertService{err: alert.ErrInvalidKind},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Create alert of an unavailable pair",
+			method:         http.MethodPost,
+			url:            "/api/alerts",
+			body:           "email=test@example.com&token=token&kind=threshold&value=1&base=BTC&quote=XYZ",
+			service:        &StubAlertService{err: alert.ErrUnavailablePair},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Create alert with an invalid token",
+			method:         http.MethodPost,
+			url:            "/api/alerts",
+			body:           "email=test@example.com&token=bad&kind=threshold&value=1",
+			service:        &StubAlertService{err: alert.ErrInvalidToken},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Create too many alerts",
+			method:         http.MethodPost,
+			url:            "/api/alerts",
+			body:           "email=test@example.com&token=token&kind=threshold&value=1",
+			service:        &StubAlertService{err: alert.ErrTooManyAlerts},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Delete alert",
+			method:         http.MethodDelete,
+			url:            "/api/alerts?email=test@example.com&token=token&id=id",
+			service:        &StubAlertService{},
+			expectedStatus: http.StatusNoContent,
+			expectedDelete: "id",
+		},
+		{
+			name:           "Delete unknown alert",
+			method:         http.MethodDelete,
+			url:            "/api/alerts?email=test@example.com&token=token&id=unknown",
+			service:        &StubAlertService{err: alert.ErrAlertNotFound},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Repository error",
+			method:         http.MethodGet,
+			url:            "/api/alerts?email=test@example.com&token=token",
+			service:        &StubAlertService{err: errAlertRepository},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodPut,
+			url:            "/api/alerts",
+			service:        &StubAlertService{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				&StubEmailSubscriptionService{},
+				&StubJobService{},
+				&StubRateHistoryService{},
+				tt.service,
+				&StubWebhookService{},
+				&StubOutboxService{},
+			)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			rr := httptest.NewRecorder()
+
+			controller.Alerts(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedAlert, tt.service.created)
+			require.Equal(t, tt.expectedDelete, tt.service.deleted)
+
+			if tt.method != http.MethodGet || tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response []alertResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			require.Equal(t, []alertResponse{{
+				ID:              "id",
+				Email:           "test@example.com",
+				Base:            "BTC",
+				Quote:           "UAH",
+				Kind:            "threshold",
+				Value:           100,
+				LastTriggeredAt: &triggeredAt,
+			}}, response)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package httpcontroller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"gses2-app/internal/core/service/subscription"
+	"gses2-app/internal/handler/subscriberfile"
+)
+
+const (
+	_formatParam = "format"
+	_dryRunParam = "dry_run"
+
+	_maxImportSize = 64 << 20
+)
+
+type importReportResponse struct {
+	DryRun     bool                  `json:"dry_run"`
+	Total      int                   `json:"total"`
+	Imported   int                   `json:"imported"`
+	Duplicates int                   `json:"duplicates"`
+	Invalid    int                   `json:"invalid"`
+	Errors     []importErrorResponse `json:"errors"`
+	// Set if the file couldn't be read to the end, none of its rows
+	// are imported
+	Error string `json:"error,omitempty"`
+}
+
+type importErrorResponse struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+// AdminSubscribersImport adds the subscribers of the file in the body,
+// the format parameter or the Content-Type tells its format. With
+// the dry_run parameter the file is only validated.
+func (ac *AppController) AdminSubscribersImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	format, err := subscriberFileFormat(r, r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var dryRun bool
+	if raw := r.URL.Query().Get(_dryRunParam); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s: %s", _dryRunParam, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	reader, err := subscriberfile.NewReader(
+		format,
+		http.MaxBytesReader(w, r.Body, _maxImportSize),
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	report, err := ac.EmailSubscriptionService.Import(reader, dryRun)
+	if report == nil || errors.Is(err, subscription.ErrUserRepository) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := newImportReportResponse(report)
+	if err != nil {
+		response.Error = err.Error()
+		writeJSON(w, http.StatusBadRequest, response)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// AdminSubscribersExport streams every subscriber in the format
+// of the format parameter, CSV by default
+func (ac *AppController) AdminSubscribersExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	format, err := subscriberFileFormat(r, subscriberfile.FormatCSV.ContentType())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := &exportBody{writer: w, format: format}
+	writer, err := subscriberfile.NewWriter(format, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = ac.EmailSubscriptionService.Export(writer)
+	if err == nil {
+		body.start()
+		err = writer.Close()
+	}
+
+	// Once the export is streaming the status is sent, the client
+	// gets a truncated file
+	if err != nil && !body.started {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// exportBody sends the headers of the export with its first bytes,
+// so a failure before them is still reported by the status
+type exportBody struct {
+	writer  http.ResponseWriter
+	format  subscriberfile.Format
+	started bool
+}
+
+func (b *exportBody) Write(p []byte) (int, error) {
+	b.start()
+	return b.writer.Write(p)
+}
+
+func (b *exportBody) start() {
+	if b.started {
+		return
+	}
+	b.started = true
+
+	b.writer.Header().Set("Content-Type", b.format.ContentType())
+	b.writer.Header().Set(
+		"Content-Disposition",
+		fmt.Sprintf(`attachment; filename="subscribers.%s"`, b.format),
+	)
+	b.writer.WriteHeader(http.StatusOK)
+}
+
+// subscriberFileFormat reads the format parameter, the format
+// of the content type if it's absent
+func subscriberFileFormat(
+	r *http.Request,
+	contentType string,
+) (subscriberfile.Format, error) {
+	if name := r.URL.Query().Get(_formatParam); name != "" {
+		return subscriberfile.ParseFormat(name)
+	}
+
+	return subscriberfile.FormatByContentType(contentType)
+}
+
+func newImportReportResponse(report *subscription.ImportReport) importReportResponse {
+	response := importReportResponse{
+		DryRun:     report.DryRun,
+		Total:      report.Total,
+		Imported:   report.Imported,
+		Duplicates: report.Duplicates,
+		Invalid:    report.Invalid,
+		Errors:     make([]importErrorResponse, 0, len(report.Errors)),
+	}
+
+	for _, e := range report.Errors {
+		response.Errors = append(response.Errors, importErrorResponse{
+			Row:    e.Row,
+			Email:  e.Email,
+			Reason: e.Reason,
+		})
+	}
+
+	return response
+}
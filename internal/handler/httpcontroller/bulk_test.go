@@ -0,0 +1,200 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/service/subscription"
+)
+
+func TestAdminSubscribersImport(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		contentType    string
+		body           string
+		service        *StubEmailSubscriptionService
+		expectedStatus int
+		expectedReport importReportResponse
+	}{
+		{
+			name:        "Import CSV",
+			url:         "/api/admin/subscribers/import",
+			contentType: "text/csv",
+			body:        "email\nfirst@example.com\nsecond@example.com\n",
+			service:     &StubEmailSubscriptionService{},
+			expectedReport: importReportResponse{
+				Total:    2,
+				Imported: 2,
+				Errors:   []importErrorResponse{},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:    "Dry run of NDJSON",
+			url:     "/api/admin/subscribers/import?format=ndjson&dry_run=true",
+			body:    `{"email":"first@example.com"}` + "\n{broken\n",
+			service: &StubEmailSubscriptionService{},
+			expectedReport: importReportResponse{
+				DryRun:   true,
+				Total:    2,
+				Imported: 1,
+				Invalid:  1,
+				Errors: []importErrorResponse{{
+					Row:    2,
+					Reason: "malformed record\ninvalid character 'b' looking for beginning of object key string",
+				}},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:        "Unreadable file",
+			url:         "/api/admin/subscribers/import",
+			contentType: "application/json",
+			body:        `[{"email":"first@example.com"}, {broken`,
+			service:     &StubEmailSubscriptionService{},
+			expectedReport: importReportResponse{
+				Total:    1,
+				Imported: 1,
+				Errors:   []importErrorResponse{},
+				Error:    "invalid character 'b' looking for beginning of object key string",
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown format",
+			url:            "/api/admin/subscribers/import",
+			contentType:    "text/plain",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "Invalid dry run",
+			url:            "/api/admin/subscribers/import?format=csv&dry_run=maybe",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Repository error",
+			url:         "/api/admin/subscribers/import?format=csv",
+			body:        "first@example.com\n",
+			contentType: "text/csv",
+			service: &StubEmailSubscriptionService{
+				subscriberErr: errors.Join(errors.New("disk full"), subscription.ErrUserRepository),
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := newSubscribersTestController(tt.service)
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			controller.AdminSubscribersImport(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			if rr.Header().Get("Content-Type") != "application/json" {
+				return
+			}
+
+			var report importReportResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+			require.Equal(t, tt.expectedReport, report)
+		})
+	}
+}
+
+func TestAdminSubscribersExport(t *testing.T) {
+	records := []subscription.SubscriberRecord{
+		{Email: "first@example.com", Status: "active"},
+		{Email: "second@example.com", Status: "pending"},
+	}
+
+	tests := []struct {
+		name                string
+		url                 string
+		service             *StubEmailSubscriptionService
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "CSV by default",
+			url:                 "/api/admin/subscribers/export",
+			service:             &StubEmailSubscriptionService{exported: records},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedBody: "email,status,language,pairs,cadence,channel\n" +
+				"first@example.com,active,,,,\n" +
+				"second@example.com,pending,,,,\n",
+		},
+		{
+			name:                "NDJSON",
+			url:                 "/api/admin/subscribers/export?format=ndjson",
+			service:             &StubEmailSubscriptionService{exported: records},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody: `{"email":"first@example.com","status":"active"}` + "\n" +
+				`{"email":"second@example.com","status":"pending"}` + "\n",
+		},
+		{
+			name:                "Empty JSON",
+			url:                 "/api/admin/subscribers/export?format=json",
+			service:             &StubEmailSubscriptionService{},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        "[]\n",
+		},
+		{
+			name:           "Unknown format",
+			url:            "/api/admin/subscribers/export?format=xml",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Repository error",
+			url:  "/api/admin/subscribers/export",
+			service: &StubEmailSubscriptionService{
+				subscriberErr: errors.New("repository error"),
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := newSubscribersTestController(tt.service)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			controller.AdminSubscribersExport(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				require.Empty(t, rr.Header().Get("Content-Disposition"))
+				return
+			}
+
+			require.Equal(t, tt.expectedContentType, rr.Header().Get("Content-Type"))
+			require.Contains(t, rr.Header().Get("Content-Disposition"), "attachment")
+			require.Equal(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
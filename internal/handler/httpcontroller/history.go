@@ -0,0 +1,109 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/ratehistory"
+)
+
+const (
+	_fromQueryParam     = "from"
+	_toQueryParam       = "to"
+	_intervalQueryParam = "interval"
+
+	_defaultHistoryRange    = 24 * time.Hour
+	_defaultHistoryInterval = time.Hour
+)
+
+type RateHistoryService interface {
+	History(
+		pair port.CurrencyPair,
+		from, to time.Time,
+		interval time.Duration,
+	) ([]ratehistory.Candle, error)
+}
+
+type rateHistoryResponse struct {
+	Base     string               `json:"base"`
+	Quote    string               `json:"quote"`
+	From     time.Time            `json:"from"`
+	To       time.Time            `json:"to"`
+	Interval string               `json:"interval"`
+	Candles  []ratehistory.Candle `json:"candles"`
+}
+
+// GetRateHistory returns the OHLC candles of the fetched rates. The range
+// defaults to the last day and the interval to an hour.
+func (ac *AppController) GetRateHistory(w http.ResponseWriter, r *http.Request) {
+	pair, err := currencyPairFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, to, interval, err := historyRangeFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	candles, err := ac.RateHistoryService.History(pair, from, to, interval)
+	if errors.Is(err, ratehistory.ErrInvalidInterval) ||
+		errors.Is(err, ratehistory.ErrInvalidRange) ||
+		errors.Is(err, ratehistory.ErrTooManyCandles) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(rateHistoryResponse{
+		Base:     pair.Base,
+		Quote:    pair.Quote,
+		From:     from,
+		To:       to,
+		Interval: interval.String(),
+		Candles:  candles,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func historyRangeFromQuery(
+	r *http.Request,
+) (from, to time.Time, interval time.Duration, err error) {
+	query := r.URL.Query()
+
+	to = time.Now().UTC()
+	if value := query.Get(_toQueryParam); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			return from, to, interval, fmt.Errorf("invalid %s: %w", _toQueryParam, err)
+		}
+	}
+
+	from = to.Add(-_defaultHistoryRange)
+	if value := query.Get(_fromQueryParam); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			return from, to, interval, fmt.Errorf("invalid %s: %w", _fromQueryParam, err)
+		}
+	}
+
+	interval = _defaultHistoryInterval
+	if value := query.Get(_intervalQueryParam); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil {
+			return from, to, interval, fmt.Errorf("invalid %s: %w", _intervalQueryParam, err)
+		}
+	}
+
+	return from, to, interval, nil
+}
@@ -0,0 +1,144 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/ratehistory"
+)
+
+var errRateHistory = errors.New("rate history error")
+
+type StubRateHistoryService struct {
+	candles []ratehistory.Candle
+	err     error
+
+	pair     port.CurrencyPair
+	from, to time.Time
+	interval time.Duration
+}
+
+func (m *StubRateHistoryService) History(
+	pair port.CurrencyPair,
+	from, to time.Time,
+	interval time.Duration,
+) ([]ratehistory.Candle, error) {
+	m.pair, m.from, m.to, m.interval = pair, from, to, interval
+
+	return m.candles, m.err
+}
+
+func TestGetRateHistory(t *testing.T) {
+	from := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)
+	candles := []ratehistory.Candle{{
+		Start: from,
+		Open:  1,
+		High:  3,
+		Low:   1,
+		Close: 2,
+		Count: 3,
+	}}
+
+	tests := []struct {
+		name             string
+		service          *StubRateHistoryService
+		url              string
+		expectedStatus   int
+		expectedPair     port.CurrencyPair
+		expectedInterval time.Duration
+	}{
+		{
+			name:             "History of the requested range",
+			service:          &StubRateHistoryService{candles: candles},
+			url:              "/rate/history?base=ETH&quote=USD&from=2023-07-01T00:00:00Z&to=2023-07-02T00:00:00Z&interval=15m",
+			expectedStatus:   http.StatusOK,
+			expectedPair:     port.CurrencyPair{Base: "ETH", Quote: "USD"},
+			expectedInterval: 15 * time.Minute,
+		},
+		{
+			name:             "Default pair and interval",
+			service:          &StubRateHistoryService{candles: candles},
+			url:              "/rate/history?from=2023-07-01T00:00:00Z&to=2023-07-02T00:00:00Z",
+			expectedStatus:   http.StatusOK,
+			expectedPair:     port.DefaultCurrencyPair,
+			expectedInterval: time.Hour,
+		},
+		{
+			name:           "Invalid time",
+			service:        &StubRateHistoryService{},
+			url:            "/rate/history?from=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid interval",
+			service:        &StubRateHistoryService{},
+			url:            "/rate/history?interval=hourly",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Too many candles",
+			service: &StubRateHistoryService{
+				err: ratehistory.ErrTooManyCandles,
+			},
+			url:            "/rate/history?interval=1s",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Repository error",
+			service:        &StubRateHistoryService{err: errRateHistory},
+			url:            "/rate/history",
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				&StubEmailSubscriptionService{},
+				&StubJobService{},
+				tt.service,
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
+			)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			controller.GetRateHistory(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, tt.expectedPair, tt.service.pair)
+			require.Equal(t, tt.expectedInterval, tt.service.interval)
+			require.Equal(t, from, tt.service.from)
+			require.Equal(t, to, tt.service.to)
+
+			var response rateHistoryResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			require.Equal(t, rateHistoryResponse{
+				Base:     tt.expectedPair.Base,
+				Quote:    tt.expectedPair.Quote,
+				From:     from,
+				To:       to,
+				Interval: tt.expectedInterval.String(),
+				Candles:  candles,
+			}, response)
+		})
+	}
+}
@@ -3,60 +3,129 @@ package httpcontroller
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"gses2-app/internal/core/port"
 	"gses2-app/internal/core/service/subscription"
 )
 
-type SenderService interface {
-	SendExchangeRate(rate port.Rate, subscribers ...port.User) error
+const (
+	_baseQueryParam  = "base"
+	_quoteQueryParam = "quote"
+	_emailParam      = "email"
+	_tokenParam      = "token"
+
+	_ageHeader = "Age"
+
+	_unsubscribedMessage = "You have been unsubscribed from the rate updates"
+	_confirmedMessage    = "Your subscription to the rate updates is confirmed"
+	_managementMessage   = "Keep the token the alerts, the webhooks " +
+		"and the preferences are managed with, it isn't mailed"
+)
+
+type JobService interface {
+	Enqueue() (*port.Job, error)
+	Job(id string) (*port.Job, error)
 }
 
 type RateService interface {
-	ExchangeRate() (rate port.Rate, err error)
+	ExchangeRate(pair port.CurrencyPair) (rate port.Rate, err error)
 }
 
 type SubscriptionService interface {
 	Subscribe(subscriber *port.User) error
+	Confirm(token string) (string, error)
+	ManagementToken(email string) string
+	Unsubscribe(subscriber *port.User, token string) error
 	Subscriptions() (subscribers []port.User, err error)
+	TelegramLinkCode(email, token string) (string, error)
+	Preferences(email, token string) (*port.Preferences, error)
+	UpdatePreferences(
+		email, token string,
+		update subscription.PreferencesUpdate,
+	) (*port.Preferences, error)
+	ListSubscribers(query subscription.SubscriberQuery) (*subscription.SubscriberPage, error)
+	Subscriber(email string) (*port.User, error)
+	RemoveSubscriber(email string) error
+	Import(reader subscription.RecordReader, dryRun bool) (*subscription.ImportReport, error)
+	Export(writer subscription.RecordWriter) error
 }
 
 type AppController struct {
 	ExchangeRateService      RateService
 	EmailSubscriptionService SubscriptionService
-	EmailSenderService       SenderService
+	JobService               JobService
+	RateHistoryService       RateHistoryService
+	AlertService             AlertService
+	WebhookService           WebhookService
+	OutboxService            OutboxService
 }
 
 func NewAppController(
 	exchangeRateService RateService,
 	emailSubscriptionService SubscriptionService,
-	emailSenderService SenderService,
+	jobService JobService,
+	rateHistoryService RateHistoryService,
+	alertService AlertService,
+	webhookService WebhookService,
+	outboxService OutboxService,
 ) *AppController {
 	return &AppController{
 		ExchangeRateService:      exchangeRateService,
 		EmailSubscriptionService: emailSubscriptionService,
-		EmailSenderService:       emailSenderService,
+		JobService:               jobService,
+		RateHistoryService:       rateHistoryService,
+		AlertService:             alertService,
+		WebhookService:           webhookService,
+		OutboxService:            outboxService,
 	}
 }
 
 func (ac *AppController) GetRate(w http.ResponseWriter, r *http.Request) {
-	exchangeRate, err := ac.ExchangeRateService.ExchangeRate()
+	pair, err := currencyPairFromRequest(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err = json.NewEncoder(w).Encode(exchangeRate); err != nil {
+	exchangeRate, err := ac.ExchangeRateService.ExchangeRate(pair)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The rate may come from the cache
+	if !exchangeRate.FetchedAt.IsZero() {
+		age := time.Since(exchangeRate.FetchedAt).Truncate(time.Second)
+		w.Header().Set(_ageHeader, strconv.Itoa(int(age.Seconds())))
+	}
+
+	if err = json.NewEncoder(w).Encode(exchangeRate.Value); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
 func (ac *AppController) SubscribeEmail(w http.ResponseWriter, r *http.Request) {
-	subscriber := &port.User{Email: r.FormValue("email")}
+	subscriber := &port.User{Email: r.FormValue(_emailParam)}
 	err := ac.EmailSubscriptionService.Subscribe(subscriber)
 
-	if errors.Is(err, subscription.ErrAlreadySubscribed) {
+	var validationErr *subscription.ValidationError
+	if errors.As(err, &validationErr) {
+		writeProblem(w, Problem{
+			Type:   _invalidEmailType,
+			Title:  _invalidEmailTitle,
+			Status: http.StatusUnprocessableEntity,
+			Detail: validationErr.Reason,
+			Email:  validationErr.Email,
+		})
+		return
+	}
+
+	if errors.Is(err, subscription.ErrAlreadySubscribed) ||
+		errors.Is(err, subscription.ErrConfirmationPending) {
 		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
@@ -69,28 +138,85 @@ func (ac *AppController) SubscribeEmail(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
-func (ac *AppController) SendEmails(w http.ResponseWriter, r *http.Request) {
-	exchangeRate, err := ac.ExchangeRateService.ExchangeRate()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+// ConfirmSubscription confirms the subscription and shows the management
+// token, the only time it's given to the subscriber
+func (ac *AppController) ConfirmSubscription(w http.ResponseWriter, r *http.Request) {
+	managementToken, err := ac.EmailSubscriptionService.Confirm(r.FormValue(_tokenParam))
+
+	if errors.Is(err, subscription.ErrInvalidConfirmationToken) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, subscription.ErrConfirmationExpired) {
+		http.Error(w, err.Error(), http.StatusGone)
 		return
 	}
 
-	subscribers, err := ac.EmailSubscriptionService.Subscriptions()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = ac.EmailSenderService.SendExchangeRate(
-		exchangeRate,
-		subscribers...,
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, _confirmedMessage)
+	fmt.Fprintf(w, "%s: %s\n", _managementMessage, managementToken)
+}
+
+// UnsubscribeEmail handles both the GET request of the link in a mailed
+// message and the POST request of a one-click unsubscribe
+func (ac *AppController) UnsubscribeEmail(w http.ResponseWriter, r *http.Request) {
+	subscriber := &port.User{Email: r.FormValue(_emailParam)}
+	err := ac.EmailSubscriptionService.Unsubscribe(
+		subscriber,
+		r.FormValue(_tokenParam),
 	)
 
+	if errors.Is(err, subscription.ErrInvalidToken) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if errors.Is(err, subscription.ErrNotSubscribed) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, _unsubscribedMessage)
+}
+
+// SendEmails queues the job sending the rate to all the subscribers
+// and returns it at once, its progress is polled at /api/jobs/{id}
+func (ac *AppController) SendEmails(w http.ResponseWriter, r *http.Request) {
+	job, err := ac.JobService.Enqueue()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", _jobsPath+job.ID)
+	writeJSON(w, http.StatusAccepted, newJobResponse(job))
+}
+
+// currencyPairFromRequest reads the requested pair from the query string
+// or the form, falling back to the default currency for each omitted
+// parameter
+func currencyPairFromRequest(r *http.Request) (port.CurrencyPair, error) {
+	base := r.FormValue(_baseQueryParam)
+	if base == "" {
+		base = port.DefaultCurrencyPair.Base
+	}
+
+	quote := r.FormValue(_quoteQueryParam)
+	if quote == "" {
+		quote = port.DefaultCurrencyPair.Quote
+	}
+
+	return port.NewCurrencyPair(base, quote)
 }
@@ -1,11 +1,14 @@
 package httpcontroller
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -16,7 +19,6 @@ import (
 var (
 	errSubscriptions = errors.New("get subscriptions error")
 	errExchangeRate  = errors.New("exchange rate error")
-	errSendEmail     = errors.New("send email error")
 )
 
 type StubExchangeRateService struct {
@@ -24,21 +26,62 @@ type StubExchangeRateService struct {
 	err  error
 }
 
-func (m *StubExchangeRateService) ExchangeRate() (port.Rate, error) {
-	return m.rate, m.err
+func (m *StubExchangeRateService) ExchangeRate(
+	pair port.CurrencyPair,
+) (port.Rate, error) {
+	if m.err != nil {
+		return port.Rate{}, m.err
+	}
+
+	rate := m.rate
+	rate.Pair = pair
+
+	return rate, nil
 }
 
 type StubEmailSubscriptionService struct {
 	subscribeErr     error
+	confirmErr       error
+	unsubscribeErr   error
 	subscriptions    []port.User
 	subscriptionsErr error
 	isSubscribedErr  error
+	linkCode         string
+	linkCodeErr      error
+	preferences      port.Preferences
+	preferencesErr   error
+	update           subscription.PreferencesUpdate
+	page             subscription.SubscriberPage
+	query            subscription.SubscriberQuery
+	subscriberErr    error
+	removed          string
+	imported         []subscription.SubscriberRecord
+	importDryRun     bool
+	exported         []subscription.SubscriberRecord
 }
 
 func (m *StubEmailSubscriptionService) Subscribe(subscriber *port.User) error {
 	return m.subscribeErr
 }
 
+func (m *StubEmailSubscriptionService) Confirm(token string) (string, error) {
+	if m.confirmErr != nil {
+		return "", m.confirmErr
+	}
+	return "management-token", nil
+}
+
+func (m *StubEmailSubscriptionService) ManagementToken(email string) string {
+	return "management:" + email
+}
+
+func (m *StubEmailSubscriptionService) Unsubscribe(
+	subscriber *port.User,
+	token string,
+) error {
+	return m.unsubscribeErr
+}
+
 func (m *StubEmailSubscriptionService) Subscriptions() ([]port.User, error) {
 	if m.subscriptionsErr != nil {
 		return nil, m.subscriptionsErr
@@ -46,37 +89,158 @@ func (m *StubEmailSubscriptionService) Subscriptions() ([]port.User, error) {
 	return m.subscriptions, nil
 }
 
-func (m *StubEmailSubscriptionService) IsSubscribed(subscriber port.User) (bool, error) {
-	return true, m.isSubscribedErr
+func (m *StubEmailSubscriptionService) TelegramLinkCode(
+	email string,
+	token string,
+) (string, error) {
+	return m.linkCode, m.linkCodeErr
 }
 
-type StubEmailSenderService struct {
-	sendErr error
+func (m *StubEmailSubscriptionService) Preferences(
+	email string,
+	token string,
+) (*port.Preferences, error) {
+	if m.preferencesErr != nil {
+		return nil, m.preferencesErr
+	}
+	return &m.preferences, nil
 }
 
-func (m *StubEmailSenderService) SendExchangeRate(
-	rate port.Rate,
-	subscribers ...port.User,
-) error {
-	return m.sendErr
+func (m *StubEmailSubscriptionService) UpdatePreferences(
+	email string,
+	token string,
+	update subscription.PreferencesUpdate,
+) (*port.Preferences, error) {
+	if m.preferencesErr != nil {
+		return nil, m.preferencesErr
+	}
+	m.update = update
+	return &m.preferences, nil
+}
+
+func (m *StubEmailSubscriptionService) ListSubscribers(
+	query subscription.SubscriberQuery,
+) (*subscription.SubscriberPage, error) {
+	if m.subscriberErr != nil {
+		return nil, m.subscriberErr
+	}
+	m.query = query
+	return &m.page, nil
+}
+
+func (m *StubEmailSubscriptionService) Subscriber(email string) (*port.User, error) {
+	if m.subscriberErr != nil {
+		return nil, m.subscriberErr
+	}
+	return &port.User{Email: email}, nil
+}
+
+func (m *StubEmailSubscriptionService) RemoveSubscriber(email string) error {
+	if m.subscriberErr != nil {
+		return m.subscriberErr
+	}
+	m.removed = email
+	return nil
+}
+
+// Import reads every record, the malformed ones are reported invalid
+func (m *StubEmailSubscriptionService) Import(
+	reader subscription.RecordReader,
+	dryRun bool,
+) (*subscription.ImportReport, error) {
+	if m.subscriberErr != nil {
+		return nil, m.subscriberErr
+	}
+
+	m.importDryRun = dryRun
+	report := &subscription.ImportReport{DryRun: dryRun}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+
+		if err != nil && !errors.Is(err, subscription.ErrMalformedRecord) {
+			return report, err
+		}
+
+		report.Total++
+		if err != nil {
+			report.Invalid++
+			report.Errors = append(report.Errors, subscription.ImportError{
+				Row:    report.Total,
+				Reason: err.Error(),
+			})
+			continue
+		}
+
+		report.Imported++
+		m.imported = append(m.imported, record)
+	}
+}
+
+func (m *StubEmailSubscriptionService) Export(writer subscription.RecordWriter) error {
+	if m.subscriberErr != nil {
+		return m.subscriberErr
+	}
+
+	for _, record := range m.exported {
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *StubEmailSubscriptionService) IsSubscribed(subscriber port.User) (bool, error) {
+	return true, m.isSubscribedErr
 }
 
 func TestGetRate(t *testing.T) {
 	tests := []struct {
 		name           string
 		service        *StubExchangeRateService
+		url            string
 		expectedStatus int
 		expectedBody   string
+		expectedAge    string
 	}{
 		{
 			name:           "Exchange rate",
-			service:        &StubExchangeRateService{rate: 1.5},
+			service:        &StubExchangeRateService{rate: port.Rate{Value: 1.5}},
+			url:            "/rate",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "1.5",
+		},
+		{
+			name:           "Exchange rate of a requested pair",
+			service:        &StubExchangeRateService{rate: port.Rate{Value: 2.5}},
+			url:            "/rate?base=ETH&quote=USD",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "2.5",
+		},
+		{
+			name: "Cached exchange rate",
+			service: &StubExchangeRateService{rate: port.Rate{
+				Value:     1.5,
+				FetchedAt: time.Now().Add(-90 * time.Second),
+			}},
+			url:            "/rate",
 			expectedStatus: http.StatusOK,
 			expectedBody:   "1.5",
+			expectedAge:    "90",
+		},
+		{
+			name:           "Invalid currency pair",
+			service:        &StubExchangeRateService{rate: port.Rate{Value: 1.5}},
+			url:            "/rate?base=BTC&quote=BTC",
+			expectedStatus: http.StatusBadRequest,
 		},
 		{
 			name:           "Exchange rate error",
 			service:        &StubExchangeRateService{err: errExchangeRate},
+			url:            "/rate",
 			expectedStatus: http.StatusBadRequest,
 		},
 	}
@@ -86,10 +250,14 @@ func TestGetRate(t *testing.T) {
 			controller := NewAppController(
 				tt.service,
 				&StubEmailSubscriptionService{},
-				&StubEmailSenderService{},
+				&StubJobService{},
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
 			)
 
-			req, err := http.NewRequest(http.MethodGet, "/rate", nil)
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -118,6 +286,8 @@ func TestGetRate(t *testing.T) {
 					tt.expectedStatus,
 				)
 			}
+
+			require.Equal(t, tt.expectedAge, rr.Header().Get("Age"))
 		})
 	}
 }
@@ -140,6 +310,23 @@ func TestSubscribeEmail(t *testing.T) {
 			},
 			expectedStatus: http.StatusConflict,
 		},
+		{
+			name: "Subscription waiting for confirmation",
+			service: &StubEmailSubscriptionService{
+				subscribeErr: subscription.ErrConfirmationPending,
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "Invalid email",
+			service: &StubEmailSubscriptionService{
+				subscribeErr: &subscription.ValidationError{
+					Email:  "test",
+					Reason: "email is not a valid address",
+				},
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
 	}
 
 	for _, tt := range tests {
@@ -147,7 +334,11 @@ func TestSubscribeEmail(t *testing.T) {
 			controller := NewAppController(
 				&StubExchangeRateService{},
 				tt.service,
-				&StubEmailSenderService{},
+				&StubJobService{},
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
 			)
 
 			req, err := http.NewRequest(http.MethodPost, "/subscribe", strings.NewReader("email=test@example.com"))
@@ -172,79 +363,178 @@ func TestSubscribeEmail(t *testing.T) {
 	}
 }
 
-func TestSendEmails(t *testing.T) {
+func TestSubscribeEmailInvalidEmailProblem(t *testing.T) {
+	controller := NewAppController(
+		&StubExchangeRateService{},
+		&StubEmailSubscriptionService{
+			subscribeErr: &subscription.ValidationError{
+				Email:  "test@mailinator.com",
+				Reason: "disposable email addresses are not allowed",
+			},
+		},
+		&StubJobService{},
+		&StubRateHistoryService{},
+		&StubAlertService{},
+		&StubWebhookService{},
+		&StubOutboxService{},
+	)
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/subscribe",
+		strings.NewReader("email=test@mailinator.com"),
+	)
+	rr := httptest.NewRecorder()
+
+	controller.SubscribeEmail(rr, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Equal(t, _problemContentType, rr.Header().Get("Content-Type"))
+
+	var problem Problem
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&problem))
+	require.Equal(t, Problem{
+		Type:   _invalidEmailType,
+		Title:  _invalidEmailTitle,
+		Status: http.StatusUnprocessableEntity,
+		Detail: "disposable email addresses are not allowed",
+		Email:  "test@mailinator.com",
+	}, problem)
+}
+
+func TestConfirmSubscription(t *testing.T) {
 	tests := []struct {
-		name                string
-		exchangeRateService *StubExchangeRateService
-		subscriptionService *StubEmailSubscriptionService
-		emailSenderService  *StubEmailSenderService
-		expectedStatus      int
+		name           string
+		service        *StubEmailSubscriptionService
+		expectedStatus int
 	}{
 		{
-			name: "Send emails",
-			exchangeRateService: &StubExchangeRateService{
-				rate: 1.5,
-			},
-			subscriptionService: &StubEmailSubscriptionService{
-				subscriptions: convertEmailsToUsers(
-					[]string{"subscriber1@example.com", "subscriber2@example.com"},
-				),
+			name:           "Confirm subscription",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Invalid token",
+			service: &StubEmailSubscriptionService{
+				confirmErr: subscription.ErrInvalidConfirmationToken,
 			},
-			emailSenderService: &StubEmailSenderService{},
-			expectedStatus:     http.StatusOK,
+			expectedStatus: http.StatusNotFound,
 		},
 		{
-			name: "Exchange rate error",
-			exchangeRateService: &StubExchangeRateService{
-				err: errExchangeRate,
+			name: "Expired token",
+			service: &StubEmailSubscriptionService{
+				confirmErr: subscription.ErrConfirmationExpired,
 			},
-			subscriptionService: &StubEmailSubscriptionService{},
-			emailSenderService:  &StubEmailSenderService{},
-			expectedStatus:      http.StatusBadRequest,
+			expectedStatus: http.StatusGone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				tt.service,
+				&StubJobService{},
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
+			)
+
+			req, err := http.NewRequest(http.MethodGet, "/subscribe/confirm?token=token", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			handler := http.HandlerFunc(controller.ConfirmSubscription)
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(
+				t,
+				tt.expectedStatus,
+				rr.Code,
+				"ConfirmSubscription returned wrong status code: got %v, expected %v",
+				rr.Code,
+				tt.expectedStatus,
+			)
+
+			if tt.expectedStatus == http.StatusOK {
+				require.Contains(t, rr.Body.String(), "management-token")
+			}
+		})
+	}
+}
+
+func TestUnsubscribeEmail(t *testing.T) {
+	tests := []struct {
+		name           string
+		service        *StubEmailSubscriptionService
+		method         string
+		expectedStatus int
+	}{
+		{
+			name:           "Unsubscribe email by link",
+			service:        &StubEmailSubscriptionService{},
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
 		},
 		{
-			name:                "Subscription service error",
-			exchangeRateService: &StubExchangeRateService{},
-			subscriptionService: &StubEmailSubscriptionService{
-				subscriptionsErr: errSubscriptions,
+			name:           "One-click unsubscribe",
+			service:        &StubEmailSubscriptionService{},
+			method:         http.MethodPost,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Invalid token",
+			service: &StubEmailSubscriptionService{
+				unsubscribeErr: subscription.ErrInvalidToken,
 			},
-			emailSenderService: &StubEmailSenderService{},
-			expectedStatus:     http.StatusInternalServerError,
+			method:         http.MethodGet,
+			expectedStatus: http.StatusForbidden,
 		},
 		{
-			name:                "Email sender service error",
-			exchangeRateService: &StubExchangeRateService{},
-			subscriptionService: &StubEmailSubscriptionService{},
-			emailSenderService: &StubEmailSenderService{
-				sendErr: errSendEmail,
+			name: "Not subscribed",
+			service: &StubEmailSubscriptionService{
+				unsubscribeErr: subscription.ErrNotSubscribed,
 			},
-			expectedStatus: http.StatusInternalServerError,
+			method:         http.MethodGet,
+			expectedStatus: http.StatusNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			controller := NewAppController(
-				tt.exchangeRateService,
-				tt.subscriptionService,
-				tt.emailSenderService,
+				&StubExchangeRateService{},
+				tt.service,
+				&StubJobService{},
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
 			)
 
-			req, err := http.NewRequest(http.MethodPost, "/send", nil)
+			req, err := http.NewRequest(
+				tt.method,
+				"/unsubscribe?email=test@example.com&token=token",
+				nil,
+			)
 			if err != nil {
 				t.Fatal(err)
 			}
 
 			rr := httptest.NewRecorder()
 
-			handler := http.HandlerFunc(controller.SendEmails)
+			handler := http.HandlerFunc(controller.UnsubscribeEmail)
 			handler.ServeHTTP(rr, req)
 
 			require.Equal(
 				t,
 				tt.expectedStatus,
 				rr.Code,
-				"SendEmails returned wrong status code: got %v, expected %v",
+				"UnsubscribeEmail returned wrong status code: got %v, expected %v",
 				rr.Code,
 				tt.expectedStatus,
 			)
@@ -0,0 +1,77 @@
+package httpcontroller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/job"
+)
+
+const _jobsPath = "/api/jobs/"
+
+type jobResponse struct {
+	ID      string         `json:"id"`
+	Status  port.JobStatus `json:"status"`
+	Total   int            `json:"total"`
+	Sent    int            `json:"sent"`
+	Failed  int            `json:"failed"`
+	Skipped int            `json:"skipped"`
+	Pending int            `json:"pending"`
+	Error   string         `json:"error,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	// Not set until the job gets there
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Job returns the progress of the send job with the id of the path
+func (ac *AppController) Job(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, _jobsPath)
+
+	found, err := ac.JobService.Job(id)
+	if errors.Is(err, job.ErrJobNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newJobResponse(found))
+}
+
+func newJobResponse(j *port.Job) jobResponse {
+	response := jobResponse{
+		ID:        j.ID,
+		Status:    j.Status,
+		Total:     j.Total,
+		Sent:      j.Sent,
+		Failed:    j.Failed,
+		Skipped:   j.Skipped,
+		Pending:   j.Pending(),
+		Error:     j.Error,
+		CreatedAt: j.CreatedAt,
+	}
+
+	if !j.StartedAt.IsZero() {
+		response.StartedAt = &j.StartedAt
+	}
+
+	if !j.FinishedAt.IsZero() {
+		response.FinishedAt = &j.FinishedAt
+	}
+
+	return response
+}
@@ -0,0 +1,190 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/job"
+)
+
+var errJobRepository = errors.New("job repository error")
+
+type StubJobService struct {
+	job *port.Job
+	err error
+
+	requestedID string
+}
+
+func (m *StubJobService) Enqueue() (*port.Job, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &port.Job{ID: "job1", Status: port.JobStatusQueued}, nil
+}
+
+func (m *StubJobService) Job(id string) (*port.Job, error) {
+	m.requestedID = id
+	return m.job, m.err
+}
+
+func TestSendEmails(t *testing.T) {
+	tests := []struct {
+		name             string
+		jobService       *StubJobService
+		expectedStatus   int
+		expectedLocation string
+		expectedResponse *jobResponse
+	}{
+		{
+			name:             "Send job is queued",
+			jobService:       &StubJobService{},
+			expectedStatus:   http.StatusAccepted,
+			expectedLocation: "/api/jobs/job1",
+			expectedResponse: &jobResponse{
+				ID:     "job1",
+				Status: port.JobStatusQueued,
+			},
+		},
+		{
+			name:           "Job repository error",
+			jobService:     &StubJobService{err: errJobRepository},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				&StubEmailSubscriptionService{},
+				tt.jobService,
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
+			)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/sendEmails", nil)
+			rr := httptest.NewRecorder()
+
+			controller.SendEmails(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedLocation, rr.Header().Get("Location"))
+
+			if tt.expectedResponse == nil {
+				return
+			}
+
+			var response jobResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			require.Equal(t, *tt.expectedResponse, response)
+		})
+	}
+}
+
+func TestJob(t *testing.T) {
+	createdAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	startedAt := createdAt.Add(time.Second)
+
+	tests := []struct {
+		name             string
+		method           string
+		jobService       *StubJobService
+		expectedStatus   int
+		expectedResponse *jobResponse
+	}{
+		{
+			name:   "Running job",
+			method: http.MethodGet,
+			jobService: &StubJobService{job: &port.Job{
+				ID:        "job1",
+				Status:    port.JobStatusRunning,
+				Total:     5,
+				Sent:      2,
+				Failed:    1,
+				Skipped:   1,
+				CreatedAt: createdAt,
+				StartedAt: startedAt,
+			}},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &jobResponse{
+				ID:        "job1",
+				Status:    port.JobStatusRunning,
+				Total:     5,
+				Sent:      2,
+				Failed:    1,
+				Skipped:   1,
+				Pending:   1,
+				CreatedAt: createdAt,
+				StartedAt: &startedAt,
+			},
+		},
+		{
+			name:           "Missing job",
+			method:         http.MethodGet,
+			jobService:     &StubJobService{err: job.ErrJobNotFound},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Job repository error",
+			method:         http.MethodGet,
+			jobService:     &StubJobService{err: errJobRepository},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodPost,
+			jobService:     &StubJobService{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				&StubEmailSubscriptionService{},
+				tt.jobService,
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
+			)
+
+			req := httptest.NewRequest(tt.method, "/api/jobs/job1", nil)
+			rr := httptest.NewRecorder()
+
+			controller.Job(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.method == http.MethodGet {
+				require.Equal(t, "job1", tt.jobService.requestedID)
+			}
+
+			if tt.expectedResponse == nil {
+				return
+			}
+
+			var response jobResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			require.Equal(t, *tt.expectedResponse, response)
+		})
+	}
+}
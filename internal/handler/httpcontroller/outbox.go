@@ -0,0 +1,117 @@
+package httpcontroller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/outbox"
+)
+
+type OutboxService interface {
+	Stats() (*outbox.Stats, error)
+	Retry(id string) error
+	Discard(id string) error
+}
+
+type outboxResponse struct {
+	Pending int `json:"pending"`
+	Due     int `json:"due"`
+	Dead    int `json:"dead"`
+	// Not set if nothing is pending
+	OldestPendingAt *time.Time           `json:"oldest_pending_at,omitempty"`
+	DeadLetters     []deadLetterResponse `json:"dead_letters"`
+}
+
+type deadLetterResponse struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminOutbox returns the depth of the email queue and the dead letters
+// on GET and discards the dead letter on DELETE
+func (ac *AppController) AdminOutbox(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ac.getOutbox(w)
+	case http.MethodDelete:
+		err := ac.OutboxService.Discard(r.FormValue(_idParam))
+		if err != nil {
+			writeOutboxError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminOutboxRetry queues the dead letter again
+func (ac *AppController) AdminOutboxRetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := ac.OutboxService.Retry(r.FormValue(_idParam)); err != nil {
+		writeOutboxError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ac *AppController) getOutbox(w http.ResponseWriter) {
+	stats, err := ac.OutboxService.Stats()
+	if err != nil {
+		writeOutboxError(w, err)
+		return
+	}
+
+	response := outboxResponse{
+		Pending:     stats.Pending,
+		Due:         stats.Due,
+		Dead:        stats.Dead,
+		DeadLetters: make([]deadLetterResponse, 0, len(stats.DeadLetters)),
+	}
+
+	if !stats.OldestPendingAt.IsZero() {
+		response.OldestPendingAt = &stats.OldestPendingAt
+	}
+
+	for _, m := range stats.DeadLetters {
+		response.DeadLetters = append(response.DeadLetters, newDeadLetterResponse(m))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeOutboxError(w http.ResponseWriter, err error) {
+	if errors.Is(err, outbox.ErrMessageNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func newDeadLetterResponse(m port.OutboxMessage) deadLetterResponse {
+	return deadLetterResponse{
+		ID:        m.ID,
+		Email:     m.Email,
+		Base:      m.Rate.Pair.Base,
+		Quote:     m.Rate.Pair.Quote,
+		Attempts:  m.Attempts,
+		LastError: m.LastError,
+		CreatedAt: m.CreatedAt,
+	}
+}
@@ -0,0 +1,212 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/outbox"
+)
+
+var errOutboxRepository = errors.New("outbox repository error")
+
+type StubOutboxService struct {
+	stats *outbox.Stats
+	err   error
+
+	retried   string
+	discarded string
+}
+
+func (m *StubOutboxService) Stats() (*outbox.Stats, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	if m.stats == nil {
+		return &outbox.Stats{}, nil
+	}
+
+	return m.stats, nil
+}
+
+func (m *StubOutboxService) Retry(id string) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.retried = id
+	return nil
+}
+
+func (m *StubOutboxService) Discard(id string) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.discarded = id
+	return nil
+}
+
+func TestAdminOutbox(t *testing.T) {
+	createdAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		method            string
+		url               string
+		handler           func(ac *AppController) http.HandlerFunc
+		service           *StubOutboxService
+		expectedStatus    int
+		expectedResponse  *outboxResponse
+		expectedRetried   string
+		expectedDiscarded string
+	}{
+		{
+			name:    "Get outbox",
+			method:  http.MethodGet,
+			url:     "/api/admin/outbox",
+			handler: func(ac *AppController) http.HandlerFunc { return ac.AdminOutbox },
+			service: &StubOutboxService{stats: &outbox.Stats{
+				Pending:         2,
+				Due:             1,
+				Dead:            1,
+				OldestPendingAt: createdAt,
+				DeadLetters: []port.OutboxMessage{{
+					ID:        "message1",
+					Email:     "test@example.com",
+					Rate:      port.Rate{Pair: port.DefaultCurrencyPair},
+					Status:    port.OutboxStatusDead,
+					Attempts:  5,
+					LastError: "550 mailbox unavailable",
+					CreatedAt: createdAt,
+				}},
+			}},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &outboxResponse{
+				Pending:         2,
+				Due:             1,
+				Dead:            1,
+				OldestPendingAt: &createdAt,
+				DeadLetters: []deadLetterResponse{{
+					ID:        "message1",
+					Email:     "test@example.com",
+					Base:      port.DefaultCurrencyPair.Base,
+					Quote:     port.DefaultCurrencyPair.Quote,
+					Attempts:  5,
+					LastError: "550 mailbox unavailable",
+					CreatedAt: createdAt,
+				}},
+			},
+		},
+		{
+			name:           "Get empty outbox",
+			method:         http.MethodGet,
+			url:            "/api/admin/outbox",
+			handler:        func(ac *AppController) http.HandlerFunc { return ac.AdminOutbox },
+			service:        &StubOutboxService{},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &outboxResponse{
+				DeadLetters: []deadLetterResponse{},
+			},
+		},
+		{
+			name:           "Repository error",
+			method:         http.MethodGet,
+			url:            "/api/admin/outbox",
+			handler:        func(ac *AppController) http.HandlerFunc { return ac.AdminOutbox },
+			service:        &StubOutboxService{err: errOutboxRepository},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:              "Discard dead letter",
+			method:            http.MethodDelete,
+			url:               "/api/admin/outbox?id=message1",
+			handler:           func(ac *AppController) http.HandlerFunc { return ac.AdminOutbox },
+			service:           &StubOutboxService{},
+			expectedStatus:    http.StatusNoContent,
+			expectedDiscarded: "message1",
+		},
+		{
+			name:           "Discard missing dead letter",
+			method:         http.MethodDelete,
+			url:            "/api/admin/outbox?id=message1",
+			handler:        func(ac *AppController) http.HandlerFunc { return ac.AdminOutbox },
+			service:        &StubOutboxService{err: outbox.ErrMessageNotFound},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:            "Retry dead letter",
+			method:          http.MethodPost,
+			url:             "/api/admin/outbox/retry?id=message1",
+			handler:         func(ac *AppController) http.HandlerFunc { return ac.AdminOutboxRetry },
+			service:         &StubOutboxService{},
+			expectedStatus:  http.StatusNoContent,
+			expectedRetried: "message1",
+		},
+		{
+			name:           "Retry missing dead letter",
+			method:         http.MethodPost,
+			url:            "/api/admin/outbox/retry?id=message1",
+			handler:        func(ac *AppController) http.HandlerFunc { return ac.AdminOutboxRetry },
+			service:        &StubOutboxService{err: outbox.ErrMessageNotFound},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodPut,
+			url:            "/api/admin/outbox",
+			handler:        func(ac *AppController) http.HandlerFunc { return ac.AdminOutbox },
+			service:        &StubOutboxService{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Unsupported retry method",
+			method:         http.MethodGet,
+			url:            "/api/admin/outbox/retry?id=message1",
+			handler:        func(ac *AppController) http.HandlerFunc { return ac.AdminOutboxRetry },
+			service:        &StubOutboxService{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				&StubEmailSubscriptionService{},
+				&StubJobService{},
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				tt.service,
+			)
+
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			tt.handler(controller)(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedRetried, tt.service.retried)
+			require.Equal(t, tt.expectedDiscarded, tt.service.discarded)
+
+			if tt.expectedResponse == nil {
+				return
+			}
+
+			var response outboxResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			require.Equal(t, *tt.expectedResponse, response)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/subscription"
+)
+
+const (
+	_subscribersPath    = "/api/subscribers/"
+	_preferencesPath    = "/preferences"
+	_maxPreferencesSize = 1 << 16
+)
+
+// The absent fields of the request body are kept, the empty ones are
+// reset to the defaults
+type preferencesRequest struct {
+	Language *string   `json:"language"`
+	Pairs    *[]string `json:"pairs"`
+	Cadence  *string   `json:"cadence"`
+	Channel  *string   `json:"channel"`
+}
+
+type preferencesResponse struct {
+	Email    string   `json:"email"`
+	Language string   `json:"language"`
+	Pairs    []string `json:"pairs"`
+	Cadence  string   `json:"cadence"`
+	// Empty if the rate is sent through every linked channel
+	Channel string `json:"channel"`
+}
+
+// SubscriberPreferences returns the preferences of the subscriber of
+// the /api/subscribers/{email}/preferences path on GET and changes them
+// on PATCH. The subscriber is authorized by the token of the unsubscribe
+// link.
+func (ac *AppController) SubscriberPreferences(w http.ResponseWriter, r *http.Request) {
+	email, ok := preferencesEmailFromPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var preferences *port.Preferences
+	var err error
+
+	switch r.Method {
+	case http.MethodGet:
+		preferences, err = ac.EmailSubscriptionService.Preferences(
+			email,
+			r.FormValue(_tokenParam),
+		)
+	case http.MethodPatch:
+		var update subscription.PreferencesUpdate
+		update, err = preferencesUpdateFromRequest(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		preferences, err = ac.EmailSubscriptionService.UpdatePreferences(
+			email,
+			r.URL.Query().Get(_tokenParam),
+			update,
+		)
+	default:
+		w.Header().Set("Allow", "GET, PATCH")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case errors.Is(err, subscription.ErrInvalidPreferences):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, subscription.ErrInvalidManagementToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, subscription.ErrNotSubscribed):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, subscription.ErrNotActive),
+		errors.Is(err, subscription.ErrTelegramNotLinked):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, newPreferencesResponse(email, *preferences))
+	}
+}
+
+// preferencesEmailFromPath returns the email of
+// the /api/subscribers/{email}/preferences path
+func preferencesEmailFromPath(path string) (string, bool) {
+	email, ok := strings.CutPrefix(path, _subscribersPath)
+	if !ok {
+		return "", false
+	}
+
+	email, ok = strings.CutSuffix(email, _preferencesPath)
+	if !ok || email == "" || strings.Contains(email, "/") {
+		return "", false
+	}
+
+	return email, true
+}
+
+func preferencesUpdateFromRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+) (subscription.PreferencesUpdate, error) {
+	var request preferencesRequest
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, _maxPreferencesSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return subscription.PreferencesUpdate{}, fmt.Errorf("invalid preferences: %w", err)
+	}
+
+	return subscription.PreferencesUpdate{
+		Language: request.Language,
+		Pairs:    request.Pairs,
+		Cadence:  request.Cadence,
+		Channel:  request.Channel,
+	}, nil
+}
+
+func newPreferencesResponse(email string, p port.Preferences) preferencesResponse {
+	pairs := p.SubscribedPairs()
+	formatted := make([]string, len(pairs))
+	for i, pair := range pairs {
+		formatted[i] = pair.String()
+	}
+
+	cadence := p.Cadence
+	if cadence == "" {
+		cadence = port.DefaultCadence
+	}
+
+	return preferencesResponse{
+		Email:    email,
+		Language: p.Language,
+		Pairs:    formatted,
+		Cadence:  string(cadence),
+		Channel:  p.Channel,
+	}
+}
@@ -0,0 +1,186 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/subscription"
+)
+
+func TestSubscriberPreferences(t *testing.T) {
+	btcUSD, _ := port.NewCurrencyPair("BTC", "USD")
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		service        *StubEmailSubscriptionService
+		expectedStatus int
+	}{
+		{
+			name:           "Get preferences",
+			method:         http.MethodGet,
+			path:           "/api/subscribers/test@example.com/preferences?token=token",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Update preferences",
+			method: http.MethodPatch,
+			path:   "/api/subscribers/test@example.com/preferences?token=token",
+			body:   `{"language":"uk","pairs":["BTC/USD"]}`,
+			service: &StubEmailSubscriptionService{
+				preferences: port.Preferences{
+					Language: "uk",
+					Pairs:    []port.CurrencyPair{btcUSD},
+				},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Malformed body",
+			method:         http.MethodPatch,
+			path:           "/api/subscribers/test@example.com/preferences?token=token",
+			body:           `{"language":`,
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unknown field",
+			method:         http.MethodPatch,
+			path:           "/api/subscribers/test@example.com/preferences?token=token",
+			body:           `{"timezone":"UTC"}`,
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Invalid preferences",
+			method: http.MethodPatch,
+			path:   "/api/subscribers/test@example.com/preferences?token=token",
+			body:   `{"cadence":"monthly"}`,
+			service: &StubEmailSubscriptionService{
+				preferencesErr: errors.Join(
+					subscription.ErrInvalidPreferences,
+					port.ErrInvalidCadence,
+				),
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Invalid token",
+			method: http.MethodGet,
+			path:   "/api/subscribers/test@example.com/preferences?token=token",
+			service: &StubEmailSubscriptionService{
+				preferencesErr: subscription.ErrInvalidManagementToken,
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:   "Not subscribed",
+			method: http.MethodGet,
+			path:   "/api/subscribers/test@example.com/preferences?token=token",
+			service: &StubEmailSubscriptionService{
+				preferencesErr: subscription.ErrNotSubscribed,
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "Telegram isn't linked",
+			method: http.MethodPatch,
+			path:   "/api/subscribers/test@example.com/preferences?token=token",
+			body:   `{"channel":"telegram"}`,
+			service: &StubEmailSubscriptionService{
+				preferencesErr: subscription.ErrTelegramNotLinked,
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Unknown path",
+			method:         http.MethodGet,
+			path:           "/api/subscribers/test@example.com",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Method not allowed",
+			method:         http.MethodDelete,
+			path:           "/api/subscribers/test@example.com/preferences?token=token",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				tt.service,
+				&StubJobService{},
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
+			)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			controller.SubscriberPreferences(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestSubscriberPreferencesResponse(t *testing.T) {
+	t.Parallel()
+
+	service := &StubEmailSubscriptionService{
+		preferences: port.Preferences{Language: "uk", Channel: port.ChannelEmail},
+	}
+	controller := NewAppController(
+		&StubExchangeRateService{},
+		service,
+		&StubJobService{},
+		&StubRateHistoryService{},
+		&StubAlertService{},
+		&StubWebhookService{},
+		&StubOutboxService{},
+	)
+
+	req := httptest.NewRequest(
+		http.MethodPatch,
+		"/api/subscribers/test@example.com/preferences?token=token",
+		strings.NewReader(`{"language":"uk","channel":"email"}`),
+	)
+	rr := httptest.NewRecorder()
+
+	controller.SubscriberPreferences(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "uk", *service.update.Language)
+	require.Equal(t, "email", *service.update.Channel)
+	require.Nil(t, service.update.Pairs)
+	require.Nil(t, service.update.Cadence)
+
+	var response preferencesResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	require.Equal(t, preferencesResponse{
+		Email:    "test@example.com",
+		Language: "uk",
+		Pairs:    []string{"BTC/UAH"},
+		Cadence:  "daily",
+		Channel:  "email",
+	}, response)
+}
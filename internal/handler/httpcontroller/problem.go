@@ -0,0 +1,34 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	_problemContentType = "application/problem+json"
+	_invalidEmailType   = "/problems/invalid-email"
+	_invalidEmailTitle  = "Invalid email address"
+	_defaultProblemType = "about:blank"
+)
+
+// Problem is the error response body as described in RFC 7807
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Email  string `json:"email,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, problem Problem) {
+	if problem.Type == "" {
+		problem.Type = _defaultProblemType
+	}
+
+	w.Header().Set("Content-Type", _problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+
+	_ = json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,178 @@
+package httpcontroller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/subscription"
+)
+
+const (
+	_adminSubscribersPath = "/api/admin/subscribers/"
+
+	_cursorParam = "cursor"
+	_limitParam  = "limit"
+	_searchParam = "q"
+)
+
+type subscribersResponse struct {
+	Subscribers []subscriberResponse `json:"subscribers"`
+	// Empty on the last page
+	NextCursor string               `json:"next_cursor,omitempty"`
+	Counts     statusCountsResponse `json:"counts"`
+}
+
+type statusCountsResponse struct {
+	Total   int `json:"total"`
+	Active  int `json:"active"`
+	Pending int `json:"pending"`
+}
+
+type subscriberResponse struct {
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	// Only set while the subscription is pending
+	ConfirmationExpiresAt *time.Time `json:"confirmation_expires_at,omitempty"`
+	TelegramLinked        bool       `json:"telegram_linked"`
+	Language              string     `json:"language"`
+	Pairs                 []string   `json:"pairs"`
+	Cadence               string     `json:"cadence"`
+	Channel               string     `json:"channel"`
+	// Only set for a confirmed subscriber returned on its own, so the admin
+	// can give the subscriber the management token again
+	ManagementToken string `json:"management_token,omitempty"`
+}
+
+// AdminSubscribers returns a page of the subscribers ordered by email.
+// The cursor parameter is the next_cursor of the previous page, limit
+// is the page size and q the substring of the emails to match.
+func (ac *AppController) AdminSubscribers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := subscriberQueryFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := ac.EmailSubscriptionService.ListSubscribers(query)
+	if err != nil {
+		writeSubscriberError(w, err)
+		return
+	}
+
+	response := subscribersResponse{
+		Subscribers: make([]subscriberResponse, 0, len(page.Subscribers)),
+		NextCursor:  page.NextCursor,
+		Counts: statusCountsResponse{
+			Total:   page.Counts.Total,
+			Active:  page.Counts.Active,
+			Pending: page.Counts.Pending,
+		},
+	}
+
+	for _, user := range page.Subscribers {
+		response.Subscribers = append(response.Subscribers, newSubscriberResponse(user))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// AdminSubscriber returns the subscriber of the /api/admin/subscribers/{email}
+// path on GET and removes it on DELETE
+func (ac *AppController) AdminSubscriber(w http.ResponseWriter, r *http.Request) {
+	email := strings.TrimPrefix(r.URL.Path, _adminSubscribersPath)
+	if email == "" || strings.Contains(email, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := ac.EmailSubscriptionService.Subscriber(email)
+		if err != nil {
+			writeSubscriberError(w, err)
+			return
+		}
+
+		response := newSubscriberResponse(*user)
+		if user.IsActive() {
+			response.ManagementToken = ac.EmailSubscriptionService.ManagementToken(user.Email)
+		}
+
+		writeJSON(w, http.StatusOK, response)
+	case http.MethodDelete:
+		if err := ac.EmailSubscriptionService.RemoveSubscriber(email); err != nil {
+			writeSubscriberError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func subscriberQueryFromRequest(r *http.Request) (subscription.SubscriberQuery, error) {
+	query := subscription.SubscriberQuery{
+		Cursor: r.FormValue(_cursorParam),
+		Search: r.FormValue(_searchParam),
+	}
+
+	if raw := r.FormValue(_limitParam); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return query, fmt.Errorf("invalid %s: %w", _limitParam, err)
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+func writeSubscriberError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, subscription.ErrInvalidCursor),
+		errors.Is(err, subscription.ErrInvalidLimit):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, subscription.ErrNotSubscribed):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func newSubscriberResponse(user port.User) subscriberResponse {
+	preferences := newPreferencesResponse(user.Email, user.Preferences)
+
+	response := subscriberResponse{
+		Email:          user.Email,
+		Status:         string(port.UserStatusActive),
+		TelegramLinked: user.TelegramChatID != 0,
+		Language:       preferences.Language,
+		Pairs:          preferences.Pairs,
+		Cadence:        preferences.Cadence,
+		Channel:        preferences.Channel,
+	}
+
+	if !user.IsActive() {
+		response.Status = string(user.Status)
+	}
+
+	if !user.ConfirmationExpiresAt.IsZero() {
+		expiresAt := user.ConfirmationExpiresAt
+		response.ConfirmationExpiresAt = &expiresAt
+	}
+
+	return response
+}
@@ -0,0 +1,217 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/subscription"
+)
+
+func newSubscribersTestController(service *StubEmailSubscriptionService) *AppController {
+	return NewAppController(
+		&StubExchangeRateService{},
+		service,
+		&StubJobService{},
+		&StubRateHistoryService{},
+		&StubAlertService{},
+		&StubWebhookService{},
+		&StubOutboxService{},
+	)
+}
+
+func TestAdminSubscribers(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		service        *StubEmailSubscriptionService
+		expectedQuery  subscription.SubscriberQuery
+		expectedStatus int
+	}{
+		{
+			name:           "List subscribers",
+			method:         http.MethodGet,
+			url:            "/api/admin/subscribers?cursor=abc&limit=10&q=example",
+			service:        &StubEmailSubscriptionService{},
+			expectedQuery:  subscription.SubscriberQuery{Cursor: "abc", Limit: 10, Search: "example"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Malformed limit",
+			method:         http.MethodGet,
+			url:            "/api/admin/subscribers?limit=ten",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Invalid cursor",
+			method: http.MethodGet,
+			url:    "/api/admin/subscribers?cursor=abc",
+			service: &StubEmailSubscriptionService{
+				subscriberErr: subscription.ErrInvalidCursor,
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Repository error",
+			method: http.MethodGet,
+			url:    "/api/admin/subscribers",
+			service: &StubEmailSubscriptionService{
+				subscriberErr: errors.New("repository error"),
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Method not allowed",
+			method:         http.MethodPost,
+			url:            "/api/admin/subscribers",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := newSubscribersTestController(tt.service)
+
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			controller.AdminSubscribers(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedQuery, tt.service.query)
+		})
+	}
+}
+
+func TestAdminSubscribersResponse(t *testing.T) {
+	t.Parallel()
+
+	expiresAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	service := &StubEmailSubscriptionService{
+		page: subscription.SubscriberPage{
+			Subscribers: []port.User{
+				{Email: "active@example.com", TelegramChatID: 42},
+				{
+					Email:                 "pending@example.com",
+					Status:                port.UserStatusPending,
+					ConfirmationExpiresAt: expiresAt,
+				},
+			},
+			NextCursor: "next",
+			Counts:     subscription.StatusCounts{Total: 3, Active: 2, Pending: 1},
+		},
+	}
+	controller := newSubscribersTestController(service)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/subscribers", nil)
+	rr := httptest.NewRecorder()
+
+	controller.AdminSubscribers(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response subscribersResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	require.Equal(t, subscribersResponse{
+		Subscribers: []subscriberResponse{
+			{
+				Email:          "active@example.com",
+				Status:         "active",
+				TelegramLinked: true,
+				Pairs:          []string{"BTC/UAH"},
+				Cadence:        "daily",
+			},
+			{
+				Email:                 "pending@example.com",
+				Status:                "pending",
+				ConfirmationExpiresAt: &expiresAt,
+				Pairs:                 []string{"BTC/UAH"},
+				Cadence:               "daily",
+			},
+		},
+		NextCursor: "next",
+		Counts:     statusCountsResponse{Total: 3, Active: 2, Pending: 1},
+	}, response)
+}
+
+func TestAdminSubscriber(t *testing.T) {
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		service         *StubEmailSubscriptionService
+		expectedStatus  int
+		expectedRemoved string
+		expectedBody    string
+	}{
+		{
+			name:           "Get subscriber",
+			method:         http.MethodGet,
+			path:           "/api/admin/subscribers/test@example.com",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"management_token":"management:test@example.com"`,
+		},
+		{
+			name:            "Remove subscriber",
+			method:          http.MethodDelete,
+			path:            "/api/admin/subscribers/test@example.com",
+			service:         &StubEmailSubscriptionService{},
+			expectedStatus:  http.StatusNoContent,
+			expectedRemoved: "test@example.com",
+		},
+		{
+			name:   "Unknown subscriber",
+			method: http.MethodDelete,
+			path:   "/api/admin/subscribers/test@example.com",
+			service: &StubEmailSubscriptionService{
+				subscriberErr: subscription.ErrNotSubscribed,
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "No email",
+			method:         http.MethodGet,
+			path:           "/api/admin/subscribers/",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Method not allowed",
+			method:         http.MethodPut,
+			path:           "/api/admin/subscribers/test@example.com",
+			service:        &StubEmailSubscriptionService{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := newSubscribersTestController(tt.service)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			controller.AdminSubscriber(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedRemoved, tt.service.removed)
+			require.Contains(t, rr.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package httpcontroller
+
+import (
+	"errors"
+	"net/http"
+
+	"gses2-app/internal/core/service/subscription"
+)
+
+type telegramLinkResponse struct {
+	Code string `json:"code"`
+	// The message to send to the bot, the code is also the payload
+	// of the https://t.me/<bot>?start=<code> deep link
+	Command string `json:"command"`
+}
+
+// TelegramLink returns the code which links a Telegram chat to
+// the confirmed subscription the token was issued for
+func (ac *AppController) TelegramLink(w http.ResponseWriter, r *http.Request) {
+	code, err := ac.EmailSubscriptionService.TelegramLinkCode(
+		r.FormValue(_emailParam),
+		r.FormValue(_tokenParam),
+	)
+
+	switch {
+	case errors.Is(err, subscription.ErrInvalidManagementToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, subscription.ErrNotSubscribed):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, subscription.ErrLinkNotActiveEmail):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, telegramLinkResponse{
+			Code:    code,
+			Command: "/start " + code,
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/service/subscription"
+)
+
+func TestTelegramLink(t *testing.T) {
+	tests := []struct {
+		name           string
+		service        *StubEmailSubscriptionService
+		expectedStatus int
+	}{
+		{
+			name:           "Link code",
+			service:        &StubEmailSubscriptionService{linkCode: "code"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Invalid token",
+			service: &StubEmailSubscriptionService{
+				linkCodeErr: subscription.ErrInvalidManagementToken,
+			},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name: "Not subscribed",
+			service: &StubEmailSubscriptionService{
+				linkCodeErr: subscription.ErrNotSubscribed,
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "Pending subscription",
+			service: &StubEmailSubscriptionService{
+				linkCodeErr: subscription.ErrLinkNotActiveEmail,
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "Repository error",
+			service: &StubEmailSubscriptionService{
+				linkCodeErr: errors.New("repository error"),
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				tt.service,
+				&StubJobService{},
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				&StubWebhookService{},
+				&StubOutboxService{},
+			)
+
+			req := httptest.NewRequest(
+				http.MethodGet,
+				"/telegram/link?email=test@example.com&token=token",
+				nil,
+			)
+			rr := httptest.NewRecorder()
+
+			controller.TelegramLink(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response telegramLinkResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			require.Equal(t, telegramLinkResponse{
+				Code:    "code",
+				Command: "/start code",
+			}, response)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package httpcontroller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/webhook"
+)
+
+const _urlParam = "url"
+
+type WebhookService interface {
+	Register(email, token, url string) (*port.Webhook, error)
+	SubscriberWebhooks(email, token string) ([]port.Webhook, error)
+	Remove(email, token, id string) error
+	Deliveries(email, token, id string) ([]port.WebhookDelivery, error)
+	AdminRegister(email, url string) (*port.Webhook, error)
+	Webhooks() ([]port.Webhook, error)
+	AdminRemove(id string) error
+	AdminDeliveries(id string) ([]port.WebhookDelivery, error)
+}
+
+type webhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Only returned when the webhook is registered
+	Secret string `json:"secret,omitempty"`
+}
+
+type webhookDeliveryResponse struct {
+	ID          string    `json:"id"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Duration    string    `json:"duration"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Succeeded   bool      `json:"succeeded"`
+}
+
+// Webhooks lists the subscriber's webhooks on GET, registers a webhook
+// on POST and removes one on DELETE. The subscriber is authorized by
+// the token of the unsubscribe link.
+func (ac *AppController) Webhooks(w http.ResponseWriter, r *http.Request) {
+	email, token := r.FormValue(_emailParam), r.FormValue(_tokenParam)
+
+	switch r.Method {
+	case http.MethodGet:
+		webhooks, err := ac.WebhookService.SubscriberWebhooks(email, token)
+		writeWebhooks(w, webhooks, err)
+	case http.MethodPost:
+		newWebhook, err := ac.WebhookService.Register(email, token, r.FormValue(_urlParam))
+		writeNewWebhook(w, newWebhook, err)
+	case http.MethodDelete:
+		err := ac.WebhookService.Remove(email, token, r.FormValue(_idParam))
+		writeWebhookRemoved(w, err)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminWebhooks lists all the webhooks on GET, registers a webhook of
+// the optional email on POST and removes any webhook on DELETE
+func (ac *AppController) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		webhooks, err := ac.WebhookService.Webhooks()
+		writeWebhooks(w, webhooks, err)
+	case http.MethodPost:
+		newWebhook, err := ac.WebhookService.AdminRegister(
+			r.FormValue(_emailParam),
+			r.FormValue(_urlParam),
+		)
+		writeNewWebhook(w, newWebhook, err)
+	case http.MethodDelete:
+		err := ac.WebhookService.AdminRemove(r.FormValue(_idParam))
+		writeWebhookRemoved(w, err)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// WebhookDeliveries lists the attempts to post to the subscriber's
+// webhook, the latest first
+func (ac *AppController) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := ac.WebhookService.Deliveries(
+		r.FormValue(_emailParam),
+		r.FormValue(_tokenParam),
+		r.FormValue(_idParam),
+	)
+	writeWebhookDeliveries(w, deliveries, err)
+}
+
+// AdminWebhookDeliveries lists the attempts to post to any webhook,
+// the latest first
+func (ac *AppController) AdminWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := ac.WebhookService.AdminDeliveries(r.FormValue(_idParam))
+	writeWebhookDeliveries(w, deliveries, err)
+}
+
+func writeWebhooks(w http.ResponseWriter, webhooks []port.Webhook, err error) {
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response := make([]webhookResponse, 0, len(webhooks))
+	for i := range webhooks {
+		response = append(response, newWebhookResponse(&webhooks[i]))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeNewWebhook(w http.ResponseWriter, newWebhook *port.Webhook, err error) {
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response := newWebhookResponse(newWebhook)
+	response.Secret = newWebhook.Secret
+
+	writeJSON(w, http.StatusCreated, response)
+}
+
+func writeWebhookRemoved(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeWebhookDeliveries(
+	w http.ResponseWriter,
+	deliveries []port.WebhookDelivery,
+	err error,
+) {
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	response := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		response = append(response, webhookDeliveryResponse{
+			ID:          d.ID,
+			Attempt:     d.Attempt,
+			StatusCode:  d.StatusCode,
+			Error:       d.Error,
+			Duration:    d.Duration.String(),
+			DeliveredAt: d.DeliveredAt,
+			Succeeded:   d.Succeeded(),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrInvalidURL),
+		errors.Is(err, webhook.ErrForbiddenURL):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, webhook.ErrInvalidToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, webhook.ErrNotSubscribed),
+		errors.Is(err, webhook.ErrWebhookNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, webhook.ErrTooManyWebhooks):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func newWebhookResponse(w *port.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:        w.ID,
+		URL:       w.URL,
+		Email:     w.Email,
+		CreatedAt: w.CreatedAt,
+	}
+}
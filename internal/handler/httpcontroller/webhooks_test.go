@@ -0,0 +1,337 @@
+package httpcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/webhook"
+)
+
+var errWebhookRepository = errors.New("webhook repository error")
+
+type StubWebhookService struct {
+	webhooks   []port.Webhook
+	deliveries []port.WebhookDelivery
+	err        error
+
+	// The email the webhook is registered for and the removed webhook
+	owner   string
+	removed string
+}
+
+func (m *StubWebhookService) Register(email, token, url string) (*port.Webhook, error) {
+	return m.AdminRegister(email, url)
+}
+
+func (m *StubWebhookService) SubscriberWebhooks(email, token string) ([]port.Webhook, error) {
+	return m.webhooks, m.err
+}
+
+func (m *StubWebhookService) Remove(email, token, id string) error {
+	return m.AdminRemove(id)
+}
+
+func (m *StubWebhookService) Deliveries(
+	email string,
+	token string,
+	id string,
+) ([]port.WebhookDelivery, error) {
+	return m.deliveries, m.err
+}
+
+func (m *StubWebhookService) AdminRegister(email, url string) (*port.Webhook, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	m.owner = email
+	return &port.Webhook{ID: "id", URL: url, Secret: "secret", Email: email}, nil
+}
+
+func (m *StubWebhookService) Webhooks() ([]port.Webhook, error) {
+	return m.webhooks, m.err
+}
+
+func (m *StubWebhookService) AdminRemove(id string) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.removed = id
+	return nil
+}
+
+func (m *StubWebhookService) AdminDeliveries(id string) ([]port.WebhookDelivery, error) {
+	return m.deliveries, m.err
+}
+
+func TestWebhooks(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		url              string
+		body             string
+		admin            bool
+		service          *StubWebhookService
+		expectedStatus   int
+		expectedWebhook  *webhookResponse
+		expectedWebhooks []webhookResponse
+		expectedOwner    string
+		expectedRemove   string
+	}{
+		{
+			name:           "Register webhook",
+			method:         http.MethodPost,
+			url:            "/api/webhooks",
+			body:           "email=test@example.com&token=token&url=https://example.com/rate",
+			service:        &StubWebhookService{},
+			expectedStatus: http.StatusCreated,
+			expectedWebhook: &webhookResponse{
+				ID:     "id",
+				URL:    "https://example.com/rate",
+				Email:  "test@example.com",
+				Secret: "secret",
+			},
+			expectedOwner: "test@example.com",
+		},
+		{
+			name:           "Invalid URL",
+			method:         http.MethodPost,
+			url:            "/api/webhooks",
+			body:           "email=test@example.com&token=token&url=example.com",
+			service:        &StubWebhookService{err: webhook.ErrInvalidURL},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Private URL",
+			method:         http.MethodPost,
+			url:            "/api/webhooks",
+			body:           "email=test@example.com&token=token&url=http://127.0.0.1/",
+			service:        &StubWebhookService{err: webhook.ErrForbiddenURL},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Too many webhooks",
+			method:         http.MethodPost,
+			url:            "/api/webhooks",
+			body:           "email=test@example.com&token=token&url=https://example.com/rate",
+			service:        &StubWebhookService{err: webhook.ErrTooManyWebhooks},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Not subscribed",
+			method:         http.MethodPost,
+			url:            "/api/webhooks",
+			body:           "email=test@example.com&token=token&url=https://example.com/rate",
+			service:        &StubWebhookService{err: webhook.ErrNotSubscribed},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "List webhooks",
+			method: http.MethodGet,
+			url:    "/api/webhooks?email=test@example.com&token=token",
+			service: &StubWebhookService{
+				webhooks: []port.Webhook{{
+					ID:     "id",
+					URL:    "https://example.com/rate",
+					Secret: "secret",
+					Email:  "test@example.com",
+				}},
+			},
+			expectedStatus: http.StatusOK,
+			expectedWebhooks: []webhookResponse{{
+				ID:    "id",
+				URL:   "https://example.com/rate",
+				Email: "test@example.com",
+			}},
+		},
+		{
+			name:           "Invalid token",
+			method:         http.MethodGet,
+			url:            "/api/webhooks?email=test@example.com&token=invalid",
+			service:        &StubWebhookService{err: webhook.ErrInvalidToken},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "Remove webhook",
+			method:         http.MethodDelete,
+			url:            "/api/webhooks?email=test@example.com&token=token&id=id",
+			service:        &StubWebhookService{},
+			expectedStatus: http.StatusNoContent,
+			expectedRemove: "id",
+		},
+		{
+			name:           "Remove missing webhook",
+			method:         http.MethodDelete,
+			url:            "/api/webhooks?email=test@example.com&token=token&id=id",
+			service:        &StubWebhookService{err: webhook.ErrWebhookNotFound},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Repository error",
+			method:         http.MethodPost,
+			url:            "/api/webhooks",
+			body:           "email=test@example.com&token=token&url=https://example.com/rate",
+			service:        &StubWebhookService{err: errWebhookRepository},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodPut,
+			url:            "/api/webhooks",
+			service:        &StubWebhookService{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Admin registers a webhook without an owner",
+			method:         http.MethodPost,
+			url:            "/api/admin/webhooks",
+			body:           "url=https://example.com/rate",
+			admin:          true,
+			service:        &StubWebhookService{},
+			expectedStatus: http.StatusCreated,
+			expectedWebhook: &webhookResponse{
+				ID:     "id",
+				URL:    "https://example.com/rate",
+				Secret: "secret",
+			},
+		},
+		{
+			name:   "Admin lists all the webhooks",
+			method: http.MethodGet,
+			url:    "/api/admin/webhooks",
+			admin:  true,
+			service: &StubWebhookService{
+				webhooks: []port.Webhook{
+					{ID: "id1", URL: "https://example.com/rate", Email: "test@example.com"},
+					{ID: "id2", URL: "https://example.org/rate"},
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedWebhooks: []webhookResponse{
+				{ID: "id1", URL: "https://example.com/rate", Email: "test@example.com"},
+				{ID: "id2", URL: "https://example.org/rate"},
+			},
+		},
+		{
+			name:           "Admin removes a webhook",
+			method:         http.MethodDelete,
+			url:            "/api/admin/webhooks?id=id",
+			admin:          true,
+			service:        &StubWebhookService{},
+			expectedStatus: http.StatusNoContent,
+			expectedRemove: "id",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := NewAppController(
+				&StubExchangeRateService{},
+				&StubEmailSubscriptionService{},
+				&StubJobService{},
+				&StubRateHistoryService{},
+				&StubAlertService{},
+				tt.service,
+				&StubOutboxService{},
+			)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			rr := httptest.NewRecorder()
+
+			if tt.admin {
+				controller.AdminWebhooks(rr, req)
+			} else {
+				controller.Webhooks(rr, req)
+			}
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+			require.Equal(t, tt.expectedOwner, tt.service.owner)
+			require.Equal(t, tt.expectedRemove, tt.service.removed)
+
+			switch {
+			case tt.expectedWebhook != nil:
+				var response webhookResponse
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				require.Equal(t, *tt.expectedWebhook, response)
+			case tt.expectedWebhooks != nil:
+				var response []webhookResponse
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				require.Equal(t, tt.expectedWebhooks, response)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	deliveredAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	controller := NewAppController(
+		&StubExchangeRateService{},
+		&StubEmailSubscriptionService{},
+		&StubJobService{},
+		&StubRateHistoryService{},
+		&StubAlertService{},
+		&StubWebhookService{deliveries: []port.WebhookDelivery{
+			{
+				ID:          "delivery1",
+				Attempt:     2,
+				StatusCode:  http.StatusOK,
+				Duration:    time.Second,
+				DeliveredAt: deliveredAt.Add(time.Second),
+			},
+			{
+				ID:          "delivery1",
+				Attempt:     1,
+				Error:       "connection refused",
+				DeliveredAt: deliveredAt,
+			},
+		}},
+		&StubOutboxService{},
+	)
+
+	req := httptest.NewRequest(
+		http.MethodGet,
+		"/api/webhooks/deliveries?email=test@example.com&token=token&id=id",
+		nil,
+	)
+	rr := httptest.NewRecorder()
+
+	controller.WebhookDeliveries(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response []webhookDeliveryResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	require.Equal(t, []webhookDeliveryResponse{
+		{
+			ID:          "delivery1",
+			Attempt:     2,
+			StatusCode:  http.StatusOK,
+			Duration:    "1s",
+			DeliveredAt: deliveredAt.Add(time.Second),
+			Succeeded:   true,
+		},
+		{
+			ID:          "delivery1",
+			Attempt:     1,
+			Error:       "connection refused",
+			Duration:    "0s",
+			DeliveredAt: deliveredAt,
+		},
+	}, response)
+}
@@ -1,31 +1,101 @@
 package router
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strings"
 	"time"
 )
 
+const _bearerPrefix = "Bearer "
+
 type HTTPConfig struct {
 	Port    string        `default:"8080"`
 	Timeout time.Duration `default:"10s"`
+	// The bearer token of the admin API, which is disabled if not set
+	AdminToken string
 }
 
 type Controller interface {
 	GetRate(w http.ResponseWriter, r *http.Request)
+	GetRateHistory(w http.ResponseWriter, r *http.Request)
 	SubscribeEmail(w http.ResponseWriter, r *http.Request)
+	ConfirmSubscription(w http.ResponseWriter, r *http.Request)
+	UnsubscribeEmail(w http.ResponseWriter, r *http.Request)
 	SendEmails(w http.ResponseWriter, r *http.Request)
+	Job(w http.ResponseWriter, r *http.Request)
+	Alerts(w http.ResponseWriter, r *http.Request)
+	TelegramLink(w http.ResponseWriter, r *http.Request)
+	SubscriberPreferences(w http.ResponseWriter, r *http.Request)
+	Webhooks(w http.ResponseWriter, r *http.Request)
+	WebhookDeliveries(w http.ResponseWriter, r *http.Request)
+	AdminWebhooks(w http.ResponseWriter, r *http.Request)
+	AdminWebhookDeliveries(w http.ResponseWriter, r *http.Request)
+	AdminOutbox(w http.ResponseWriter, r *http.Request)
+	AdminOutboxRetry(w http.ResponseWriter, r *http.Request)
+	AdminSubscribers(w http.ResponseWriter, r *http.Request)
+	AdminSubscriber(w http.ResponseWriter, r *http.Request)
+	AdminSubscribersImport(w http.ResponseWriter, r *http.Request)
+	AdminSubscribersExport(w http.ResponseWriter, r *http.Request)
 }
 
 type httpRouter struct {
 	controller Controller
+	adminToken string
 }
 
-func NewHTTPRouter(controller Controller) *httpRouter {
-	return &httpRouter{controller: controller}
+func NewHTTPRouter(controller Controller, adminToken string) *httpRouter {
+	return &httpRouter{controller: controller, adminToken: adminToken}
 }
 
 func (router *httpRouter) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/rate", router.controller.GetRate)
+	mux.HandleFunc("/api/rate/history", router.controller.GetRateHistory)
 	mux.HandleFunc("/api/subscribe", router.controller.SubscribeEmail)
+	mux.HandleFunc("/api/subscribe/confirm", router.controller.ConfirmSubscription)
+	mux.HandleFunc("/api/unsubscribe", router.controller.UnsubscribeEmail)
 	mux.HandleFunc("/api/sendEmails", router.controller.SendEmails)
+	mux.HandleFunc("/api/jobs/", router.controller.Job)
+	mux.HandleFunc("/api/alerts", router.controller.Alerts)
+	mux.HandleFunc("/api/telegram/link", router.controller.TelegramLink)
+	mux.HandleFunc("/api/subscribers/", router.controller.SubscriberPreferences)
+	mux.HandleFunc("/api/webhooks", router.controller.Webhooks)
+	mux.HandleFunc("/api/webhooks/deliveries", router.controller.WebhookDeliveries)
+	mux.HandleFunc("/api/admin/webhooks", router.admin(router.controller.AdminWebhooks))
+	mux.HandleFunc(
+		"/api/admin/webhooks/deliveries",
+		router.admin(router.controller.AdminWebhookDeliveries),
+	)
+	mux.HandleFunc("/api/admin/outbox", router.admin(router.controller.AdminOutbox))
+	mux.HandleFunc("/api/admin/outbox/retry", router.admin(router.controller.AdminOutboxRetry))
+	mux.HandleFunc("/api/admin/subscribers", router.admin(router.controller.AdminSubscribers))
+	mux.HandleFunc("/api/admin/subscribers/", router.admin(router.controller.AdminSubscriber))
+	mux.HandleFunc(
+		"/api/admin/subscribers/import",
+		router.admin(router.controller.AdminSubscribersImport),
+	)
+	mux.HandleFunc(
+		"/api/admin/subscribers/export",
+		router.admin(router.controller.AdminSubscribersExport),
+	)
+}
+
+// admin lets through the requests bearing the admin token. Without
+// the token configured the admin API is not found.
+func (router *httpRouter) admin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if router.adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), _bearerPrefix)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(router.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		handler(w, r)
+	}
 }
@@ -15,18 +15,86 @@ func (m *stubController) GetRate(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("getRate"))
 }
 
+func (m *stubController) GetRateHistory(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("getRateHistory"))
+}
+
 func (m *stubController) SubscribeEmail(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("subscribeEmail"))
 }
 
+func (m *stubController) ConfirmSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("confirmSubscription"))
+}
+
+func (m *stubController) UnsubscribeEmail(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("unsubscribeEmail"))
+}
+
 func (m *stubController) SendEmails(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("sendEmails"))
 }
 
+func (m *stubController) Job(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("job"))
+}
+
+func (m *stubController) Alerts(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("alerts"))
+}
+
+func (m *stubController) TelegramLink(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("telegramLink"))
+}
+
+func (m *stubController) SubscriberPreferences(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("subscriberPreferences"))
+}
+
+func (m *stubController) Webhooks(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("webhooks"))
+}
+
+func (m *stubController) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("webhookDeliveries"))
+}
+
+func (m *stubController) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("adminWebhooks"))
+}
+
+func (m *stubController) AdminWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("adminWebhookDeliveries"))
+}
+
+func (m *stubController) AdminOutbox(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("adminOutbox"))
+}
+
+func (m *stubController) AdminOutboxRetry(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("adminOutboxRetry"))
+}
+
+func (m *stubController) AdminSubscribers(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("adminSubscribers"))
+}
+
+func (m *stubController) AdminSubscriber(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("adminSubscriber"))
+}
+
+func (m *stubController) AdminSubscribersImport(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("adminSubscribersImport"))
+}
+
+func (m *stubController) AdminSubscribersExport(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("adminSubscribersExport"))
+}
+
 func TestHttpRouter(t *testing.T) {
 	mux := http.NewServeMux()
 	controller := &stubController{}
-	router := NewHTTPRouter(controller)
+	router := NewHTTPRouter(controller, "admin")
 	router.RegisterRoutes(mux)
 
 	server := httptest.NewServer(mux)
@@ -38,13 +106,54 @@ func TestHttpRouter(t *testing.T) {
 		want  string
 	}{
 		{name: "Test rate", route: "/api/rate", want: "getRate"},
+		{name: "Test rate history", route: "/api/rate/history", want: "getRateHistory"},
 		{name: "Test subscribe", route: "/api/subscribe", want: "subscribeEmail"},
+		{name: "Test confirm", route: "/api/subscribe/confirm", want: "confirmSubscription"},
+		{name: "Test unsubscribe", route: "/api/unsubscribe", want: "unsubscribeEmail"},
 		{name: "Test sendEmails", route: "/api/sendEmails", want: "sendEmails"},
+		{name: "Test job", route: "/api/jobs/job1", want: "job"},
+		{name: "Test alerts", route: "/api/alerts", want: "alerts"},
+		{name: "Test telegram link", route: "/api/telegram/link", want: "telegramLink"},
+		{
+			name:  "Test subscriber preferences",
+			route: "/api/subscribers/test@example.com/preferences",
+			want:  "subscriberPreferences",
+		},
+		{name: "Test webhooks", route: "/api/webhooks", want: "webhooks"},
+		{name: "Test webhook deliveries", route: "/api/webhooks/deliveries", want: "webhookDeliveries"},
+		{name: "Test admin webhooks", route: "/api/admin/webhooks", want: "adminWebhooks"},
+		{
+			name:  "Test admin webhook deliveries",
+			route: "/api/admin/webhooks/deliveries",
+			want:  "adminWebhookDeliveries",
+		},
+		{name: "Test admin outbox", route: "/api/admin/outbox", want: "adminOutbox"},
+		{name: "Test admin outbox retry", route: "/api/admin/outbox/retry", want: "adminOutboxRetry"},
+		{name: "Test admin subscribers", route: "/api/admin/subscribers", want: "adminSubscribers"},
+		{
+			name:  "Test admin subscriber",
+			route: "/api/admin/subscribers/test@example.com",
+			want:  "adminSubscriber",
+		},
+		{
+			name:  "Test admin subscribers import",
+			route: "/api/admin/subscribers/import",
+			want:  "adminSubscribersImport",
+		},
+		{
+			name:  "Test admin subscribers export",
+			route: "/api/admin/subscribers/export",
+			want:  "adminSubscribersExport",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			res, err := http.Get(server.URL + tt.route)
+			req, err := http.NewRequest(http.MethodGet, server.URL+tt.route, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer admin")
+
+			res, err := http.DefaultClient.Do(req)
 			require.NoError(t, err)
 			body, err := io.ReadAll(res.Body)
 			require.NoError(t, err)
@@ -54,3 +163,58 @@ func TestHttpRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestAdminRoutes(t *testing.T) {
+	tests := []struct {
+		name           string
+		adminToken     string
+		authorization  string
+		expectedStatus int
+	}{
+		{
+			name:           "Valid token",
+			adminToken:     "admin",
+			authorization:  "Bearer admin",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Invalid token",
+			adminToken:     "admin",
+			authorization:  "Bearer other",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Missing token",
+			adminToken:     "admin",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Not a bearer token",
+			adminToken:     "admin",
+			authorization:  "admin",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Admin API disabled",
+			authorization:  "Bearer ",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			NewHTTPRouter(&stubController{}, tt.adminToken).RegisterRoutes(mux)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/outbox", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+
+			mux.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package subscriberfile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gses2-app/internal/core/service/subscription"
+)
+
+const (
+	_emailColumn    = "email"
+	_statusColumn   = "status"
+	_languageColumn = "language"
+	_pairsColumn    = "pairs"
+	_cadenceColumn  = "cadence"
+	_channelColumn  = "channel"
+)
+
+var _columns = []string{
+	_emailColumn,
+	_statusColumn,
+	_languageColumn,
+	_pairsColumn,
+	_cadenceColumn,
+	_channelColumn,
+}
+
+var ErrMissingEmailColumn = errors.New("csv header has no email column")
+
+// csvReader reads the columns named by the header, the unknown ones are
+// ignored. A file without the header has the emails in the first column.
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	// The first row of a file without the header
+	pending []string
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	return &csvReader{reader: reader}
+}
+
+func (r *csvReader) Read() (subscription.SubscriberRecord, error) {
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return subscription.SubscriberRecord{}, err
+		}
+	}
+
+	row := r.pending
+	r.pending = nil
+	if row == nil {
+		var err error
+		row, err = r.reader.Read()
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return subscription.SubscriberRecord{}, errors.Join(subscription.ErrMalformedRecord, err)
+		}
+
+		if err != nil {
+			return subscription.SubscriberRecord{}, err
+		}
+	}
+
+	field := func(column string) string {
+		i, ok := r.columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	return subscription.SubscriberRecord{
+		Email:    field(_emailColumn),
+		Status:   field(_statusColumn),
+		Language: field(_languageColumn),
+		Pairs:    splitPairs(field(_pairsColumn)),
+		Cadence:  field(_cadenceColumn),
+		Channel:  field(_channelColumn),
+	}, nil
+}
+
+func (r *csvReader) readHeader() error {
+	header, err := r.reader.Read()
+	if err != nil {
+		return err
+	}
+
+	r.columns = make(map[string]int)
+
+	if len(header) > 0 && strings.Contains(header[0], "@") {
+		r.columns[_emailColumn] = 0
+		r.pending = header
+		return nil
+	}
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := r.columns[name]; !ok {
+			r.columns[name] = i
+		}
+	}
+
+	if _, ok := r.columns[_emailColumn]; !ok {
+		return fmt.Errorf("%w: %q", ErrMissingEmailColumn, strings.Join(header, ","))
+	}
+
+	return nil
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(record subscription.SubscriberRecord) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	return w.writer.Write([]string{
+		record.Email,
+		record.Status,
+		record.Language,
+		strings.Join(record.Pairs, _pairsSeparator),
+		record.Cadence,
+		record.Channel,
+	})
+}
+
+// Close writes the header of an empty export and flushes the rows
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+
+	return w.writer.Write(_columns)
+}
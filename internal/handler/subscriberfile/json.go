@@ -0,0 +1,179 @@
+package subscriberfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gses2-app/internal/core/service/subscription"
+)
+
+// The longest NDJSON line read
+const _maxLineSize = 1 << 20
+
+var ErrNotJSONArray = errors.New("json file must be an array of subscribers")
+
+type jsonRecord struct {
+	Email    string   `json:"email"`
+	Status   string   `json:"status,omitempty"`
+	Language string   `json:"language,omitempty"`
+	Pairs    []string `json:"pairs,omitempty"`
+	Cadence  string   `json:"cadence,omitempty"`
+	Channel  string   `json:"channel,omitempty"`
+}
+
+func (r jsonRecord) toRecord() subscription.SubscriberRecord {
+	return subscription.SubscriberRecord{
+		Email:    r.Email,
+		Status:   r.Status,
+		Language: r.Language,
+		Pairs:    r.Pairs,
+		Cadence:  r.Cadence,
+		Channel:  r.Channel,
+	}
+}
+
+func newJSONRecord(record subscription.SubscriberRecord) jsonRecord {
+	return jsonRecord{
+		Email:    record.Email,
+		Status:   record.Status,
+		Language: record.Language,
+		Pairs:    record.Pairs,
+		Cadence:  record.Cadence,
+		Channel:  record.Channel,
+	}
+}
+
+// jsonReader streams the elements of an array of subscribers,
+// so the whole file isn't held in memory
+type jsonReader struct {
+	decoder *json.Decoder
+	started bool
+}
+
+func newJSONReader(r io.Reader) *jsonReader {
+	return &jsonReader{decoder: json.NewDecoder(r)}
+}
+
+func (r *jsonReader) Read() (subscription.SubscriberRecord, error) {
+	if !r.started {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return subscription.SubscriberRecord{}, err
+		}
+
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return subscription.SubscriberRecord{}, ErrNotJSONArray
+		}
+		r.started = true
+	}
+
+	if !r.decoder.More() {
+		return subscription.SubscriberRecord{}, io.EOF
+	}
+
+	var record jsonRecord
+	err := r.decoder.Decode(&record)
+
+	// The element of a wrong type is skipped, the syntax errors
+	// leave the rest of the file unreadable
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return record.toRecord(), errors.Join(subscription.ErrMalformedRecord, err)
+	}
+
+	if err != nil {
+		return subscription.SubscriberRecord{}, err
+	}
+
+	return record.toRecord(), nil
+}
+
+// jsonWriter writes the records as the elements of an array
+type jsonWriter struct {
+	writer  io.Writer
+	written int
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{writer: w}
+}
+
+func (w *jsonWriter) Write(record subscription.SubscriberRecord) error {
+	element, err := json.Marshal(newJSONRecord(record))
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if w.written == 0 {
+		separator = "[\n"
+	}
+	w.written++
+
+	_, err = fmt.Fprintf(w.writer, "%s%s", separator, element)
+	return err
+}
+
+func (w *jsonWriter) Close() error {
+	if w.written == 0 {
+		_, err := io.WriteString(w.writer, "[]\n")
+		return err
+	}
+
+	_, err := io.WriteString(w.writer, "\n]\n")
+	return err
+}
+
+// ndjsonReader reads a subscriber per line, the blank lines are skipped
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), _maxLineSize)
+
+	return &ndjsonReader{scanner: scanner}
+}
+
+func (r *ndjsonReader) Read() (subscription.SubscriberRecord, error) {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record jsonRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return record.toRecord(), errors.Join(subscription.ErrMalformedRecord, err)
+		}
+
+		return record.toRecord(), nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return subscription.SubscriberRecord{}, err
+	}
+
+	return subscription.SubscriberRecord{}, io.EOF
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{encoder: json.NewEncoder(w)}
+}
+
+func (w *ndjsonWriter) Write(record subscription.SubscriberRecord) error {
+	return w.encoder.Encode(newJSONRecord(record))
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package subscriberfile reads and writes the subscribers of a bulk
+// import or export in CSV, JSON or NDJSON
+package subscriberfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"gses2-app/internal/core/service/subscription"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+)
+
+// The separator of the pairs in a CSV field, the commas and the spaces
+// are read too
+const _pairsSeparator = ";"
+
+var ErrUnknownFormat = errors.New("unknown file format")
+
+var _contentTypes = map[Format]string{
+	FormatCSV:    "text/csv",
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// Writer writes the records of an export, Close finishes the file
+type Writer interface {
+	subscription.RecordWriter
+	Close() error
+}
+
+// ParseFormat validates the name of the format, e.g. ndjson
+func ParseFormat(name string) (Format, error) {
+	format := Format(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := _contentTypes[format]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+
+	return format, nil
+}
+
+// FormatByContentType returns the format of the media type,
+// e.g. text/csv; charset=utf-8
+func FormatByContentType(contentType string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, contentType)
+	}
+
+	for format, formatType := range _contentTypes {
+		if mediaType == formatType {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, contentType)
+}
+
+// FormatByFileName returns the format of the file's extension
+func FormatByFileName(name string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(name), "."))
+}
+
+func (f Format) ContentType() string {
+	return _contentTypes[f]
+}
+
+func NewReader(format Format, r io.Reader) (subscription.RecordReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r), nil
+	case FormatJSON:
+		return newJSONReader(r), nil
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatJSON:
+		return newJSONWriter(w), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// splitPairs returns nil if there are no pairs, like a JSON record
+func splitPairs(value string) []string {
+	pairs := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ';' || r == ',' || unicode.IsSpace(r)
+	})
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	return pairs
+}
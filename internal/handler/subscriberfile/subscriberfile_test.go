@@ -0,0 +1,216 @@
+package subscriberfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/service/subscription"
+)
+
+// readAll reads the records up to the end, the malformed ones are nil
+func readAll(t *testing.T, reader subscription.RecordReader) []*subscription.SubscriberRecord {
+	t.Helper()
+
+	var records []*subscription.SubscriberRecord
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+
+		if errors.Is(err, subscription.ErrMalformedRecord) {
+			records = append(records, nil)
+			continue
+		}
+
+		require.NoError(t, err)
+		records = append(records, &record)
+	}
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	full := &subscription.SubscriberRecord{
+		Email:    "first@example.com",
+		Status:   "active",
+		Language: "uk",
+		Pairs:    []string{"BTC/UAH", "ETH/USD"},
+		Cadence:  "hourly",
+		Channel:  "email",
+	}
+	plain := &subscription.SubscriberRecord{Email: "second@example.com"}
+
+	tests := []struct {
+		name     string
+		format   Format
+		content  string
+		expected []*subscription.SubscriberRecord
+	}{
+		{
+			name:   "CSV",
+			format: FormatCSV,
+			content: "name,Email,status,language,pairs,cadence,channel\n" +
+				"First,first@example.com,active,uk,\"BTC/UAH, ETH/USD\",hourly,email\n" +
+				"Second,second@example.com\n",
+			expected: []*subscription.SubscriberRecord{full, plain},
+		},
+		{
+			name:     "CSV without header",
+			format:   FormatCSV,
+			content:  "second@example.com\nsecond@example.com,ignored\n",
+			expected: []*subscription.SubscriberRecord{plain, plain},
+		},
+		{
+			name:   "JSON",
+			format: FormatJSON,
+			content: `[
+				{"email":"first@example.com","status":"active","language":"uk",
+				 "pairs":["BTC/UAH","ETH/USD"],"cadence":"hourly","channel":"email"},
+				{"email":5},
+				{"email":"second@example.com","name":"Second"}
+			]`,
+			expected: []*subscription.SubscriberRecord{full, nil, plain},
+		},
+		{
+			name:   "NDJSON",
+			format: FormatNDJSON,
+			content: `{"email":"first@example.com","status":"active","language":"uk",` +
+				`"pairs":["BTC/UAH","ETH/USD"],"cadence":"hourly","channel":"email"}` + "\n" +
+				"\n" +
+				"{broken\n" +
+				`{"email":"second@example.com"}`,
+			expected: []*subscription.SubscriberRecord{full, nil, plain},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reader, err := NewReader(tt.format, strings.NewReader(tt.content))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, readAll(t, reader))
+		})
+	}
+}
+
+func TestReaderInvalidFile(t *testing.T) {
+	t.Parallel()
+
+	reader, err := NewReader(FormatCSV, strings.NewReader("name,status\nFirst,active\n"))
+	require.NoError(t, err)
+	_, err = reader.Read()
+	require.ErrorIs(t, err, ErrMissingEmailColumn)
+
+	reader, err = NewReader(FormatJSON, strings.NewReader(`{"email":"first@example.com"}`))
+	require.NoError(t, err)
+	_, err = reader.Read()
+	require.ErrorIs(t, err, ErrNotJSONArray)
+
+	_, err = NewReader(Format("xml"), strings.NewReader(""))
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	records := []subscription.SubscriberRecord{
+		{
+			Email:    "first@example.com",
+			Status:   "active",
+			Language: "uk",
+			Pairs:    []string{"BTC/UAH", "ETH/USD"},
+			Cadence:  "hourly",
+			Channel:  "email",
+		},
+		{Email: "second@example.com", Status: "pending", Pairs: []string{}},
+	}
+
+	tests := []struct {
+		format   Format
+		expected string
+		empty    string
+	}{
+		{
+			format: FormatCSV,
+			expected: "email,status,language,pairs,cadence,channel\n" +
+				"first@example.com,active,uk,BTC/UAH;ETH/USD,hourly,email\n" +
+				"second@example.com,pending,,,,\n",
+			empty: "email,status,language,pairs,cadence,channel\n",
+		},
+		{
+			format: FormatJSON,
+			expected: "[\n" +
+				`{"email":"first@example.com","status":"active","language":"uk",` +
+				`"pairs":["BTC/UAH","ETH/USD"],"cadence":"hourly","channel":"email"},` + "\n" +
+				`{"email":"second@example.com","status":"pending"}` + "\n" +
+				"]\n",
+			empty: "[]\n",
+		},
+		{
+			format: FormatNDJSON,
+			expected: `{"email":"first@example.com","status":"active","language":"uk",` +
+				`"pairs":["BTC/UAH","ETH/USD"],"cadence":"hourly","channel":"email"}` + "\n" +
+				`{"email":"second@example.com","status":"pending"}` + "\n",
+			empty: "",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.format), func(t *testing.T) {
+			t.Parallel()
+
+			var buffer bytes.Buffer
+			writer, err := NewWriter(tt.format, &buffer)
+			require.NoError(t, err)
+
+			for _, record := range records {
+				require.NoError(t, writer.Write(record))
+			}
+			require.NoError(t, writer.Close())
+			require.Equal(t, tt.expected, buffer.String())
+
+			// The export reads back
+			reader, err := NewReader(tt.format, &buffer)
+			require.NoError(t, err)
+			require.Len(t, readAll(t, reader), len(records))
+
+			buffer.Reset()
+			writer, err = NewWriter(tt.format, &buffer)
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+			require.Equal(t, tt.empty, buffer.String())
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	format, err := ParseFormat(" NDJSON ")
+	require.NoError(t, err)
+	require.Equal(t, FormatNDJSON, format)
+
+	format, err = FormatByContentType("text/csv; charset=utf-8")
+	require.NoError(t, err)
+	require.Equal(t, FormatCSV, format)
+
+	format, err = FormatByFileName("./export/subscribers.json")
+	require.NoError(t, err)
+	require.Equal(t, FormatJSON, format)
+	require.Equal(t, "application/json", format.ContentType())
+
+	_, err = FormatByContentType("text/plain")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = FormatByFileName("subscribers")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
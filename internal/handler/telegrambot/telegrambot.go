@@ -0,0 +1,140 @@
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/subscription"
+)
+
+const (
+	_startCommand = "/start"
+	_stopCommand  = "/stop"
+
+	// The pause after a failed poll, so a Bot API outage isn't hammered
+	_retryDelay = 5 * time.Second
+
+	_linkedReply    = "The exchange rate will be sent to this chat. Send /stop to stop it."
+	_invalidReply   = "The link is invalid. Get a new one for your confirmed subscription."
+	_unlinkedReply  = "The exchange rate won't be sent to this chat anymore."
+	_notLinkedReply = "This chat isn't linked to a subscription."
+	_failureReply   = "Something went wrong, please try again later."
+	_helpReply      = "Follow the Telegram link of your confirmed subscription to get the exchange rate here. Send /stop to stop it."
+)
+
+type SubscriptionService interface {
+	LinkTelegram(code string, chatID int64) error
+	UnlinkTelegram(chatID int64) error
+}
+
+type BotAPI interface {
+	Updates(ctx context.Context, offset int64) ([]port.ChatMessage, error)
+	SendMessage(ctx context.Context, chatID int64, text string) error
+}
+
+// Bot answers the commands users send to the Telegram bot
+type Bot struct {
+	logger              port.Logger
+	api                 BotAPI
+	subscriptionService SubscriptionService
+	retryDelay          time.Duration
+}
+
+func NewBot(
+	logger port.Logger,
+	api BotAPI,
+	subscriptionService SubscriptionService,
+) *Bot {
+	return &Bot{
+		logger:              logger,
+		api:                 api,
+		subscriptionService: subscriptionService,
+		retryDelay:          _retryDelay,
+	}
+}
+
+// Run long polls the messages and handles them until the context
+// is canceled
+func (b *Bot) Run(ctx context.Context) error {
+	var offset int64
+	for {
+		messages, err := b.api.Updates(ctx, offset)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			b.logger.Errorf("Error, telegram updates: %v", err)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(b.retryDelay):
+			}
+
+			continue
+		}
+
+		for _, message := range messages {
+			offset = message.UpdateID + 1
+
+			if message.ChatID == 0 {
+				continue
+			}
+
+			b.Handle(ctx, message)
+		}
+	}
+}
+
+// Handle replies to the message, linking or unlinking the chat
+// on the /start and /stop commands
+func (b *Bot) Handle(ctx context.Context, message port.ChatMessage) {
+	reply := b.reply(message)
+
+	err := b.api.SendMessage(ctx, message.ChatID, reply)
+	if err != nil {
+		b.logger.Errorf("Error, telegram reply to %d: %v", message.ChatID, err)
+	}
+}
+
+func (b *Bot) reply(message port.ChatMessage) string {
+	command, argument, _ := strings.Cut(strings.TrimSpace(message.Text), " ")
+	// In group chats the commands may be addressed as /start@bot
+	command, _, _ = strings.Cut(command, "@")
+
+	switch command {
+	case _startCommand:
+		code := strings.TrimSpace(argument)
+		if code == "" {
+			return _helpReply
+		}
+
+		err := b.subscriptionService.LinkTelegram(code, message.ChatID)
+		switch {
+		case err == nil:
+			return _linkedReply
+		case errors.Is(err, subscription.ErrInvalidLinkCode):
+			return _invalidReply
+		default:
+			b.logger.Errorf("Error, telegram link of %d: %v", message.ChatID, err)
+			return _failureReply
+		}
+	case _stopCommand:
+		err := b.subscriptionService.UnlinkTelegram(message.ChatID)
+		switch {
+		case err == nil:
+			return _unlinkedReply
+		case errors.Is(err, subscription.ErrTelegramNotLinked):
+			return _notLinkedReply
+		default:
+			b.logger.Errorf("Error, telegram unlink of %d: %v", message.ChatID, err)
+			return _failureReply
+		}
+	default:
+		return _helpReply
+	}
+}
@@ -0,0 +1,200 @@
+package telegrambot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/subscription"
+)
+
+var errRepository = errors.New("repository error")
+
+type StubLogger struct{}
+
+func (s *StubLogger) Info(...interface{})           {}
+func (s *StubLogger) Infof(string, ...interface{})  {}
+func (s *StubLogger) Debug(...interface{})          {}
+func (s *StubLogger) Debugf(string, ...interface{}) {}
+func (s *StubLogger) Error(...interface{})          {}
+func (s *StubLogger) Errorf(string, ...interface{}) {}
+
+type StubSubscriptionService struct {
+	LinkErr   error
+	UnlinkErr error
+	Code      string
+	ChatID    int64
+}
+
+func (s *StubSubscriptionService) LinkTelegram(code string, chatID int64) error {
+	s.Code = code
+	s.ChatID = chatID
+	return s.LinkErr
+}
+
+func (s *StubSubscriptionService) UnlinkTelegram(chatID int64) error {
+	s.ChatID = chatID
+	return s.UnlinkErr
+}
+
+type sentMessage struct {
+	ChatID int64
+	Text   string
+}
+
+// StubBotAPI returns the batches of messages one per poll and cancels
+// the bot when they run out
+type StubBotAPI struct {
+	Batches [][]port.ChatMessage
+	Err     error
+	Cancel  context.CancelFunc
+	Offsets []int64
+	Sent    []sentMessage
+}
+
+func (s *StubBotAPI) Updates(ctx context.Context, offset int64) ([]port.ChatMessage, error) {
+	s.Offsets = append(s.Offsets, offset)
+
+	if s.Err != nil {
+		err := s.Err
+		s.Err = nil
+		return nil, err
+	}
+
+	if len(s.Batches) == 0 {
+		s.Cancel()
+		return nil, ctx.Err()
+	}
+
+	batch := s.Batches[0]
+	s.Batches = s.Batches[1:]
+	return batch, nil
+}
+
+func (s *StubBotAPI) SendMessage(ctx context.Context, chatID int64, text string) error {
+	s.Sent = append(s.Sent, sentMessage{ChatID: chatID, Text: text})
+	return nil
+}
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		text           string
+		service        *StubSubscriptionService
+		expectedReply  string
+		expectedCode   string
+		expectedChatID int64
+	}{
+		{
+			name:           "Link the chat",
+			text:           "/start code",
+			service:        &StubSubscriptionService{},
+			expectedReply:  _linkedReply,
+			expectedCode:   "code",
+			expectedChatID: 42,
+		},
+		{
+			name:           "Command addressed to the bot",
+			text:           "/start@rate_bot code",
+			service:        &StubSubscriptionService{},
+			expectedReply:  _linkedReply,
+			expectedCode:   "code",
+			expectedChatID: 42,
+		},
+		{
+			name:           "Invalid code",
+			text:           "/start code",
+			service:        &StubSubscriptionService{LinkErr: subscription.ErrInvalidLinkCode},
+			expectedReply:  _invalidReply,
+			expectedCode:   "code",
+			expectedChatID: 42,
+		},
+		{
+			name:           "Link failure",
+			text:           "/start code",
+			service:        &StubSubscriptionService{LinkErr: errRepository},
+			expectedReply:  _failureReply,
+			expectedCode:   "code",
+			expectedChatID: 42,
+		},
+		{
+			name:          "Start without a code",
+			text:          "/start",
+			service:       &StubSubscriptionService{},
+			expectedReply: _helpReply,
+		},
+		{
+			name:           "Unlink the chat",
+			text:           "/stop",
+			service:        &StubSubscriptionService{},
+			expectedReply:  _unlinkedReply,
+			expectedChatID: 42,
+		},
+		{
+			name:           "Unlink a chat which isn't linked",
+			text:           "/stop",
+			service:        &StubSubscriptionService{UnlinkErr: subscription.ErrTelegramNotLinked},
+			expectedReply:  _notLinkedReply,
+			expectedChatID: 42,
+		},
+		{
+			name:          "Other text",
+			text:          "hello",
+			service:       &StubSubscriptionService{},
+			expectedReply: _helpReply,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := &StubBotAPI{}
+			bot := NewBot(&StubLogger{}, api, tt.service)
+
+			bot.Handle(context.Background(), port.ChatMessage{ChatID: 42, Text: tt.text})
+
+			require.Equal(t, []sentMessage{{ChatID: 42, Text: tt.expectedReply}}, api.Sent)
+			require.Equal(t, tt.expectedCode, tt.service.Code)
+			require.Equal(t, tt.expectedChatID, tt.service.ChatID)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	api := &StubBotAPI{
+		Err: errRepository,
+		Batches: [][]port.ChatMessage{
+			{
+				{UpdateID: 10, ChatID: 42, Text: "/stop"},
+				// Not a message, only moves the offset
+				{UpdateID: 11},
+			},
+			{{UpdateID: 12, ChatID: 7, Text: "hello"}},
+		},
+		Cancel: cancel,
+	}
+	bot := NewBot(&StubLogger{}, api, &StubSubscriptionService{})
+	bot.retryDelay = time.Millisecond
+
+	err := bot.Run(ctx)
+
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, []int64{0, 0, 12, 13}, api.Offsets)
+	require.Equal(t, []sentMessage{
+		{ChatID: 42, Text: _unlinkedReply},
+		{ChatID: 7, Text: _helpReply},
+	}, api.Sent)
+}
@@ -0,0 +1,74 @@
+package blocklist
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+const _commentPrefix = "#"
+
+// DomainBlocklist is a set of email domains, e.g. disposable email
+// services. A blocked domain blocks all its subdomains as well.
+type DomainBlocklist struct {
+	domains map[string]struct{}
+}
+
+func NewDomainBlocklist(domains ...string) *DomainBlocklist {
+	blocklist := &DomainBlocklist{domains: make(map[string]struct{}, len(domains))}
+	for _, domain := range domains {
+		blocklist.add(domain)
+	}
+
+	return blocklist
+}
+
+// LoadDomainBlocklist reads the domains from the file, one per line.
+// Blank lines and lines starting with # are skipped. An empty path
+// gives an empty blocklist.
+func LoadDomainBlocklist(path string) (*DomainBlocklist, error) {
+	blocklist := NewDomainBlocklist()
+	if path == "" {
+		return blocklist, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, _commentPrefix) {
+			continue
+		}
+
+		blocklist.add(line)
+	}
+
+	return blocklist, scanner.Err()
+}
+
+func (b *DomainBlocklist) Contains(domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	for domain != "" {
+		if _, ok := b.domains[domain]; ok {
+			return true
+		}
+
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			return false
+		}
+		domain = parent
+	}
+
+	return false
+}
+
+func (b *DomainBlocklist) add(domain string) {
+	b.domains[strings.ToLower(strings.TrimSpace(domain))] = struct{}{}
+}
@@ -0,0 +1,62 @@
+package blocklist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainBlocklistContains(t *testing.T) {
+	blocklist := NewDomainBlocklist("mailinator.com", "Trashmail.io")
+
+	tests := []struct {
+		name     string
+		domain   string
+		expected bool
+	}{
+		{name: "Blocked domain", domain: "mailinator.com", expected: true},
+		{name: "Blocked domain in upper case", domain: "TRASHMAIL.IO", expected: true},
+		{name: "Subdomain of blocked domain", domain: "eu.mailinator.com", expected: true},
+		{name: "Allowed domain", domain: "example.com", expected: false},
+		{name: "Domain with blocked suffix", domain: "notmailinator.com", expected: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, blocklist.Contains(tt.domain))
+		})
+	}
+}
+
+func TestLoadDomainBlocklist(t *testing.T) {
+	t.Run("Load from file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "domains.txt")
+		content := "# disposable domains\nmailinator.com\n\n  trashmail.io  \n"
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		blocklist, err := LoadDomainBlocklist(path)
+
+		require.NoError(t, err)
+		require.True(t, blocklist.Contains("mailinator.com"))
+		require.True(t, blocklist.Contains("trashmail.io"))
+		require.False(t, blocklist.Contains("# disposable domains"))
+	})
+
+	t.Run("Empty path", func(t *testing.T) {
+		blocklist, err := LoadDomainBlocklist("")
+
+		require.NoError(t, err)
+		require.False(t, blocklist.Contains("mailinator.com"))
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := LoadDomainBlocklist(filepath.Join(t.TempDir(), "missing"))
+
+		require.Error(t, err)
+	})
+}
@@ -1,6 +1,13 @@
 package config
 
 import (
+	"gses2-app/internal/core/service/alert"
+	"gses2-app/internal/core/service/job"
+	"gses2-app/internal/core/service/outbox"
+	"gses2-app/internal/core/service/rate"
+	"gses2-app/internal/core/service/scheduler"
+	"gses2-app/internal/core/service/subscription"
+	"gses2-app/internal/core/service/webhook"
 	"gses2-app/internal/handler/router"
 	"gses2-app/internal/repository/logger/rabbit"
 	"gses2-app/internal/repository/rate/rest/binance"
@@ -8,16 +15,30 @@ import (
 	"gses2-app/internal/repository/rate/rest/kuna"
 	"gses2-app/internal/repository/sender/email/send"
 	"gses2-app/internal/repository/sender/smtp"
+	"gses2-app/internal/repository/sender/telegram"
+	webhooksender "gses2-app/internal/repository/sender/webhook"
+	"gses2-app/internal/repository/signature"
 	"gses2-app/internal/repository/storage"
 )
 
 type Config struct {
-	SMTP         smtp.SMTPConfig
-	Email        send.EmailConfig
-	Storage      storage.StorageConfig
-	HTTP         router.HTTPConfig
-	KunaAPI      kuna.KunaAPIConfig
-	BinanceAPI   binance.BinanceAPIConfig
-	CoingeckoAPI coingecko.CoingeckoAPIConfig
-	RabbitMQ     rabbit.RabbitMQConfig
+	SMTP          smtp.SMTPConfig
+	Email         send.EmailConfig
+	Storage       storage.StorageConfig
+	HTTP          router.HTTPConfig
+	KunaAPI       kuna.KunaAPIConfig
+	BinanceAPI    binance.BinanceAPIConfig
+	CoingeckoAPI  coingecko.CoingeckoAPIConfig
+	RabbitMQ      rabbit.RabbitMQConfig
+	Scheduler     scheduler.SchedulerConfig
+	Signature     signature.SignatureConfig
+	Subscription  subscription.SubscriptionConfig
+	Rate          rate.RateConfig
+	RateCache     rate.CacheConfig
+	Alert         alert.AlertConfig
+	Telegram      telegram.TelegramConfig
+	Webhook       webhook.WebhookConfig
+	WebhookSender webhooksender.WebhookSenderConfig
+	Outbox        outbox.OutboxConfig
+	Job           job.JobConfig
 }
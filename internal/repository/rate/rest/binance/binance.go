@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"gses2-app/internal/core/port"
 	"gses2-app/internal/repository/rate/rest"
@@ -23,14 +24,20 @@ const (
 	_firstItemIndex   = 0
 	_minResponseItems = 5
 	_rateIndex        = 4
+
+	_symbolParam   = "symbol"
+	_intervalParam = "interval"
+	_limitParam    = "limit"
+	_interval      = "1s"
+	_limit         = "1"
 )
 
 type HTTPClient interface {
-	Get(url string) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 type BinanceAPIConfig struct {
-	URL string `default:"https://api.binance.com/api/v3/klines?symbol=BTCUAH&interval=1s&limit=1"`
+	URL string `default:"https://api.binance.com/api/v3/klines"`
 }
 
 type BinanceProvider struct {
@@ -51,15 +58,22 @@ func NewProvider(
 	)
 }
 
-func (p *BinanceProvider) URL() string {
-	return p.config.URL
+func (p *BinanceProvider) URL(pair port.CurrencyPair) (string, error) {
+	return rest.BuildURL(p.config.URL, map[string]string{
+		_symbolParam:   strings.ToUpper(pair.Base + pair.Quote),
+		_intervalParam: _interval,
+		_limitParam:    _limit,
+	})
 }
 
 func (p *BinanceProvider) Name() string {
 	return _providerName
 }
 
-func (p *BinanceProvider) ExtractRate(resp *http.Response) (port.Rate, error) {
+func (p *BinanceProvider) ExtractRate(
+	resp *http.Response,
+	pair port.CurrencyPair,
+) (float64, error) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return 0, err
@@ -85,5 +99,5 @@ func (p *BinanceProvider) ExtractRate(resp *http.Response) (port.Rate, error) {
 		return 0, errors.Join(err, ErrUnexpectedExchangeRateFormat)
 	}
 
-	return port.Rate(rateValue), nil
+	return rateValue, nil
 }
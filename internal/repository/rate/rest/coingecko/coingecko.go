@@ -5,17 +5,15 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strings"
 
 	"gses2-app/internal/core/port"
 	"gses2-app/internal/repository/rate/rest"
 )
 
-// Represents data type for JSON response
-type Response struct {
-	Bitcoin struct {
-		UAH float64 `json:"uah"`
-	} `json:"bitcoin"`
-}
+// Represents data type for JSON response: the price of every requested
+// coin id in every requested currency, e.g. {"bitcoin":{"uah":1000000}}
+type Response map[string]map[string]float64
 
 var (
 	ErrHTTPRequestFailure       = errors.New("http request failure")
@@ -25,14 +23,31 @@ var (
 
 const (
 	_providerName = "CoingeckoRateProvider"
+
+	_idsParam          = "ids"
+	_vsCurrenciesParam = "vs_currencies"
 )
 
+// Coingecko identifies coins by their API id instead of the ticker symbol
+var _coinIDs = map[string]string{
+	"BTC":  "bitcoin",
+	"ETH":  "ethereum",
+	"USDT": "tether",
+	"BNB":  "binancecoin",
+	"SOL":  "solana",
+	"XRP":  "ripple",
+	"ADA":  "cardano",
+	"DOGE": "dogecoin",
+	"TRX":  "tron",
+	"LTC":  "litecoin",
+}
+
 type CoingeckoAPIConfig struct {
-	URL string `default:"https://api.coingecko.com/api/v3/simple/price?ids=bitcoin&vs_currencies=uah"`
+	URL string `default:"https://api.coingecko.com/api/v3/simple/price"`
 }
 
 type HTTPClient interface {
-	Get(url string) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 type CoingeckoProvider struct {
@@ -53,15 +68,26 @@ func NewProvider(
 	)
 }
 
-func (p *CoingeckoProvider) URL() string {
-	return p.config.URL
+func (p *CoingeckoProvider) URL(pair port.CurrencyPair) (string, error) {
+	coinID, ok := _coinIDs[strings.ToUpper(pair.Base)]
+	if !ok {
+		return "", rest.ErrUnsupportedCurrencyPair
+	}
+
+	return rest.BuildURL(p.config.URL, map[string]string{
+		_idsParam:          coinID,
+		_vsCurrenciesParam: strings.ToLower(pair.Quote),
+	})
 }
 
 func (p *CoingeckoProvider) Name() string {
 	return _providerName
 }
 
-func (p *CoingeckoProvider) ExtractRate(resp *http.Response) (port.Rate, error) {
+func (p *CoingeckoProvider) ExtractRate(
+	resp *http.Response,
+	pair port.CurrencyPair,
+) (float64, error) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return 0, err
@@ -73,5 +99,15 @@ func (p *CoingeckoProvider) ExtractRate(resp *http.Response) (port.Rate, error)
 		return 0, errors.Join(err, ErrUnexpectedResponseFormat)
 	}
 
-	return port.Rate(data.Bitcoin.UAH), nil
+	prices, ok := data[_coinIDs[strings.ToUpper(pair.Base)]]
+	if !ok {
+		return 0, ErrUnexpectedResponseFormat
+	}
+
+	rate, ok := prices[strings.ToLower(pair.Quote)]
+	if !ok {
+		return 0, ErrUnexpectedResponseFormat
+	}
+
+	return rate, nil
 }
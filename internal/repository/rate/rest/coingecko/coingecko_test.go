@@ -2,6 +2,7 @@ package coingecko
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"testing"
@@ -26,7 +27,7 @@ type StubHTTPClient struct {
 	Error    error
 }
 
-func (m *StubHTTPClient) Get(url string) (*http.Response, error) {
+func (m *StubHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	return m.Response, m.Error
 }
 
@@ -49,7 +50,11 @@ func TestBinanceProviderExchangeRate(t *testing.T) {
 					),
 				},
 			},
-			expectedRate: 123456,
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     123456,
+				Providers: []string{_providerName},
+			},
 		},
 		{
 			name: "HTTP request failure",
@@ -64,6 +69,7 @@ func TestBinanceProviderExchangeRate(t *testing.T) {
 			stubHTTPClient: &StubHTTPClient{
 				Response: &http.Response{
 					StatusCode: http.StatusForbidden,
+					Body:       io.NopCloser(bytes.NewBufferString("")),
 				},
 			},
 			expectedError: rest.ErrUnexpectedStatusCode,
@@ -101,10 +107,55 @@ func TestBinanceProviderExchangeRate(t *testing.T) {
 
 			config := CoingeckoAPIConfig{}
 			provider := NewProvider(&StubLogger{}, config, tt.stubHTTPClient)
-			rate, err := provider.ExchangeRate()
+			rate, err := provider.ExchangeRate(
+				context.Background(),
+				port.DefaultCurrencyPair,
+			)
 
 			require.ErrorIs(t, err, tt.expectedError)
 			require.Equal(t, tt.expectedRate, rate, "Expected rate %v, got %v", tt.expectedRate, rate)
 		})
 	}
 }
+
+func TestCoingeckoProviderURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      CoingeckoAPIConfig
+		pair        port.CurrencyPair
+		expectedURL string
+		expectedErr error
+	}{
+		{
+			name:        "BTC to UAH",
+			config:      CoingeckoAPIConfig{URL: "https://api.coingecko.com/api/v3/simple/price"},
+			pair:        port.CurrencyPair{Base: "BTC", Quote: "UAH"},
+			expectedURL: "https://api.coingecko.com/api/v3/simple/price?ids=bitcoin&vs_currencies=uah",
+		},
+		{
+			name:        "ETH to USD",
+			config:      CoingeckoAPIConfig{URL: "https://api.coingecko.com/api/v3/simple/price"},
+			pair:        port.CurrencyPair{Base: "ETH", Quote: "USD"},
+			expectedURL: "https://api.coingecko.com/api/v3/simple/price?ids=ethereum&vs_currencies=usd",
+		},
+		{
+			name:        "Unknown coin",
+			config:      CoingeckoAPIConfig{URL: "https://api.coingecko.com/api/v3/simple/price"},
+			pair:        port.CurrencyPair{Base: "UNKNOWN", Quote: "USD"},
+			expectedErr: rest.ErrUnsupportedCurrencyPair,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &CoingeckoProvider{config: tt.config}
+			url, err := provider.URL(tt.pair)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedURL, url)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strings"
 
 	"gses2-app/internal/core/port"
 	"gses2-app/internal/repository/rate/rest"
@@ -22,14 +23,16 @@ const (
 	_firstItemIndex   = 0
 	_minResponseItems = 9
 	_rateIndex        = 7
+
+	_symbolsParam = "symbols"
 )
 
 type KunaAPIConfig struct {
-	URL string `default:"https://api.kuna.io/v3/tickers?symbols=btcuah"`
+	URL string `default:"https://api.kuna.io/v3/tickers"`
 }
 
 type HTTPClient interface {
-	Get(url string) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 type KunaProvider struct {
@@ -50,15 +53,20 @@ func NewProvider(
 	)
 }
 
-func (p *KunaProvider) URL() string {
-	return p.config.URL
+func (p *KunaProvider) URL(pair port.CurrencyPair) (string, error) {
+	return rest.BuildURL(p.config.URL, map[string]string{
+		_symbolsParam: strings.ToLower(pair.Base + pair.Quote),
+	})
 }
 
 func (p *KunaProvider) Name() string {
 	return _providerName
 }
 
-func (p *KunaProvider) ExtractRate(resp *http.Response) (port.Rate, error) {
+func (p *KunaProvider) ExtractRate(
+	resp *http.Response,
+	pair port.CurrencyPair,
+) (float64, error) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return 0, err
@@ -79,5 +87,5 @@ func (p *KunaProvider) ExtractRate(resp *http.Response) (port.Rate, error) {
 		return 0, ErrUnexpectedExchangeRateFormat
 	}
 
-	return port.Rate(exchangeRate), nil
+	return exchangeRate, nil
 }
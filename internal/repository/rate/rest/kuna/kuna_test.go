@@ -2,6 +2,7 @@ package kuna
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"testing"
@@ -26,7 +27,7 @@ type StubHTTPClient struct {
 	Error    error
 }
 
-func (m *StubHTTPClient) Get(url string) (*http.Response, error) {
+func (m *StubHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	return m.Response, m.Error
 }
 
@@ -49,7 +50,11 @@ func TestKunaProviderExchangeRate(t *testing.T) {
 					),
 				},
 			},
-			expectedRate: 1.24,
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     1.24,
+				Providers: []string{_providerName},
+			},
 		},
 		{
 			name: "HTTP request failure",
@@ -64,6 +69,7 @@ func TestKunaProviderExchangeRate(t *testing.T) {
 			stubHTTPClient: &StubHTTPClient{
 				Response: &http.Response{
 					StatusCode: http.StatusForbidden,
+					Body:       io.NopCloser(bytes.NewBufferString("")),
 				},
 			},
 			expectedError: rest.ErrUnexpectedStatusCode,
@@ -101,7 +107,10 @@ func TestKunaProviderExchangeRate(t *testing.T) {
 
 			config := KunaAPIConfig{}
 			provider := NewProvider(&StubLogger{}, config, tt.stubHTTPClient)
-			rate, err := provider.ExchangeRate()
+			rate, err := provider.ExchangeRate(
+				context.Background(),
+				port.DefaultCurrencyPair,
+			)
 
 			require.ErrorIs(t, err, tt.expectedError)
 			require.Equal(t, tt.expectedRate, rate, "Expected rate %v, got %v", tt.expectedRate, rate)
@@ -109,3 +118,39 @@ func TestKunaProviderExchangeRate(t *testing.T) {
 	}
 
 }
+
+func TestKunaProviderURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      KunaAPIConfig
+		pair        port.CurrencyPair
+		expectedURL string
+		expectedErr error
+	}{
+		{
+			name:        "BTC to UAH",
+			config:      KunaAPIConfig{URL: "https://api.kuna.io/v3/tickers"},
+			pair:        port.CurrencyPair{Base: "BTC", Quote: "UAH"},
+			expectedURL: "https://api.kuna.io/v3/tickers?symbols=btcuah",
+		},
+		{
+			name:        "ETH to USD",
+			config:      KunaAPIConfig{URL: "https://api.kuna.io/v3/tickers"},
+			pair:        port.CurrencyPair{Base: "ETH", Quote: "USD"},
+			expectedURL: "https://api.kuna.io/v3/tickers?symbols=ethusd",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &KunaProvider{config: tt.config}
+			url, err := provider.URL(tt.pair)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedURL, url)
+		})
+	}
+}
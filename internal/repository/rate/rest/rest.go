@@ -1,25 +1,29 @@
 package rest
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"gses2-app/internal/core/port"
 	"net/http"
+	"net/url"
+
+	"gses2-app/internal/core/port"
 )
 
 var (
-	ErrHTTPRequestFailure   = errors.New("http request failure")
-	ErrUnexpectedStatusCode = errors.New("unexpected status code")
+	ErrHTTPRequestFailure      = errors.New("http request failure")
+	ErrUnexpectedStatusCode    = errors.New("unexpected status code")
+	ErrUnsupportedCurrencyPair = errors.New("unsupported currency pair")
 )
 
 type HTTPClient interface {
-	Get(url string) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 type Provider interface {
-	URL() string
+	URL(pair port.CurrencyPair) (string, error)
 	Name() string
-	ExtractRate(resp *http.Response) (port.Rate, error)
+	ExtractRate(resp *http.Response, pair port.CurrencyPair) (float64, error)
 }
 
 type AbstractProvider struct {
@@ -44,22 +48,41 @@ func (ap *AbstractProvider) Name() string {
 	return ap.actualProvider.Name()
 }
 
-func (ap *AbstractProvider) ExchangeRate() (port.Rate, error) {
-	resp, err := ap.requestAPI()
+// ExchangeRate requests the rate, the request is aborted when
+// the context is canceled
+func (ap *AbstractProvider) ExchangeRate(
+	ctx context.Context,
+	pair port.CurrencyPair,
+) (port.Rate, error) {
+	resp, err := ap.requestAPI(ctx, pair)
 	if err != nil {
-		return 0, err
+		return port.Rate{}, err
 	}
 
-	return ap.extractRateFromResponse(resp)
+	return ap.extractRateFromResponse(resp, pair)
 }
 
-func (ap *AbstractProvider) requestAPI() (*http.Response, error) {
-	resp, err := ap.httpClient.Get(ap.actualProvider.URL())
+func (ap *AbstractProvider) requestAPI(
+	ctx context.Context,
+	pair port.CurrencyPair,
+) (*http.Response, error) {
+	requestURL, err := ap.actualProvider.URL(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
 	if err != nil {
-		return nil, ErrHTTPRequestFailure
+		return nil, err
+	}
+
+	resp, err := ap.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrHTTPRequestFailure, ctx.Err())
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatusCode, resp.StatusCode)
 	}
 
@@ -67,7 +90,37 @@ func (ap *AbstractProvider) requestAPI() (*http.Response, error) {
 	return resp, nil
 }
 
-func (ap *AbstractProvider) extractRateFromResponse(resp *http.Response) (port.Rate, error) {
+func (ap *AbstractProvider) extractRateFromResponse(
+	resp *http.Response,
+	pair port.CurrencyPair,
+) (port.Rate, error) {
 	defer resp.Body.Close()
-	return ap.actualProvider.ExtractRate(resp)
+
+	value, err := ap.actualProvider.ExtractRate(resp, pair)
+	if err != nil {
+		return port.Rate{}, err
+	}
+
+	return port.Rate{
+		Pair:      pair,
+		Value:     float32(value),
+		Providers: []string{ap.actualProvider.Name()},
+	}, nil
+}
+
+// BuildURL sets the query parameters on the base URL, keeping any
+// parameters the base URL already has.
+func BuildURL(baseURL string, params map[string]string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
 }
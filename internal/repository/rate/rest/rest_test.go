@@ -2,6 +2,7 @@ package rest
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"testing"
@@ -25,26 +26,30 @@ type StubHTTPClient struct {
 	Error    error
 }
 
-func (m *StubHTTPClient) Get(url string) (*http.Response, error) {
+func (m *StubHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	return m.Response, m.Error
 }
 
 type StubProvider struct {
 	Url          string
+	URLError     error
 	ProviderName string
-	Rate         port.Rate
+	Rate         float64
 	Error        error
 }
 
-func (s *StubProvider) URL() string {
-	return s.Url
+func (s *StubProvider) URL(pair port.CurrencyPair) (string, error) {
+	return s.Url, s.URLError
 }
 
 func (s *StubProvider) Name() string {
 	return s.ProviderName
 }
 
-func (s *StubProvider) ExtractRate(r *http.Response) (port.Rate, error) {
+func (s *StubProvider) ExtractRate(
+	r *http.Response,
+	pair port.CurrencyPair,
+) (float64, error) {
 	return s.Rate, s.Error
 }
 
@@ -69,7 +74,11 @@ func TestExchangeRate(t *testing.T) {
 					Body:       io.NopCloser(bytes.NewBufferString("Success Response")),
 				},
 			},
-			expectedRate: 1.23,
+			expectedRate: port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     1.23,
+				Providers: []string{"Test"},
+			},
 		},
 		{
 			name: "HTTP request failure",
@@ -89,10 +98,19 @@ func TestExchangeRate(t *testing.T) {
 			stubHTTPClient: &StubHTTPClient{
 				Response: &http.Response{
 					StatusCode: http.StatusForbidden,
+					Body:       io.NopCloser(bytes.NewBufferString("")),
 				},
 			},
 			expectedError: ErrUnexpectedStatusCode,
 		},
+		{
+			name: "Unsupported currency pair",
+			stubProvider: &StubProvider{
+				URLError: ErrUnsupportedCurrencyPair,
+			},
+			stubHTTPClient: &StubHTTPClient{},
+			expectedError:  ErrUnsupportedCurrencyPair,
+		},
 	}
 
 	for _, tt := range tests {
@@ -103,10 +121,47 @@ func TestExchangeRate(t *testing.T) {
 				tt.stubProvider,
 				tt.stubHTTPClient,
 			)
-			rate, err := abstractProvider.ExchangeRate()
+			rate, err := abstractProvider.ExchangeRate(
+				context.Background(),
+				port.DefaultCurrencyPair,
+			)
 
 			require.ErrorIs(t, err, tt.expectedError)
 			require.Equal(t, tt.expectedRate, rate, "Expected rate %v, got %v", tt.expectedRate, rate)
 		})
 	}
 }
+
+func TestBuildURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		params      map[string]string
+		expectedURL string
+	}{
+		{
+			name:        "Without query",
+			baseURL:     "https://test.url/api",
+			params:      map[string]string{"symbol": "BTCUAH"},
+			expectedURL: "https://test.url/api?symbol=BTCUAH",
+		},
+		{
+			name:        "With existing query",
+			baseURL:     "https://test.url/api?limit=1",
+			params:      map[string]string{"symbol": "BTCUAH"},
+			expectedURL: "https://test.url/api?limit=1&symbol=BTCUAH",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			url, err := BuildURL(tt.baseURL, tt.params)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedURL, url)
+		})
+	}
+}
@@ -1,59 +1,271 @@
 package email
 
 import (
+	"errors"
 	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
 
 	"gses2-app/internal/core/port"
+	"gses2-app/internal/repository/rate/rest"
 	"gses2-app/internal/repository/sender/email/send"
 	"gses2-app/internal/repository/sender/smtp"
 )
 
+const (
+	_emailQueryParam = "email"
+	_tokenQueryParam = "token"
+	// The SMTP replies from this code on are permanent negative ones
+	_smtpPermanentFailureCode = 500
+	// The rate email shows the change since the rate this long before
+	_changeWindow = 24 * time.Hour
+)
+
 type EmailSenderConfig struct {
 	SMTP  smtp.SMTPConfig
 	Email send.EmailConfig
 }
 
+// Signer issues the token of the subscriber's unsubscribe link
+type Signer interface {
+	Sign(value string) string
+}
+
+// RateHistory finds the earlier rate the rate email compares with
+type RateHistory interface {
+	RateAt(pair port.CurrencyPair, at time.Time) (port.Rate, error)
+}
+
 type Provider struct {
-	config     *EmailSenderConfig
-	connection smtp.SMTPConnectionClient
+	config      *EmailSenderConfig
+	signer      Signer
+	templates   *send.Templates
+	history     RateHistory
+	connections *smtp.Pool
 }
 
+// NewProvider creates the provider, the rate history is optional
+// and the rate email has no change without it
 func NewProvider(
 	config *EmailSenderConfig,
-	dialer smtp.TLSConnectionDialer,
+	dialer smtp.ConnectionDialer,
 	factory smtp.SMTPClientFactory,
+	signer Signer,
+	templates *send.Templates,
+	history RateHistory,
 ) (*Provider, error) {
-	client := smtp.NewSMTPClient(config.SMTP, dialer, factory)
-	clientConnection, err := client.Connect()
+	client, err := smtp.NewSMTPClient(config.SMTP, dialer, factory)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Provider{config: config, connection: clientConnection}, nil
+	return &Provider{
+		config:      config,
+		signer:      signer,
+		templates:   templates,
+		history:     history,
+		connections: smtp.NewPool(config.SMTP, client),
+	}, nil
+}
+
+// Close quits the connections to the SMTP server
+func (p *Provider) Close() error {
+	return p.connections.Close()
 }
 
+// SendExchangeRate sends a separate message to every subscriber, as each
+// of them carries the subscriber's own unsubscribe link and language and
+// no subscriber may see the others' addresses. The subscribers who only
+// want the rate in another channel are skipped. The subscribers
+// the message wasn't sent to are reported by a port.DeliveryError.
 func (p *Provider) SendExchangeRate(
 	rate port.Rate,
 	subscribers []port.User,
 ) error {
+	templateData := p.rateTemplateData(rate)
+
+	sent := 0
+	var failures []port.DeliveryFailure
+	for _, subscriber := range subscribers {
+		if !subscriber.Preferences.WantsChannel(port.ChannelEmail) {
+			continue
+		}
+
+		err := p.sendToSubscriber(templateData, subscriber)
+		if err != nil {
+			failures = append(failures, port.DeliveryFailure{
+				Channel:   port.ChannelEmail,
+				Recipient: subscriber.Email,
+				Err:       err,
+			})
+			continue
+		}
+		sent++
+	}
+
+	return port.NewDeliveryError(sent, failures)
+}
+
+// rateTemplateData fills in the data shared by every subscriber
+func (p *Provider) rateTemplateData(rate port.Rate) send.TemplateData {
+	timestamp := rate.FetchedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	data := send.TemplateData{
+		Rate:      formatRate(rate.Value),
+		Base:      rate.Pair.Base,
+		Quote:     rate.Pair.Quote,
+		Pair:      rate.Pair.String(),
+		Timestamp: timestamp,
+		Provider:  strings.Join(rate.Providers, ", "),
+	}
+
+	if p.history == nil {
+		return data
+	}
+
+	// The email goes out without the change if the earlier rate is unknown
+	previous, err := p.history.RateAt(rate.Pair, timestamp.Add(-_changeWindow))
+	if err != nil || previous.Value == 0 {
+		return data
+	}
+
+	data.PreviousRate = formatRate(previous.Value)
+	data.Change = fmt.Sprintf(
+		"%+.2f%%",
+		float64((rate.Value-previous.Value)/previous.Value*100),
+	)
+
+	return data
+}
+
+func (p *Provider) sendToSubscriber(
+	templateData send.TemplateData,
+	subscriber port.User,
+) error {
+	unsubscribeLink, err := p.unsubscribeLink(subscriber.Email)
+	if err != nil {
+		return err
+	}
 
-	emailAddresses := convertUsersToEmails(subscribers)
+	templateData.Subscriber = subscriberData(subscriber)
+	templateData.UnsubscribeLink = unsubscribeLink
 
-	templateData := send.TemplateData{Rate: fmt.Sprintf("%.2f", rate)}
-	emailMessage, err := send.NewEmailMessage(p.config.Email, emailAddresses, templateData)
+	emailMessage, err := send.NewEmailMessage(
+		p.config.Email,
+		p.templates,
+		subscriber.Preferences.Language,
+		[]string{subscriber.Email},
+		templateData,
+	)
 	if err != nil {
 		return err
 	}
 
-	return send.SendEmail(p.connection, emailMessage)
+	return p.send(emailMessage)
 }
 
-func convertUsersToEmails(users []port.User) []string {
-	emails := make([]string, len(users))
+// SendAlert tells the subscriber that the alert has fired,
+// in the subscriber's language
+func (p *Provider) SendAlert(notice port.AlertNotice) error {
+	unsubscribeLink, err := p.unsubscribeLink(notice.Alert.Email)
+	if err != nil {
+		return err
+	}
+
+	timestamp := notice.Rate.FetchedAt
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
 
-	for i, user := range users {
-		emails[i] = user.Email
+	emailMessage, err := send.NewAlertMessage(
+		p.config.Email,
+		p.templates,
+		notice.Language,
+		[]string{notice.Alert.Email},
+		send.AlertTemplateData{
+			Message:         notice.Message,
+			Kind:            string(notice.Alert.Kind),
+			Value:           strconv.FormatFloat(notice.Alert.Value, 'f', -1, 64),
+			Rose:            notice.Rose,
+			Change:          fmt.Sprintf("%+.2f%%", notice.Change),
+			Rate:            formatRate(notice.Rate.Value),
+			Base:            notice.Rate.Pair.Base,
+			Quote:           notice.Rate.Pair.Quote,
+			Pair:            notice.Rate.Pair.String(),
+			Timestamp:       timestamp,
+			Provider:        strings.Join(notice.Rate.Providers, ", "),
+			Subscriber:      send.SubscriberData{Email: notice.Alert.Email},
+			UnsubscribeLink: unsubscribeLink,
+		},
+	)
+	if err != nil {
+		return err
 	}
 
-	return emails
+	return p.send(emailMessage)
+}
+
+// SendConfirmation sends the link that confirms the pending subscription
+func (p *Provider) SendConfirmation(user port.User) error {
+	confirmationLink, err := rest.BuildURL(
+		p.config.Email.ConfirmationURL,
+		map[string]string{_tokenQueryParam: user.ConfirmationToken},
+	)
+	if err != nil {
+		return err
+	}
+
+	emailMessage, err := send.NewConfirmationMessage(
+		p.config.Email,
+		p.templates,
+		user.Preferences.Language,
+		[]string{user.Email},
+		send.ConfirmationTemplateData{
+			ConfirmationLink: confirmationLink,
+			Subscriber:       subscriberData(user),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return p.send(emailMessage)
+}
+
+// send sends the message over a pooled connection. The rejections
+// which won't succeed on a retry are marked by port.ErrPermanentFailure.
+func (p *Provider) send(message *send.EmailMessage) error {
+	err := p.connections.Do(func(client smtp.SMTPConnectionClient) error {
+		return send.SendEmail(client, message)
+	})
+
+	var replyErr *textproto.Error
+	if errors.As(err, &replyErr) && replyErr.Code >= _smtpPermanentFailureCode {
+		return errors.Join(err, port.ErrPermanentFailure)
+	}
+
+	return err
+}
+
+func (p *Provider) unsubscribeLink(email string) (string, error) {
+	return rest.BuildURL(p.config.Email.UnsubscribeURL, map[string]string{
+		_emailQueryParam: email,
+		_tokenQueryParam: p.signer.Sign(email),
+	})
+}
+
+func subscriberData(user port.User) send.SubscriberData {
+	return send.SubscriberData{
+		Email:          user.Email,
+		TelegramLinked: user.TelegramChatID != 0,
+	}
+}
+
+func formatRate(value float32) string {
+	return fmt.Sprintf("%.2f", value)
 }
@@ -1,33 +1,75 @@
 package email
 
 import (
+	"crypto/tls"
 	"errors"
+	"io"
+	netsmtp "net/smtp"
+	"net/textproto"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"gses2-app/internal/core/port"
+	"gses2-app/internal/repository/sender/email/send"
 	"gses2-app/internal/repository/sender/smtp"
 )
 
 var (
 	errDialerError  = errors.New("dialer error")
 	errFactoryError = errors.New("factory error")
+	errMailbox      = errors.New("550 mailbox unavailable")
 )
 
+const _templatesDir = "../../../../templates/email"
+
+var _testSMTPConfig = smtp.SMTPConfig{
+	Host:     "smtp.example.com",
+	Security: smtp.SecurityTLS,
+	Auth:     smtp.AuthNone,
+}
+
+type StubLogger struct{}
+
+func (s *StubLogger) Info(...interface{})           {}
+func (s *StubLogger) Infof(string, ...interface{})  {}
+func (s *StubLogger) Debug(...interface{})          {}
+func (s *StubLogger) Debugf(string, ...interface{}) {}
+func (s *StubLogger) Error(...interface{})          {}
+func (s *StubLogger) Errorf(string, ...interface{}) {}
+
+type StubRateHistory struct {
+	rate port.Rate
+	err  error
+	at   time.Time
+}
+
+func (s *StubRateHistory) RateAt(pair port.CurrencyPair, at time.Time) (port.Rate, error) {
+	s.at = at
+	return s.rate, s.err
+}
+
+type StubSigner struct{}
+
+func (s *StubSigner) Sign(value string) string {
+	return "signature"
+}
+
 func TestSendExchangeRate(t *testing.T) {
 	tests := []struct {
 		name         string
 		emails       []string
 		exchangeRate port.Rate
-		dialer       smtp.TLSConnectionDialer
+		dialer       smtp.ConnectionDialer
 		factory      smtp.SMTPClientFactory
 		expectedErr  error
 	}{
 		{
 			name:         "Successful SendExchangeRate",
 			emails:       []string{"test@example.com"},
-			exchangeRate: 10.5,
+			exchangeRate: port.Rate{Pair: port.DefaultCurrencyPair, Value: 10.5},
 			dialer:       &smtp.StubDialer{},
 			factory:      &smtp.StubSMTPClientFactory{Client: &smtp.StubSMTPClient{}},
 			expectedErr:  nil,
@@ -35,7 +77,7 @@ func TestSendExchangeRate(t *testing.T) {
 		{
 			name:         "Failed due to dialer error",
 			emails:       []string{"test@example.com"},
-			exchangeRate: 10.5,
+			exchangeRate: port.Rate{Pair: port.DefaultCurrencyPair, Value: 10.5},
 			dialer:       &smtp.StubDialer{Err: errDialerError},
 			factory:      &smtp.StubSMTPClientFactory{Client: &smtp.StubSMTPClient{}},
 			expectedErr:  errDialerError,
@@ -43,7 +85,7 @@ func TestSendExchangeRate(t *testing.T) {
 		{
 			name:         "Failed due to factory error",
 			emails:       []string{"test@example.com"},
-			exchangeRate: 10.5,
+			exchangeRate: port.Rate{Pair: port.DefaultCurrencyPair, Value: 10.5},
 			dialer:       &smtp.StubDialer{},
 			factory: &smtp.StubSMTPClientFactory{
 				Client: &smtp.StubSMTPClient{},
@@ -58,23 +100,335 @@ func TestSendExchangeRate(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			config := &EmailSenderConfig{}
-			service, err := NewProvider(config, tt.dialer, tt.factory)
+			config := &EmailSenderConfig{
+				SMTP:  _testSMTPConfig,
+				Email: send.EmailConfig{From: "no.reply@example.com"},
+			}
+			service, err := NewProvider(
+				config,
+				tt.dialer,
+				tt.factory,
+				&StubSigner{},
+				newTestTemplates(t),
+				nil,
+			)
+			require.NoError(t, err)
 
-			require.Equal(t, tt.expectedErr, err)
+			users := convertEmailsToUsers(tt.emails)
+			err = service.SendExchangeRate(tt.exchangeRate, users)
 
 			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
 				return
 			}
 
-			users := convertEmailsToUsers(tt.emails)
-			err = service.SendExchangeRate(tt.exchangeRate, users)
-
 			require.NoError(t, err, "SendExchangeRate() unexpected error = %v", err)
 		})
 	}
 }
 
+// RecordingSMTPClient rejects the recipients of the rejected set
+// with their errors and keeps the recipients of every sent message
+type RecordingSMTPClient struct {
+	rejected map[string]error
+
+	recipients []string
+	sent       [][]string
+	resets     int
+}
+
+func (c *RecordingSMTPClient) Extension(string) (bool, string) { return false, "" }
+func (c *RecordingSMTPClient) StartTLS(*tls.Config) error      { return nil }
+func (c *RecordingSMTPClient) Auth(a netsmtp.Auth) error       { return nil }
+func (c *RecordingSMTPClient) Noop() error                     { return nil }
+func (c *RecordingSMTPClient) Quit() error                     { return nil }
+func (c *RecordingSMTPClient) Close() error                    { return nil }
+func (c *RecordingSMTPClient) Mail(string) error               { return nil }
+
+func (c *RecordingSMTPClient) Rcpt(to string) error {
+	if err := c.rejected[to]; err != nil {
+		return err
+	}
+
+	c.recipients = append(c.recipients, to)
+	return nil
+}
+
+func (c *RecordingSMTPClient) Data() (io.WriteCloser, error) {
+	c.sent = append(c.sent, c.recipients)
+	c.recipients = nil
+	return &smtp.StubWriteCloser{}, nil
+}
+
+func (c *RecordingSMTPClient) Reset() error {
+	c.recipients = nil
+	c.resets++
+	return nil
+}
+
+func newTestTemplates(t *testing.T) *send.Templates {
+	t.Helper()
+
+	templates, err := send.NewTemplates(
+		send.EmailConfig{DefaultLocale: "en"},
+		&StubLogger{},
+		os.DirFS(_templatesDir),
+	)
+	require.NoError(t, err)
+
+	return templates
+}
+
+func newTestProvider(t *testing.T, connector smtp.Connector) *Provider {
+	return &Provider{
+		config: &EmailSenderConfig{
+			Email: send.EmailConfig{
+				From:           "no.reply@example.com",
+				UnsubscribeURL: "https://test.url/api/unsubscribe",
+			},
+		},
+		signer:      &StubSigner{},
+		templates:   newTestTemplates(t),
+		connections: smtp.NewPool(smtp.SMTPConfig{}, connector),
+	}
+}
+
+func TestSendExchangeRatePerRecipient(t *testing.T) {
+	client := &RecordingSMTPClient{
+		rejected: map[string]error{"missing@example.com": errMailbox},
+	}
+	provider := newTestProvider(
+		t,
+		&smtp.StubConnector{Clients: []smtp.SMTPConnectionClient{client}},
+	)
+
+	err := provider.SendExchangeRate(
+		port.Rate{Pair: port.DefaultCurrencyPair, Value: 10.5},
+		append(
+			convertEmailsToUsers([]string{
+				"first@example.com",
+				"missing@example.com",
+				"second@example.com",
+			}),
+			port.User{
+				Email:       "telegram.only@example.com",
+				Preferences: port.Preferences{Channel: port.ChannelTelegram},
+			},
+		),
+	)
+
+	var report *port.DeliveryError
+	require.ErrorAs(t, err, &report)
+	require.Equal(t, 2, report.Sent)
+	require.Equal(t, []port.DeliveryFailure{{
+		Channel:   port.ChannelEmail,
+		Recipient: "missing@example.com",
+		Err:       errMailbox,
+	}}, report.Failures)
+
+	require.Equal(t, [][]string{
+		{"first@example.com"},
+		{"second@example.com"},
+	}, client.sent)
+	require.Equal(t, 1, client.resets)
+}
+
+func TestSendExchangeRatePermanentFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		rejection     error
+		wantPermanent bool
+	}{
+		{
+			name:          "Mailbox doesn't exist",
+			rejection:     &textproto.Error{Code: 550, Msg: "mailbox unavailable"},
+			wantPermanent: true,
+		},
+		{
+			name:      "Mailbox is busy",
+			rejection: &textproto.Error{Code: 450, Msg: "mailbox busy"},
+		},
+		{
+			name:      "Connection is lost",
+			rejection: io.EOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, &smtp.StubConnector{
+				Clients: []smtp.SMTPConnectionClient{&RecordingSMTPClient{
+					rejected: map[string]error{"missing@example.com": tt.rejection},
+				}},
+			})
+
+			err := provider.SendExchangeRate(
+				port.Rate{Pair: port.DefaultCurrencyPair, Value: 10.5},
+				convertEmailsToUsers([]string{"missing@example.com"}),
+			)
+
+			require.ErrorIs(t, err, tt.rejection)
+			require.Equal(t, tt.wantPermanent, errors.Is(err, port.ErrPermanentFailure))
+		})
+	}
+}
+
+func TestSendExchangeRateReconnects(t *testing.T) {
+	client := &RecordingSMTPClient{}
+	connector := &smtp.StubConnector{
+		Clients: []smtp.SMTPConnectionClient{
+			&smtp.StubSMTPClient{MailErr: io.EOF},
+			client,
+		},
+	}
+	provider := newTestProvider(t, connector)
+
+	err := provider.SendExchangeRate(
+		port.Rate{Pair: port.DefaultCurrencyPair, Value: 10.5},
+		convertEmailsToUsers([]string{"first@example.com", "second@example.com"}),
+	)
+
+	var report *port.DeliveryError
+	require.ErrorAs(t, err, &report)
+	require.Equal(t, 1, report.Sent)
+	require.Len(t, report.Failures, 1)
+	require.ErrorIs(t, report.Failures[0].Err, io.EOF)
+
+	require.Equal(t, 2, connector.Connects)
+	require.Equal(t, [][]string{{"second@example.com"}}, client.sent)
+}
+
+func TestUnsubscribeLink(t *testing.T) {
+	provider := &Provider{
+		config: &EmailSenderConfig{
+			Email: send.EmailConfig{
+				From:           "no.reply@example.com",
+				UnsubscribeURL: "https://test.url/api/unsubscribe",
+			},
+		},
+		signer: &StubSigner{},
+	}
+
+	link, err := provider.unsubscribeLink("test+1@example.com")
+
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		"https://test.url/api/unsubscribe?email=test%2B1%40example.com&token=signature",
+		link,
+	)
+}
+
+func TestSendConfirmation(t *testing.T) {
+	client := &smtp.StubSMTPClient{}
+	provider, err := NewProvider(
+		&EmailSenderConfig{
+			SMTP: _testSMTPConfig,
+			Email: send.EmailConfig{
+				From:            "no.reply@example.com",
+				ConfirmationURL: "https://test.url/api/subscribe/confirm",
+			},
+		},
+		&smtp.StubDialer{},
+		&smtp.StubSMTPClientFactory{Client: client},
+		&StubSigner{},
+		newTestTemplates(t),
+		nil,
+	)
+	require.NoError(t, err)
+
+	err = provider.SendConfirmation(port.User{
+		Email:             "test@example.com",
+		ConfirmationToken: "token",
+	})
+
+	require.NoError(t, err)
+}
+
+func TestSendAlert(t *testing.T) {
+	client := &smtp.StubSMTPClient{}
+	provider, err := NewProvider(
+		&EmailSenderConfig{
+			SMTP: _testSMTPConfig,
+			Email: send.EmailConfig{
+				From:           "no.reply@example.com",
+				UnsubscribeURL: "https://test.url/api/unsubscribe",
+			},
+		},
+		&smtp.StubDialer{},
+		&smtp.StubSMTPClientFactory{Client: client},
+		&StubSigner{},
+		newTestTemplates(t),
+		nil,
+	)
+	require.NoError(t, err)
+
+	err = provider.SendAlert(port.AlertNotice{
+		Alert:   port.Alert{Email: "test@example.com"},
+		Rate:    port.Rate{Pair: port.DefaultCurrencyPair, Value: 100},
+		Message: "BTC/UAH rose above 100",
+	})
+
+	require.NoError(t, err)
+}
+
+func TestRateTemplateData(t *testing.T) {
+	fetchedAt := time.Date(2023, time.July, 2, 12, 0, 0, 0, time.UTC)
+	rate := port.Rate{
+		Pair:      port.DefaultCurrencyPair,
+		Value:     1100,
+		Providers: []string{"binance", "coingecko"},
+		FetchedAt: fetchedAt,
+	}
+
+	tests := []struct {
+		name     string
+		history  *StubRateHistory
+		expected send.TemplateData
+	}{
+		{
+			name:    "Change since the day before",
+			history: &StubRateHistory{rate: port.Rate{Value: 1000}},
+			expected: send.TemplateData{
+				Rate:         "1100.00",
+				Base:         "BTC",
+				Quote:        "UAH",
+				Pair:         "BTC/UAH",
+				Timestamp:    fetchedAt,
+				Provider:     "binance, coingecko",
+				PreviousRate: "1000.00",
+				Change:       "+10.00%",
+			},
+		},
+		{
+			name:    "No earlier rate",
+			history: &StubRateHistory{err: errors.New("no rate")},
+			expected: send.TemplateData{
+				Rate:      "1100.00",
+				Base:      "BTC",
+				Quote:     "UAH",
+				Pair:      "BTC/UAH",
+				Timestamp: fetchedAt,
+				Provider:  "binance, coingecko",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{history: tt.history}
+
+			data := provider.rateTemplateData(rate)
+
+			require.Equal(t, tt.expected, data)
+			require.Equal(t, fetchedAt.Add(-24*time.Hour), tt.history.at)
+		})
+	}
+}
+
 func convertEmailsToUsers(emails []string) []port.User {
 	users := make([]port.User, len(emails))
 
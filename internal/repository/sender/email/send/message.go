@@ -2,30 +2,82 @@ package send
 
 import (
 	"bytes"
-	"errors"
+	htmltemplate "html/template"
 	"strings"
-	"text/template"
+	"time"
 )
 
-const _emailTemplate = `From: {{.From}}
-To: {{.To}}
-Subject: {{.Subject}}
+const _unsubscribeFooter = "\n\nTo unsubscribe, follow the link: "
 
-{{.Body}}`
-
-var (
-	errParseTemplate   = errors.New("parse template error")
-	errExecuteTemplate = errors.New("cannot execute email")
-)
+var _htmlUnsubscribeFooter = htmltemplate.Must(htmltemplate.New("footer").Parse(
+	`<p>To unsubscribe, follow the <a href="{{.}}">link</a>.</p>`,
+))
 
 type EmailConfig struct {
-	From    string `default:"no.reply@currency.info.api"`
-	Subject string `default:"BTC to UAH exchange rate"`
-	Body    string `default:"The BTC to UAH exchange rate is {{.Rate}} UAH per BTC"`
+	From           string `default:"no.reply@currency.info.api"`
+	UnsubscribeURL string `default:"http://localhost:8080/api/unsubscribe"`
+	// ConfirmationURL is the confirmation endpoint the link points to
+	ConfirmationURL string `default:"http://localhost:8080/api/subscribe/confirm"`
+
+	// The directory with a subdirectory of templates per locale
+	TemplatesDir string `default:"./templates/email"`
+	// The locale which has every template, the others fall back to it
+	DefaultLocale string `default:"en"`
+	// How often the templates are checked for changes, zero disables
+	// the reload
+	TemplatesReloadInterval time.Duration `default:"10s"`
+}
+
+// SubscriberData is what the templates know about the recipient
+type SubscriberData struct {
+	Email          string
+	TelegramLinked bool
 }
 
 type TemplateData struct {
-	Rate string
+	Rate  string
+	Base  string
+	Quote string
+	// The pair as BTC/UAH
+	Pair      string
+	Timestamp time.Time
+	// The providers the rate was fetched from, comma-separated
+	Provider string
+	// The rate a day before and the signed change to the current one
+	// in percent, e.g. +1.25%, both are empty if the earlier rate
+	// is unknown
+	PreviousRate    string
+	Change          string
+	Subscriber      SubscriberData
+	UnsubscribeLink string
+}
+
+type AlertTemplateData struct {
+	// What has happened in English, the translated templates build
+	// their own sentence from the kind and its details
+	Message string
+	// The kind of the alert, "threshold" or "change"
+	Kind string
+	// The threshold rate or the percent of the change
+	Value string
+	// The rate of the threshold alert rose above the value,
+	// otherwise it fell below
+	Rose bool
+	// The change of the change alert's rate, e.g. +5.20%
+	Change          string
+	Rate            string
+	Base            string
+	Quote           string
+	Pair            string
+	Timestamp       time.Time
+	Provider        string
+	Subscriber      SubscriberData
+	UnsubscribeLink string
+}
+
+type ConfirmationTemplateData struct {
+	ConfirmationLink string
+	Subscriber       SubscriberData
 }
 
 type EmailMessage struct {
@@ -33,53 +85,120 @@ type EmailMessage struct {
 	To      []string
 	Subject string
 	Body    string
+	// The HTML alternative of the body, the message is plain text without it
+	HTMLBody string
+	// Announced in the List-Unsubscribe header
+	UnsubscribeLink string
 }
 
+// NewEmailMessage renders the rate message in the subscriber's locale
 func NewEmailMessage(
 	config EmailConfig,
+	templates *Templates,
+	locale string,
 	to []string,
 	data TemplateData,
 ) (*EmailMessage, error) {
-	tmpl, err := template.New("email").Parse(config.Body)
+	return newMessage(
+		config,
+		templates,
+		TemplateRate,
+		locale,
+		to,
+		data,
+		data.UnsubscribeLink,
+	)
+}
+
+// NewAlertMessage renders the alert message in the subscriber's locale
+func NewAlertMessage(
+	config EmailConfig,
+	templates *Templates,
+	locale string,
+	to []string,
+	data AlertTemplateData,
+) (*EmailMessage, error) {
+	return newMessage(
+		config,
+		templates,
+		TemplateAlert,
+		locale,
+		to,
+		data,
+		data.UnsubscribeLink,
+	)
+}
+
+// NewConfirmationMessage renders the confirmation message, which has
+// no unsubscribe link as the subscription isn't confirmed yet
+func NewConfirmationMessage(
+	config EmailConfig,
+	templates *Templates,
+	locale string,
+	to []string,
+	data ConfirmationTemplateData,
+) (*EmailMessage, error) {
+	return newMessage(config, templates, TemplateConfirmation, locale, to, data, "")
+}
+
+func newMessage(
+	config EmailConfig,
+	templates *Templates,
+	kind TemplateKind,
+	locale string,
+	to []string,
+	data any,
+	unsubscribeLink string,
+) (*EmailMessage, error) {
+	subject, text, html, err := templates.Render(kind, locale, data)
 	if err != nil {
 		return nil, err
 	}
 
-	var body bytes.Buffer
-	err = tmpl.Execute(&body, data)
+	body := bytes.NewBufferString(text)
+	addUnsubscribeFooter(body, unsubscribeLink)
+
+	htmlBody := bytes.NewBufferString(html)
+	err = addHTMLUnsubscribeFooter(htmlBody, unsubscribeLink)
 	if err != nil {
 		return nil, err
 	}
 
 	return &EmailMessage{
-		From:    config.From,
-		To:      to,
-		Subject: config.Subject,
-		Body:    body.String(),
+		From:            config.From,
+		To:              to,
+		Subject:         subject,
+		Body:            body.String(),
+		HTMLBody:        htmlBody.String(),
+		UnsubscribeLink: unsubscribeLink,
 	}, nil
 }
 
-func (e *EmailMessage) Prepare() ([]byte, error) {
-	tmpl, err := template.New("email").Parse(_emailTemplate)
-	if err != nil {
-		return nil, errParseTemplate
+// Every message must carry an opt-out, even if the template lacks it
+func addUnsubscribeFooter(body *bytes.Buffer, unsubscribeLink string) {
+	if unsubscribeLink != "" &&
+		!strings.Contains(body.String(), unsubscribeLink) {
+		body.WriteString(_unsubscribeFooter + unsubscribeLink)
+	}
+}
+
+// addHTMLUnsubscribeFooter adds the opt-out to the HTML alternative,
+// where the link's ampersands are escaped
+func addHTMLUnsubscribeFooter(body *bytes.Buffer, unsubscribeLink string) error {
+	if body.Len() == 0 || unsubscribeLink == "" ||
+		strings.Contains(body.String(), htmltemplate.HTMLEscapeString(unsubscribeLink)) {
+		return nil
 	}
 
-	var message bytes.Buffer
-	err = tmpl.Execute(&message, struct {
-		From    string
-		To      string
-		Subject string
-		Body    string
-	}{
-		From:    e.From,
-		To:      strings.Join(e.To, ","),
-		Subject: e.Subject,
-		Body:    e.Body,
-	})
+	return _htmlUnsubscribeFooter.Execute(body, unsubscribeLink)
+}
+
+// Prepare composes the message with the current date and a new Message-ID
+func (e *EmailMessage) Prepare() ([]byte, error) {
+	messageID, err := newMessageID(e.From)
 	if err != nil {
-		return nil, errExecuteTemplate
+		return nil, err
 	}
 
-	return message.Bytes(), nil
+	return e.compose(time.Now(), messageID)
 }
@@ -1,7 +1,15 @@
 package send
 
 import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -9,44 +17,89 @@ import (
 func TestNewEmailMessage(t *testing.T) {
 	tests := []struct {
 		name         string
-		emailConfig  EmailConfig
-		to           []string
+		bodyTemplate string
+		locale       string
 		templateData TemplateData
 		expected     *EmailMessage
-		hasError     bool
 	}{
 		{
-			name: "Create email message",
-			emailConfig: EmailConfig{
+			name:         "Create email message",
+			bodyTemplate: "The current exchange rate is {{.Rate}}.",
+			templateData: TemplateData{
+				Rate: "200",
+			},
+			expected: &EmailMessage{
 				From:    "test_from@example.com",
+				To:      []string{"test_to@example.com"},
 				Subject: "Test Subject",
-				Body:    "The current exchange rate is {{.Rate}}.",
+				Body:    "The current exchange rate is 200.",
 			},
-			to: []string{"test_to@example.com"},
+		},
+		{
+			name:         "Append unsubscribe link",
+			bodyTemplate: "The current exchange rate is {{.Rate}}.",
 			templateData: TemplateData{
-				Rate: "200",
+				Rate:            "200",
+				UnsubscribeLink: "https://test.url/unsubscribe",
 			},
 			expected: &EmailMessage{
 				From:    "test_from@example.com",
 				To:      []string{"test_to@example.com"},
 				Subject: "Test Subject",
-				Body:    "The current exchange rate is 200.",
+				Body: "The current exchange rate is 200." +
+					"\n\nTo unsubscribe, follow the link: https://test.url/unsubscribe",
+				UnsubscribeLink: "https://test.url/unsubscribe",
 			},
-			hasError: false,
 		},
 		{
-			name: "Bad template",
-			emailConfig: EmailConfig{
+			name:         "Unsubscribe link in template",
+			bodyTemplate: "Rate: {{.Rate}}. Unsubscribe: {{.UnsubscribeLink}}",
+			templateData: TemplateData{
+				Rate:            "200",
+				UnsubscribeLink: "https://test.url/unsubscribe",
+			},
+			expected: &EmailMessage{
+				From:            "test_from@example.com",
+				To:              []string{"test_to@example.com"},
+				Subject:         "Test Subject",
+				Body:            "Rate: 200. Unsubscribe: https://test.url/unsubscribe",
+				UnsubscribeLink: "https://test.url/unsubscribe",
+			},
+		},
+		{
+			name: "Enriched template data",
+			bodyTemplate: "{{.Pair}} {{.Rate}} ({{.Change}} from {{.PreviousRate}}) " +
+				"by {{.Provider}} at {{.Timestamp.Format \"15:04\"}} for {{.Subscriber.Email}}",
+			templateData: TemplateData{
+				Rate:         "200.00",
+				Pair:         "BTC/UAH",
+				Timestamp:    time.Date(2023, time.July, 1, 12, 30, 0, 0, time.UTC),
+				Provider:     "binance",
+				PreviousRate: "100.00",
+				Change:       "+100.00%",
+				Subscriber:   SubscriberData{Email: "test_to@example.com"},
+			},
+			expected: &EmailMessage{
 				From:    "test_from@example.com",
+				To:      []string{"test_to@example.com"},
 				Subject: "Test Subject",
-				Body:    "The current exchange rate is {{.Rate",
+				Body: "BTC/UAH 200.00 (+100.00% from 100.00) " +
+					"by binance at 12:30 for test_to@example.com",
 			},
-			to: []string{"test_to@example.com"},
+		},
+		{
+			name:         "Locale template",
+			bodyTemplate: "The current exchange rate is {{.Rate}}.",
+			locale:       "uk-UA",
 			templateData: TemplateData{
 				Rate: "200",
 			},
-			expected: nil,
-			hasError: true,
+			expected: &EmailMessage{
+				From:    "test_from@example.com",
+				To:      []string{"test_to@example.com"},
+				Subject: "Курс",
+				Body:    "Поточний курс: 200.",
+			},
 		},
 	}
 
@@ -55,120 +108,221 @@ func TestNewEmailMessage(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			emailMessage, err := NewEmailMessage(tt.emailConfig, tt.to, tt.templateData)
-			require.Equal(
-				t,
-				tt.hasError,
-				err != nil,
-				"NewEmailMessage() error = %v, wantErr %v",
-				err,
-				tt.hasError,
+			templates := newTestTemplates(t, fstest.MapFS{
+				"en/rate.txt.tmpl": {Data: []byte(tt.bodyTemplate)},
+				"uk/rate.subject.tmpl": {
+					Data: []byte("Курс"),
+				},
+				"uk/rate.txt.tmpl": {
+					Data: []byte("Поточний курс: {{.Rate}}."),
+				},
+			})
+
+			emailMessage, err := NewEmailMessage(
+				_testEmailConfig,
+				templates,
+				tt.locale,
+				[]string{"test_to@example.com"},
+				tt.templateData,
 			)
 
-			if err != nil {
-				return
-			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, emailMessage)
+		})
+	}
+}
 
-			require.Equal(
-				t,
-				tt.expected.From,
-				emailMessage.From,
-				"From: got %v, want %v",
-				emailMessage.From,
-				tt.expected.From,
-			)
+func TestNewConfirmationMessage(t *testing.T) {
+	templates := newTestTemplates(t, fstest.MapFS{
+		"en/confirmation.subject.tmpl": {Data: []byte("Confirm")},
+		"en/confirmation.txt.tmpl": {
+			Data: []byte("Follow the link: {{.ConfirmationLink}}"),
+		},
+	})
 
-			require.Equal(
-				t,
-				tt.expected.To,
-				emailMessage.To,
-				"To: got %v, want %v",
-				emailMessage.To,
-				tt.expected.To,
-			)
+	emailMessage, err := NewConfirmationMessage(
+		_testEmailConfig,
+		templates,
+		"",
+		[]string{"test_to@example.com"},
+		ConfirmationTemplateData{ConfirmationLink: "https://test.url/confirm"},
+	)
 
-			require.Equal(
-				t,
-				tt.expected.Subject,
-				emailMessage.Subject,
-				"Subject: got %v, want %v",
-				emailMessage.Subject,
-				tt.expected.Subject,
-			)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		&EmailMessage{
+			From:    "test_from@example.com",
+			To:      []string{"test_to@example.com"},
+			Subject: "Confirm",
+			Body:    "Follow the link: https://test.url/confirm",
+		},
+		emailMessage,
+	)
+}
+
+func TestNewAlertMessage(t *testing.T) {
+	templates := newTestTemplates(t, fstest.MapFS{
+		"en/alert.subject.tmpl": {Data: []byte("{{.Pair}} alert")},
+		"en/alert.txt.tmpl": {
+			Data: []byte("{{.Message}}. The rate is {{.Rate}} {{.Quote}} per {{.Base}}"),
+		},
+	})
+
+	emailMessage, err := NewAlertMessage(
+		_testEmailConfig,
+		templates,
+		"",
+		[]string{"test_to@example.com"},
+		AlertTemplateData{
+			Message:         "BTC/UAH rose above 100",
+			Rate:            "100.50",
+			Base:            "BTC",
+			Quote:           "UAH",
+			Pair:            "BTC/UAH",
+			UnsubscribeLink: "https://test.url/unsubscribe",
+		},
+	)
+
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		&EmailMessage{
+			From:    "test_from@example.com",
+			To:      []string{"test_to@example.com"},
+			Subject: "BTC/UAH alert",
+			Body: "BTC/UAH rose above 100. The rate is 100.50 UAH per BTC" +
+				"\n\nTo unsubscribe, follow the link: https://test.url/unsubscribe",
+			UnsubscribeLink: "https://test.url/unsubscribe",
+		},
+		emailMessage,
+	)
+}
+
+func TestNewEmailMessageHTML(t *testing.T) {
+	tests := []struct {
+		name         string
+		htmlBody     string
+		link         string
+		expectedHTML string
+	}{
+		{
+			name:     "Append unsubscribe link",
+			htmlBody: "<p>Rate: <b>{{.Rate}}</b></p>",
+			link:     "https://test.url/unsubscribe?email=a%40b.c&token=t",
+			expectedHTML: "<p>Rate: <b>200</b></p>" +
+				`<p>To unsubscribe, follow the <a href="https://test.url/unsubscribe?email=a%40b.c&amp;token=t">link</a>.</p>`,
+		},
+		{
+			name:         "Unsubscribe link in template",
+			htmlBody:     `<p>{{.Rate}} <a href="{{.UnsubscribeLink}}">unsubscribe</a></p>`,
+			link:         "https://test.url/unsubscribe?email=a%40b.c&token=t",
+			expectedHTML: `<p>200 <a href="https://test.url/unsubscribe?email=a%40b.c&amp;token=t">unsubscribe</a></p>`,
+		},
+		{
+			name:         "Escape template data",
+			htmlBody:     "<p>{{.Base}}</p>",
+			expectedHTML: "<p>&lt;b&gt;</p>",
+		},
+		{
+			name: "No HTML template",
+			link: "https://test.url/unsubscribe",
+		},
+	}
 
-			require.Equal(
-				t,
-				tt.expected.Body,
-				emailMessage.Body,
-				"Body: got %v, want %v",
-				emailMessage.Body,
-				tt.expected.Body,
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			files := fstest.MapFS{}
+			if tt.htmlBody != "" {
+				files["en/rate.html.tmpl"] = &fstest.MapFile{Data: []byte(tt.htmlBody)}
+			}
+
+			emailMessage, err := NewEmailMessage(
+				_testEmailConfig,
+				newTestTemplates(t, files),
+				"",
+				[]string{"test_to@example.com"},
+				TemplateData{Rate: "200", Base: "<b>", UnsubscribeLink: tt.link},
 			)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedHTML, emailMessage.HTMLBody)
+			require.Equal(t, tt.link, emailMessage.UnsubscribeLink)
 		})
 	}
 }
 
-func TestPrepare(t *testing.T) {
+var _testDate = time.Date(2023, time.July, 1, 12, 30, 0, 0, time.UTC)
+
+const _testMessageID = "<id@example.com>"
+
+func TestCompose(t *testing.T) {
 	tests := []struct {
 		name     string
 		message  *EmailMessage
 		expected string
 	}{
 		{
-			name: "Prepare single recipient message",
+			name: "Compose single recipient message",
 			message: &EmailMessage{
 				From:    "test_from@example.com",
 				To:      []string{"test_to@example.com"},
 				Subject: "Test Subject",
 				Body:    "Test Body",
 			},
-			expected: `From: test_from@example.com
-To: test_to@example.com
-Subject: Test Subject
-
-Test Body`,
+			expected: "From: test_from@example.com\r\n" +
+				"To: test_to@example.com\r\n" +
+				"Subject: Test Subject\r\n" +
+				"Date: Sat, 01 Jul 2023 12:30:00 +0000\r\n" +
+				"Message-ID: <id@example.com>\r\n" +
+				"MIME-Version: 1.0\r\n" +
+				"Content-Type: text/plain; charset=utf-8\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n" +
+				"Test Body",
 		},
 		{
-			name: "Prepare multiple recipient message",
+			name: "Compose multiple recipient message",
 			message: &EmailMessage{
-				From:    "test_from@example.com",
+				From:    "Rates <test_from@example.com>",
 				To:      []string{"test_to1@example.com", "test_to2@example.com"},
 				Subject: "Test Subject",
 				Body:    "Test Body",
 			},
-			expected: `From: test_from@example.com
-To: test_to1@example.com,test_to2@example.com
-Subject: Test Subject
-
-Test Body`,
-		},
-		{
-			name: "Prepare message with no body",
-			message: &EmailMessage{
-				From:    "test_from@example.com",
-				To:      []string{"test_to@example.com"},
-				Subject: "Test Subject",
-				Body:    "",
-			},
-			expected: `From: test_from@example.com
-To: test_to@example.com
-Subject: Test Subject
-
-`,
+			expected: "From: \"Rates\" <test_from@example.com>\r\n" +
+				"To: test_to1@example.com, test_to2@example.com\r\n" +
+				"Subject: Test Subject\r\n" +
+				"Date: Sat, 01 Jul 2023 12:30:00 +0000\r\n" +
+				"Message-ID: <id@example.com>\r\n" +
+				"MIME-Version: 1.0\r\n" +
+				"Content-Type: text/plain; charset=utf-8\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n" +
+				"Test Body",
 		},
 		{
-			name: "Prepare message with no subject",
+			name: "Compose message with unsubscribe link",
 			message: &EmailMessage{
-				From:    "test_from@example.com",
-				To:      []string{"test_to@example.com"},
-				Subject: "",
-				Body:    "Test Body",
+				From:            "test_from@example.com",
+				To:              []string{"test_to@example.com"},
+				Subject:         "",
+				Body:            "",
+				UnsubscribeLink: "https://test.url/unsubscribe?token=t",
 			},
-			expected: `From: test_from@example.com
-To: test_to@example.com
-Subject: 
-
-Test Body`,
+			expected: "From: test_from@example.com\r\n" +
+				"To: test_to@example.com\r\n" +
+				"Subject: \r\n" +
+				"Date: Sat, 01 Jul 2023 12:30:00 +0000\r\n" +
+				"Message-ID: <id@example.com>\r\n" +
+				"List-Unsubscribe: <https://test.url/unsubscribe?token=t>\r\n" +
+				"List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n" +
+				"MIME-Version: 1.0\r\n" +
+				"Content-Type: text/plain; charset=utf-8\r\n" +
+				"Content-Transfer-Encoding: quoted-printable\r\n" +
+				"\r\n",
 		},
 	}
 
@@ -177,17 +331,105 @@ Test Body`,
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			prepared, err := tt.message.Prepare()
+			composed, err := tt.message.compose(_testDate, _testMessageID)
 
-			require.NoError(t, err, "Prepared message: want %v but got error %v", tt.expected, err)
-			require.Equal(
-				t,
-				tt.expected,
-				string(prepared),
-				"Prepared message: got \n%v, want \n%v",
-				string(prepared),
-				tt.expected,
-			)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(composed))
 		})
 	}
 }
+
+func TestComposeMultipart(t *testing.T) {
+	subject := "Курс BTC до UAH: щоденне оновлення обмінного курсу для підписників"
+	body := strings.Repeat("Поточний курс BTC до UAH становить 1 000 000 гривень. ", 5)
+	htmlBody := "<p>" + body + "</p>"
+
+	message := &EmailMessage{
+		From:     "Курси валют <no.reply@example.com>",
+		To:       []string{"test_to@example.com"},
+		Subject:  subject,
+		Body:     body,
+		HTMLBody: htmlBody,
+	}
+
+	composed, err := message.compose(_testDate, _testMessageID)
+	require.NoError(t, err)
+
+	for _, line := range strings.Split(string(composed), "\r\n") {
+		require.LessOrEqual(t, len(line), _maxLineLength, "line %q is too long", line)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(composed))
+	require.NoError(t, err)
+
+	decoder := new(mime.WordDecoder)
+	decodedSubject, err := decoder.DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, subject, decodedSubject)
+
+	from, err := parsed.Header.AddressList("From")
+	require.NoError(t, err)
+	require.Equal(t, []*mail.Address{{
+		Name:    "Курси валют",
+		Address: "no.reply@example.com",
+	}}, from)
+
+	date, err := parsed.Header.Date()
+	require.NoError(t, err)
+	require.True(t, _testDate.Equal(date))
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, expected := range []struct {
+		contentType string
+		content     string
+	}{
+		{contentType: "text/plain; charset=utf-8", content: body},
+		{contentType: "text/html; charset=utf-8", content: htmlBody},
+	} {
+		part, err := reader.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, expected.contentType, part.Header.Get("Content-Type"))
+
+		// The reader decodes the quoted-printable parts itself
+		content, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, expected.content, string(content))
+	}
+
+	_, err = reader.NextPart()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestComposeInvalidAddress(t *testing.T) {
+	message := &EmailMessage{
+		From: "test_from@example.com",
+		To:   []string{"test_to.example.com"},
+	}
+
+	_, err := message.compose(_testDate, _testMessageID)
+
+	require.ErrorIs(t, err, errInvalidAddress)
+}
+
+func TestPrepare(t *testing.T) {
+	message := &EmailMessage{
+		From:    "Rates <test_from@example.com>",
+		To:      []string{"test_to@example.com"},
+		Subject: "Test Subject",
+		Body:    "Test Body",
+	}
+
+	prepared, err := message.Prepare()
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(prepared))
+	require.NoError(t, err)
+	require.Regexp(t, `^<[0-9a-f]{32}@example\.com>$`, parsed.Header.Get("Message-ID"))
+
+	_, err = parsed.Header.Date()
+	require.NoError(t, err)
+}
@@ -0,0 +1,183 @@
+package send
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	_crlf = "\r\n"
+	// RFC 5322 recommends to keep the lines within 78 characters
+	_maxLineLength = 78
+	_charset       = "utf-8"
+	_messageIDSize = 16
+
+	_textContentType = "text/plain; charset=utf-8"
+	_htmlContentType = "text/html; charset=utf-8"
+	_quotedPrintable = "quoted-printable"
+)
+
+var errInvalidAddress = errors.New("invalid email address")
+
+// compose writes the message in the MIME format: the text body and its
+// HTML alternative are quoted-printable, so the long lines are wrapped
+// and the non-ASCII text survives, and so are the non-ASCII headers
+func (e *EmailMessage) compose(date time.Time, messageID string) ([]byte, error) {
+	from, err := formatAddress(e.From)
+	if err != nil {
+		return nil, err
+	}
+
+	to := make([]string, 0, len(e.To))
+	for _, recipient := range e.To {
+		address, err := formatAddress(recipient)
+		if err != nil {
+			return nil, err
+		}
+		to = append(to, address)
+	}
+
+	var body bytes.Buffer
+	contentType := _textContentType
+	if e.HTMLBody == "" {
+		err = writeQuotedPrintable(&body, e.Body)
+	} else {
+		contentType, err = writeAlternatives(&body, e.Body, e.HTMLBody)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	writeHeader(&message, "From", from)
+	writeHeader(&message, "To", strings.Join(to, ", "))
+	writeHeader(&message, "Subject", mime.QEncoding.Encode(_charset, e.Subject))
+	writeHeader(&message, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&message, "Message-ID", messageID)
+	if e.UnsubscribeLink != "" {
+		// The unsubscribe endpoint takes a POST, so the mail clients
+		// may unsubscribe with a click, see RFC 8058
+		writeHeader(&message, "List-Unsubscribe", "<"+e.UnsubscribeLink+">")
+		writeHeader(&message, "List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	writeHeader(&message, "MIME-Version", "1.0")
+	writeHeader(&message, "Content-Type", contentType)
+	if e.HTMLBody == "" {
+		writeHeader(&message, "Content-Transfer-Encoding", _quotedPrintable)
+	}
+	message.WriteString(_crlf)
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+// writeAlternatives writes the multipart/alternative body, the HTML part
+// goes last as the mail clients prefer the last part they can show
+func writeAlternatives(body *bytes.Buffer, text, html string) (string, error) {
+	writer := multipart.NewWriter(body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{contentType: _textContentType, content: text},
+		{contentType: _htmlContentType, content: html},
+	} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {_quotedPrintable},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = writeQuotedPrintable(partWriter, part.content)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err := writer.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return mime.FormatMediaType(
+		"multipart/alternative",
+		map[string]string{"boundary": writer.Boundary()},
+	), nil
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	writer := quotedprintable.NewWriter(w)
+
+	_, err := writer.Write([]byte(content))
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// writeHeader folds the header at the spaces, so its lines are within
+// the recommended length unless a single word is longer. The first word
+// may go to the next line too, e.g. a long encoded display name.
+func writeHeader(message *bytes.Buffer, name, value string) {
+	message.WriteString(name + ":")
+	lineLength := len(name) + 1
+
+	for _, word := range strings.Split(value, " ") {
+		if lineLength > 0 && lineLength+1+len(word) > _maxLineLength {
+			message.WriteString(_crlf)
+			lineLength = 0
+		}
+
+		message.WriteString(" " + word)
+		lineLength += 1 + len(word)
+	}
+
+	message.WriteString(_crlf)
+}
+
+// formatAddress encodes the display name of the address if it has one
+func formatAddress(address string) (string, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", errInvalidAddress, address)
+	}
+
+	if parsed.Name == "" {
+		return parsed.Address, nil
+	}
+
+	return parsed.String(), nil
+}
+
+// newMessageID returns a unique Message-ID on the domain of the sender
+func newMessageID(from string) (string, error) {
+	parsed, err := mail.ParseAddress(from)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", errInvalidAddress, from)
+	}
+
+	domain := parsed.Address[strings.LastIndex(parsed.Address, "@")+1:]
+
+	id := make([]byte, _messageIDSize)
+	_, err = rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain), nil
+}
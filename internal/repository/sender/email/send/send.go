@@ -13,6 +13,7 @@ type SenderSMTPClient interface {
 	Mail(string) error
 	Rcpt(string) error
 	Data() (io.WriteCloser, error)
+	Reset() error
 	Quit() error
 }
 
@@ -48,25 +49,36 @@ func writeAndClose(client SenderSMTPClient, message []byte) error {
 	return writer.Close()
 }
 
+// SendEmail sends the message in a single mail transaction. A failed
+// transaction is aborted, so the connection can send the next message.
 func SendEmail(client SenderSMTPClient, email *EmailMessage) error {
 	err := setMail(client, email.From)
 	if err != nil {
-		return err
+		return abort(client, err)
 	}
 
 	err = setRecipients(client, email.To)
-	if errors.Is(err, errNoRecipients) {
-		return nil
+	if err != nil {
+		return abort(client, err)
 	}
 
+	emailMessage, err := email.Prepare()
 	if err != nil {
-		return err
+		return abort(client, err)
 	}
 
-	emailMessage, err := email.Prepare()
+	err = writeAndClose(client, emailMessage)
 	if err != nil {
-		return err
+		return abort(client, err)
+	}
+
+	return nil
+}
+
+func abort(client SenderSMTPClient, err error) error {
+	if resetErr := client.Reset(); resetErr != nil {
+		return errors.Join(err, resetErr)
 	}
 
-	return writeAndClose(client, emailMessage)
+	return err
 }
@@ -17,6 +17,7 @@ type StubSMTPClient struct {
 	writeCalledWith   []byte
 	writeShouldReturn error
 	mailShouldReturn  error
+	resetCalled       bool
 }
 
 func (m *StubSMTPClient) Mail(from string) error {
@@ -46,17 +47,23 @@ func (m *StubSMTPClient) Close() error {
 	return nil
 }
 
+func (m *StubSMTPClient) Reset() error {
+	m.resetCalled = true
+	return nil
+}
+
 func (m *StubSMTPClient) Quit() error {
 	m.quitCalled = true
 	return nil
 }
 
 type testCase struct {
-	name             string
-	client           *StubSMTPClient
-	email            *EmailMessage
-	expectedErr      error
-	expectDataCalled bool
+	name              string
+	client            *StubSMTPClient
+	email             *EmailMessage
+	expectedErr       error
+	expectDataCalled  bool
+	expectResetCalled bool
 }
 
 var (
@@ -92,8 +99,9 @@ func TestSendEmail(t *testing.T) {
 				Subject: "Test Subject",
 				Body:    "Test Body",
 			},
-			expectedErr:      errWrite,
-			expectDataCalled: true,
+			expectedErr:       errWrite,
+			expectDataCalled:  true,
+			expectResetCalled: true,
 		},
 		{
 			name: "Error on setMail",
@@ -106,8 +114,9 @@ func TestSendEmail(t *testing.T) {
 				Subject: "Test Subject",
 				Body:    "Test Body",
 			},
-			expectedErr:      errSetMail,
-			expectDataCalled: false,
+			expectedErr:       errSetMail,
+			expectDataCalled:  false,
+			expectResetCalled: true,
 		},
 		{
 			name: "Error on setRecipients",
@@ -120,8 +129,21 @@ func TestSendEmail(t *testing.T) {
 				Subject: "Test Subject",
 				Body:    "Test Body",
 			},
-			expectedErr:      errSetRecipients,
-			expectDataCalled: false,
+			expectedErr:       errSetRecipients,
+			expectDataCalled:  false,
+			expectResetCalled: true,
+		},
+		{
+			name:   "No recipients",
+			client: &StubSMTPClient{},
+			email: &EmailMessage{
+				From:    "test_from@example.com",
+				Subject: "Test Subject",
+				Body:    "Test Body",
+			},
+			expectedErr:       errNoRecipients,
+			expectDataCalled:  false,
+			expectResetCalled: true,
 		},
 	}
 
@@ -139,6 +161,7 @@ func TestSendEmail(t *testing.T) {
 			}
 
 			require.Equal(t, tt.expectDataCalled, tt.client.dataCalled, "Data called: got %v, want %v", tt.client.dataCalled, tt.expectDataCalled)
+			require.Equal(t, tt.expectResetCalled, tt.client.resetCalled)
 		})
 	}
 }
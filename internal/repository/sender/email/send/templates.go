@@ -0,0 +1,355 @@
+package send
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+// TemplateKind names the message a template set is for
+type TemplateKind string
+
+const (
+	TemplateRate         TemplateKind = "rate"
+	TemplateConfirmation TemplateKind = "confirmation"
+	TemplateAlert        TemplateKind = "alert"
+)
+
+const (
+	_subjectSuffix = ".subject.tmpl"
+	_textSuffix    = ".txt.tmpl"
+	_htmlSuffix    = ".html.tmpl"
+)
+
+var (
+	ErrMissingTemplate = errors.New("missing email template")
+	ErrInvalidTemplate = errors.New("invalid email template")
+)
+
+// The data every kind of templates is validated with
+var _templateKinds = map[TemplateKind]any{
+	TemplateRate:         TemplateData{},
+	TemplateConfirmation: ConfirmationTemplateData{},
+	TemplateAlert:        AlertTemplateData{},
+}
+
+type messageTemplate struct {
+	subject *template.Template
+	text    *template.Template
+	// Nil if the message has no HTML alternative
+	html *htmltemplate.Template
+}
+
+type localeTemplates map[TemplateKind]*messageTemplate
+
+// Templates holds the message templates of every locale. The templates
+// are read from a directory per locale, e.g. en/rate.subject.tmpl,
+// en/rate.txt.tmpl and the optional en/rate.html.tmpl. A locale lacking
+// a kind of message falls back to the default locale, which has them all.
+type Templates struct {
+	logger        port.Logger
+	fsys          fs.FS
+	defaultLocale string
+	interval      time.Duration
+
+	mu        sync.RWMutex
+	locales   map[string]localeTemplates
+	signature string
+}
+
+// NewTemplates loads and validates the templates, so a broken template
+// is reported on start rather than on the first send
+func NewTemplates(
+	config EmailConfig,
+	logger port.Logger,
+	fsys fs.FS,
+) (*Templates, error) {
+	t := &Templates{
+		logger:        logger,
+		fsys:          fsys,
+		defaultLocale: normalizeLocale(config.DefaultLocale),
+		interval:      config.TemplatesReloadInterval,
+	}
+
+	_, err := t.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Run reloads the templates whenever their files change. A broken change
+// is logged and the templates loaded before are kept.
+func (t *Templates) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			reloaded, err := t.Reload()
+			if err != nil {
+				t.logger.Errorf("Error, email templates weren't reloaded: %s", err)
+				continue
+			}
+
+			if reloaded {
+				t.logger.Infof("Email templates reloaded")
+			}
+		}
+	}
+}
+
+// Reload loads the templates if their files have changed since
+// the last load and tells whether they have
+func (t *Templates) Reload() (bool, error) {
+	signature, err := t.filesSignature()
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.RLock()
+	unchanged := signature == t.signature
+	t.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	locales, err := t.load()
+	if err != nil {
+		return false, err
+	}
+
+	t.mu.Lock()
+	t.locales = locales
+	t.signature = signature
+	t.mu.Unlock()
+
+	return true, nil
+}
+
+// Render executes the templates of the kind in the locale. The HTML body
+// is empty if the message has no HTML alternative.
+func (t *Templates) Render(
+	kind TemplateKind,
+	locale string,
+	data any,
+) (subject, text, html string, err error) {
+	tmpl := t.lookup(kind, normalizeLocale(locale))
+	if tmpl == nil {
+		return "", "", "", fmt.Errorf("%w: %s", ErrMissingTemplate, kind)
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.subject.Execute(&buffer, data); err != nil {
+		return "", "", "", err
+	}
+	// A subject must be a single line
+	subject = strings.Join(strings.Fields(buffer.String()), " ")
+
+	buffer.Reset()
+	if err := tmpl.text.Execute(&buffer, data); err != nil {
+		return "", "", "", err
+	}
+	text = buffer.String()
+
+	if tmpl.html != nil {
+		buffer.Reset()
+		if err := tmpl.html.Execute(&buffer, data); err != nil {
+			return "", "", "", err
+		}
+		html = buffer.String()
+	}
+
+	return subject, text, html, nil
+}
+
+func (t *Templates) lookup(kind TemplateKind, locale string) *messageTemplate {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if tmpl, ok := t.locales[locale][kind]; ok {
+		return tmpl
+	}
+
+	return t.locales[t.defaultLocale][kind]
+}
+
+func (t *Templates) load() (map[string]localeTemplates, error) {
+	entries, err := fs.ReadDir(t.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	locales := make(map[string]localeTemplates)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		locale := normalizeLocale(entry.Name())
+		templates, err := t.loadLocale(entry.Name(), locale == t.defaultLocale)
+		if err != nil {
+			return nil, err
+		}
+
+		locales[locale] = templates
+	}
+
+	if _, ok := locales[t.defaultLocale]; !ok {
+		return nil, fmt.Errorf(
+			"%w: no templates of the default locale %q",
+			ErrMissingTemplate,
+			t.defaultLocale,
+		)
+	}
+
+	return locales, nil
+}
+
+// loadLocale loads the templates of the locale's directory, the default
+// locale must have every kind of them
+func (t *Templates) loadLocale(dir string, isDefault bool) (localeTemplates, error) {
+	templates := make(localeTemplates)
+
+	for kind, data := range _templateKinds {
+		base := path.Join(dir, string(kind))
+
+		subject, err := readTemplate(t.fsys, base+_subjectSuffix)
+		if errors.Is(err, fs.ErrNotExist) && !isDefault {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		text, err := readTemplate(t.fsys, base+_textSuffix)
+		if err != nil {
+			return nil, err
+		}
+
+		html, err := readTemplate(t.fsys, base+_htmlSuffix)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		tmpl, err := parseMessageTemplate(base, subject, text, html)
+		if err != nil {
+			return nil, err
+		}
+
+		err = validateMessageTemplate(base, tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+
+		templates[kind] = tmpl
+	}
+
+	return templates, nil
+}
+
+func readTemplate(fsys fs.FS, name string) (string, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s: %w", ErrMissingTemplate, name, err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+func parseMessageTemplate(
+	base, subject, text, html string,
+) (*messageTemplate, error) {
+	var tmpl messageTemplate
+	var err error
+
+	tmpl.subject, err = template.New(base + _subjectSuffix).Parse(subject)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidTemplate, err)
+	}
+
+	tmpl.text, err = template.New(base + _textSuffix).Parse(text)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidTemplate, err)
+	}
+
+	if html == "" {
+		return &tmpl, nil
+	}
+
+	tmpl.html, err = htmltemplate.New(base + _htmlSuffix).Parse(html)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidTemplate, err)
+	}
+
+	return &tmpl, nil
+}
+
+// validateMessageTemplate executes the templates with empty data, which
+// catches the fields the data doesn't have
+func validateMessageTemplate(base string, tmpl *messageTemplate, data any) error {
+	err := tmpl.subject.Execute(io.Discard, data)
+	if err == nil {
+		err = tmpl.text.Execute(io.Discard, data)
+	}
+	if err == nil && tmpl.html != nil {
+		err = tmpl.html.Execute(io.Discard, data)
+	}
+
+	if err != nil {
+		return errors.Join(ErrInvalidTemplate, fmt.Errorf("%s: %w", base, err))
+	}
+
+	return nil
+}
+
+// filesSignature sums up the names, sizes and modification times
+// of the template files, it changes whenever any of them changes
+func (t *Templates) filesSignature() (string, error) {
+	var signature strings.Builder
+
+	err := fs.WalkDir(t.fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&signature, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+
+	return signature.String(), err
+}
+
+// normalizeLocale reduces the locale to its lower-case language,
+// e.g. uk-UA to uk
+func normalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if language, _, found := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-"); found {
+		return language
+	}
+
+	return locale
+}
@@ -0,0 +1,298 @@
+package send
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const _shippedTemplatesDir = "../../../../../templates/email"
+
+var _testEmailConfig = EmailConfig{
+	From:          "test_from@example.com",
+	DefaultLocale: "en",
+}
+
+type StubLogger struct{}
+
+func (s *StubLogger) Info(...interface{})           {}
+func (s *StubLogger) Infof(string, ...interface{})  {}
+func (s *StubLogger) Debug(...interface{})          {}
+func (s *StubLogger) Debugf(string, ...interface{}) {}
+func (s *StubLogger) Error(...interface{})          {}
+func (s *StubLogger) Errorf(string, ...interface{}) {}
+
+// newTestFiles returns the plain text templates of every kind
+// in the default locale, overridden by the files
+func newTestFiles(files fstest.MapFS) fstest.MapFS {
+	testFiles := fstest.MapFS{
+		"en/rate.subject.tmpl":         {Data: []byte("Test Subject")},
+		"en/rate.txt.tmpl":             {Data: []byte("{{.Rate}}")},
+		"en/confirmation.subject.tmpl": {Data: []byte("Test Subject")},
+		"en/confirmation.txt.tmpl":     {Data: []byte("{{.ConfirmationLink}}")},
+		"en/alert.subject.tmpl":        {Data: []byte("Test Subject")},
+		"en/alert.txt.tmpl":            {Data: []byte("{{.Message}}")},
+	}
+
+	for name, file := range files {
+		testFiles[name] = file
+	}
+
+	return testFiles
+}
+
+func newTestTemplates(t *testing.T, files fstest.MapFS) *Templates {
+	t.Helper()
+
+	templates, err := NewTemplates(_testEmailConfig, &StubLogger{}, newTestFiles(files))
+	require.NoError(t, err)
+
+	return templates
+}
+
+func TestNewTemplates(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		expectedErr error
+	}{
+		{
+			name: "Valid templates",
+		},
+		{
+			name: "Locale with some of the kinds",
+			files: fstest.MapFS{
+				"uk/rate.subject.tmpl": {Data: []byte("Курс")},
+				"uk/rate.txt.tmpl":     {Data: []byte("{{.Rate}}")},
+			},
+		},
+		{
+			name: "Missing default locale kind",
+			files: fstest.MapFS{
+				"en/alert.subject.tmpl": nil,
+			},
+			expectedErr: ErrMissingTemplate,
+		},
+		{
+			name: "Missing text body",
+			files: fstest.MapFS{
+				"uk/rate.subject.tmpl": {Data: []byte("Курс")},
+			},
+			expectedErr: ErrMissingTemplate,
+		},
+		{
+			name: "Syntax error",
+			files: fstest.MapFS{
+				"en/rate.txt.tmpl": {Data: []byte("{{.Rate")},
+			},
+			expectedErr: ErrInvalidTemplate,
+		},
+		{
+			name: "Unknown field",
+			files: fstest.MapFS{
+				"en/rate.html.tmpl": {Data: []byte("<p>{{.Price}}</p>")},
+			},
+			expectedErr: ErrInvalidTemplate,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			files := newTestFiles(tt.files)
+			for name, file := range files {
+				if file == nil {
+					delete(files, name)
+				}
+			}
+
+			_, err := NewTemplates(_testEmailConfig, &StubLogger{}, files)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestNewTemplatesMissingDefaultLocale(t *testing.T) {
+	config := _testEmailConfig
+	config.DefaultLocale = "de"
+
+	_, err := NewTemplates(config, &StubLogger{}, newTestFiles(nil))
+
+	require.ErrorIs(t, err, ErrMissingTemplate)
+}
+
+func TestTemplatesRender(t *testing.T) {
+	templates := newTestTemplates(t, fstest.MapFS{
+		"en/rate.subject.tmpl": {Data: []byte("{{.Base}}\n  rate\n")},
+		"uk/rate.subject.tmpl": {Data: []byte("Курс {{.Base}}")},
+		"uk/rate.txt.tmpl":     {Data: []byte("{{.Rate}} грн")},
+	})
+
+	tests := []struct {
+		name            string
+		kind            TemplateKind
+		locale          string
+		expectedSubject string
+		expectedText    string
+	}{
+		{
+			name:            "Default locale",
+			kind:            TemplateRate,
+			expectedSubject: "BTC rate",
+			expectedText:    "200",
+		},
+		{
+			name:            "Locale",
+			kind:            TemplateRate,
+			locale:          "uk",
+			expectedSubject: "Курс BTC",
+			expectedText:    "200 грн",
+		},
+		{
+			name:            "Locale with region",
+			kind:            TemplateRate,
+			locale:          "UK_ua",
+			expectedSubject: "Курс BTC",
+			expectedText:    "200 грн",
+		},
+		{
+			name:            "Unknown locale",
+			kind:            TemplateRate,
+			locale:          "de",
+			expectedSubject: "BTC rate",
+			expectedText:    "200",
+		},
+		{
+			name:            "Kind missing in locale",
+			kind:            TemplateAlert,
+			locale:          "uk",
+			expectedSubject: "Test Subject",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var data any = TemplateData{Rate: "200", Base: "BTC"}
+			if tt.kind == TemplateAlert {
+				data = AlertTemplateData{}
+			}
+
+			subject, text, html, err := templates.Render(tt.kind, tt.locale, data)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedSubject, subject)
+			require.Equal(t, tt.expectedText, text)
+			require.Empty(t, html)
+		})
+	}
+}
+
+func TestTemplatesReload(t *testing.T) {
+	files := newTestFiles(nil)
+	templates, err := NewTemplates(_testEmailConfig, &StubLogger{}, files)
+	require.NoError(t, err)
+
+	reloaded, err := templates.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	files["en/rate.txt.tmpl"] = &fstest.MapFile{
+		Data:    []byte("Rate {{.Rate}}"),
+		ModTime: time.Now(),
+	}
+
+	reloaded, err = templates.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+
+	_, text, _, err := templates.Render(TemplateRate, "", TemplateData{Rate: "200"})
+	require.NoError(t, err)
+	require.Equal(t, "Rate 200", text)
+
+	// A broken change keeps the templates loaded before
+	files["en/rate.txt.tmpl"] = &fstest.MapFile{
+		Data:    []byte("Rate {{.Rate"),
+		ModTime: time.Now().Add(time.Second),
+	}
+
+	_, err = templates.Reload()
+	require.ErrorIs(t, err, ErrInvalidTemplate)
+
+	_, text, _, err = templates.Render(TemplateRate, "", TemplateData{Rate: "200"})
+	require.NoError(t, err)
+	require.Equal(t, "Rate 200", text)
+}
+
+func TestShippedTemplates(t *testing.T) {
+	templates, err := NewTemplates(
+		_testEmailConfig,
+		&StubLogger{},
+		os.DirFS(_shippedTemplatesDir),
+	)
+	require.NoError(t, err)
+
+	for _, locale := range []string{"en", "uk"} {
+		for kind, data := range _templateKinds {
+			subject, text, html, err := templates.Render(kind, locale, data)
+
+			require.NoError(t, err)
+			require.NotEmpty(t, subject)
+			require.NotEmpty(t, text)
+			require.NotEmpty(t, html)
+		}
+	}
+}
+
+func TestShippedAlertTemplatesTranslated(t *testing.T) {
+	templates, err := NewTemplates(
+		_testEmailConfig,
+		&StubLogger{},
+		os.DirFS(_shippedTemplatesDir),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		data     AlertTemplateData
+		expected string
+	}{
+		{
+			name:     "Threshold crossed upwards",
+			data:     AlertTemplateData{Kind: "threshold", Pair: "BTC/UAH", Value: "100", Rose: true},
+			expected: "Курс BTC/UAH піднявся вище 100",
+		},
+		{
+			name:     "Threshold crossed downwards",
+			data:     AlertTemplateData{Kind: "threshold", Pair: "BTC/UAH", Value: "100"},
+			expected: "Курс BTC/UAH опустився нижче 100",
+		},
+		{
+			name:     "Rate changed",
+			data:     AlertTemplateData{Kind: "change", Pair: "BTC/UAH", Change: "+5.20%"},
+			expected: "Курс BTC/UAH змінився на +5.20% за 24 години",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.data.Message = "English message"
+
+			_, text, html, err := templates.Render(TemplateAlert, "uk", tt.data)
+
+			require.NoError(t, err)
+			require.Contains(t, text, tt.expected)
+			require.NotContains(t, text, tt.data.Message)
+			require.NotContains(t, html, tt.data.Message)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+var (
+	ErrUnencryptedConnection = errors.New("unencrypted connection")
+	ErrWrongHostName         = errors.New("wrong host name")
+	ErrUnexpectedChallenge   = errors.New("unexpected LOGIN challenge")
+)
+
+// loginAuth implements the LOGIN mechanism, which isn't in net/smtp but
+// is the only one some servers accept. Like smtp.PlainAuth, it sends the
+// credentials only over TLS or to localhost.
+type loginAuth struct {
+	user     string
+	password string
+	host     string
+}
+
+func newLoginAuth(user, password, host string) smtp.Auth {
+	return &loginAuth{user: user, password: password, host: host}
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, ErrUnencryptedConnection
+	}
+
+	if server.Name != a.host {
+		return "", nil, ErrWrongHostName
+	}
+
+	return "LOGIN", nil, nil
+}
+
+// Next answers the "Username:" and "Password:" challenges, some servers
+// word them differently, so only their start is matched
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	challenge := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(challenge, "user"):
+		return []byte(a.user), nil
+	case strings.HasPrefix(challenge, "pass"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedChallenge, fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,109 @@
+package smtp
+
+import (
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginAuthStart(t *testing.T) {
+	tests := []struct {
+		name        string
+		server      smtp.ServerInfo
+		expectedErr error
+	}{
+		{
+			name:   "TLS connection",
+			server: smtp.ServerInfo{Name: "smtp.example.com", TLS: true},
+		},
+		{
+			name:   "Plain connection to localhost",
+			server: smtp.ServerInfo{Name: "localhost"},
+		},
+		{
+			name:        "Plain connection",
+			server:      smtp.ServerInfo{Name: "smtp.example.com"},
+			expectedErr: ErrUnencryptedConnection,
+		},
+		{
+			name:        "Wrong host name",
+			server:      smtp.ServerInfo{Name: "other.example.com", TLS: true},
+			expectedErr: ErrWrongHostName,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			host := tt.server.Name
+			if tt.expectedErr == ErrWrongHostName {
+				host = "smtp.example.com"
+			}
+
+			auth := newLoginAuth("user", "password", host)
+			mechanism, initial, err := auth.Start(&tt.server)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr != nil {
+				return
+			}
+
+			require.Equal(t, "LOGIN", mechanism)
+			require.Nil(t, initial)
+		})
+	}
+}
+
+func TestLoginAuthNext(t *testing.T) {
+	tests := []struct {
+		name        string
+		challenge   string
+		more        bool
+		expected    []byte
+		expectedErr error
+	}{
+		{
+			name:      "Username",
+			challenge: "Username:",
+			more:      true,
+			expected:  []byte("user"),
+		},
+		{
+			name:      "Password",
+			challenge: "Password:",
+			more:      true,
+			expected:  []byte("password"),
+		},
+		{
+			name:      "Differently worded username",
+			challenge: "User Name",
+			more:      true,
+			expected:  []byte("user"),
+		},
+		{
+			name:        "Unexpected challenge",
+			challenge:   "Token:",
+			more:        true,
+			expectedErr: ErrUnexpectedChallenge,
+		},
+		{
+			name: "Done",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			auth := newLoginAuth("user", "password", "smtp.example.com")
+			response, err := auth.Next([]byte(tt.challenge), tt.more)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expected, response)
+		})
+	}
+}
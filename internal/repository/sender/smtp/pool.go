@@ -0,0 +1,216 @@
+package smtp
+
+import (
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPoolClosed       = errors.New("smtp connection pool is closed")
+	ErrReconnectBackoff = errors.New("waiting to reconnect to the smtp server")
+)
+
+// Connector opens an authenticated connection to the SMTP server
+type Connector interface {
+	Connect() (SMTPConnectionClient, error)
+}
+
+type pooledConnection struct {
+	client   SMTPConnectionClient
+	lastUsed time.Time
+	// The connection is checked before it's reused after a failed
+	// transaction, as the server may have dropped it
+	suspect bool
+}
+
+// Pool keeps the connections to the SMTP server open between the sends.
+// A connection is dialed when there's no idle one, a dropped one is
+// replaced, and the dials are backed off while the server is unavailable.
+type Pool struct {
+	config    SMTPConfig
+	connector Connector
+	now       func() time.Time
+
+	slots  chan struct{}
+	closed chan struct{}
+
+	mu       sync.Mutex
+	idle     []*pooledConnection
+	isClosed bool
+	failures int
+	retryAt  time.Time
+	lastErr  error
+}
+
+func NewPool(config SMTPConfig, connector Connector) *Pool {
+	size := config.PoolSize
+	if size < 1 {
+		size = 1
+	}
+
+	return &Pool{
+		config:    config,
+		connector: connector,
+		now:       time.Now,
+		slots:     make(chan struct{}, size),
+		closed:    make(chan struct{}),
+	}
+}
+
+// Do runs the send over a pooled connection, waiting for one to be free
+// if all of them are busy. The connection is dropped if the send fails
+// other than by a reply of the server.
+func (p *Pool) Do(send func(client SMTPConnectionClient) error) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.closed:
+		return ErrPoolClosed
+	}
+	defer func() { <-p.slots }()
+
+	conn, err := p.acquire()
+	if err != nil {
+		return err
+	}
+
+	err = send(conn.client)
+	p.release(conn, err)
+
+	return err
+}
+
+// Close quits the idle connections, the busy ones are quit once
+// their sends are done
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return nil
+	}
+
+	p.isClosed = true
+	idle := p.idle
+	p.idle = nil
+	close(p.closed)
+	p.mu.Unlock()
+
+	var errs []error
+	for _, conn := range idle {
+		if err := conn.client.Quit(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *Pool) acquire() (*pooledConnection, error) {
+	for {
+		conn, err := p.popIdle()
+		if err != nil {
+			return nil, err
+		}
+
+		if conn == nil {
+			return p.dial()
+		}
+
+		if p.healthy(conn) {
+			return conn, nil
+		}
+
+		conn.client.Close()
+	}
+}
+
+// popIdle takes the most recently used idle connection, which is
+// the least likely to be dropped by the server
+func (p *Pool) popIdle() (*pooledConnection, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isClosed {
+		return nil, ErrPoolClosed
+	}
+
+	if len(p.idle) == 0 {
+		return nil, nil
+	}
+
+	conn := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+
+	return conn, nil
+}
+
+func (p *Pool) healthy(conn *pooledConnection) bool {
+	if !conn.suspect && p.now().Sub(conn.lastUsed) < p.config.IdleCheck {
+		return true
+	}
+
+	return conn.client.Noop() == nil
+}
+
+func (p *Pool) dial() (*pooledConnection, error) {
+	p.mu.Lock()
+	if p.now().Before(p.retryAt) {
+		err := p.lastErr
+		p.mu.Unlock()
+		return nil, errors.Join(ErrReconnectBackoff, err)
+	}
+	p.mu.Unlock()
+
+	client, err := p.connector.Connect()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		p.failures++
+		p.retryAt = p.now().Add(p.backoff(p.failures))
+		p.lastErr = err
+		return nil, err
+	}
+
+	p.failures = 0
+	p.retryAt = time.Time{}
+	p.lastErr = nil
+
+	return &pooledConnection{client: client, lastUsed: p.now()}, nil
+}
+
+func (p *Pool) release(conn *pooledConnection, err error) {
+	var replyErr *textproto.Error
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.client.Close()
+		return
+	}
+
+	conn.lastUsed = p.now()
+	conn.suspect = err != nil
+
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		conn.client.Quit()
+		return
+	}
+
+	p.idle = append(p.idle, conn)
+	p.mu.Unlock()
+}
+
+func (p *Pool) backoff(failures int) time.Duration {
+	backoff := p.config.InitialBackoff
+	for i := 1; i < failures && backoff < p.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > p.config.MaxBackoff {
+		return p.config.MaxBackoff
+	}
+
+	return backoff
+}
@@ -0,0 +1,144 @@
+package smtp
+
+import (
+	"errors"
+	"io"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errServerUnavailable = errors.New("server unavailable")
+
+func newTestPool(connector Connector, now *time.Time) *Pool {
+	pool := NewPool(SMTPConfig{
+		PoolSize:       2,
+		IdleCheck:      30 * time.Second,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}, connector)
+	pool.now = func() time.Time { return *now }
+
+	return pool
+}
+
+func sendWith(err error) func(SMTPConnectionClient) error {
+	return func(SMTPConnectionClient) error { return err }
+}
+
+func TestPoolDo(t *testing.T) {
+	tests := []struct {
+		name             string
+		firstErr         error
+		idle             time.Duration
+		noopErr          error
+		expectedConnects int
+		expectedNoop     bool
+		expectedClose    bool
+	}{
+		{
+			name:             "Reuses connection",
+			expectedConnects: 1,
+		},
+		{
+			name:             "Checks connection after reply",
+			firstErr:         &textproto.Error{Code: 550, Msg: "mailbox unavailable"},
+			expectedConnects: 1,
+			expectedNoop:     true,
+		},
+		{
+			name:             "Drops broken connection",
+			firstErr:         io.EOF,
+			expectedConnects: 2,
+			expectedClose:    true,
+		},
+		{
+			name:             "Checks idle connection",
+			idle:             time.Minute,
+			expectedConnects: 1,
+			expectedNoop:     true,
+		},
+		{
+			name:             "Replaces dropped idle connection",
+			idle:             time.Minute,
+			noopErr:          io.EOF,
+			expectedConnects: 2,
+			expectedNoop:     true,
+			expectedClose:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &StubSMTPClient{noopErr: tt.noopErr}
+			connector := &StubConnector{
+				Clients: []SMTPConnectionClient{client, &StubSMTPClient{}},
+			}
+			now := time.Now()
+			pool := newTestPool(connector, &now)
+
+			err := pool.Do(sendWith(tt.firstErr))
+			require.Equal(t, tt.firstErr, err)
+
+			now = now.Add(tt.idle)
+
+			err = pool.Do(sendWith(nil))
+			require.NoError(t, err)
+
+			require.Equal(t, tt.expectedConnects, connector.Connects)
+			require.Equal(t, tt.expectedNoop, client.noopCalled)
+			require.Equal(t, tt.expectedClose, client.closeCalled)
+		})
+	}
+}
+
+func TestPoolBacksOffDials(t *testing.T) {
+	connector := &StubConnector{Err: errServerUnavailable}
+	now := time.Now()
+	pool := newTestPool(connector, &now)
+
+	err := pool.Do(sendWith(nil))
+	require.ErrorIs(t, err, errServerUnavailable)
+
+	err = pool.Do(sendWith(nil))
+	require.ErrorIs(t, err, ErrReconnectBackoff)
+	require.ErrorIs(t, err, errServerUnavailable)
+	require.Equal(t, 1, connector.Connects)
+
+	now = now.Add(time.Second)
+	connector.Err = nil
+	connector.Clients = []SMTPConnectionClient{&StubSMTPClient{}}
+
+	err = pool.Do(sendWith(nil))
+	require.NoError(t, err)
+	require.Equal(t, 2, connector.Connects)
+}
+
+func TestPoolBackoff(t *testing.T) {
+	pool := newTestPool(&StubConnector{}, &time.Time{})
+
+	require.Equal(t, time.Second, pool.backoff(1))
+	require.Equal(t, 4*time.Second, pool.backoff(3))
+	require.Equal(t, time.Minute, pool.backoff(10))
+}
+
+func TestPoolClose(t *testing.T) {
+	client := &StubSMTPClient{}
+	now := time.Now()
+	pool := newTestPool(
+		&StubConnector{Clients: []SMTPConnectionClient{client}},
+		&now,
+	)
+
+	require.NoError(t, pool.Do(sendWith(nil)))
+	require.NoError(t, pool.Close())
+	require.True(t, client.quitCalled)
+
+	err := pool.Do(sendWith(nil))
+	require.ErrorIs(t, err, ErrPoolClosed)
+}
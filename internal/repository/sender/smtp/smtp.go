@@ -2,36 +2,104 @@ package smtp
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/smtp"
+	"os"
 	"strconv"
+	"time"
+)
+
+// Security tells how the connection to the SMTP server is protected
+type Security string
+
+const (
+	// SecurityTLS starts TLS on connect, usually on port 465
+	SecurityTLS Security = "tls"
+	// SecurityStartTLS upgrades the plain connection with STARTTLS,
+	// usually on port 587
+	SecurityStartTLS Security = "starttls"
+	// SecurityNone keeps the connection plain, e.g. to a local relay
+	SecurityNone Security = "none"
+)
+
+// AuthMechanism is the SASL mechanism the client authenticates with
+type AuthMechanism string
+
+const (
+	AuthPlain   AuthMechanism = "plain"
+	AuthLogin   AuthMechanism = "login"
+	AuthCRAMMD5 AuthMechanism = "cram-md5"
+	AuthNone    AuthMechanism = "none"
+)
+
+const _startTLSExtension = "STARTTLS"
+
+var (
+	ErrUnknownSecurity      = errors.New("unknown smtp security mode")
+	ErrUnknownAuthMechanism = errors.New("unknown smtp auth mechanism")
+	ErrMissingCredentials   = errors.New("smtp user and password are required")
+	ErrInvalidCABundle      = errors.New("no certificates in the ca bundle")
+	ErrStartTLSUnsupported  = errors.New("smtp server doesn't support STARTTLS")
 )
 
 type SMTPConfig struct {
 	Host     string `required:"true"`
 	Port     int    `default:"465"`
-	User     string `required:"true"`
-	Password string `required:"true"`
+	User     string
+	Password string
+	// One of tls, starttls or none
+	Security Security `default:"tls"`
+	// One of plain, login, cram-md5 or none
+	Auth AuthMechanism `default:"plain"`
+	// The PEM file with the CA certificates the server certificate is
+	// verified with instead of the system ones
+	CAFile string
+	// Skips the verification of the server certificate, for testing only
+	TLSSkipVerify bool `default:"false"`
+	// How many connections are open at most, one message is sent
+	// over a connection at a time
+	PoolSize int `default:"2"`
+	// A connection idle for longer is checked with NOOP before it's reused
+	IdleCheck time.Duration `default:"30s"`
+	// The delay before dialing again after a failed dial, doubled
+	// for every next failure up to the maximum
+	InitialBackoff time.Duration `default:"1s"`
+	MaxBackoff     time.Duration `default:"1m"`
 }
 
-type TLSConnectionDialer interface {
-	Dial(network, addr string, config *tls.Config) (*tls.Conn, error)
+type ConnectionDialer interface {
+	Dial(network, addr string) (net.Conn, error)
+	DialTLS(network, addr string, config *tls.Config) (net.Conn, error)
 }
 
-type TLSConnectionDialerImpl struct{}
+type ConnectionDialerImpl struct{}
+
+func (d ConnectionDialerImpl) Dial(network, addr string) (net.Conn, error) {
+	return net.Dial(network, addr)
+}
 
-func (d TLSConnectionDialerImpl) Dial(network, addr string, config *tls.Config) (conn *tls.Conn, err error) {
+func (d ConnectionDialerImpl) DialTLS(
+	network, addr string,
+	config *tls.Config,
+) (net.Conn, error) {
 	return tls.Dial(network, addr, config)
 }
 
 type SMTPConnectionClient interface {
+	Extension(ext string) (bool, string)
+	StartTLS(config *tls.Config) error
 	Auth(a smtp.Auth) error
+	Noop() error
 	Quit() error
+	Close() error
 	Data() (io.WriteCloser, error)
 	Mail(string) error
 	Rcpt(string) error
+	Reset() error
 }
 
 type SMTPClientFactory interface {
@@ -52,65 +120,143 @@ type SMTPClient struct {
 	port              int
 	user              string
 	password          string
-	dialer            TLSConnectionDialer
+	security          Security
+	authMechanism     AuthMechanism
+	tlsConfig         *tls.Config
+	dialer            ConnectionDialer
 	smtpClientFactory SMTPClientFactory
 }
 
+// NewSMTPClient checks the security and auth settings and loads
+// the CA bundle, the server isn't connected to until Connect
 func NewSMTPClient(
 	config SMTPConfig,
-	dialer TLSConnectionDialer,
+	dialer ConnectionDialer,
 	factory SMTPClientFactory,
-) *SMTPClient {
+) (*SMTPClient, error) {
+	switch config.Security {
+	case SecurityTLS, SecurityStartTLS, SecurityNone:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSecurity, config.Security)
+	}
+
+	switch config.Auth {
+	case AuthPlain, AuthLogin, AuthCRAMMD5:
+		if config.User == "" || config.Password == "" {
+			return nil, ErrMissingCredentials
+		}
+	case AuthNone:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAuthMechanism, config.Auth)
+	}
+
+	tlsConfig, err := createTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &SMTPClient{
 		host:              config.Host,
 		port:              config.Port,
 		user:              config.User,
 		password:          config.Password,
+		security:          config.Security,
+		authMechanism:     config.Auth,
+		tlsConfig:         tlsConfig,
 		dialer:            dialer,
 		smtpClientFactory: factory,
-	}
+	}, nil
 }
 
-func (c *SMTPClient) createTLSConfig() *tls.Config {
-	return &tls.Config{
-		InsecureSkipVerify: true,
-		ServerName:         c.host,
+func createTLSConfig(config SMTPConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.Host,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.TLSSkipVerify,
 	}
+
+	if config.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	bundle, err := os.ReadFile(config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCABundle, config.CAFile)
+	}
+	tlsConfig.RootCAs = rootCAs
+
+	return tlsConfig, nil
 }
 
-func (c *SMTPClient) createConnection(tlsConfig *tls.Config) (*tls.Conn, error) {
-	conn, err := c.dialer.Dial(
-		"tcp",
-		fmt.Sprintf("%s:%s", c.host, strconv.Itoa(c.port)),
-		tlsConfig,
-	)
-	return conn, err
+func (c *SMTPClient) createConnection() (net.Conn, error) {
+	addr := net.JoinHostPort(c.host, strconv.Itoa(c.port))
+
+	if c.security == SecurityTLS {
+		return c.dialer.DialTLS("tcp", addr, c.tlsConfig)
+	}
+
+	return c.dialer.Dial("tcp", addr)
 }
 
-func (c *SMTPClient) createSMTPClient(conn *tls.Conn) (SMTPConnectionClient, error) {
+func (c *SMTPClient) createSMTPClient(conn net.Conn) (SMTPConnectionClient, error) {
 	client, err := c.smtpClientFactory.NewClient(conn, c.host)
 	return client, err
 }
 
+// startTLS upgrades the connection, the client doesn't fall back
+// to the plain one if the server can't
+func (c *SMTPClient) startTLS(client SMTPConnectionClient) error {
+	if ok, _ := client.Extension(_startTLSExtension); !ok {
+		return ErrStartTLSUnsupported
+	}
+
+	return client.StartTLS(c.tlsConfig)
+}
+
 func (c *SMTPClient) authenticate(client SMTPConnectionClient) error {
-	auth := smtp.PlainAuth("", c.user, c.password, c.host)
+	var auth smtp.Auth
+	switch c.authMechanism {
+	case AuthPlain:
+		auth = smtp.PlainAuth("", c.user, c.password, c.host)
+	case AuthLogin:
+		auth = newLoginAuth(c.user, c.password, c.host)
+	case AuthCRAMMD5:
+		auth = smtp.CRAMMD5Auth(c.user, c.password)
+	default:
+		return nil
+	}
+
 	return client.Auth(auth)
 }
 
 func (c *SMTPClient) Connect() (SMTPConnectionClient, error) {
-	tlsConfig := c.createTLSConfig()
-	conn, err := c.createConnection(tlsConfig)
+	conn, err := c.createConnection()
 	if err != nil {
 		return nil, err
 	}
 
 	client, err := c.createSMTPClient(conn)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
+	if c.security == SecurityStartTLS {
+		err = c.startTLS(client)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
 	err = c.authenticate(client)
 	if err != nil {
+		client.Close()
 		return nil, err
 	}
 
@@ -1,45 +1,52 @@
 package smtp
 
 import (
+	"encoding/pem"
 	"errors"
+	"net/http/httptest"
+	netsmtp "net/smtp"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 var (
 	errConnectionFailed = errors.New("failed to create connection")
 	errSMTPClientFailed = errors.New("failed to create SMTP client")
+	errStartTLSFailed   = errors.New("failed to start TLS")
 )
 
+var _testConfig = SMTPConfig{
+	Host:     "smtp.example.com",
+	Port:     587,
+	User:     "user@example.com",
+	Password: "password",
+	Security: SecurityTLS,
+	Auth:     AuthPlain,
+}
+
 func TestConnect(t *testing.T) {
 	tests := []struct {
 		name        string
-		dialer      TLSConnectionDialer
+		dialer      ConnectionDialer
 		factory     SMTPClientFactory
 		config      SMTPConfig
 		expectedErr error
 	}{
 		{
-			name:    "Successful Connection",
-			dialer:  &StubDialer{},
-			factory: &StubSMTPClientFactory{Client: &StubSMTPClient{}},
-			config: SMTPConfig{
-				Host:     "smtp.example.com",
-				Port:     587,
-				User:     "user@example.com",
-				Password: "password",
-			},
+			name:        "Successful Connection",
+			dialer:      &StubDialer{},
+			factory:     &StubSMTPClientFactory{Client: &StubSMTPClient{}},
+			config:      _testConfig,
 			expectedErr: nil,
 		},
 		{
-			name:    "Fail to create connection",
-			dialer:  &StubDialer{Err: errConnectionFailed},
-			factory: &StubSMTPClientFactory{Client: &StubSMTPClient{}},
-			config: SMTPConfig{
-				Host:     "smtp.example.com",
-				Port:     587,
-				User:     "user@example.com",
-				Password: "password",
-			},
+			name:        "Fail to create connection",
+			dialer:      &StubDialer{Err: errConnectionFailed},
+			factory:     &StubSMTPClientFactory{Client: &StubSMTPClient{}},
+			config:      _testConfig,
 			expectedErr: errConnectionFailed,
 		},
 		{
@@ -48,12 +55,7 @@ func TestConnect(t *testing.T) {
 			factory: &StubSMTPClientFactory{
 				Client: &StubSMTPClient{}, Err: errSMTPClientFailed,
 			},
-			config: SMTPConfig{
-				Host:     "smtp.example.com",
-				Port:     587,
-				User:     "user@example.com",
-				Password: "password",
-			},
+			config:      _testConfig,
 			expectedErr: errSMTPClientFailed,
 		},
 	}
@@ -63,7 +65,9 @@ func TestConnect(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			client := NewSMTPClient(tt.config, tt.dialer, tt.factory)
+			client, err := NewSMTPClient(tt.config, tt.dialer, tt.factory)
+			require.NoError(t, err)
+
 			smtpClient, err := client.Connect()
 
 			if err != nil && !errors.Is(err, tt.expectedErr) {
@@ -77,3 +81,240 @@ func TestConnect(t *testing.T) {
 		})
 	}
 }
+
+func TestConnectSecurity(t *testing.T) {
+	tests := []struct {
+		name             string
+		security         Security
+		client           *StubSMTPClient
+		expectedErr      error
+		expectedTLSDial  bool
+		expectedStartTLS bool
+	}{
+		{
+			name:            "Implicit TLS",
+			security:        SecurityTLS,
+			client:          &StubSMTPClient{},
+			expectedTLSDial: true,
+		},
+		{
+			name:     "STARTTLS",
+			security: SecurityStartTLS,
+			client: &StubSMTPClient{
+				extensions: map[string]bool{"STARTTLS": true},
+			},
+			expectedStartTLS: true,
+		},
+		{
+			name:        "STARTTLS unsupported",
+			security:    SecurityStartTLS,
+			client:      &StubSMTPClient{},
+			expectedErr: ErrStartTLSUnsupported,
+		},
+		{
+			name:     "STARTTLS failed",
+			security: SecurityStartTLS,
+			client: &StubSMTPClient{
+				extensions:  map[string]bool{"STARTTLS": true},
+				startTLSErr: errStartTLSFailed,
+			},
+			expectedErr:      errStartTLSFailed,
+			expectedStartTLS: true,
+		},
+		{
+			name:     "Plain connection",
+			security: SecurityNone,
+			client:   &StubSMTPClient{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := _testConfig
+			config.Security = tt.security
+			dialer := &StubDialer{}
+
+			client, err := NewSMTPClient(
+				config,
+				dialer,
+				&StubSMTPClientFactory{Client: tt.client},
+			)
+			require.NoError(t, err)
+
+			_, err = client.Connect()
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedTLSDial, dialer.dialedTLS)
+			require.Equal(t, tt.expectedStartTLS, tt.client.startTLSCalled)
+			require.Equal(t, tt.expectedErr != nil, tt.client.closeCalled)
+		})
+	}
+}
+
+func TestConnectAuth(t *testing.T) {
+	tests := []struct {
+		name              string
+		auth              AuthMechanism
+		expectedMechanism string
+	}{
+		{
+			name:              "PLAIN",
+			auth:              AuthPlain,
+			expectedMechanism: "PLAIN",
+		},
+		{
+			name:              "LOGIN",
+			auth:              AuthLogin,
+			expectedMechanism: "LOGIN",
+		},
+		{
+			name:              "CRAM-MD5",
+			auth:              AuthCRAMMD5,
+			expectedMechanism: "CRAM-MD5",
+		},
+		{
+			name: "None",
+			auth: AuthNone,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := _testConfig
+			config.Auth = tt.auth
+			smtpClient := &StubSMTPClient{}
+
+			client, err := NewSMTPClient(
+				config,
+				&StubDialer{},
+				&StubSMTPClientFactory{Client: smtpClient},
+			)
+			require.NoError(t, err)
+
+			_, err = client.Connect()
+			require.NoError(t, err)
+
+			if tt.expectedMechanism == "" {
+				require.False(t, smtpClient.authCalled)
+				return
+			}
+
+			mechanism, _, err := smtpClient.auth.Start(
+				&netsmtp.ServerInfo{Name: config.Host, TLS: true},
+			)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedMechanism, mechanism)
+		})
+	}
+}
+
+func TestNewSMTPClient(t *testing.T) {
+	dir := t.TempDir()
+
+	server := httptest.NewTLSServer(nil)
+	server.Close()
+
+	validBundle := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(validBundle, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0o600)
+	require.NoError(t, err)
+
+	invalidBundle := filepath.Join(dir, "invalid.pem")
+	err = os.WriteFile(invalidBundle, []byte("not a certificate"), 0o600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		updateConfig  func(config *SMTPConfig)
+		expectedErr   error
+		expectRootCAs bool
+	}{
+		{
+			name:         "Valid config",
+			updateConfig: func(config *SMTPConfig) {},
+		},
+		{
+			name: "Custom CA bundle",
+			updateConfig: func(config *SMTPConfig) {
+				config.CAFile = validBundle
+			},
+			expectRootCAs: true,
+		},
+		{
+			name: "Invalid CA bundle",
+			updateConfig: func(config *SMTPConfig) {
+				config.CAFile = invalidBundle
+			},
+			expectedErr: ErrInvalidCABundle,
+		},
+		{
+			name: "Missing CA bundle",
+			updateConfig: func(config *SMTPConfig) {
+				config.CAFile = filepath.Join(dir, "missing.pem")
+			},
+			expectedErr: os.ErrNotExist,
+		},
+		{
+			name: "Unknown security",
+			updateConfig: func(config *SMTPConfig) {
+				config.Security = "ssl"
+			},
+			expectedErr: ErrUnknownSecurity,
+		},
+		{
+			name: "Unknown auth mechanism",
+			updateConfig: func(config *SMTPConfig) {
+				config.Auth = "xoauth2"
+			},
+			expectedErr: ErrUnknownAuthMechanism,
+		},
+		{
+			name: "Missing credentials",
+			updateConfig: func(config *SMTPConfig) {
+				config.Password = ""
+			},
+			expectedErr: ErrMissingCredentials,
+		},
+		{
+			name: "No credentials without auth",
+			updateConfig: func(config *SMTPConfig) {
+				config.User = ""
+				config.Password = ""
+				config.Auth = AuthNone
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := _testConfig
+			tt.updateConfig(&config)
+
+			client, err := NewSMTPClient(
+				config,
+				&StubDialer{},
+				&StubSMTPClientFactory{Client: &StubSMTPClient{}},
+			)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr != nil {
+				return
+			}
+
+			require.False(t, client.tlsConfig.InsecureSkipVerify)
+			require.Equal(t, config.Host, client.tlsConfig.ServerName)
+			require.Equal(t, tt.expectRootCAs, client.tlsConfig.RootCAs != nil)
+		})
+	}
+}
@@ -21,31 +21,61 @@ func (m *StubWriteCloser) Write(data []byte) (int, error) {
 }
 
 type StubSMTPClient struct {
-	authCalled bool
-	quitCalled bool
-	dataCalled bool
-	mailCalled bool
-	rcptCalled bool
-
-	authErr error
-	quitErr error
-	dataErr error
-	MailErr error
-	rcptErr error
+	startTLSCalled bool
+	authCalled     bool
+	noopCalled     bool
+	quitCalled     bool
+	closeCalled    bool
+	dataCalled     bool
+	mailCalled     bool
+	rcptCalled     bool
+	resetCalled    bool
+
+	// The extensions the server advertises
+	extensions map[string]bool
+	auth       smtp.Auth
+
+	startTLSErr error
+	authErr     error
+	noopErr     error
+	quitErr     error
+	dataErr     error
+	MailErr     error
+	rcptErr     error
 
 	writer io.WriteCloser
 }
 
+func (m *StubSMTPClient) Extension(ext string) (bool, string) {
+	return m.extensions[ext], ""
+}
+
+func (m *StubSMTPClient) StartTLS(config *tls.Config) error {
+	m.startTLSCalled = true
+	return m.startTLSErr
+}
+
 func (m *StubSMTPClient) Auth(a smtp.Auth) error {
 	m.authCalled = true
+	m.auth = a
 	return m.authErr
 }
 
+func (m *StubSMTPClient) Noop() error {
+	m.noopCalled = true
+	return m.noopErr
+}
+
 func (m *StubSMTPClient) Quit() error {
 	m.quitCalled = true
 	return m.quitErr
 }
 
+func (m *StubSMTPClient) Close() error {
+	m.closeCalled = true
+	return nil
+}
+
 func (m *StubSMTPClient) Data() (io.WriteCloser, error) {
 	m.dataCalled = true
 	m.writer = &StubWriteCloser{}
@@ -62,12 +92,34 @@ func (m *StubSMTPClient) Rcpt(to string) error {
 	return m.rcptErr
 }
 
+func (m *StubSMTPClient) Reset() error {
+	m.resetCalled = true
+	return nil
+}
+
+// StubDialer returns one end of a pipe in place of the connection
 type StubDialer struct {
 	Err error
+
+	dialedTLS bool
+}
+
+func (d *StubDialer) Dial(network string, addr string) (net.Conn, error) {
+	if d.Err != nil {
+		return nil, d.Err
+	}
+
+	conn, _ := net.Pipe()
+	return conn, nil
 }
 
-func (d *StubDialer) Dial(network string, addr string, config *tls.Config) (*tls.Conn, error) {
-	return nil, d.Err
+func (d *StubDialer) DialTLS(
+	network string,
+	addr string,
+	config *tls.Config,
+) (net.Conn, error) {
+	d.dialedTLS = true
+	return d.Dial(network, addr)
 }
 
 type StubSMTPClientFactory struct {
@@ -81,3 +133,26 @@ func (f StubSMTPClientFactory) NewClient(
 ) (SMTPConnectionClient, error) {
 	return f.Client, f.Err
 }
+
+// StubConnector returns the next of the clients on every connect,
+// or the error if it's set
+type StubConnector struct {
+	Clients []SMTPConnectionClient
+	Err     error
+
+	Connects int
+}
+
+func (c *StubConnector) Connect() (SMTPConnectionClient, error) {
+	c.Connects++
+	if c.Err != nil {
+		return nil, c.Err
+	}
+
+	client := c.Clients[0]
+	if len(c.Clients) > 1 {
+		c.Clients = c.Clients[1:]
+	}
+
+	return client, nil
+}
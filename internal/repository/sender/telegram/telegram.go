@@ -0,0 +1,333 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+const (
+	_sendMessageMethod = "sendMessage"
+	_getUpdatesMethod  = "getUpdates"
+
+	_templateSuffix = ".tmpl"
+)
+
+var (
+	ErrHTTPRequestFailure   = errors.New("http request failure")
+	ErrUnexpectedStatusCode = errors.New("unexpected status code")
+	ErrBotAPI               = errors.New("telegram bot api error")
+	errParseTemplate        = errors.New("parse template error")
+	errExecuteTemplate      = errors.New("cannot execute message")
+)
+
+type TelegramConfig struct {
+	// Send the rate to the linked chats and answer the bot commands
+	Enabled bool `default:"false"`
+	// The token BotFather has issued for the bot
+	Token  string
+	APIURL string `default:"https://api.telegram.org"`
+	// How long the Bot API holds a poll waiting for new messages
+	PollTimeout time.Duration `default:"30s"`
+	// The message in the languages without a translation
+	Message string `default:"The {{.Base}} to {{.Quote}} exchange rate is {{.Rate}} {{.Quote}} per {{.Base}}"`
+	// The directory with the translated messages, a file per language,
+	// e.g. uk.tmpl
+	TemplatesDir string `default:"./templates/telegram"`
+}
+
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type TemplateData struct {
+	Rate  string
+	Base  string
+	Quote string
+}
+
+type Provider struct {
+	config     TelegramConfig
+	httpClient HTTPClient
+	message    *template.Template
+	// The translated messages by language
+	translations map[string]*template.Template
+}
+
+// NewProvider parses the messages, so a broken one is reported on start.
+// The translations are read from the fsys, which may not exist.
+func NewProvider(
+	config TelegramConfig,
+	httpClient HTTPClient,
+	fsys fs.FS,
+) (*Provider, error) {
+	message, err := parseTemplate("message", config.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	translations, err := loadTranslations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		config:       config,
+		httpClient:   httpClient,
+		message:      message,
+		translations: translations,
+	}, nil
+}
+
+// SendExchangeRate sends the rate in their language to the subscribers
+// who have linked a chat and haven't chosen email only, the others are
+// skipped. The subscribers the rate wasn't sent to are reported by
+// a port.DeliveryError.
+func (p *Provider) SendExchangeRate(
+	rate port.Rate,
+	subscribers []port.User,
+) error {
+	data := TemplateData{
+		Rate:  fmt.Sprintf("%.2f", rate.Value),
+		Base:  rate.Pair.Base,
+		Quote: rate.Pair.Quote,
+	}
+	texts := make(map[string]string)
+
+	sent := 0
+	var failures []port.DeliveryFailure
+	for _, subscriber := range subscribers {
+		if subscriber.TelegramChatID == 0 ||
+			!subscriber.Preferences.WantsChannel(port.ChannelTelegram) {
+			continue
+		}
+
+		text, err := p.text(texts, subscriber.Preferences.Language, data)
+		if err != nil {
+			return err
+		}
+
+		err = p.SendMessage(context.Background(), subscriber.TelegramChatID, text)
+		if err != nil {
+			failures = append(failures, port.DeliveryFailure{
+				Channel:   port.ChannelTelegram,
+				Recipient: subscriber.Email,
+				Err:       err,
+			})
+			continue
+		}
+		sent++
+	}
+
+	return port.NewDeliveryError(sent, failures)
+}
+
+// SendMessage sends the text to the chat
+func (p *Provider) SendMessage(ctx context.Context, chatID int64, text string) error {
+	return p.call(ctx, _sendMessageMethod, sendMessageRequest{
+		ChatID: chatID,
+		Text:   text,
+	}, nil)
+}
+
+// Updates long polls the messages sent to the bot starting with
+// the offset update
+func (p *Provider) Updates(ctx context.Context, offset int64) ([]port.ChatMessage, error) {
+	var updates []update
+	err := p.call(ctx, _getUpdatesMethod, getUpdatesRequest{
+		Offset:         offset,
+		Timeout:        int(p.config.PollTimeout / time.Second),
+		AllowedUpdates: []string{"message"},
+	}, &updates)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]port.ChatMessage, 0, len(updates))
+	for _, u := range updates {
+		message := port.ChatMessage{UpdateID: u.UpdateID}
+		// Other kinds of updates still have to be skipped by the offset
+		if u.Message != nil {
+			message.ChatID = u.Message.Chat.ID
+			message.Text = u.Message.Text
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func (p *Provider) call(ctx context.Context, method string, request, result any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		p.methodURL(method),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return errors.Join(ErrHTTPRequestFailure, ctx.Err())
+	}
+	defer resp.Body.Close()
+
+	var response apiResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatusCode, resp.StatusCode)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !response.OK {
+		return fmt.Errorf("%w: %s: %s", ErrBotAPI, method, response.Description)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
+
+func (p *Provider) methodURL(method string) string {
+	return strings.TrimSuffix(p.config.APIURL, "/") +
+		"/bot" + p.config.Token + "/" + method
+}
+
+// text returns the message in the language, the default one if it isn't
+// translated. The texts keep the messages executed by the send.
+func (p *Provider) text(
+	texts map[string]string,
+	language string,
+	data TemplateData,
+) (string, error) {
+	language = strings.ToLower(language)
+	if text, ok := texts[language]; ok {
+		return text, nil
+	}
+
+	tmpl, ok := p.translations[language]
+	if !ok {
+		tmpl = p.message
+	}
+
+	text, err := executeTemplate(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+	texts[language] = text
+
+	return text, nil
+}
+
+// loadTranslations parses the <language>.tmpl files of the fsys
+func loadTranslations(fsys fs.FS) (map[string]*template.Template, error) {
+	translations := make(map[string]*template.Template)
+	if fsys == nil {
+		return translations, nil
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if errors.Is(err, fs.ErrNotExist) {
+		return translations, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		language, ok := strings.CutSuffix(entry.Name(), _templateSuffix)
+		if entry.IsDir() || !ok {
+			continue
+		}
+
+		text, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		// A single line message shouldn't end with the file's newline
+		tmpl, err := parseTemplate(entry.Name(), strings.TrimRight(string(text), "\n"))
+		if err != nil {
+			return nil, err
+		}
+
+		translations[strings.ToLower(language)] = tmpl
+	}
+
+	return translations, nil
+}
+
+// parseTemplate parses the message and checks that it can be executed
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, errors.Join(err, errParseTemplate)
+	}
+
+	if _, err = executeTemplate(tmpl, TemplateData{}); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+func executeTemplate(tmpl *template.Template, data TemplateData) (string, error) {
+	var message bytes.Buffer
+	if err := tmpl.Execute(&message, data); err != nil {
+		return "", errors.Join(err, errExecuteTemplate)
+	}
+
+	return message.String(), nil
+}
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type sendMessageRequest struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+type getUpdatesRequest struct {
+	Offset         int64    `json:"offset,omitempty"`
+	Timeout        int      `json:"timeout"`
+	AllowedUpdates []string `json:"allowed_updates"`
+}
+
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type message struct {
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
@@ -0,0 +1,207 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+const _testToken = "123:secret"
+
+// fakeBotAPI serves the Bot API methods the provider calls
+type fakeBotAPI struct {
+	mu       sync.Mutex
+	sent     []sendMessageRequest
+	polls    []getUpdatesRequest
+	updates  string
+	failChat int64
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/bot" + _testToken + "/" + _sendMessageMethod:
+		var request sendMessageRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+
+		if request.ChatID == f.failChat {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"ok":false,"description":"Forbidden: bot was blocked by the user"}`))
+			return
+		}
+
+		f.sent = append(f.sent, request)
+		_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+	case "/bot" + _testToken + "/" + _getUpdatesMethod:
+		var request getUpdatesRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+
+		f.polls = append(f.polls, request)
+		_, _ = w.Write([]byte(`{"ok":true,"result":` + f.updates + `}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"ok":false,"description":"Not Found"}`))
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakeBotAPI) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	provider, err := NewProvider(
+		TelegramConfig{
+			Token:       _testToken,
+			APIURL:      server.URL,
+			PollTimeout: 0,
+			Message:     "{{.Base}}/{{.Quote}}: {{.Rate}}",
+		},
+		server.Client(),
+		fstest.MapFS{
+			"uk.tmpl":   {Data: []byte("Курс {{.Base}}/{{.Quote}}: {{.Rate}}\n")},
+			"README.md": {Data: []byte("Not a message")},
+		},
+	)
+	require.NoError(t, err)
+
+	return provider
+}
+
+func TestSendExchangeRate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		failChat     int64
+		expectedSent []sendMessageRequest
+		expectedErr  error
+	}{
+		{
+			name: "Send to the linked chats",
+			expectedSent: []sendMessageRequest{
+				{ChatID: 1, Text: "BTC/UAH: 1000000.50"},
+				{ChatID: 2, Text: "BTC/UAH: 1000000.50"},
+				{ChatID: 4, Text: "Курс BTC/UAH: 1000000.50"},
+				{ChatID: 5, Text: "BTC/UAH: 1000000.50"},
+			},
+		},
+		{
+			name:     "Blocked bot",
+			failChat: 1,
+			expectedSent: []sendMessageRequest{
+				{ChatID: 2, Text: "BTC/UAH: 1000000.50"},
+				{ChatID: 4, Text: "Курс BTC/UAH: 1000000.50"},
+				{ChatID: 5, Text: "BTC/UAH: 1000000.50"},
+			},
+			expectedErr: ErrBotAPI,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fake := &fakeBotAPI{failChat: tt.failChat}
+			provider := newTestProvider(t, fake)
+
+			err := provider.SendExchangeRate(
+				port.Rate{Pair: port.DefaultCurrencyPair, Value: 1000000.5},
+				[]port.User{
+					{Email: "first@example.com", TelegramChatID: 1},
+					{Email: "email.only@example.com"},
+					{
+						Email:          "email.preferred@example.com",
+						TelegramChatID: 3,
+						Preferences:    port.Preferences{Channel: port.ChannelEmail},
+					},
+					{Email: "second@example.com", TelegramChatID: 2},
+					{
+						Email:          "ukrainian@example.com",
+						TelegramChatID: 4,
+						Preferences:    port.Preferences{Language: "uk"},
+					},
+					{
+						Email:          "untranslated@example.com",
+						TelegramChatID: 5,
+						Preferences:    port.Preferences{Language: "de"},
+					},
+				},
+			)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedSent, fake.sent)
+		})
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeBotAPI{updates: `[
+		{"update_id":10,"message":{"chat":{"id":42},"text":"/start code"}},
+		{"update_id":11,"edited_message":{"chat":{"id":42},"text":"/stop"}}
+	]`}
+	provider := newTestProvider(t, fake)
+
+	messages, err := provider.Updates(context.Background(), 10)
+
+	require.NoError(t, err)
+	require.Equal(t, []port.ChatMessage{
+		{UpdateID: 10, ChatID: 42, Text: "/start code"},
+		{UpdateID: 11},
+	}, messages)
+	require.Equal(t, int64(10), fake.polls[0].Offset)
+}
+
+func TestUnknownToken(t *testing.T) {
+	t.Parallel()
+
+	provider := newTestProvider(t, &fakeBotAPI{})
+	provider.config.Token = "unknown"
+
+	err := provider.SendMessage(context.Background(), 42, "text")
+
+	require.ErrorIs(t, err, ErrBotAPI)
+}
+
+func TestNewProviderInvalidMessage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		message string
+		fsys    fstest.MapFS
+	}{
+		{
+			name:    "Invalid message",
+			message: "{{.Rate",
+		},
+		{
+			name:    "Invalid translation",
+			message: "{{.Rate}}",
+			fsys:    fstest.MapFS{"uk.tmpl": {Data: []byte("{{.Unknown}}")}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewProvider(TelegramConfig{Message: tt.message}, http.DefaultClient, tt.fsys)
+			require.Error(t, err)
+		})
+	}
+}
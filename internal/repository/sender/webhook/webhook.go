@@ -0,0 +1,410 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+const (
+	_deliveryIDSize = 8
+
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	// The hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the secret
+	// of the webhook, prefixed with "sha256="
+	SignatureHeader = "X-Webhook-Signature"
+
+	_signaturePrefix = "sha256="
+)
+
+var (
+	ErrHTTPRequestFailure   = errors.New("http request failure")
+	ErrUnexpectedStatusCode = errors.New("unexpected status code")
+	ErrForbiddenAddress     = errors.New("webhook address is not public")
+)
+
+type WebhookSenderConfig struct {
+	// The timeout of a single attempt
+	Timeout     time.Duration `default:"5s"`
+	MaxAttempts int           `default:"4"`
+	// The delay before the first retry, doubled for every next one
+	InitialBackoff time.Duration `default:"1s"`
+	MaxBackoff     time.Duration `default:"30s"`
+	// How often the retries are checked for the due ones
+	PollInterval time.Duration `default:"1s"`
+}
+
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Registry provides the webhooks and keeps the delivery attempts
+type Registry interface {
+	Webhooks() ([]port.Webhook, error)
+	Record(delivery *port.WebhookDelivery) error
+}
+
+// Payload is the JSON body posted to the webhooks
+type Payload struct {
+	Rate      float32   `json:"rate"`
+	Pair      string    `json:"pair"`
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Timestamp time.Time `json:"timestamp"`
+	// The providers the rate is based on, comma separated
+	Provider string `json:"provider"`
+}
+
+// retry is the next attempt of a failed delivery
+type retry struct {
+	webhookID  string
+	deliveryID string
+	body       []byte
+	attempt    int
+	// The delay before the attempt after this one
+	backoff time.Duration
+	dueAt   time.Time
+}
+
+type Provider struct {
+	config     WebhookSenderConfig
+	logger     port.Logger
+	httpClient HTTPClient
+	registry   Registry
+	now        func() time.Time
+
+	mu      sync.Mutex
+	retries []retry
+}
+
+// NewHTTPClient returns the client which refuses to connect to
+// the addresses that aren't public. They are checked once the host is
+// resolved, so a host changed to point to the private network after
+// the webhook is registered isn't reached either, nor is a redirect.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: controlAddress}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be checked instead of the webhook
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func NewProvider(
+	config WebhookSenderConfig,
+	logger port.Logger,
+	httpClient HTTPClient,
+	registry Registry,
+) *Provider {
+	return &Provider{
+		config:     config,
+		logger:     logger,
+		httpClient: httpClient,
+		registry:   registry,
+		now:        time.Now,
+	}
+}
+
+// SendExchangeRate posts the rate to the webhooks of the subscribers
+// who haven't chosen another channel only. The webhooks are posted to
+// at once, making a single attempt, so the send doesn't wait for
+// the backoffs. The webhooks which haven't accepted the rate are reported
+// by a port.DeliveryError, the retryable failures are retried by Run.
+func (p *Provider) SendExchangeRate(rate port.Rate, subscribers []port.User) error {
+	all, err := p.registry.Webhooks()
+	if err != nil {
+		return err
+	}
+
+	owners := make(map[string]bool, len(subscribers))
+	for _, subscriber := range subscribers {
+		if subscriber.Preferences.WantsChannel(port.ChannelWebhook) {
+			owners[subscriber.Email] = true
+		}
+	}
+
+	webhooks := make([]port.Webhook, 0, len(all))
+	for _, webhook := range all {
+		if owners[webhook.Email] {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	timestamp := rate.FetchedAt
+	if timestamp.IsZero() {
+		timestamp = p.now()
+	}
+
+	body, err := json.Marshal(Payload{
+		Rate:      rate.Value,
+		Pair:      rate.Pair.String(),
+		Base:      rate.Pair.Base,
+		Quote:     rate.Pair.Quote,
+		Timestamp: timestamp.UTC(),
+		Provider:  strings.Join(rate.Providers, ","),
+	})
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(webhooks))
+
+	var wg sync.WaitGroup
+	for i := range webhooks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = p.send(&webhooks[i], body)
+		}(i)
+	}
+	wg.Wait()
+
+	sent := 0
+	var failures []port.DeliveryFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, port.DeliveryFailure{
+				Channel:   port.ChannelWebhook,
+				Recipient: webhooks[i].ID,
+				Err:       err,
+			})
+			continue
+		}
+		sent++
+	}
+
+	return port.NewDeliveryError(sent, failures)
+}
+
+// Run retries the failed deliveries every poll interval until
+// the context is canceled. The retries are kept in memory, so the ones
+// pending on a stop are dropped.
+func (p *Provider) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := p.Retry(); err != nil {
+				p.logger.Errorf("Error, webhook retry: %v", err)
+			}
+		}
+	}
+}
+
+// Retry makes the due attempts at once and waits for them. The retries
+// of the removed webhooks are dropped.
+func (p *Provider) Retry() error {
+	due := p.takeDue(p.now())
+	if len(due) == 0 {
+		return nil
+	}
+
+	webhooks, err := p.registry.Webhooks()
+	if err != nil {
+		p.schedule(due...)
+		return err
+	}
+
+	byID := make(map[string]*port.Webhook, len(webhooks))
+	for i := range webhooks {
+		byID[webhooks[i].ID] = &webhooks[i]
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range due {
+		webhook, ok := byID[r.webhookID]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(r retry) {
+			defer wg.Done()
+
+			if err := p.deliver(webhook, r); err != nil {
+				p.logger.Errorf(
+					"Error, webhook %s delivery %s attempt %d: %v",
+					r.webhookID, r.deliveryID, r.attempt, err,
+				)
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// send makes the first attempt of a new delivery of the body
+func (p *Provider) send(webhook *port.Webhook, body []byte) error {
+	deliveryID, err := randomHex(_deliveryIDSize)
+	if err != nil {
+		return err
+	}
+
+	return p.deliver(webhook, retry{
+		webhookID:  webhook.ID,
+		deliveryID: deliveryID,
+		body:       body,
+		attempt:    1,
+		backoff:    p.config.InitialBackoff,
+	})
+}
+
+// deliver makes the attempt and schedules the next one after the backoff
+// if it can be retried: the network errors, the server errors and
+// the throttled requests are, while the attempts are left
+func (p *Provider) deliver(webhook *port.Webhook, r retry) error {
+	delivery := p.post(webhook, r.deliveryID, r.body)
+	delivery.Attempt = r.attempt
+
+	if err := p.registry.Record(&delivery); err != nil {
+		p.logger.Errorf("Error, webhook delivery record: %v", err)
+	}
+
+	if delivery.Succeeded() {
+		return nil
+	}
+
+	if r.attempt < p.config.MaxAttempts && retryable(&delivery) {
+		r.dueAt = p.now().Add(r.backoff)
+		r.attempt++
+		if r.backoff *= 2; r.backoff > p.config.MaxBackoff {
+			r.backoff = p.config.MaxBackoff
+		}
+		p.schedule(r)
+	}
+
+	return deliveryError(&delivery)
+}
+
+func (p *Provider) schedule(retries ...retry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.retries = append(p.retries, retries...)
+}
+
+// takeDue removes the retries due at the time and returns them
+func (p *Provider) takeDue(now time.Time) []retry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var due []retry
+	kept := p.retries[:0]
+	for _, r := range p.retries {
+		if r.dueAt.After(now) {
+			kept = append(kept, r)
+			continue
+		}
+		due = append(due, r)
+	}
+	p.retries = kept
+
+	return due
+}
+
+func (p *Provider) post(
+	webhook *port.Webhook,
+	deliveryID string,
+	body []byte,
+) port.WebhookDelivery {
+	start := p.now()
+	delivery := port.WebhookDelivery{
+		ID:          deliveryID,
+		WebhookID:   webhook.ID,
+		DeliveredAt: start.UTC(),
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := p.httpClient.Do(req)
+	delivery.Duration = p.now().Sub(start)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	return delivery
+}
+
+// Sign returns the value of the signature header, so the receivers
+// can check the payload came from the application
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return _signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func controlAddress(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !port.IsPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+
+	return nil
+}
+
+func retryable(delivery *port.WebhookDelivery) bool {
+	return delivery.StatusCode == 0 ||
+		delivery.StatusCode == http.StatusTooManyRequests ||
+		delivery.StatusCode >= http.StatusInternalServerError
+}
+
+func deliveryError(delivery *port.WebhookDelivery) error {
+	if delivery.StatusCode == 0 {
+		return fmt.Errorf("%w: %s", ErrHTTPRequestFailure, delivery.Error)
+	}
+
+	return fmt.Errorf("%w: %d", ErrUnexpectedStatusCode, delivery.StatusCode)
+}
+
+func randomHex(size int) (string, error) {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(value), nil
+}
@@ -0,0 +1,360 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+var errRegistry = errors.New("registry error")
+
+// The owner of the test webhooks
+var _subscribers = []port.User{{Email: "test@example.com"}}
+
+type StubLogger struct{}
+
+func (s *StubLogger) Info(...interface{})           {}
+func (s *StubLogger) Infof(string, ...interface{})  {}
+func (s *StubLogger) Debug(...interface{})          {}
+func (s *StubLogger) Debugf(string, ...interface{}) {}
+func (s *StubLogger) Error(...interface{})          {}
+func (s *StubLogger) Errorf(string, ...interface{}) {}
+
+type StubRegistry struct {
+	webhooks   []port.Webhook
+	err        error
+	mu         sync.Mutex
+	deliveries []port.WebhookDelivery
+}
+
+func (s *StubRegistry) Webhooks() ([]port.Webhook, error) {
+	return s.webhooks, s.err
+}
+
+func (s *StubRegistry) Record(delivery *port.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries = append(s.deliveries, *delivery)
+	return nil
+}
+
+// receiver answers with the statuses in turn and keeps the requests
+type receiver struct {
+	statuses []int
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+
+	status := r.statuses[min(len(r.requests), len(r.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func TestSendExchangeRate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		statuses         []int
+		expectedStatuses []int
+		expectedBackoffs []time.Duration
+		expectedErr      error
+	}{
+		{
+			name:             "Accepted at once",
+			statuses:         []int{http.StatusOK},
+			expectedStatuses: []int{http.StatusOK},
+		},
+		{
+			name: "Accepted after retries",
+			statuses: []int{
+				http.StatusServiceUnavailable,
+				http.StatusTooManyRequests,
+				http.StatusNoContent,
+			},
+			expectedStatuses: []int{
+				http.StatusServiceUnavailable,
+				http.StatusTooManyRequests,
+				http.StatusNoContent,
+			},
+			expectedBackoffs: []time.Duration{time.Second, 2 * time.Second},
+			expectedErr:      ErrUnexpectedStatusCode,
+		},
+		{
+			name:     "Retries run out",
+			statuses: []int{http.StatusInternalServerError},
+			expectedStatuses: []int{
+				http.StatusInternalServerError,
+				http.StatusInternalServerError,
+				http.StatusInternalServerError,
+				http.StatusInternalServerError,
+			},
+			expectedBackoffs: []time.Duration{
+				time.Second,
+				2 * time.Second,
+				3 * time.Second,
+			},
+			expectedErr: ErrUnexpectedStatusCode,
+		},
+		{
+			name:             "Rejected without retries",
+			statuses:         []int{http.StatusGone},
+			expectedStatuses: []int{http.StatusGone},
+			expectedErr:      ErrUnexpectedStatusCode,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			receiver := &receiver{statuses: tt.statuses}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			registry := &StubRegistry{webhooks: []port.Webhook{{
+				ID:     "webhook1",
+				URL:    server.URL + "/rate",
+				Secret: "secret",
+				Email:  "test@example.com",
+			}}}
+			provider := NewProvider(
+				WebhookSenderConfig{
+					Timeout:        time.Second,
+					MaxAttempts:    4,
+					InitialBackoff: time.Second,
+					MaxBackoff:     3 * time.Second,
+				},
+				&StubLogger{},
+				server.Client(),
+				registry,
+			)
+
+			fetchedAt := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+			now := fetchedAt
+			provider.now = func() time.Time { return now }
+
+			// The send makes only the first attempt
+			err := provider.SendExchangeRate(port.Rate{
+				Pair:      port.DefaultCurrencyPair,
+				Value:     1000000.5,
+				Providers: []string{"BinanceRateProvider"},
+				FetchedAt: fetchedAt,
+			}, _subscribers)
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.Len(t, registry.deliveries, 1)
+
+			for i := 0; i < 10; i++ {
+				now = now.Add(time.Second)
+				require.NoError(t, provider.Retry())
+			}
+
+			var statuses []int
+			var backoffs []time.Duration
+			for i, delivery := range registry.deliveries {
+				require.Equal(t, registry.deliveries[0].ID, delivery.ID)
+				require.Equal(t, "webhook1", delivery.WebhookID)
+				require.Equal(t, i+1, delivery.Attempt)
+				statuses = append(statuses, delivery.StatusCode)
+
+				if i > 0 {
+					backoffs = append(
+						backoffs,
+						delivery.DeliveredAt.Sub(registry.deliveries[i-1].DeliveredAt),
+					)
+				}
+			}
+			require.Equal(t, tt.expectedStatuses, statuses)
+			require.Equal(t, tt.expectedBackoffs, backoffs)
+			require.Empty(t, provider.retries)
+
+			req := receiver.requests[0]
+			require.Equal(t, "/rate", req.URL.Path)
+			require.Equal(t, registry.deliveries[0].ID, req.Header.Get(DeliveryHeader))
+			require.Equal(t, Sign(
+				"secret",
+				req.Header.Get(TimestampHeader),
+				receiver.bodies[0],
+			), req.Header.Get(SignatureHeader))
+
+			var payload Payload
+			require.NoError(t, json.Unmarshal(receiver.bodies[0], &payload))
+			require.Equal(t, Payload{
+				Rate:      1000000.5,
+				Pair:      "BTC/UAH",
+				Base:      "BTC",
+				Quote:     "UAH",
+				Timestamp: fetchedAt,
+				Provider:  "BinanceRateProvider",
+			}, payload)
+		})
+	}
+}
+
+func TestSendExchangeRateNetworkError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	registry := &StubRegistry{webhooks: []port.Webhook{{
+		ID:    "webhook1",
+		URL:   server.URL,
+		Email: "test@example.com",
+	}}}
+	provider := NewProvider(
+		WebhookSenderConfig{MaxAttempts: 2},
+		&StubLogger{},
+		server.Client(),
+		registry,
+	)
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	err := provider.SendExchangeRate(port.Rate{Pair: port.DefaultCurrencyPair}, _subscribers)
+
+	require.ErrorIs(t, err, ErrHTTPRequestFailure)
+	require.Len(t, registry.deliveries, 1)
+
+	now = now.Add(time.Second)
+	require.NoError(t, provider.Retry())
+
+	require.Len(t, registry.deliveries, 2)
+	require.NotEmpty(t, registry.deliveries[1].Error)
+	require.Zero(t, registry.deliveries[1].StatusCode)
+}
+
+func TestSendExchangeRateToSubscribersWebhooks(t *testing.T) {
+	t.Parallel()
+
+	receiver := &receiver{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	registry := &StubRegistry{webhooks: []port.Webhook{
+		{ID: "subscriber", URL: server.URL, Email: "subscriber@example.com"},
+		{ID: "email-only", URL: server.URL, Email: "email@example.com"},
+		{ID: "not-due", URL: server.URL, Email: "other@example.com"},
+		{ID: "ownerless", URL: server.URL},
+	}}
+	provider := NewProvider(
+		WebhookSenderConfig{MaxAttempts: 1},
+		&StubLogger{},
+		server.Client(),
+		registry,
+	)
+
+	err := provider.SendExchangeRate(port.Rate{Pair: port.DefaultCurrencyPair}, []port.User{
+		{Email: "subscriber@example.com"},
+		{
+			Email:       "email@example.com",
+			Preferences: port.Preferences{Channel: port.ChannelEmail},
+		},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, registry.deliveries, 1)
+	require.Equal(t, "subscriber", registry.deliveries[0].WebhookID)
+}
+
+func TestRetryDropsRemovedWebhooks(t *testing.T) {
+	t.Parallel()
+
+	receiver := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	registry := &StubRegistry{webhooks: []port.Webhook{{
+		ID:    "webhook1",
+		URL:   server.URL,
+		Email: "test@example.com",
+	}}}
+	provider := NewProvider(
+		WebhookSenderConfig{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: time.Second},
+		&StubLogger{},
+		server.Client(),
+		registry,
+	)
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+
+	err := provider.SendExchangeRate(port.Rate{Pair: port.DefaultCurrencyPair}, _subscribers)
+	require.ErrorIs(t, err, ErrUnexpectedStatusCode)
+	require.Len(t, provider.retries, 1)
+
+	// Not due yet
+	require.NoError(t, provider.Retry())
+	require.Len(t, registry.deliveries, 1)
+
+	registry.webhooks = nil
+	now = now.Add(time.Second)
+	require.NoError(t, provider.Retry())
+
+	require.Len(t, registry.deliveries, 1)
+	require.Empty(t, provider.retries)
+}
+
+func TestNewHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	t.Parallel()
+
+	// The test server listens on the loopback address
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := NewHTTPClient(time.Second).Get(server.URL)
+
+	require.ErrorIs(t, err, ErrForbiddenAddress)
+}
+
+func TestSendExchangeRateRegistryError(t *testing.T) {
+	t.Parallel()
+
+	provider := NewProvider(
+		WebhookSenderConfig{MaxAttempts: 1},
+		&StubLogger{},
+		http.DefaultClient,
+		&StubRegistry{err: errRegistry},
+	)
+
+	err := provider.SendExchangeRate(port.Rate{Pair: port.DefaultCurrencyPair}, nil)
+
+	require.ErrorIs(t, err, errRegistry)
+}
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(
+		t,
+		"sha256=c3972b99d4a3dd6137d7c0c855408b57df4b4f417d04c8805eb82c1e1be216ed",
+		Sign("secret", "1688202000", []byte(`{"rate":1}`)),
+	)
+}
@@ -0,0 +1,49 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+type SignatureConfig struct {
+	Secret string `required:"true"`
+}
+
+// HMACSigner signs values with HMAC-SHA256, so the application can
+// verify a value it gave out earlier without storing it
+type HMACSigner struct {
+	secret []byte
+}
+
+func NewHMACSigner(config SignatureConfig) *HMACSigner {
+	return &HMACSigner{secret: []byte(config.Secret)}
+}
+
+// WithPurpose returns the signer whose signatures are only valid
+// for the purpose, e.g. the management token isn't the unsubscribe one.
+// Its secret is derived from this one and the purpose, so the same
+// value gets a different signature for every purpose.
+func (s *HMACSigner) WithPurpose(purpose string) *HMACSigner {
+	return &HMACSigner{secret: s.mac(purpose)}
+}
+
+// Sign returns the URL-safe signature of the value
+func (s *HMACSigner) Sign(value string) string {
+	return base64.RawURLEncoding.EncodeToString(s.mac(value))
+}
+
+func (s *HMACSigner) Verify(value, signature string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(decoded, s.mac(value))
+}
+
+func (s *HMACSigner) mac(value string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,82 @@
+package signature
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMACSigner(t *testing.T) {
+	signer := NewHMACSigner(SignatureConfig{Secret: "secret"})
+	otherSigner := NewHMACSigner(SignatureConfig{Secret: "other secret"})
+	purposeSigner := signer.WithPurpose("manage")
+
+	signature := signer.Sign("test@example.com")
+
+	tests := []struct {
+		name      string
+		signer    *HMACSigner
+		value     string
+		signature string
+		expected  bool
+	}{
+		{
+			name:      "Valid signature",
+			signer:    signer,
+			value:     "test@example.com",
+			signature: signature,
+			expected:  true,
+		},
+		{
+			name:      "Signature of another value",
+			signer:    signer,
+			value:     "other@example.com",
+			signature: signature,
+			expected:  false,
+		},
+		{
+			name:      "Signature with another secret",
+			signer:    otherSigner,
+			value:     "test@example.com",
+			signature: signature,
+			expected:  false,
+		},
+		{
+			name:      "Signature for another purpose",
+			signer:    purposeSigner,
+			value:     "test@example.com",
+			signature: signature,
+			expected:  false,
+		},
+		{
+			name:      "Signature for the purpose",
+			signer:    purposeSigner,
+			value:     "test@example.com",
+			signature: purposeSigner.Sign("test@example.com"),
+			expected:  true,
+		},
+		{
+			name:      "Signature of the purpose without it",
+			signer:    signer,
+			value:     "test@example.com",
+			signature: purposeSigner.Sign("test@example.com"),
+			expected:  false,
+		},
+		{
+			name:      "Malformed signature",
+			signer:    signer,
+			value:     "test@example.com",
+			signature: "%%%",
+			expected:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.expected, tt.signer.Verify(tt.value, tt.signature))
+		})
+	}
+}
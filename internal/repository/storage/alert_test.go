@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAlertStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	sqliteStorage, err := NewSQLiteAlertStorage(filepath.Join(dir, "storage.db"))
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { sqliteStorage.Close() })
+
+	tests := []struct {
+		name    string
+		storage interface {
+			AppendUnique(key string, record map[string]string) error
+			AllRecords() ([]map[string]string, error)
+			Update(key, value string, record map[string]string) error
+		}
+	}{
+		{name: "CSV", storage: NewCSVAlertStorage(filepath.Join(dir, "alerts.csv"))},
+		{name: "SQLite", storage: sqliteStorage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := map[string]string{
+				"id":                "alert1",
+				"email":             "example@test.com",
+				"base":              "BTC",
+				"quote":             "UAH",
+				"kind":              "threshold",
+				"value":             "1000000",
+				"cooldown":          "",
+				"last_rate":         "",
+				"last_triggered_at": "",
+			}
+			if err := tt.storage.AppendUnique("id", record); err != nil {
+				t.Fatalf("failed to append data: %v", err)
+			}
+
+			record["last_rate"] = "999999.5"
+			if err := tt.storage.Update("id", "alert1", record); err != nil {
+				t.Fatalf("failed to update data: %v", err)
+			}
+
+			readData, err := tt.storage.AllRecords()
+			if err != nil {
+				t.Fatalf("failed to read data: %v", err)
+			}
+
+			if diff := cmp.Diff([]map[string]string{record}, readData); diff != "" {
+				t.Errorf("read data does not match written data (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
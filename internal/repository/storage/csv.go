@@ -2,51 +2,326 @@ package storage
 
 import (
 	"encoding/csv"
+	"errors"
 	"os"
-)
+	"path/filepath"
+	"sync"
 
-var _headers = []string{"email"} // The order of the columns keys
+	"golang.org/x/exp/slices"
 
-type StorageConfig struct {
-	Path string `default:"./storage/storage.csv"`
-}
+	"gses2-app/internal/core/port"
+)
 
+const (
+	_lockFileSuffix = ".lock"
+	// The permissions of a file the storage creates
+	_defaultFileMode os.FileMode = 0644
+)
+
+// CSVStorage is safe for concurrent use by goroutines and by processes
+// sharing the file: the mutex guards it within the process and the lock
+// file guards it from other processes. The lock is kept on a separate
+// file since the rewrites replace the storage file.
 type CSVStorage struct {
 	FilePath string
+
+	// The known columns, the subscriber ones if not set
+	knownColumns []string
+	mu           sync.RWMutex
 }
 
 func NewCSVStorage(filePath string) *CSVStorage {
-	return &CSVStorage{FilePath: filePath}
+	return &CSVStorage{FilePath: filePath, knownColumns: _headers}
+}
+
+// NewCSVAlertStorage keeps the alert rules in the file
+func NewCSVAlertStorage(filePath string) *CSVStorage {
+	return &CSVStorage{FilePath: filePath, knownColumns: _alertHeaders}
+}
+
+// NewCSVWebhookStorage keeps the webhooks in the file
+func NewCSVWebhookStorage(filePath string) *CSVStorage {
+	return &CSVStorage{FilePath: filePath, knownColumns: _webhookHeaders}
+}
+
+// NewCSVWebhookDeliveryStorage keeps the webhook delivery attempts
+// in the file
+func NewCSVWebhookDeliveryStorage(filePath string) *CSVStorage {
+	return &CSVStorage{FilePath: filePath, knownColumns: _webhookDeliveryHeaders}
+}
+
+// NewCSVOutboxStorage keeps the emails waiting to be sent in the file
+func NewCSVOutboxStorage(filePath string) *CSVStorage {
+	return &CSVStorage{FilePath: filePath, knownColumns: _outboxHeaders}
+}
+
+// NewCSVJobStorage keeps the send jobs in the file
+func NewCSVJobStorage(filePath string) *CSVStorage {
+	return &CSVStorage{FilePath: filePath, knownColumns: _jobHeaders}
+}
+
+func (s *CSVStorage) AllRecords() (records []map[string]string, err error) {
+	err = s.withLock(false, func() error {
+		table, err := s.load()
+		records = table.records
+		return err
+	})
+
+	return records, err
+}
+
+// Append adds the record as a new row. A record with a column the file
+// doesn't have yet makes the file be rewritten with the column added.
+func (s *CSVStorage) Append(record map[string]string) error {
+	return s.withLock(true, func() error {
+		table, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		return s.append(table, record)
+	})
 }
 
-func (s *CSVStorage) AllRecords() ([]map[string]string, error) {
+// AppendAll adds the records as new rows loading the file once
+func (s *CSVStorage) AppendAll(records []map[string]string) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return s.withLock(true, func() error {
+		table, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		return s.append(table, records...)
+	})
+}
+
+// AppendUnique appends the record unless a record with the same value
+// of the key is already stored, in which case port.ErrDuplicateRecord
+// is returned. The check and the append are done under the same lock.
+func (s *CSVStorage) AppendUnique(key string, record map[string]string) error {
+	return s.withLock(true, func() error {
+		table, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range table.records {
+			if r[key] == record[key] {
+				return port.ErrDuplicateRecord
+			}
+		}
+
+		return s.append(table, record)
+	})
+}
+
+// Update replaces all the records whose value of the key matches the value.
+// The columns missing in the record keep their values.
+func (s *CSVStorage) Update(key, value string, record map[string]string) error {
+	return s.withLock(true, func() error {
+		table, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range table.records {
+			if r[key] != value {
+				continue
+			}
+
+			// The columns unknown to the caller are kept
+			for k, v := range record {
+				r[k] = v
+			}
+		}
+
+		return s.rewrite(table)
+	})
+}
+
+// Delete removes all the records whose value of the key matches the value
+func (s *CSVStorage) Delete(key, value string) error {
+	return s.withLock(true, func() error {
+		table, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		kept := table.records[:0]
+		for _, r := range table.records {
+			if r[key] != value {
+				kept = append(kept, r)
+			}
+		}
+		table.records = kept
+
+		return s.rewrite(table)
+	})
+}
+
+// UpdateAll replaces every stored record whose value of the key matches
+// the value of the key in one of the records, rewriting the file once.
+// The columns missing in the records keep their values.
+func (s *CSVStorage) UpdateAll(key string, records []map[string]string) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	updates := make(map[string]map[string]string, len(records))
+	for _, record := range records {
+		updates[record[key]] = record
+	}
+
+	return s.withLock(true, func() error {
+		table, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range table.records {
+			record, ok := updates[r[key]]
+			if !ok {
+				continue
+			}
+
+			for k, v := range record {
+				r[k] = v
+			}
+		}
+
+		return s.rewrite(table)
+	})
+}
+
+// DeleteAll removes the records whose value of the key is one of
+// the values, rewriting the file once
+func (s *CSVStorage) DeleteAll(key string, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	deleted := make(map[string]bool, len(values))
+	for _, value := range values {
+		deleted[value] = true
+	}
+
+	return s.withLock(true, func() error {
+		table, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		kept := table.records[:0]
+		for _, r := range table.records {
+			if !deleted[r[key]] {
+				kept = append(kept, r)
+			}
+		}
+		table.records = kept
+
+		return s.rewrite(table)
+	})
+}
+
+// withLock runs the fn holding the exclusive lock for writing
+// or the shared one for reading
+func (s *CSVStorage) withLock(exclusive bool, fn func() error) error {
+	if exclusive {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	unlock, err := lockFile(s.FilePath+_lockFileSuffix, exclusive)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return fn()
+}
+
+// csvTable is the content of the storage file
+type csvTable struct {
+	header  []string
+	records []map[string]string
+	// The file has no header row, it's migrated on the next write
+	legacy bool
+}
+
+// load reads the file mapping the columns by the header row. The columns
+// missing in the file are read as empty and the unknown ones are kept.
+// A missing or empty file gives an empty table.
+func (s *CSVStorage) load() (*csvTable, error) {
+	table := &csvTable{records: []map[string]string{}}
+
 	f, err := os.Open(s.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return table, nil
+	}
+
 	if err != nil {
-		return nil, err
+		return table, err
 	}
 	defer f.Close()
 
 	r := csv.NewReader(f)
-	records, err := r.ReadAll()
+	// Legacy rows written before a column was added are shorter
+	r.FieldsPerRecord = -1
+
+	rows, err := r.ReadAll()
 	if err != nil {
-		return nil, err
+		return table, err
 	}
 
-	maps := make([]map[string]string, 0, len(records))
+	if len(rows) == 0 {
+		return table, nil
+	}
 
-	for _, record := range records {
-		rowMap := make(map[string]string, len(_headers))
-		for i, key := range _headers {
-			rowMap[key] = record[i]
+	columns := s.columns()
+	if isHeader(rows[0], columns) {
+		table.header, rows = rows[0], rows[1:]
+	} else {
+		table.header, table.legacy = columns, true
+	}
+
+	for _, row := range rows {
+		record := make(map[string]string, len(table.header))
+		for _, key := range columns {
+			record[key] = ""
+		}
+
+		for i, value := range row {
+			if i < len(table.header) {
+				record[table.header[i]] = value
+			}
 		}
-		maps = append(maps, rowMap)
+		table.records = append(table.records, record)
 	}
 
-	return maps, nil
+	return table, nil
 }
 
-func (s *CSVStorage) Append(record map[string]string) error {
-	f, err := os.OpenFile(s.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// append adds the rows to the end of the file if the file already has
+// all the columns of the records, otherwise the whole file is rewritten
+func (s *CSVStorage) append(table *csvTable, records ...map[string]string) error {
+	rewrite := table.header == nil || table.legacy
+	for _, record := range records {
+		rewrite = rewrite || hasNewColumns(table.header, record)
+	}
+
+	if rewrite {
+		table.records = append(table.records, records...)
+		return s.rewrite(table)
+	}
+
+	f, err := os.OpenFile(s.FilePath, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
@@ -54,16 +329,153 @@ func (s *CSVStorage) Append(record map[string]string) error {
 
 	w := csv.NewWriter(f)
 
-	// Build a slice of values based on the order of the keys
-	values := make([]string, 0, len(_headers))
-	for _, key := range _headers {
-		values = append(values, record[key])
+	for _, record := range records {
+		if err = w.Write(recordToRow(table.header, record)); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+
+	if err = w.Error(); err != nil {
+		return err
 	}
 
-	if err = w.Write(values); err != nil {
+	return f.Sync()
+}
+
+// rewrite writes the header row and the records to a temporary file
+// which then replaces the storage file, so a failure never leaves it
+// half-written. The header gets the columns of all the records.
+func (s *CSVStorage) rewrite(table *csvTable) error {
+	header := mergeColumns(s.columns(), table.header, table.records)
+
+	mode, err := fileMode(s.FilePath)
+	if err != nil {
 		return err
 	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.FilePath), filepath.Base(s.FilePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := csv.NewWriter(tmp)
+	if err = w.Write(header); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	for _, record := range table.records {
+		if err = w.Write(recordToRow(header, record)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
 	w.Flush()
 
-	return w.Error()
+	if err = w.Error(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// The temporary file is only readable by the owner
+	if err = tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	// The data must reach the disk before the rename makes it visible
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.FilePath)
+}
+
+// fileMode returns the permissions of the file a rewrite replaces,
+// the default ones if it doesn't exist yet
+func fileMode(path string) (os.FileMode, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return _defaultFileMode, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Mode().Perm(), nil
+}
+
+func (s *CSVStorage) columns() []string {
+	if s.knownColumns == nil {
+		return _headers
+	}
+
+	return s.knownColumns
+}
+
+// A header row is told apart from a legacy row by the name of the first
+// known column, e.g. a legacy row has the email address in the email one
+func isHeader(row []string, columns []string) bool {
+	for _, cell := range row {
+		if cell == columns[0] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasNewColumns(header []string, record map[string]string) bool {
+	for key := range record {
+		if !slices.Contains(header, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mergeColumns returns the known columns followed by the file's unknown
+// ones and then the new columns of the records sorted by name
+func mergeColumns(
+	known []string,
+	header []string,
+	records []map[string]string,
+) []string {
+	columns := slices.Clone(known)
+	for _, column := range header {
+		if !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+
+	var added []string
+	for _, record := range records {
+		for key := range record {
+			if !slices.Contains(columns, key) && !slices.Contains(added, key) {
+				added = append(added, key)
+			}
+		}
+	}
+	slices.Sort(added)
+
+	return append(columns, added...)
+}
+
+// Build a slice of values based on the order of the header
+func recordToRow(header []string, record map[string]string) []string {
+	values := make([]string, 0, len(header))
+	for _, key := range header {
+		values = append(values, record[key])
+	}
+
+	return values
 }
@@ -1,10 +1,15 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+
+	"gses2-app/internal/core/port"
 )
 
 func setup(t *testing.T) (*CSVStorage, func()) {
@@ -17,6 +22,7 @@ func setup(t *testing.T) (*CSVStorage, func()) {
 
 	return storage, func() {
 		os.Remove(tmpfile.Name())
+		os.Remove(tmpfile.Name() + _lockFileSuffix)
 	}
 }
 
@@ -37,7 +43,17 @@ func TestCSVStorageAllRecords(t *testing.T) {
 	storage, teardown := setup(t)
 	defer teardown()
 
-	data := map[string]string{"email": "example@test.com"}
+	data := map[string]string{
+		"email":                   "example@test.com",
+		"status":                  "active",
+		"confirmation_token":      "",
+		"confirmation_expires_at": "",
+		"telegram_chat_id":        "",
+		"language":                "",
+		"pairs":                   "",
+		"cadence":                 "",
+		"channel":                 "",
+	}
 	if err := storage.Append(data); err != nil {
 		t.Fatalf("failed to append data: %v", err)
 	}
@@ -53,3 +69,380 @@ func TestCSVStorageAllRecords(t *testing.T) {
 		}
 	})
 }
+
+func TestCSVStorageDelete(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	for _, email := range []string{"first@test.com", "second@test.com"} {
+		if err := storage.Append(map[string]string{"email": email}); err != nil {
+			t.Fatalf("failed to append data: %v", err)
+		}
+	}
+
+	t.Run("Delete data from storage", func(t *testing.T) {
+		if err := storage.Delete("email", "first@test.com"); err != nil {
+			t.Fatalf("failed to delete data: %v", err)
+		}
+
+		readData, err := storage.AllRecords()
+		if err != nil {
+			t.Fatalf("failed to read data: %v", err)
+		}
+
+		expected := []map[string]string{{
+			"email":                   "second@test.com",
+			"status":                  "",
+			"confirmation_token":      "",
+			"confirmation_expires_at": "",
+			"telegram_chat_id":        "",
+			"language":                "",
+			"pairs":                   "",
+			"cadence":                 "",
+			"channel":                 "",
+		}}
+		if diff := cmp.Diff(expected, readData); diff != "" {
+			t.Errorf("read data does not match expected data (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestCSVStorageUpdate(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	for _, email := range []string{"first@test.com", "second@test.com"} {
+		record := map[string]string{"email": email, "status": "pending"}
+		if err := storage.Append(record); err != nil {
+			t.Fatalf("failed to append data: %v", err)
+		}
+	}
+
+	t.Run("Update data in storage", func(t *testing.T) {
+		updated := map[string]string{
+			"email":                   "first@test.com",
+			"status":                  "active",
+			"confirmation_token":      "",
+			"confirmation_expires_at": "",
+			"telegram_chat_id":        "",
+			"language":                "",
+			"pairs":                   "",
+			"cadence":                 "",
+			"channel":                 "",
+		}
+		if err := storage.Update("email", "first@test.com", updated); err != nil {
+			t.Fatalf("failed to update data: %v", err)
+		}
+
+		readData, err := storage.AllRecords()
+		if err != nil {
+			t.Fatalf("failed to read data: %v", err)
+		}
+
+		expected := []map[string]string{
+			updated,
+			{
+				"email":                   "second@test.com",
+				"status":                  "pending",
+				"confirmation_token":      "",
+				"confirmation_expires_at": "",
+				"telegram_chat_id":        "",
+				"language":                "",
+				"pairs":                   "",
+				"cadence":                 "",
+				"channel":                 "",
+			},
+		}
+		if diff := cmp.Diff(expected, readData); diff != "" {
+			t.Errorf("read data does not match expected data (-want +got):\n%s", diff)
+		}
+	})
+}
+
+func TestCSVStorageLegacyRows(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	if err := os.WriteFile(storage.FilePath, []byte("legacy@test.com\n"), 0644); err != nil {
+		t.Fatalf("failed to write legacy data: %v", err)
+	}
+
+	if err := storage.Append(map[string]string{"email": "new@test.com", "status": "active"}); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+
+	t.Run("Read legacy and new rows", func(t *testing.T) {
+		readData, err := storage.AllRecords()
+		if err != nil {
+			t.Fatalf("failed to read data: %v", err)
+		}
+
+		if len(readData) != 2 || readData[0]["email"] != "legacy@test.com" ||
+			readData[0]["status"] != "" || readData[1]["status"] != "active" {
+			t.Errorf("unexpected data: %v", readData)
+		}
+	})
+}
+
+func TestCSVStorageAppendUnique(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	record := map[string]string{"email": "example@test.com"}
+	if err := storage.AppendUnique("email", record); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+
+	err := storage.AppendUnique("email", record)
+	if !errors.Is(err, port.ErrDuplicateRecord) {
+		t.Fatalf("expected %v, got %v", port.ErrDuplicateRecord, err)
+	}
+}
+
+func TestCSVStorageAppendUniqueMissingFile(t *testing.T) {
+	storage := NewCSVStorage(t.TempDir() + "/storage.csv")
+
+	record := map[string]string{"email": "example@test.com"}
+	if err := storage.AppendUnique("email", record); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+}
+
+func TestCSVStorageConcurrentAppendUnique(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	// A separate instance has its own mutex, so only the file lock
+	// guards the file as it would between processes
+	otherStorage := NewCSVStorage(storage.FilePath)
+
+	const writers = 40
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		duplicates int
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			s := storage
+			if i%2 == 1 {
+				s = otherStorage
+			}
+
+			// Every email is appended by both instances
+			email := fmt.Sprintf("user%d@test.com", i/2)
+			err := s.AppendUnique("email", map[string]string{"email": email})
+			if errors.Is(err, port.ErrDuplicateRecord) {
+				mu.Lock()
+				duplicates++
+				mu.Unlock()
+				return
+			}
+
+			if err != nil {
+				t.Errorf("failed to append data: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	if len(readData) != writers/2 || duplicates != writers/2 {
+		t.Errorf(
+			"expected %d records and duplicates, got %d records and %d duplicates",
+			writers/2, len(readData), duplicates,
+		)
+	}
+}
+
+func TestCSVStorageHeader(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	if err := storage.Append(map[string]string{"email": "first@test.com"}); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+	if err := storage.Append(map[string]string{"email": "second@test.com"}); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+
+	content, err := os.ReadFile(storage.FilePath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	expected := "email,status,confirmation_token,confirmation_expires_at,telegram_chat_id,language,pairs,cadence,channel\n" +
+		"first@test.com,,,,,,,,\n" +
+		"second@test.com,,,,,,,,\n"
+	if diff := cmp.Diff(expected, string(content)); diff != "" {
+		t.Errorf("file content does not match (-want +got):\n%s", diff)
+	}
+}
+
+func TestCSVStorageMapsColumnsByName(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	content := "status,locale,email\n" +
+		"active,uk,first@test.com\n" +
+		"pending\n"
+	if err := os.WriteFile(storage.FilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write data: %v", err)
+	}
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	expected := []map[string]string{
+		{
+			"email":                   "first@test.com",
+			"status":                  "active",
+			"confirmation_token":      "",
+			"confirmation_expires_at": "",
+			"telegram_chat_id":        "",
+			"language":                "",
+			"pairs":                   "",
+			"cadence":                 "",
+			"channel":                 "",
+			"locale":                  "uk",
+		},
+		{
+			"email":                   "",
+			"status":                  "pending",
+			"confirmation_token":      "",
+			"confirmation_expires_at": "",
+			"telegram_chat_id":        "",
+			"language":                "",
+			"pairs":                   "",
+			"cadence":                 "",
+			"channel":                 "",
+		},
+	}
+	if diff := cmp.Diff(expected, readData); diff != "" {
+		t.Errorf("read data does not match expected data (-want +got):\n%s", diff)
+	}
+}
+
+func TestCSVStorageKeepsUnknownColumns(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	content := "email,locale\nfirst@test.com,uk\n"
+	if err := os.WriteFile(storage.FilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write data: %v", err)
+	}
+
+	updated := map[string]string{"email": "first@test.com", "status": "active"}
+	if err := storage.Update("email", "first@test.com", updated); err != nil {
+		t.Fatalf("failed to update data: %v", err)
+	}
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	if readData[0]["locale"] != "uk" || readData[0]["status"] != "active" {
+		t.Errorf("unexpected data: %v", readData)
+	}
+}
+
+func TestCSVStorageAddsNewColumns(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	if err := storage.Append(map[string]string{"email": "first@test.com"}); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+
+	record := map[string]string{"email": "second@test.com", "subscribed_at": "now"}
+	if err := storage.Append(record); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	if len(readData) != 2 || readData[0]["subscribed_at"] != "" ||
+		readData[1]["subscribed_at"] != "now" {
+		t.Errorf("unexpected data: %v", readData)
+	}
+}
+
+func TestCSVStorageMigratesLegacyFile(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	content := "legacy@test.com\npending@test.com,pending,token,\n"
+	if err := os.WriteFile(storage.FilePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write legacy data: %v", err)
+	}
+
+	if err := storage.Delete("email", "nobody@test.com"); err != nil {
+		t.Fatalf("failed to delete data: %v", err)
+	}
+
+	migrated, err := os.ReadFile(storage.FilePath)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	expected := "email,status,confirmation_token,confirmation_expires_at,telegram_chat_id,language,pairs,cadence,channel\n" +
+		"legacy@test.com,,,,,,,,\n" +
+		"pending@test.com,pending,token,,,,,,\n"
+	if diff := cmp.Diff(expected, string(migrated)); diff != "" {
+		t.Errorf("file content does not match (-want +got):\n%s", diff)
+	}
+}
+
+func TestCSVStorageMissingFile(t *testing.T) {
+	storage := NewCSVStorage(t.TempDir() + "/storage.csv")
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	if len(readData) != 0 {
+		t.Errorf("expected no records, got %v", readData)
+	}
+}
+
+func TestCSVStorageKeepsFileMode(t *testing.T) {
+	storage, teardown := setup(t)
+	defer teardown()
+
+	if err := os.Chmod(storage.FilePath, 0640); err != nil {
+		t.Fatalf("failed to change the file mode: %v", err)
+	}
+
+	record := map[string]string{"email": "first@test.com", "status": "pending"}
+	if err := storage.Append(record); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+
+	if err := storage.Delete("email", "first@test.com"); err != nil {
+		t.Fatalf("failed to delete data: %v", err)
+	}
+
+	info, err := os.Stat(storage.FilePath)
+	if err != nil {
+		t.Fatalf("failed to stat the file: %v", err)
+	}
+
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("file mode = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+}
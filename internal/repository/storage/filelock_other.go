@@ -0,0 +1,9 @@
+//go:build !unix
+
+package storage
+
+// lockFile is a no-op where flock isn't available,
+// the storage is guarded within the process only
+func lockFile(path string, exclusive bool) (func() error, error) {
+	return func() error { return nil }, nil
+}
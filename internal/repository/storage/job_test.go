@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJobStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	sqliteJobs, err := NewSQLiteJobStorage(filepath.Join(dir, "storage.db"))
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { sqliteJobs.Close() })
+
+	type storage interface {
+		AppendUnique(key string, record map[string]string) error
+		AllRecords() ([]map[string]string, error)
+		Update(key, value string, record map[string]string) error
+	}
+
+	tests := []struct {
+		name string
+		jobs storage
+	}{
+		{
+			name: "CSV",
+			jobs: NewCSVJobStorage(filepath.Join(dir, "jobs.csv")),
+		},
+		{
+			name: "SQLite",
+			jobs: sqliteJobs,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := map[string]string{
+				"id":          "job1",
+				"status":      "queued",
+				"total":       "0",
+				"sent":        "0",
+				"failed":      "0",
+				"skipped":     "0",
+				"error":       "",
+				"created_at":  "2023-07-01T09:00:00Z",
+				"started_at":  "",
+				"finished_at": "",
+			}
+			if err := tt.jobs.AppendUnique("id", job); err != nil {
+				t.Fatalf("failed to append data: %v", err)
+			}
+
+			completed := map[string]string{
+				"id":          "job1",
+				"status":      "completed",
+				"total":       "3",
+				"sent":        "2",
+				"failed":      "1",
+				"skipped":     "0",
+				"error":       "",
+				"created_at":  "2023-07-01T09:00:00Z",
+				"started_at":  "2023-07-01T09:00:01Z",
+				"finished_at": "2023-07-01T09:00:05Z",
+			}
+			if err := tt.jobs.Update("id", "job1", completed); err != nil {
+				t.Fatalf("failed to update data: %v", err)
+			}
+
+			jobs, err := tt.jobs.AllRecords()
+			if err != nil {
+				t.Fatalf("failed to read data: %v", err)
+			}
+
+			if diff := cmp.Diff([]map[string]string{completed}, jobs); diff != "" {
+				t.Errorf("read data does not match written data (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOutboxStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	sqliteOutbox, err := NewSQLiteOutboxStorage(filepath.Join(dir, "storage.db"))
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { sqliteOutbox.Close() })
+
+	type storage interface {
+		AppendAll(records []map[string]string) error
+		AllRecords() ([]map[string]string, error)
+		Update(key, value string, record map[string]string) error
+		Delete(key, value string) error
+	}
+
+	tests := []struct {
+		name   string
+		outbox storage
+	}{
+		{
+			name:   "CSV",
+			outbox: NewCSVOutboxStorage(filepath.Join(dir, "outbox.csv")),
+		},
+		{
+			name:   "SQLite",
+			outbox: sqliteOutbox,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := func(id, email string) map[string]string {
+				return map[string]string{
+					"id":              id,
+					"email":           email,
+					"base":            "BTC",
+					"quote":           "UAH",
+					"rate":            "1000000",
+					"providers":       "coingecko",
+					"fetched_at":      "2023-07-01T09:00:00Z",
+					"status":          "pending",
+					"attempts":        "0",
+					"next_attempt_at": "",
+					"last_error":      "",
+					"created_at":      "2023-07-01T09:00:01Z",
+					"job_id":          "job1",
+				}
+			}
+
+			first := message("message1", "first@example.com")
+			second := message("message2", "second@example.com")
+			third := message("message3", "third@example.com")
+
+			// The second batch is appended to the existing rows
+			for _, batch := range [][]map[string]string{{first, second}, {third}, {}} {
+				if err := tt.outbox.AppendAll(batch); err != nil {
+					t.Fatalf("failed to append data: %v", err)
+				}
+			}
+
+			dead := message("message2", "second@example.com")
+			dead["status"] = "dead"
+			dead["attempts"] = "5"
+			dead["last_error"] = "550 mailbox unavailable"
+			if err := tt.outbox.Update("id", "message2", dead); err != nil {
+				t.Fatalf("failed to update data: %v", err)
+			}
+
+			if err := tt.outbox.Delete("id", "message1"); err != nil {
+				t.Fatalf("failed to delete data: %v", err)
+			}
+
+			messages, err := tt.outbox.AllRecords()
+			if err != nil {
+				t.Fatalf("failed to read data: %v", err)
+			}
+
+			if diff := cmp.Diff([]map[string]string{dead, third}, messages); diff != "" {
+				t.Errorf("read data does not match written data (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
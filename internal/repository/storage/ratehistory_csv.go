@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+const (
+	_providersSeparator = "+"
+	// The rates are appended in the order they're fetched, give or take
+	// the fetches of the pairs running at the same time
+	_appendOrderSlack = time.Minute
+)
+
+var ErrMalformedRateRecord = errors.New("malformed rate history record")
+
+var _rateHistoryHeaders = []string{"fetched_at", "base", "quote", "provider", "value"}
+
+// CSVRateHistory keeps the fetched rates in an append-only CSV file
+type CSVRateHistory struct {
+	FilePath string
+
+	mu sync.RWMutex
+}
+
+func NewCSVRateHistory(filePath string) *CSVRateHistory {
+	return &CSVRateHistory{FilePath: filePath}
+}
+
+func (h *CSVRateHistory) Append(rate port.Rate) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	unlock, err := lockFile(h.FilePath+_lockFileSuffix, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(h.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	if info.Size() == 0 {
+		if err = w.Write(_rateHistoryHeaders); err != nil {
+			return err
+		}
+	}
+
+	err = w.Write([]string{
+		rate.FetchedAt.UTC().Format(time.RFC3339Nano),
+		rate.Pair.Base,
+		rate.Pair.Quote,
+		strings.Join(rate.Providers, _providersSeparator),
+		strconv.FormatFloat(float64(rate.Value), 'f', -1, 32),
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+
+	if err = w.Error(); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// Range returns the rates of the pair fetched within [from, to)
+// in the order they were appended. The file is in the time order,
+// so it's read only up to a row fetched well after the range.
+func (h *CSVRateHistory) Range(
+	pair port.CurrencyPair,
+	from, to time.Time,
+) ([]port.Rate, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	unlock, err := lockFile(h.FilePath+_lockFileSuffix, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	f, err := os.Open(h.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return []port.Rate{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(_rateHistoryHeaders)
+
+	// The header row
+	if _, err = r.Read(); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	rates := []port.Rate{}
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		fetchedAt, err := time.Parse(time.RFC3339Nano, row[0])
+		if err != nil {
+			return nil, errors.Join(err, ErrMalformedRateRecord)
+		}
+
+		if fetchedAt.After(to.Add(_appendOrderSlack)) {
+			return rates, nil
+		}
+
+		if row[1] != pair.Base || row[2] != pair.Quote {
+			continue
+		}
+
+		rate, err := rowToRate(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if rate.FetchedAt.Before(from) || !rate.FetchedAt.Before(to) {
+			continue
+		}
+
+		rates = append(rates, rate)
+	}
+}
+
+func rowToRate(row []string) (port.Rate, error) {
+	fetchedAt, err := time.Parse(time.RFC3339Nano, row[0])
+	if err != nil {
+		return port.Rate{}, errors.Join(err, ErrMalformedRateRecord)
+	}
+
+	value, err := strconv.ParseFloat(row[4], 32)
+	if err != nil {
+		return port.Rate{}, errors.Join(err, ErrMalformedRateRecord)
+	}
+
+	return port.Rate{
+		Pair:      port.CurrencyPair{Base: row[1], Quote: row[2]},
+		Value:     float32(value),
+		Providers: splitProviders(row[3]),
+		FetchedAt: fetchedAt,
+	}, nil
+}
+
+func splitProviders(providers string) []string {
+	if providers == "" {
+		return nil
+	}
+
+	return strings.Split(providers, _providersSeparator)
+}
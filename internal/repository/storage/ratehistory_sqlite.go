@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"gses2-app/internal/core/port"
+)
+
+// SQLiteRateHistory keeps the fetched rates in the rate_history table
+type SQLiteRateHistory struct {
+	db *sql.DB
+}
+
+// NewSQLiteRateHistory opens the database file, creating it if needed,
+// and brings its schema up to date. It may share the file with
+// the SQLiteStorage.
+func NewSQLiteRateHistory(path string) (*SQLiteRateHistory, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteRateHistory{db: db}, nil
+}
+
+func (h *SQLiteRateHistory) Close() error {
+	return h.db.Close()
+}
+
+func (h *SQLiteRateHistory) Append(rate port.Rate) error {
+	_, err := h.db.Exec(
+		`INSERT INTO rate_history (fetched_at, base, quote, provider, value)
+		VALUES (?, ?, ?, ?, ?)`,
+		rate.FetchedAt.UnixNano(),
+		rate.Pair.Base,
+		rate.Pair.Quote,
+		strings.Join(rate.Providers, _providersSeparator),
+		rate.Value,
+	)
+
+	return err
+}
+
+// Range returns the rates of the pair fetched within [from, to)
+// ordered by the time they were fetched
+func (h *SQLiteRateHistory) Range(
+	pair port.CurrencyPair,
+	from, to time.Time,
+) ([]port.Rate, error) {
+	rows, err := h.db.Query(
+		`SELECT fetched_at, provider, value FROM rate_history
+		WHERE base = ? AND quote = ? AND fetched_at >= ? AND fetched_at < ?
+		ORDER BY fetched_at, id`,
+		pair.Base,
+		pair.Quote,
+		from.UnixNano(),
+		to.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []port.Rate{}
+	for rows.Next() {
+		var (
+			fetchedAt int64
+			providers string
+			value     float32
+		)
+		if err = rows.Scan(&fetchedAt, &providers, &value); err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, port.Rate{
+			Pair:      pair,
+			Value:     value,
+			Providers: splitProviders(providers),
+			FetchedAt: time.Unix(0, fetchedAt).UTC(),
+		})
+	}
+
+	return rates, rows.Err()
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"gses2-app/internal/core/port"
+)
+
+type rateHistory interface {
+	Append(rate port.Rate) error
+	Range(pair port.CurrencyPair, from, to time.Time) ([]port.Rate, error)
+}
+
+func TestRateHistory(t *testing.T) {
+	sqliteHistory, err := NewSQLiteRateHistory(filepath.Join(t.TempDir(), "storage.db"))
+	if err != nil {
+		t.Fatalf("failed to open rate history: %v", err)
+	}
+	defer sqliteHistory.Close()
+
+	histories := map[string]rateHistory{
+		"CSV":    NewCSVRateHistory(filepath.Join(t.TempDir(), "rate_history.csv")),
+		"SQLite": sqliteHistory,
+	}
+
+	start := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	ethUSD := port.CurrencyPair{Base: "ETH", Quote: "USD"}
+	rates := []port.Rate{
+		{
+			Pair:      port.DefaultCurrencyPair,
+			Value:     1000.5,
+			Providers: []string{"Binance"},
+			FetchedAt: start,
+		},
+		{
+			Pair:      ethUSD,
+			Value:     2,
+			Providers: []string{"Kuna"},
+			FetchedAt: start.Add(time.Minute),
+		},
+		{
+			Pair:      port.DefaultCurrencyPair,
+			Value:     1001.25,
+			Providers: []string{"Binance", "Kuna"},
+			FetchedAt: start.Add(time.Hour),
+		},
+	}
+
+	for name, history := range histories {
+		t.Run(name, func(t *testing.T) {
+			for _, rate := range rates {
+				if err := history.Append(rate); err != nil {
+					t.Fatalf("failed to append rate: %v", err)
+				}
+			}
+
+			got, err := history.Range(port.DefaultCurrencyPair, start, start.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("failed to read rates: %v", err)
+			}
+
+			if diff := cmp.Diff(rates[:1], got); diff != "" {
+				t.Errorf("read rates do not match (-want +got):\n%s", diff)
+			}
+
+			got, err = history.Range(ethUSD, start, start.Add(2*time.Hour))
+			if err != nil {
+				t.Fatalf("failed to read rates: %v", err)
+			}
+
+			if diff := cmp.Diff(rates[1:2], got); diff != "" {
+				t.Errorf("read rates do not match (-want +got):\n%s", diff)
+			}
+
+			got, err = history.Range(port.DefaultCurrencyPair, start, start.Add(2*time.Hour))
+			if err != nil {
+				t.Fatalf("failed to read rates: %v", err)
+			}
+
+			if diff := cmp.Diff([]port.Rate{rates[0], rates[2]}, got); diff != "" {
+				t.Errorf("read rates do not match (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCSVRateHistoryMissingFile(t *testing.T) {
+	history := NewCSVRateHistory(filepath.Join(t.TempDir(), "rate_history.csv"))
+
+	rates, err := history.Range(port.DefaultCurrencyPair, time.Time{}, time.Now())
+	if err != nil {
+		t.Fatalf("failed to read rates: %v", err)
+	}
+
+	if len(rates) != 0 {
+		t.Errorf("expected no rates, got %v", rates)
+	}
+}
+
+func TestCSVRateHistoryReadsUpToRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rate_history.csv")
+	history := NewCSVRateHistory(path)
+
+	start := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+	for _, fetchedAt := range []time.Time{start, start.Add(time.Hour)} {
+		rate := port.Rate{Pair: port.DefaultCurrencyPair, Value: 1000, FetchedAt: fetchedAt}
+		if err := history.Append(rate); err != nil {
+			t.Fatalf("failed to append rate: %v", err)
+		}
+	}
+
+	// A row after the range isn't read
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open rate history: %v", err)
+	}
+	if _, err = f.WriteString("malformed,BTC,UAH,Binance,1\n"); err != nil {
+		t.Fatalf("failed to write rate history: %v", err)
+	}
+	f.Close()
+
+	rates, err := history.Range(port.DefaultCurrencyPair, start, start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("failed to read rates: %v", err)
+	}
+
+	if len(rates) != 1 || !rates[0].FetchedAt.Equal(start) {
+		t.Errorf("expected the first rate, got %v", rates)
+	}
+
+	_, err = history.Range(port.DefaultCurrencyPair, start, start.Add(2*time.Hour))
+	if !errors.Is(err, ErrMalformedRateRecord) {
+		t.Errorf("expected the malformed row to be read, got %v", err)
+	}
+}
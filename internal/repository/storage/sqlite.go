@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"gses2-app/internal/core/port"
+)
+
+const (
+	_sqliteDriverName = "sqlite"
+	_subscribersTable = "subscribers"
+	_alertsTable      = "alerts"
+	_webhooksTable    = "webhooks"
+	_deliveriesTable  = "webhook_deliveries"
+	_outboxTable      = "outbox"
+	_jobsTable        = "jobs"
+	_sqliteBusyTimeMs = 5000
+)
+
+var ErrUnknownColumn = errors.New("unknown column")
+
+// The migrations are applied in order, the number of the applied ones
+// is kept in the user_version of the database. Only append new ones.
+var _migrations = []string{
+	`CREATE TABLE subscribers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT '',
+		confirmation_token TEXT NOT NULL DEFAULT '',
+		confirmation_expires_at TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE UNIQUE INDEX subscribers_email_idx ON subscribers (email)`,
+	`CREATE INDEX subscribers_confirmation_token_idx
+		ON subscribers (confirmation_token)`,
+	`CREATE TABLE rate_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		fetched_at INTEGER NOT NULL,
+		base TEXT NOT NULL,
+		quote TEXT NOT NULL,
+		provider TEXT NOT NULL DEFAULT '',
+		value REAL NOT NULL
+	)`,
+	`CREATE INDEX rate_history_pair_fetched_at_idx
+		ON rate_history (base, quote, fetched_at)`,
+	`CREATE TABLE alerts (
+		id TEXT NOT NULL,
+		email TEXT NOT NULL,
+		base TEXT NOT NULL DEFAULT '',
+		quote TEXT NOT NULL DEFAULT '',
+		kind TEXT NOT NULL DEFAULT '',
+		value TEXT NOT NULL DEFAULT '',
+		cooldown TEXT NOT NULL DEFAULT '',
+		last_rate TEXT NOT NULL DEFAULT '',
+		last_triggered_at TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE UNIQUE INDEX alerts_id_idx ON alerts (id)`,
+	`CREATE INDEX alerts_email_idx ON alerts (email)`,
+	`ALTER TABLE subscribers
+		ADD COLUMN telegram_chat_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE webhooks (
+		id TEXT NOT NULL,
+		url TEXT NOT NULL DEFAULT '',
+		secret TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE UNIQUE INDEX webhooks_id_idx ON webhooks (id)`,
+	`CREATE TABLE webhook_deliveries (
+		id TEXT NOT NULL,
+		webhook_id TEXT NOT NULL,
+		attempt TEXT NOT NULL DEFAULT '',
+		status_code TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		duration TEXT NOT NULL DEFAULT '',
+		delivered_at TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX webhook_deliveries_id_idx ON webhook_deliveries (id)`,
+	`CREATE INDEX webhook_deliveries_webhook_id_idx
+		ON webhook_deliveries (webhook_id)`,
+	`CREATE TABLE outbox (
+		id TEXT NOT NULL,
+		email TEXT NOT NULL,
+		base TEXT NOT NULL DEFAULT '',
+		quote TEXT NOT NULL DEFAULT '',
+		rate TEXT NOT NULL DEFAULT '',
+		providers TEXT NOT NULL DEFAULT '',
+		fetched_at TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT '',
+		attempts TEXT NOT NULL DEFAULT '',
+		next_attempt_at TEXT NOT NULL DEFAULT '',
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE UNIQUE INDEX outbox_id_idx ON outbox (id)`,
+	`ALTER TABLE outbox ADD COLUMN job_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE jobs (
+		id TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT '',
+		total TEXT NOT NULL DEFAULT '',
+		sent TEXT NOT NULL DEFAULT '',
+		failed TEXT NOT NULL DEFAULT '',
+		skipped TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT '',
+		started_at TEXT NOT NULL DEFAULT '',
+		finished_at TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE UNIQUE INDEX jobs_id_idx ON jobs (id)`,
+	`ALTER TABLE subscribers ADD COLUMN language TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE subscribers ADD COLUMN pairs TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE subscribers ADD COLUMN cadence TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE subscribers ADD COLUMN channel TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE webhooks ADD COLUMN email TEXT NOT NULL DEFAULT ''`,
+}
+
+// SQLiteStorage keeps the records in a table whose columns
+// are all text, like the cells of the CSV file
+type SQLiteStorage struct {
+	db      *sql.DB
+	table   string
+	columns []string
+}
+
+// NewSQLiteStorage opens the database file, creating it if needed,
+// and brings its schema up to date
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	return newSQLiteStorage(path, _subscribersTable, _headers)
+}
+
+// NewSQLiteAlertStorage keeps the alert rules in the database file,
+// which may be shared with the subscribers
+func NewSQLiteAlertStorage(path string) (*SQLiteStorage, error) {
+	return newSQLiteStorage(path, _alertsTable, _alertHeaders)
+}
+
+// NewSQLiteWebhookStorage keeps the webhooks in the database file
+func NewSQLiteWebhookStorage(path string) (*SQLiteStorage, error) {
+	return newSQLiteStorage(path, _webhooksTable, _webhookHeaders)
+}
+
+// NewSQLiteWebhookDeliveryStorage keeps the webhook delivery attempts
+// in the database file
+func NewSQLiteWebhookDeliveryStorage(path string) (*SQLiteStorage, error) {
+	return newSQLiteStorage(path, _deliveriesTable, _webhookDeliveryHeaders)
+}
+
+// NewSQLiteOutboxStorage keeps the emails waiting to be sent
+// in the database file
+func NewSQLiteOutboxStorage(path string) (*SQLiteStorage, error) {
+	return newSQLiteStorage(path, _outboxTable, _outboxHeaders)
+}
+
+// NewSQLiteJobStorage keeps the send jobs in the database file
+func NewSQLiteJobStorage(path string) (*SQLiteStorage, error) {
+	return newSQLiteStorage(path, _jobsTable, _jobHeaders)
+}
+
+func newSQLiteStorage(path, table string, columns []string) (*SQLiteStorage, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStorage{db: db, table: table, columns: columns}, nil
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStorage) AllRecords() ([]map[string]string, error) {
+	rows, err := s.db.Query(fmt.Sprintf(
+		"SELECT %s FROM %s ORDER BY rowid",
+		strings.Join(s.columns, ", "),
+		s.table,
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []map[string]string{}
+	for rows.Next() {
+		values := make([]string, len(s.columns))
+		pointers := make([]any, len(s.columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err = rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]string, len(s.columns))
+		for i, key := range s.columns {
+			record[key] = values[i]
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Append inserts the record in a transaction. A record violating
+// a unique index, e.g. of the email, gives port.ErrDuplicateRecord.
+func (s *SQLiteStorage) Append(record map[string]string) error {
+	return inTx(s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			s.table,
+			strings.Join(s.columns, ", "),
+			placeholders(len(s.columns)),
+		), s.rowArgs(record)...)
+
+		if isUniqueViolation(err) {
+			return errors.Join(err, port.ErrDuplicateRecord)
+		}
+
+		return err
+	})
+}
+
+// AppendAll inserts the records in one transaction, so either
+// all of them are stored or none
+func (s *SQLiteStorage) AppendAll(records []map[string]string) error {
+	return inTx(s.db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			s.table,
+			strings.Join(s.columns, ", "),
+			placeholders(len(s.columns)),
+		))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, record := range records {
+			_, err = stmt.Exec(s.rowArgs(record)...)
+			if isUniqueViolation(err) {
+				return errors.Join(err, port.ErrDuplicateRecord)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// AppendUnique inserts the record unless a record with the same value
+// of the key is already stored, in which case port.ErrDuplicateRecord
+// is returned. The check and the insert are done in one transaction.
+func (s *SQLiteStorage) AppendUnique(key string, record map[string]string) error {
+	if !slices.Contains(s.columns, key) {
+		return fmt.Errorf("%w: %s", ErrUnknownColumn, key)
+	}
+
+	return inTx(s.db, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRow(fmt.Sprintf(
+			"SELECT EXISTS (SELECT 1 FROM %s WHERE %s = ?)",
+			s.table,
+			key,
+		), record[key]).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return port.ErrDuplicateRecord
+		}
+
+		_, err = tx.Exec(fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			s.table,
+			strings.Join(s.columns, ", "),
+			placeholders(len(s.columns)),
+		), s.rowArgs(record)...)
+
+		if isUniqueViolation(err) {
+			return errors.Join(err, port.ErrDuplicateRecord)
+		}
+
+		return err
+	})
+}
+
+// Update replaces all the records whose value of the key matches the value.
+// The columns missing in the record keep their values, like in CSVStorage.
+func (s *SQLiteStorage) Update(key, value string, record map[string]string) error {
+	if !slices.Contains(s.columns, key) {
+		return fmt.Errorf("%w: %s", ErrUnknownColumn, key)
+	}
+
+	return inTx(s.db, func(tx *sql.Tx) error {
+		return s.update(tx, key, value, record)
+	})
+}
+
+// UpdateAll replaces every stored record whose value of the key matches
+// the value of the key in one of the records, in one transaction
+func (s *SQLiteStorage) UpdateAll(key string, records []map[string]string) error {
+	if !slices.Contains(s.columns, key) {
+		return fmt.Errorf("%w: %s", ErrUnknownColumn, key)
+	}
+
+	return inTx(s.db, func(tx *sql.Tx) error {
+		for _, record := range records {
+			if err := s.update(tx, key, record[key], record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// update sets the columns present in the record
+func (s *SQLiteStorage) update(tx *sql.Tx, key, value string, record map[string]string) error {
+	assignments := make([]string, 0, len(s.columns))
+	args := make([]any, 0, len(s.columns)+1)
+	for _, column := range s.columns {
+		if v, ok := record[column]; ok {
+			assignments = append(assignments, column+" = ?")
+			args = append(args, v)
+		}
+	}
+
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(
+		"UPDATE %s SET %s WHERE %s = ?",
+		s.table,
+		strings.Join(assignments, ", "),
+		key,
+	), append(args, value)...)
+
+	if isUniqueViolation(err) {
+		return errors.Join(err, port.ErrDuplicateRecord)
+	}
+
+	return err
+}
+
+// Delete removes all the records whose value of the key matches the value
+func (s *SQLiteStorage) Delete(key, value string) error {
+	if !slices.Contains(s.columns, key) {
+		return fmt.Errorf("%w: %s", ErrUnknownColumn, key)
+	}
+
+	_, err := s.db.Exec(fmt.Sprintf(
+		"DELETE FROM %s WHERE %s = ?",
+		s.table,
+		key,
+	), value)
+
+	return err
+}
+
+// DeleteAll removes the records whose value of the key is one of
+// the values, in one transaction
+func (s *SQLiteStorage) DeleteAll(key string, values []string) error {
+	if !slices.Contains(s.columns, key) {
+		return fmt.Errorf("%w: %s", ErrUnknownColumn, key)
+	}
+
+	return inTx(s.db, func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(fmt.Sprintf(
+			"DELETE FROM %s WHERE %s = ?",
+			s.table,
+			key,
+		))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, value := range values {
+			if _, err = stmt.Exec(value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open(_sqliteDriverName, sqliteDSN(path))
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, so the connections would only
+	// wait for each other
+	db.SetMaxOpenConns(1)
+
+	if err = migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrate applies the migrations which weren't applied yet,
+// each one in its own transaction
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(_migrations); i++ {
+		err := inTx(db, func(tx *sql.Tx) error {
+			if _, err := tx.Exec(_migrations[i]); err != nil {
+				return fmt.Errorf("migration %d: %w", i+1, err)
+			}
+
+			// PRAGMA doesn't accept placeholders
+			_, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+func sqliteDSN(path string) string {
+	query := url.Values{}
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", _sqliteBusyTimeMs))
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "foreign_keys(1)")
+
+	return fmt.Sprintf("file:%s?%s", path, query.Encode())
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) &&
+		sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (s *SQLiteStorage) rowArgs(record map[string]string) []any {
+	args := make([]any, 0, len(s.columns))
+	for _, value := range recordToRow(s.columns, record) {
+		args = append(args, value)
+	}
+
+	return args
+}
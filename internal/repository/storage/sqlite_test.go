@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"gses2-app/internal/core/port"
+)
+
+func setupSQLite(t *testing.T) *SQLiteStorage {
+	t.Helper()
+
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "storage.db"))
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	return storage
+}
+
+func TestSQLiteStorageAppendAndAllRecords(t *testing.T) {
+	storage := setupSQLite(t)
+
+	data := map[string]string{
+		"email":                   "example@test.com",
+		"status":                  "active",
+		"confirmation_token":      "",
+		"confirmation_expires_at": "",
+		"telegram_chat_id":        "",
+		"language":                "",
+		"pairs":                   "",
+		"cadence":                 "",
+		"channel":                 "",
+	}
+	if err := storage.Append(data); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	if diff := cmp.Diff([]map[string]string{data}, readData); diff != "" {
+		t.Errorf("read data does not match written data (-want +got):\n%s", diff)
+	}
+}
+
+func TestSQLiteStorageDuplicate(t *testing.T) {
+	storage := setupSQLite(t)
+
+	record := map[string]string{"email": "example@test.com"}
+	if err := storage.Append(record); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+
+	err := storage.Append(record)
+	if !errors.Is(err, port.ErrDuplicateRecord) {
+		t.Fatalf("expected %v, got %v", port.ErrDuplicateRecord, err)
+	}
+}
+
+func TestSQLiteStorageConcurrentAppend(t *testing.T) {
+	storage := setupSQLite(t)
+
+	const writers = 20
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		duplicates int
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Every email is appended twice
+			email := fmt.Sprintf("user%d@test.com", i/2)
+			err := storage.Append(map[string]string{"email": email})
+			if errors.Is(err, port.ErrDuplicateRecord) {
+				mu.Lock()
+				duplicates++
+				mu.Unlock()
+				return
+			}
+
+			if err != nil {
+				t.Errorf("failed to append data: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	if len(readData) != writers/2 || duplicates != writers/2 {
+		t.Errorf(
+			"expected %d records and duplicates, got %d records and %d duplicates",
+			writers/2, len(readData), duplicates,
+		)
+	}
+}
+
+func TestSQLiteStorageUpdate(t *testing.T) {
+	storage := setupSQLite(t)
+
+	for _, email := range []string{"first@test.com", "second@test.com"} {
+		record := map[string]string{"email": email, "status": "pending"}
+		if err := storage.Append(record); err != nil {
+			t.Fatalf("failed to append data: %v", err)
+		}
+	}
+
+	updated := map[string]string{
+		"email":                   "first@test.com",
+		"status":                  "active",
+		"confirmation_token":      "",
+		"confirmation_expires_at": "",
+		"telegram_chat_id":        "",
+		"language":                "",
+		"pairs":                   "",
+		"cadence":                 "",
+		"channel":                 "",
+	}
+	if err := storage.Update("email", "first@test.com", updated); err != nil {
+		t.Fatalf("failed to update data: %v", err)
+	}
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	expected := []map[string]string{
+		updated,
+		{
+			"email":                   "second@test.com",
+			"status":                  "pending",
+			"confirmation_token":      "",
+			"confirmation_expires_at": "",
+			"telegram_chat_id":        "",
+			"language":                "",
+			"pairs":                   "",
+			"cadence":                 "",
+			"channel":                 "",
+		},
+	}
+	if diff := cmp.Diff(expected, readData); diff != "" {
+		t.Errorf("read data does not match expected data (-want +got):\n%s", diff)
+	}
+}
+
+func TestSQLiteStorageDelete(t *testing.T) {
+	storage := setupSQLite(t)
+
+	for _, email := range []string{"first@test.com", "second@test.com"} {
+		if err := storage.Append(map[string]string{"email": email}); err != nil {
+			t.Fatalf("failed to append data: %v", err)
+		}
+	}
+
+	if err := storage.Delete("email", "first@test.com"); err != nil {
+		t.Fatalf("failed to delete data: %v", err)
+	}
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	if len(readData) != 1 || readData[0]["email"] != "second@test.com" {
+		t.Errorf("unexpected data: %v", readData)
+	}
+}
+
+func TestSQLiteStorageUnknownColumn(t *testing.T) {
+	storage := setupSQLite(t)
+
+	err := storage.Delete("email; DROP TABLE subscribers", "x")
+	if !errors.Is(err, ErrUnknownColumn) {
+		t.Fatalf("expected %v, got %v", ErrUnknownColumn, err)
+	}
+}
+
+func TestSQLiteStorageReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+
+	if err = storage.Append(map[string]string{"email": "example@test.com"}); err != nil {
+		t.Fatalf("failed to append data: %v", err)
+	}
+	storage.Close()
+
+	storage, err = NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("failed to reopen storage: %v", err)
+	}
+	defer storage.Close()
+
+	readData, err := storage.AllRecords()
+	if err != nil {
+		t.Fatalf("failed to read data: %v", err)
+	}
+
+	if len(readData) != 1 {
+		t.Errorf("expected the record to survive reopening, got %v", readData)
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+const (
+	DriverCSV    = "csv"
+	DriverSQLite = "sqlite"
+)
+
+// The known columns. Legacy files without the header row have them
+// in this order, so new columns are only appended.
+var _headers = []string{
+	"email",
+	"status",
+	"confirmation_token",
+	"confirmation_expires_at",
+	"telegram_chat_id",
+	"language",
+	"pairs",
+	"cadence",
+	"channel",
+}
+
+// The columns of the alert rules
+var _alertHeaders = []string{
+	"id",
+	"email",
+	"base",
+	"quote",
+	"kind",
+	"value",
+	"cooldown",
+	"last_rate",
+	"last_triggered_at",
+}
+
+// The columns of the webhooks the rate is posted to
+var _webhookHeaders = []string{
+	"id",
+	"url",
+	"secret",
+	"created_at",
+	"email",
+}
+
+// The columns of the attempts to post to the webhooks
+var _webhookDeliveryHeaders = []string{
+	"id",
+	"webhook_id",
+	"attempt",
+	"status_code",
+	"error",
+	"duration",
+	"delivered_at",
+}
+
+// The columns of the rate emails waiting to be sent
+var _outboxHeaders = []string{
+	"id",
+	"email",
+	"base",
+	"quote",
+	"rate",
+	"providers",
+	"fetched_at",
+	"status",
+	"attempts",
+	"next_attempt_at",
+	"last_error",
+	"created_at",
+	"job_id",
+}
+
+// The columns of the jobs sending the rate emails
+var _jobHeaders = []string{
+	"id",
+	"status",
+	"total",
+	"sent",
+	"failed",
+	"skipped",
+	"error",
+	"created_at",
+	"started_at",
+	"finished_at",
+}
+
+type StorageConfig struct {
+	Driver     string `default:"csv"`
+	Path       string `default:"./storage/storage.csv"`
+	SQLitePath string `default:"./storage/storage.db"`
+	// The CSV file of the rate history, the SQLite driver keeps
+	// the history in the database
+	RateHistoryPath string `default:"./storage/rate_history.csv"`
+	// The CSV file of the alert rules, the SQLite driver keeps
+	// the rules in the database
+	AlertsPath string `default:"./storage/alerts.csv"`
+	// The CSV files of the webhooks and their delivery attempts,
+	// the SQLite driver keeps them in the database
+	WebhooksPath          string `default:"./storage/webhooks.csv"`
+	WebhookDeliveriesPath string `default:"./storage/webhook_deliveries.csv"`
+	// The CSV file of the email outbox, the SQLite driver keeps
+	// the outbox in the database
+	OutboxPath string `default:"./storage/outbox.csv"`
+	// The CSV file of the send jobs, the SQLite driver keeps
+	// the jobs in the database
+	JobsPath string `default:"./storage/jobs.csv"`
+}
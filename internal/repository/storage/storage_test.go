@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gses2-app/internal/core/port"
+)
+
+// TestStorageContract checks that the backends behave the same
+// behind port.Storage
+func TestStorageContract(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) port.Storage
+	}{
+		{
+			name: "CSV",
+			open: func(t *testing.T) port.Storage {
+				return NewCSVStorage(filepath.Join(t.TempDir(), "storage.csv"))
+			},
+		},
+		{
+			name: "SQLite",
+			open: func(t *testing.T) port.Storage {
+				return setupSQLite(t)
+			},
+		},
+	}
+
+	for _, backend := range backends {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("Partial update keeps the missing columns", func(t *testing.T) {
+				storage := backend.open(t)
+
+				require.NoError(t, storage.Append(map[string]string{
+					"email":            "first@test.com",
+					"status":           "active",
+					"telegram_chat_id": "42",
+					"language":         "uk",
+				}))
+
+				require.NoError(t, storage.Update("email", "first@test.com", map[string]string{
+					"email":  "first@test.com",
+					"status": "pending",
+				}))
+
+				records, err := storage.AllRecords()
+				require.NoError(t, err)
+				require.Len(t, records, 1)
+				require.Equal(t, "pending", records[0]["status"])
+				require.Equal(t, "42", records[0]["telegram_chat_id"])
+				require.Equal(t, "uk", records[0]["language"])
+			})
+
+			t.Run("Update changes only the matched records", func(t *testing.T) {
+				storage := backend.open(t)
+
+				for _, email := range []string{"first@test.com", "second@test.com"} {
+					require.NoError(t, storage.Append(map[string]string{
+						"email":  email,
+						"status": "pending",
+					}))
+				}
+
+				require.NoError(t, storage.Update("email", "second@test.com", map[string]string{
+					"status": "active",
+				}))
+
+				records, err := storage.AllRecords()
+				require.NoError(t, err)
+				require.Len(t, records, 2)
+				require.Equal(t, "pending", records[0]["status"])
+				require.Equal(t, "active", records[1]["status"])
+			})
+
+			t.Run("Batches change the matched records", func(t *testing.T) {
+				writer, ok := backend.open(t).(port.BatchWriter)
+				require.True(t, ok)
+				storage := writer.(port.Storage)
+
+				for _, email := range []string{"first@test.com", "second@test.com", "third@test.com"} {
+					require.NoError(t, storage.Append(map[string]string{
+						"email":  email,
+						"status": "pending",
+					}))
+				}
+
+				require.NoError(t, writer.UpdateAll("email", []map[string]string{
+					{"email": "first@test.com", "status": "active"},
+					{"email": "missing@test.com", "status": "active"},
+				}))
+				require.NoError(t, writer.DeleteAll("email", []string{"second@test.com", "missing@test.com"}))
+
+				records, err := storage.AllRecords()
+				require.NoError(t, err)
+				require.Len(t, records, 2)
+				require.Equal(t, "first@test.com", records[0]["email"])
+				require.Equal(t, "active", records[0]["status"])
+				require.Equal(t, "third@test.com", records[1]["email"])
+				require.Equal(t, "pending", records[1]["status"])
+			})
+
+			t.Run("Delete removes the matched records", func(t *testing.T) {
+				storage := backend.open(t)
+
+				for _, email := range []string{"first@test.com", "second@test.com"} {
+					require.NoError(t, storage.Append(map[string]string{"email": email}))
+				}
+
+				require.NoError(t, storage.Delete("email", "first@test.com"))
+
+				records, err := storage.AllRecords()
+				require.NoError(t, err)
+				require.Len(t, records, 1)
+				require.Equal(t, "second@test.com", records[0]["email"])
+			})
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TimestampFile keeps a single timestamp in a text file
+type TimestampFile struct {
+	FilePath string
+}
+
+func NewTimestampFile(filePath string) *TimestampFile {
+	return &TimestampFile{FilePath: filePath}
+}
+
+// Load returns zero time if the timestamp wasn't saved yet. The timestamp
+// is saved in UTC and returned in the local time, the schedule is in
+func (f *TimestampFile) Load() (time.Time, error) {
+	data, err := os.ReadFile(f.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return time.Time{}, nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, content)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return timestamp.Local(), nil
+}
+
+// Save writes the timestamp to a temporary file and renames it,
+// so a crash never leaves a partially written timestamp
+func (f *TimestampFile) Save(timestamp time.Time) error {
+	mode, err := fileMode(f.FilePath)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.FilePath), filepath.Base(f.FilePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(timestamp.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.FilePath)
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampFile(t *testing.T) {
+	t.Run("Load missing timestamp", func(t *testing.T) {
+		file := NewTimestampFile(filepath.Join(t.TempDir(), "missing"))
+
+		timestamp, err := file.Load()
+
+		require.NoError(t, err)
+		require.True(t, timestamp.IsZero())
+	})
+
+	t.Run("Save and load timestamp", func(t *testing.T) {
+		file := NewTimestampFile(filepath.Join(t.TempDir(), "timestamp"))
+		expected := time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)
+
+		require.NoError(t, file.Save(expected))
+		require.NoError(t, file.Save(expected.Add(time.Hour)))
+
+		timestamp, err := file.Load()
+
+		require.NoError(t, err)
+		require.True(t, expected.Add(time.Hour).Equal(timestamp))
+	})
+
+	t.Run("Load timestamp in local time", func(t *testing.T) {
+		local := time.Local
+		time.Local = time.FixedZone("UTC+3", 3*60*60)
+		defer func() { time.Local = local }()
+
+		file := NewTimestampFile(filepath.Join(t.TempDir(), "timestamp"))
+		expected := time.Date(2023, 7, 1, 9, 0, 0, 0, time.Local)
+
+		require.NoError(t, file.Save(expected))
+
+		timestamp, err := file.Load()
+
+		require.NoError(t, err)
+		require.True(t, expected.Equal(timestamp))
+		require.Equal(t, time.Local, timestamp.Location())
+		require.Equal(t, 9, timestamp.Hour())
+	})
+
+	t.Run("Save keeps the file mode", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "timestamp")
+		file := NewTimestampFile(path)
+
+		require.NoError(t, file.Save(time.Now()))
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, _defaultFileMode, info.Mode().Perm())
+
+		require.NoError(t, os.Chmod(path, 0640))
+		require.NoError(t, file.Save(time.Now()))
+
+		info, err = os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	})
+}
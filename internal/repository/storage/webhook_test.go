@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWebhookStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	sqliteWebhooks, err := NewSQLiteWebhookStorage(filepath.Join(dir, "storage.db"))
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { sqliteWebhooks.Close() })
+
+	sqliteDeliveries, err := NewSQLiteWebhookDeliveryStorage(filepath.Join(dir, "storage.db"))
+	if err != nil {
+		t.Fatalf("failed to open storage: %v", err)
+	}
+	t.Cleanup(func() { sqliteDeliveries.Close() })
+
+	type storage interface {
+		Append(record map[string]string) error
+		AppendUnique(key string, record map[string]string) error
+		AllRecords() ([]map[string]string, error)
+		Delete(key, value string) error
+	}
+
+	tests := []struct {
+		name       string
+		webhooks   storage
+		deliveries storage
+	}{
+		{
+			name:       "CSV",
+			webhooks:   NewCSVWebhookStorage(filepath.Join(dir, "webhooks.csv")),
+			deliveries: NewCSVWebhookDeliveryStorage(filepath.Join(dir, "webhook_deliveries.csv")),
+		},
+		{
+			name:       "SQLite",
+			webhooks:   sqliteWebhooks,
+			deliveries: sqliteDeliveries,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := map[string]string{
+				"id":         "webhook1",
+				"url":        "https://example.com/rate",
+				"secret":     "secret",
+				"created_at": "2023-07-01T09:00:00Z",
+				"email":      "test@example.com",
+			}
+			if err := tt.webhooks.AppendUnique("id", webhook); err != nil {
+				t.Fatalf("failed to append data: %v", err)
+			}
+
+			delivery := map[string]string{
+				"id":           "delivery1",
+				"webhook_id":   "webhook1",
+				"attempt":      "1",
+				"status_code":  "",
+				"error":        "connection refused",
+				"duration":     "1ms",
+				"delivered_at": "2023-07-01T09:00:00.5Z",
+			}
+			retry := map[string]string{
+				"id":           "delivery1",
+				"webhook_id":   "webhook1",
+				"attempt":      "2",
+				"status_code":  "200",
+				"error":        "",
+				"duration":     "1s",
+				"delivered_at": "2023-07-01T09:00:01.5Z",
+			}
+			for _, record := range []map[string]string{delivery, retry} {
+				if err := tt.deliveries.Append(record); err != nil {
+					t.Fatalf("failed to append data: %v", err)
+				}
+			}
+
+			webhooks, err := tt.webhooks.AllRecords()
+			if err != nil {
+				t.Fatalf("failed to read data: %v", err)
+			}
+
+			if diff := cmp.Diff([]map[string]string{webhook}, webhooks); diff != "" {
+				t.Errorf("read data does not match written data (-want +got):\n%s", diff)
+			}
+
+			deliveries, err := tt.deliveries.AllRecords()
+			if err != nil {
+				t.Fatalf("failed to read data: %v", err)
+			}
+
+			if diff := cmp.Diff([]map[string]string{delivery, retry}, deliveries); diff != "" {
+				t.Errorf("read data does not match written data (-want +got):\n%s", diff)
+			}
+
+			if err := tt.deliveries.Delete("webhook_id", "webhook1"); err != nil {
+				t.Fatalf("failed to delete data: %v", err)
+			}
+
+			deliveries, err = tt.deliveries.AllRecords()
+			if err != nil {
+				t.Fatalf("failed to read data: %v", err)
+			}
+
+			if len(deliveries) != 0 {
+				t.Errorf("deleted data is still stored: %v", deliveries)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	Port = ":8083"
+	// How long a poll waits when there are no messages
+	PollDelay = time.Second
+)
+
+type message struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// The messages the application has sent, listed by GET /sent
+var (
+	mu   sync.Mutex
+	sent []message
+)
+
+func main() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			var m message
+			if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"ok":false,"description":"Bad Request"}`)
+				return
+			}
+
+			mu.Lock()
+			sent = append(sent, m)
+			mu.Unlock()
+
+			fmt.Fprint(w, `{"ok":true,"result":{}}`)
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			time.Sleep(PollDelay)
+			fmt.Fprint(w, `{"ok":true,"result":[]}`)
+		case r.URL.Path == "/sent":
+			mu.Lock()
+			defer mu.Unlock()
+
+			_ = json.NewEncoder(w).Encode(sent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"ok":false,"description":"Not Found"}`)
+		}
+	})
+
+	fmt.Printf("Serving on localhost%s", Port)
+	err := http.ListenAndServe(Port, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
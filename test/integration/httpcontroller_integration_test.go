@@ -6,24 +6,43 @@ package integration
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 
 	"gses2-app/internal/core/port"
+	"gses2-app/internal/core/service/alert"
+	"gses2-app/internal/core/service/job"
+	"gses2-app/internal/core/service/outbox"
 	"gses2-app/internal/core/service/rate"
+	"gses2-app/internal/core/service/ratehistory"
 	"gses2-app/internal/core/service/sender"
 	"gses2-app/internal/core/service/subscription"
+	"gses2-app/internal/core/service/webhook"
 	"gses2-app/internal/handler/httpcontroller"
 	"gses2-app/internal/handler/router"
+	"gses2-app/internal/repository/blocklist"
 	"gses2-app/internal/repository/config"
 	"gses2-app/internal/repository/sender/email"
+	"gses2-app/internal/repository/sender/email/send"
 	"gses2-app/internal/repository/sender/smtp"
+	"gses2-app/internal/repository/signature"
+	"gses2-app/internal/repository/storage"
 )
 
-const _configPrefix = "GSES2_APP"
+const (
+	_configPrefix = "GSES2_APP"
+	_templatesDir = "../../templates/email"
+)
 
 type StubLogger struct{}
 
@@ -64,7 +83,10 @@ type StubRateProvider struct {
 	ProviderName string
 }
 
-func (m *StubRateProvider) ExchangeRate() (port.Rate, error) {
+func (m *StubRateProvider) ExchangeRate(
+	ctx context.Context,
+	pair port.CurrencyPair,
+) (port.Rate, error) {
 	return m.Rate, m.Error
 }
 
@@ -82,33 +104,177 @@ func (s *StubUserRepository) Add(user *port.User) error {
 	return s.Err
 }
 
+func (s *StubUserRepository) AddAll(users []port.User) error {
+	s.Users = append(s.Users, users...)
+	return s.Err
+}
+
+func (s *StubUserRepository) Update(user *port.User) error {
+	return s.Err
+}
+
+func (s *StubUserRepository) Remove(user *port.User) error {
+	return s.Err
+}
+
 func (s *StubUserRepository) FindByEmail(email string) (*port.User, error) {
-	return &s.Users[0], s.Err
+	return s.findBy(
+		func(user port.User) bool { return user.Email == email },
+		port.ErrCannotFindByEmail,
+	)
+}
+
+func (s *StubUserRepository) FindByConfirmationToken(token string) (*port.User, error) {
+	return s.findBy(
+		func(user port.User) bool { return user.ConfirmationToken == token },
+		port.ErrCannotFindByToken,
+	)
 }
 
 func (s *StubUserRepository) All() ([]port.User, error) {
 	return s.Users, s.Err
 }
 
+func (s *StubUserRepository) findBy(
+	match func(port.User) bool,
+	errNotFound error,
+) (*port.User, error) {
+	if s.Err != nil {
+		return &port.User{}, s.Err
+	}
+
+	for _, user := range s.Users {
+		if match(user) {
+			found := user
+			return &found, nil
+		}
+	}
+
+	return &port.User{}, errNotFound
+}
+
+type StubConfirmationSender struct {
+	Err error
+}
+
+func (s *StubConfirmationSender) SendConfirmation(user port.User) error {
+	return s.Err
+}
+
+type StubAlertNotifier struct {
+	Err error
+}
+
+func (s *StubAlertNotifier) SendAlert(notice port.AlertNotice) error {
+	return s.Err
+}
+
 var (
 	errRateProviderAnavailable = errors.New("rate provider unavailable")
 	errSendMessage             = errors.New("failed to send a message")
 )
 
+type jobResponse struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Total   int    `json:"total"`
+	Sent    int    `json:"sent"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+	Pending int    `json:"pending"`
+}
+
 func TestAppControllerIntegration(t *testing.T) {
 	config := initConfig(t)
 
-	defaultEmailSenderService := sender.NewService(
-		&StubSenderProvider{},
+	defaultRateService := rate.NewService(
+		config.Rate,
+		&StubLogger{},
+		&StubRateProvider{
+			Rate: port.Rate{Pair: port.DefaultCurrencyPair, Value: 42},
+		},
 	)
 
-	defaultRateService := rate.NewService(
+	signer := signature.NewHMACSigner(config.Signature)
+	managementSigner := signer.WithPurpose("manage")
+
+	rateHistoryService := ratehistory.NewService(
+		storage.NewCSVRateHistory(filepath.Join(t.TempDir(), "rate_history.csv")),
+	)
+
+	newSubscriptionService := func(
+		userRepository *StubUserRepository,
+	) *subscription.Service {
+		return subscription.NewService(
+			config.Subscription,
+			userRepository,
+			signer,
+			managementSigner,
+			&StubConfirmationSender{},
+			blocklist.NewDomainBlocklist("mailinator.com"),
+		)
+	}
+
+	defaultSubscriptionService := newSubscriptionService(&StubUserRepository{})
+
+	alertService := alert.NewService(
+		config.Alert,
 		&StubLogger{},
-		&StubRateProvider{Rate: 42},
+		port.NewAlertRepository(
+			storage.NewCSVAlertStorage(filepath.Join(t.TempDir(), "alerts.csv")),
+		),
+		&StubUserRepository{
+			Users: []port.User{{
+				Email:  "test@test.com",
+				Status: port.UserStatusActive,
+			}},
+		},
+		managementSigner,
+		defaultRateService,
+		rateHistoryService,
+		&StubAlertNotifier{},
+	)
+
+	webhookService := webhook.NewService(
+		config.Webhook,
+		port.NewWebhookRepository(
+			storage.NewCSVWebhookStorage(filepath.Join(t.TempDir(), "webhooks.csv")),
+		),
+		port.NewWebhookDeliveryRepository(
+			storage.NewCSVWebhookDeliveryStorage(
+				filepath.Join(t.TempDir(), "webhook_deliveries.csv"),
+			),
+		),
+		&StubUserRepository{
+			Users: []port.User{{
+				Email:  "test@test.com",
+				Status: port.UserStatusActive,
+			}},
+		},
+		managementSigner,
 	)
 
-	defaultSubscriptionService := subscription.NewService(
+	jobTracker := newJobTracker(t)
+
+	outboxService := outbox.NewService(
+		config.Outbox,
+		&StubLogger{},
+		port.NewOutboxRepository(
+			storage.NewCSVOutboxStorage(filepath.Join(t.TempDir(), "outbox.csv")),
+		),
 		&StubUserRepository{},
+		&StubSenderProvider{},
+		jobTracker,
+	)
+
+	jobService := job.NewService(
+		config.Job,
+		&StubLogger{},
+		jobTracker,
+		defaultRateService,
+		defaultSubscriptionService,
+		outboxService,
+		sender.NewService(),
 	)
 
 	tests := []struct {
@@ -116,8 +282,8 @@ func TestAppControllerIntegration(t *testing.T) {
 		requestMethod       string
 		requestURL          string
 		requestBody         io.Reader
+		adminToken          string
 		expectedStatus      int
-		senderService       *sender.Service
 		subscriptionService *subscription.Service
 		rateService         *rate.Service
 	}{
@@ -128,7 +294,24 @@ func TestAppControllerIntegration(t *testing.T) {
 			requestBody:         nil,
 			expectedStatus:      http.StatusOK,
 			subscriptionService: defaultSubscriptionService,
-			senderService:       defaultEmailSenderService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "GetRateHistory OK",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/rate/history?interval=15m",
+			requestBody:         nil,
+			expectedStatus:      http.StatusOK,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "GetRateHistory BadRequest",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/rate/history?interval=0s",
+			requestBody:         nil,
+			expectedStatus:      http.StatusBadRequest,
+			subscriptionService: defaultSubscriptionService,
 			rateService:         defaultRateService,
 		},
 		{
@@ -137,7 +320,6 @@ func TestAppControllerIntegration(t *testing.T) {
 			requestURL:          "/api/subscribe",
 			requestBody:         bytes.NewBufferString("email=test@test.com"),
 			expectedStatus:      http.StatusOK,
-			senderService:       defaultEmailSenderService,
 			subscriptionService: defaultSubscriptionService,
 			rateService:         defaultRateService,
 		},
@@ -147,65 +329,215 @@ func TestAppControllerIntegration(t *testing.T) {
 			requestURL:     "/api/subscribe",
 			requestBody:    bytes.NewBufferString("email=test@test.com"),
 			expectedStatus: http.StatusConflict,
-			subscriptionService: subscription.NewService(
-				&StubUserRepository{Err: port.ErrAlreadyAdded},
+			subscriptionService: newSubscriptionService(
+				&StubUserRepository{
+					Users: []port.User{{
+						Email:  "test@test.com",
+						Status: port.UserStatusActive,
+					}},
+				},
 			),
-			senderService: defaultEmailSenderService,
-			rateService:   defaultRateService,
+			rateService: defaultRateService,
 		},
 		{
-			name:                "SendEmails OK",
+			name:                "SubscribeEmail UnprocessableEntity Malformed",
 			requestMethod:       http.MethodPost,
-			requestURL:          "/api/sendEmails",
-			requestBody:         nil,
-			expectedStatus:      http.StatusOK,
+			requestURL:          "/api/subscribe",
+			requestBody:         bytes.NewBufferString("email=test.test.com"),
+			expectedStatus:      http.StatusUnprocessableEntity,
 			subscriptionService: defaultSubscriptionService,
-			senderService:       defaultEmailSenderService,
 			rateService:         defaultRateService,
 		},
 		{
-			name:                "SendEmails BadRequest Rate Provider Unavailable",
+			name:                "SubscribeEmail UnprocessableEntity Disposable",
+			requestMethod:       http.MethodPost,
+			requestURL:          "/api/subscribe",
+			requestBody:         bytes.NewBufferString("email=test@mailinator.com"),
+			expectedStatus:      http.StatusUnprocessableEntity,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "SendEmails Accepted",
 			requestMethod:       http.MethodPost,
 			requestURL:          "/api/sendEmails",
 			requestBody:         nil,
-			expectedStatus:      http.StatusBadRequest,
+			expectedStatus:      http.StatusAccepted,
 			subscriptionService: defaultSubscriptionService,
-			senderService:       defaultEmailSenderService,
-			rateService: rate.NewService(
-				&StubLogger{},
-				&StubRateProvider{
-					Error: errRateProviderAnavailable,
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "Job NotFound",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/jobs/missing",
+			requestBody:         nil,
+			expectedStatus:      http.StatusNotFound,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:           "ConfirmSubscription OK",
+			requestMethod:  http.MethodGet,
+			requestURL:     "/api/subscribe/confirm?token=token",
+			requestBody:    nil,
+			expectedStatus: http.StatusOK,
+			subscriptionService: newSubscriptionService(
+				&StubUserRepository{
+					Users: []port.User{{
+						Email:                 "test@test.com",
+						Status:                port.UserStatusPending,
+						ConfirmationToken:     "token",
+						ConfirmationExpiresAt: time.Now().Add(time.Hour),
+					}},
 				},
 			),
+			rateService: defaultRateService,
 		},
 		{
-			name:           "SendEmails InternalServerError Subscribtions Error",
-			requestMethod:  http.MethodPost,
-			requestURL:     "/api/sendEmails",
-			requestBody:    nil,
-			expectedStatus: http.StatusInternalServerError,
-			subscriptionService: subscription.NewService(
-				&StubUserRepository{Err: port.ErrCannotLoadUsers},
+			name:                "ConfirmSubscription NotFound Unknown Token",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/subscribe/confirm?token=unknown",
+			requestBody:         nil,
+			expectedStatus:      http.StatusNotFound,
+			subscriptionService: newSubscriptionService(&StubUserRepository{}),
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "UnsubscribeEmail OK",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/unsubscribe?email=test@test.com&token=" + signer.Sign("test@test.com"),
+			requestBody:         nil,
+			expectedStatus:      http.StatusOK,
+			subscriptionService: newSubscriptionService(&StubUserRepository{}),
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "UnsubscribeEmail Forbidden Invalid Token",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/unsubscribe?email=test@test.com&token=" + signer.Sign("other@test.com"),
+			requestBody:         nil,
+			expectedStatus:      http.StatusForbidden,
+			subscriptionService: newSubscriptionService(&StubUserRepository{}),
+			rateService:         defaultRateService,
+		},
+		{
+			name:          "Alerts Created",
+			requestMethod: http.MethodPost,
+			requestURL:    "/api/alerts",
+			requestBody: bytes.NewBufferString(
+				"email=test@test.com&kind=threshold&value=1000000&token=" +
+					managementSigner.Sign("test@test.com"),
 			),
-			senderService: defaultEmailSenderService,
-			rateService:   defaultRateService,
+			expectedStatus:      http.StatusCreated,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:          "Alerts Forbidden Invalid Token",
+			requestMethod: http.MethodPost,
+			requestURL:    "/api/alerts",
+			requestBody: bytes.NewBufferString(
+				"email=test@test.com&kind=threshold&value=1000000&token=" +
+					managementSigner.Sign("other@test.com"),
+			),
+			expectedStatus:      http.StatusForbidden,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:          "Alerts Forbidden Unsubscribe Token",
+			requestMethod: http.MethodPost,
+			requestURL:    "/api/alerts",
+			requestBody: bytes.NewBufferString(
+				"email=test@test.com&kind=threshold&value=1000000&token=" +
+					signer.Sign("test@test.com"),
+			),
+			expectedStatus:      http.StatusForbidden,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:          "Webhooks Created",
+			requestMethod: http.MethodPost,
+			requestURL:    "/api/webhooks",
+			// An address isn't looked up, so the test runs offline
+			requestBody: bytes.NewBufferString(
+				"email=test@test.com&url=https://93.184.216.34/rate&token=" +
+					managementSigner.Sign("test@test.com"),
+			),
+			expectedStatus:      http.StatusCreated,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
 		},
 		{
-			name:                "SendEmails InternalServerError Send Error",
+			name:                "Webhooks Forbidden Without Token",
 			requestMethod:       http.MethodPost,
-			requestURL:          "/api/sendEmails",
-			requestBody:         nil,
-			expectedStatus:      http.StatusInternalServerError,
+			requestURL:          "/api/webhooks",
+			requestBody:         bytes.NewBufferString("url=https://93.184.216.34/rate"),
+			expectedStatus:      http.StatusForbidden,
 			subscriptionService: defaultSubscriptionService,
-			senderService: initEmailSenderService(
-				t,
-				config,
-				&smtp.StubDialer{},
-				&smtp.StubSMTPClientFactory{
-					Client: &smtp.StubSMTPClient{MailErr: errSendMessage},
-				},
+			rateService:         defaultRateService,
+		},
+		{
+			name:          "Webhooks BadRequest Loopback URL",
+			requestMethod: http.MethodPost,
+			requestURL:    "/api/webhooks",
+			requestBody: bytes.NewBufferString(
+				"email=test@test.com&url=http://127.0.0.1:8080/api/admin/outbox&token=" +
+					managementSigner.Sign("test@test.com"),
 			),
-			rateService: defaultRateService,
+			expectedStatus:      http.StatusBadRequest,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:          "WebhookDeliveries Forbidden Invalid Token",
+			requestMethod: http.MethodGet,
+			requestURL: "/api/webhooks/deliveries?email=test@test.com&id=id&token=" +
+				managementSigner.Sign("other@test.com"),
+			requestBody:         nil,
+			expectedStatus:      http.StatusForbidden,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "AdminWebhooks OK",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/admin/webhooks",
+			requestBody:         nil,
+			adminToken:          config.HTTP.AdminToken,
+			expectedStatus:      http.StatusOK,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "AdminOutbox OK",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/admin/outbox",
+			requestBody:         nil,
+			adminToken:          config.HTTP.AdminToken,
+			expectedStatus:      http.StatusOK,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "AdminOutbox Unauthorized Invalid Token",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/admin/outbox",
+			requestBody:         nil,
+			adminToken:          "other",
+			expectedStatus:      http.StatusUnauthorized,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
+		},
+		{
+			name:                "Alerts OK Empty List",
+			requestMethod:       http.MethodGet,
+			requestURL:          "/api/alerts?email=other@test.com&token=" + managementSigner.Sign("other@test.com"),
+			requestBody:         nil,
+			expectedStatus:      http.StatusOK,
+			subscriptionService: defaultSubscriptionService,
+			rateService:         defaultRateService,
 		},
 	}
 
@@ -221,16 +553,24 @@ func TestAppControllerIntegration(t *testing.T) {
 			appController := httpcontroller.NewAppController(
 				tt.rateService,
 				tt.subscriptionService,
-				tt.senderService,
+				jobService,
+				rateHistoryService,
+				alertService,
+				webhookService,
+				outboxService,
 			)
 
 			if tt.requestMethod == http.MethodPost {
 				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 			}
 
+			if tt.adminToken != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.adminToken)
+			}
+
 			rr := httptest.NewRecorder()
 
-			router := router.NewHTTPRouter(appController)
+			router := router.NewHTTPRouter(appController, config.HTTP.AdminToken)
 			mux := http.NewServeMux()
 			router.RegisterRoutes(mux)
 
@@ -243,6 +583,168 @@ func TestAppControllerIntegration(t *testing.T) {
 	}
 }
 
+func TestSendEmailsJobIntegration(t *testing.T) {
+	config := initConfig(t)
+
+	signer := signature.NewHMACSigner(config.Signature)
+	managementSigner := signer.WithPurpose("manage")
+
+	defaultRateService := rate.NewService(
+		config.Rate,
+		&StubLogger{},
+		&StubRateProvider{
+			Rate: port.Rate{Pair: port.DefaultCurrencyPair, Value: 42},
+		},
+	)
+
+	newSubscriptionService := func(
+		userRepository *StubUserRepository,
+	) *subscription.Service {
+		return subscription.NewService(
+			config.Subscription,
+			userRepository,
+			signer,
+			managementSigner,
+			&StubConfirmationSender{},
+			blocklist.NewDomainBlocklist(),
+		)
+	}
+
+	subscribers := &StubUserRepository{
+		Users: []port.User{{
+			Email:  "test@test.com",
+			Status: port.UserStatusActive,
+		}},
+	}
+
+	defaultEmailSender := initEmailSender(
+		t,
+		config,
+		&smtp.StubDialer{},
+		&smtp.StubSMTPClientFactory{Client: &smtp.StubSMTPClient{}},
+	)
+
+	tests := []struct {
+		name                string
+		subscriptionService *subscription.Service
+		rateService         *rate.Service
+		emailSender         job.EmailSender
+		expectedJob         jobResponse
+	}{
+		{
+			name:                "Completed",
+			subscriptionService: newSubscriptionService(subscribers),
+			rateService:         defaultRateService,
+			emailSender:         defaultEmailSender,
+			expectedJob: jobResponse{
+				Status: string(port.JobStatusCompleted),
+				Total:  1,
+				Sent:   1,
+			},
+		},
+		{
+			name:                "Failed Rate Provider Unavailable",
+			subscriptionService: newSubscriptionService(subscribers),
+			rateService: rate.NewService(
+				config.Rate,
+				&StubLogger{},
+				&StubRateProvider{
+					Error: errRateProviderAnavailable,
+				},
+			),
+			emailSender: defaultEmailSender,
+			expectedJob: jobResponse{
+				Status: string(port.JobStatusFailed),
+			},
+		},
+		{
+			name: "Failed Subscriptions Error",
+			subscriptionService: newSubscriptionService(
+				&StubUserRepository{Err: port.ErrCannotLoadUsers},
+			),
+			rateService: defaultRateService,
+			emailSender: defaultEmailSender,
+			expectedJob: jobResponse{
+				Status: string(port.JobStatusFailed),
+			},
+		},
+		{
+			name:                "Completed Send Error",
+			subscriptionService: newSubscriptionService(subscribers),
+			rateService:         defaultRateService,
+			emailSender: initEmailSender(
+				t,
+				config,
+				&smtp.StubDialer{},
+				&smtp.StubSMTPClientFactory{
+					Client: &smtp.StubSMTPClient{MailErr: errSendMessage},
+				},
+			),
+			expectedJob: jobResponse{
+				Status: string(port.JobStatusCompleted),
+				Total:  1,
+				Failed: 1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			jobTracker := newJobTracker(t)
+			jobService := job.NewService(
+				config.Job,
+				&StubLogger{},
+				jobTracker,
+				tt.rateService,
+				tt.subscriptionService,
+				job.NewDirectEmails(tt.emailSender, jobTracker),
+				sender.NewService(),
+			)
+
+			appController := httpcontroller.NewAppController(
+				tt.rateService,
+				tt.subscriptionService,
+				jobService,
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+
+			mux := http.NewServeMux()
+			router.NewHTTPRouter(appController, "").RegisterRoutes(mux)
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/sendEmails", nil)
+			mux.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusAccepted, rr.Code)
+
+			var queued jobResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&queued))
+			require.Equal(t, string(port.JobStatusQueued), queued.Status)
+
+			location := rr.Header().Get("Location")
+			require.Equal(t, "/api/jobs/"+queued.ID, location)
+
+			require.NoError(t, jobService.Process())
+
+			rr = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodGet, location, nil)
+			mux.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var finished jobResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&finished))
+
+			tt.expectedJob.ID = queued.ID
+			require.Equal(t, tt.expectedJob, finished)
+		})
+	}
+}
+
 func initConfig(t *testing.T) *config.Config {
 	envVariables := map[string]string{
 		"GSES2_APP_SMTP_HOST":             "test.server.com",
@@ -255,8 +757,10 @@ func initConfig(t *testing.T) *config.Config {
 		"GSES2_APP_STORAGE_PATH":          "./storage/storage.csv",
 		"GSES2_APP_HTTP_PORT":             "8080",
 		"GSES2_APP_HTTP_TIMEOUT":          "10s",
+		"GSES2_APP_HTTP_ADMINTOKEN":       "testadmintoken",
 		"GSES2_APP_KUNA_API_URL":          "https://www.example.com",
 		"GSES2_APP_KUNA_API_DEFAULT_RATE": "0",
+		"GSES2_APP_SIGNATURE_SECRET":      "testsecret",
 	}
 
 	for key, value := range envVariables {
@@ -271,12 +775,29 @@ func initConfig(t *testing.T) *config.Config {
 	return &config
 }
 
-func initEmailSenderService(
+func newJobTracker(t *testing.T) *job.Tracker {
+	return job.NewTracker(
+		port.NewJobRepository(
+			storage.NewCSVJobStorage(filepath.Join(t.TempDir(), "jobs.csv")),
+		),
+	)
+}
+
+func initEmailSender(
 	t *testing.T,
 	config *config.Config,
-	dialer smtp.TLSConnectionDialer,
+	dialer smtp.ConnectionDialer,
 	factory smtp.SMTPClientFactory,
-) *sender.Service {
+) *email.Provider {
+	templates, err := send.NewTemplates(
+		config.Email,
+		&StubLogger{},
+		os.DirFS(_templatesDir),
+	)
+	if err != nil {
+		t.Fatalf("error loading email templates: %v", err)
+	}
+
 	provider, err := email.NewProvider(
 		&email.EmailSenderConfig{
 			SMTP:  config.SMTP,
@@ -284,11 +805,14 @@ func initEmailSenderService(
 		},
 		dialer,
 		factory,
+		signature.NewHMACSigner(config.Signature),
+		templates,
+		nil,
 	)
 
 	if err != nil {
 		t.Fatalf("error creating email sender provider: %v", err)
 	}
 
-	return sender.NewService(provider)
+	return provider
 }
@@ -8,11 +8,14 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
 	"gses2-app/internal/core/port"
 	"gses2-app/internal/core/service/subscription"
+	"gses2-app/internal/repository/blocklist"
+	"gses2-app/internal/repository/signature"
 	"gses2-app/internal/repository/storage"
 )
 
@@ -24,6 +27,16 @@ type SubscriptionTest struct {
 	ExpectedResult []port.User
 }
 
+// TokenRecordingSender keeps the last confirmation token sent to every email
+type TokenRecordingSender struct {
+	tokens map[string]string
+}
+
+func (s *TokenRecordingSender) SendConfirmation(user port.User) error {
+	s.tokens[user.Email] = user.ConfirmationToken
+	return nil
+}
+
 func TestSubscriptionServiceIntegration(t *testing.T) {
 	tmpFile, err := os.CreateTemp("", "example")
 	if err != nil {
@@ -33,7 +46,39 @@ func TestSubscriptionServiceIntegration(t *testing.T) {
 
 	storageCSV := storage.NewCSVStorage(tmpFile.Name())
 	userRepository := port.NewUserRepository(storageCSV)
-	service := subscription.NewService(userRepository)
+	signer := signature.NewHMACSigner(signature.SignatureConfig{Secret: "secret"})
+	confirmationSender := &TokenRecordingSender{tokens: map[string]string{}}
+	service := subscription.NewService(
+		subscription.SubscriptionConfig{ConfirmationTTL: time.Hour},
+		userRepository,
+		signer,
+		signer.WithPurpose("manage"),
+		confirmationSender,
+		blocklist.NewDomainBlocklist(),
+	)
+
+	subscribeAndConfirm := func(
+		service *subscription.Service,
+		subscribers []port.User,
+	) error {
+		for _, subscriber := range subscribers {
+			subscriber := subscriber
+			err := service.Subscribe(&subscriber)
+			if errors.Is(err, subscription.ErrAlreadySubscribed) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			_, err = service.Confirm(confirmationSender.tokens[subscriber.Email])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
 	tests := []SubscriptionTest{
 		{
@@ -42,23 +87,43 @@ func TestSubscriptionServiceIntegration(t *testing.T) {
 			Action: func(service *subscription.Service, subscribers []port.User) error {
 				return service.Subscribe(&subscribers[0])
 			},
+			ExpectedResult: []port.User{},
 		},
 		{
-			Name:        "Subscribe an already subscribed email",
+			Name:        "Subscribe an email waiting for confirmation",
 			Subscribers: []port.User{{Email: "test1@example.com"}},
 			Action: func(service *subscription.Service, subscribers []port.User) error {
 				return service.Subscribe(&subscribers[0])
 			},
-			ExpectedError: subscription.ErrAlreadySubscribed,
+			ExpectedError: subscription.ErrConfirmationPending,
 		},
 		{
-			Name:        "Get all subscriptions",
-			Subscribers: []port.User{},
+			Name:        "Confirm the subscription",
+			Subscribers: []port.User{{Email: "test1@example.com"}},
 			Action: func(service *subscription.Service, subscribers []port.User) error {
-				_, err := service.Subscriptions()
+				_, err := service.Confirm(confirmationSender.tokens[subscribers[0].Email])
 				return err
 			},
-			ExpectedResult: []port.User{{Email: "test1@example.com"}},
+			ExpectedResult: []port.User{
+				{Email: "test1@example.com", Status: port.UserStatusActive},
+			},
+		},
+		{
+			Name:        "Confirm with the used token",
+			Subscribers: []port.User{{Email: "test1@example.com"}},
+			Action: func(service *subscription.Service, subscribers []port.User) error {
+				_, err := service.Confirm(confirmationSender.tokens[subscribers[0].Email])
+				return err
+			},
+			ExpectedError: subscription.ErrInvalidConfirmationToken,
+		},
+		{
+			Name:        "Subscribe an already subscribed email",
+			Subscribers: []port.User{{Email: "test1@example.com"}},
+			Action: func(service *subscription.Service, subscribers []port.User) error {
+				return service.Subscribe(&subscribers[0])
+			},
+			ExpectedError: subscription.ErrAlreadySubscribed,
 		},
 		{
 			Name: "Subscribe multiple emails",
@@ -66,18 +131,11 @@ func TestSubscriptionServiceIntegration(t *testing.T) {
 				{Email: "test2@example.com"},
 				{Email: "test3@example.com"},
 			},
-			Action: func(service *subscription.Service, subscribers []port.User) error {
-				for _, subscriber := range subscribers {
-					if err := service.Subscribe(&subscriber); err != nil {
-						return err
-					}
-				}
-				return nil
-			},
+			Action: subscribeAndConfirm,
 			ExpectedResult: []port.User{
-				{Email: "test1@example.com"},
-				{Email: "test2@example.com"},
-				{Email: "test3@example.com"},
+				{Email: "test1@example.com", Status: port.UserStatusActive},
+				{Email: "test2@example.com", Status: port.UserStatusActive},
+				{Email: "test3@example.com", Status: port.UserStatusActive},
 			},
 		},
 		{
@@ -86,21 +144,35 @@ func TestSubscriptionServiceIntegration(t *testing.T) {
 				{Email: "test4@example.com"},
 				{Email: "test1@example.com"},
 			},
+			Action: subscribeAndConfirm,
+			ExpectedResult: []port.User{
+				{Email: "test1@example.com", Status: port.UserStatusActive},
+				{Email: "test2@example.com", Status: port.UserStatusActive},
+				{Email: "test3@example.com", Status: port.UserStatusActive},
+				{Email: "test4@example.com", Status: port.UserStatusActive},
+			},
+		},
+		{
+			Name:        "Unsubscribe with a signed token",
+			Subscribers: []port.User{{Email: "test2@example.com"}},
 			Action: func(service *subscription.Service, subscribers []port.User) error {
-				for _, subscriber := range subscribers {
-					err := service.Subscribe(&subscriber)
-					if err != nil && !errors.Is(err, subscription.ErrAlreadySubscribed) {
-						return err
-					}
-				}
-				return nil
+				token := signer.Sign(subscribers[0].Email)
+				return service.Unsubscribe(&subscribers[0], token)
 			},
 			ExpectedResult: []port.User{
-				{Email: "test1@example.com"},
-				{Email: "test2@example.com"},
-				{Email: "test3@example.com"},
-				{Email: "test4@example.com"},
+				{Email: "test1@example.com", Status: port.UserStatusActive},
+				{Email: "test3@example.com", Status: port.UserStatusActive},
+				{Email: "test4@example.com", Status: port.UserStatusActive},
+			},
+		},
+		{
+			Name:        "Unsubscribe an already unsubscribed email",
+			Subscribers: []port.User{{Email: "test2@example.com"}},
+			Action: func(service *subscription.Service, subscribers []port.User) error {
+				token := signer.Sign(subscribers[0].Email)
+				return service.Unsubscribe(&subscribers[0], token)
 			},
+			ExpectedError: subscription.ErrNotSubscribed,
 		},
 	}
 